@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/georgewheatcroft/simple-pass/internal/db"
 	"github.com/georgewheatcroft/simple-pass/internal/item"
@@ -20,6 +21,10 @@ const (
 	NotesShortFlag    = "n"
 	URLFlag           = "url"
 	URLShortFlag      = "w"
+	FieldFlag         = "field"
+	SecretFieldFlag   = "secret-field"
+	MatchURLFlag      = "match-url"
+	TagFlag           = "tag"
 
 	SuccessfullyAddedMessage = "successfully added %s to the passDB\n"
 )
@@ -30,13 +35,24 @@ func NewAddCmd(passDB *db.PassDB) *cobra.Command {
 		itemPassword string
 		itemNotes    []string
 		itemURL      string
+		itemFields   []string
+		itemSecrets  []string
+		otpURI       string
+		otpSecret    string
+		matchURLs    []string
+		tags         []string
+		generatePass bool
+		generator    generatorFlags
+		profile      string
+		force        bool
 	)
 
 	cmd := &cobra.Command{
 		Use:   AddCmdName,
 		Short: "add new item to your simple-pass",
 		Long: `e.g. 
-			   simple-pass add <new-item-name> --password <some password>`,
+			   simple-pass add <new-item-name> --password <some password>
+			   simple-pass add-typed credit-card <new-item-name> --number <card number> --expiry <MM/YY>`,
 		PreRunE: passDBCacheExistsOrErr,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			log.Debugf("add called with %v", args)
@@ -51,23 +67,114 @@ func NewAddCmd(passDB *db.PassDB) *cobra.Command {
 
 			itemName := args[0]
 
-			newItem, err := item.NewItem(itemName, itemUsername, itemPassword, itemURL, itemNotes)
+			fields, err := parseFieldFlags(itemFields, itemSecrets)
 			if err != nil {
 				return fmt.Errorf("cannot add new item to passDB: %s\n", err)
 			}
 
+			otpKey, err := parseOTPFlags(otpURI, otpSecret)
+			if err != nil {
+				return fmt.Errorf("cannot add new item to passDB: %s\n", err)
+			}
+			// the account named in an otpauth uri is almost always the username
+			if otpKey != nil && itemUsername == "" {
+				itemUsername = otpKey.Account
+			}
+
+			if profile != "" {
+				_, err = resolvePasswordProfile(profile)
+				if err != nil {
+					return fmt.Errorf("cannot add new item to passDB: %s\n", err)
+				}
+			}
+			if generatePass {
+				itemPassword, err = generator.password(profile)
+				if err != nil {
+					return fmt.Errorf("cannot add new item to passDB: %s\n", err)
+				}
+			}
+
+			newItem, err := item.NewItem(itemName, itemUsername, itemPassword, itemURL, itemNotes, fields...)
+			if err != nil {
+				return fmt.Errorf("cannot add new item to passDB: %s\n", err)
+			}
+
+			newItem.OTP = otpKey
+			newItem.PasswordProfile = profile
+			newItem.URLs, err = parseMatchURLFlags(matchURLs)
+			if err != nil {
+				return fmt.Errorf("cannot add new item to passDB: %s\n", err)
+			}
+			for _, tag := range tags {
+				err = newItem.AddTag(tag)
+				if err != nil {
+					return fmt.Errorf("cannot add new item to passDB: %s\n", err)
+				}
+			}
+
+			if newItem.Password != "" {
+				err = reportPasswordStrength(passDB, newItem, force)
+				if err != nil {
+					return fmt.Errorf("cannot add new item to passDB: %s\n", err)
+				}
+			}
+
 			err = passDB.SaveNewItem(newItem)
 			if err != nil {
 				return fmt.Errorf("cannot add new item to passDB: %s\n", err)
 			}
-			log.Infof(SuccessfullyAddedMessage, itemName)
-			return nil
+			return writeOutput(cmd, ChangeOutput{Action: "added", Target: itemName, message: fmt.Sprintf(SuccessfullyAddedMessage, itemName)})
 		},
 	}
 	cmd.Flags().StringVarP(&itemUsername, UsernameFlag, UsernameShortFlag, "", "username for the item")
 	cmd.Flags().StringVarP(&itemPassword, PasswordFlag, PasswordShortFlag, "", "password for the item")
 	cmd.Flags().StringArrayVarP(&itemNotes, NotesFlag, NotesShortFlag, nil, "notes for the item")
 	cmd.Flags().StringVarP(&itemURL, URLFlag, URLShortFlag, "", "url for the item")
+	cmd.Flags().StringArrayVar(&itemFields, FieldFlag, nil, "custom field for the item, given as name=value")
+	cmd.Flags().StringArrayVar(&itemSecrets, SecretFieldFlag, nil, "concealed custom field for the item, given as name=value")
+	cmd.Flags().StringVar(&otpURI, OTPURIFlag, "", "otpauth:// uri holding the item's one time password secret")
+	cmd.Flags().StringVar(&otpSecret, OTPSecretFlag, "", "base32 secret for the item's time based one time passwords")
+	cmd.Flags().StringArrayVar(&matchURLs, MatchURLFlag, nil, matchURLUsage)
+	cmd.Flags().StringArrayVar(&tags, TagFlag, nil, "tag for the item")
+	cmd.Flags().BoolVar(&generatePass, GenerateFlag, false, "generate the item's password (see the generate command for options)")
+	generator.register(cmd.Flags())
+	cmd.MarkFlagsMutuallyExclusive(PasswordFlag, GenerateFlag)
+	cmd.Flags().StringVar(&profile, PasswordProfileFlag, "", fmt.Sprintf("password profile whose rules generated passwords for the item must follow (see: simple-pass %s)", PasswordProfilesCmdName))
+	cmd.Flags().BoolVar(&force, ForceFlag, false, "set the password even if it is weaker than the passdb password policy allows")
 
 	return cmd
 }
+
+// parseFieldFlags converts name=value pairs given to the field flags into item fields
+func parseFieldFlags(plain, concealed []string) ([]item.Field, error) {
+	fields := []item.Field{}
+	for _, pairs := range []struct {
+		values    []string
+		concealed bool
+	}{{plain, false}, {concealed, true}} {
+		for _, pair := range pairs.values {
+			name, value, found := strings.Cut(pair, "=")
+			if !found || name == "" {
+				return nil, fmt.Errorf("field '%s' must be given as name=value", pair)
+			}
+			fields = append(fields, item.Field{Name: name, Value: value, Concealed: pairs.concealed})
+		}
+	}
+	return fields, nil
+}
+
+// matchURLUsage describes the format of values given to the match url flag
+var matchURLUsage = fmt.Sprintf("additional url for the item, given as [<match-mode>=]<url> where match-mode is one of %v (default %s)", item.MatchModes(), item.DefaultMatchMode)
+
+// parseMatchURLFlags converts the values given to the match url flag into item urls
+func parseMatchURLFlags(values []string) ([]item.ItemURL, error) {
+	var urls []item.ItemURL
+	for _, value := range values {
+		itemURL, err := item.ParseItemURL(value)
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, itemURL)
+	}
+	return urls, nil
+}
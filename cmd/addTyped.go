@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/georgewheatcroft/simple-pass/internal/db"
+	"github.com/georgewheatcroft/simple-pass/internal/item"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	AddTypedCmdName = "add-typed"
+
+	NotesFileFlag  = "notes-file"
+	fileFlagSuffix = "-file"
+)
+
+// NewAddTypedCmd returns a command with a subcommand for each item type with a schema beyond that of a plain login,
+// with flags generated from the fields of that schema. it is kept apart from the add command so that items can still
+// be added with the same name as a type
+func NewAddTypedCmd(passDB *db.PassDB) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   AddTypedCmdName,
+		Short: "add new item of a given type to your simple-pass",
+		Long: fmt.Sprintf(`e.g.
+			   simple-pass %s %s <new-item-name> --number <card number> --expiry <MM/YY>`, AddTypedCmdName, item.TypeCreditCard),
+	}
+	for _, schema := range item.Schemas() {
+		if schema.Type == item.TypeLogin {
+			continue
+		}
+		cmd.AddCommand(newAddTypedCmd(passDB, schema))
+	}
+	return cmd
+}
+
+func newAddTypedCmd(passDB *db.PassDB, schema *item.Schema) *cobra.Command {
+	itemType := schema.Type
+	var (
+		itemNotes   []string
+		notesFile   string
+		itemFields  []string
+		itemSecrets []string
+	)
+	schemaValues := map[string]*string{}
+	schemaFiles := map[string]*string{}
+
+	cmd := &cobra.Command{
+		Use:   string(itemType),
+		Short: fmt.Sprintf("add new %s item - %s", itemType, schema.Description),
+		Long: fmt.Sprintf(`e.g.
+			   simple-pass %s %s <new-item-name> [flags]`, AddTypedCmdName, itemType),
+		Args:    cobra.ExactArgs(1),
+		PreRunE: passDBCacheExistsOrErr,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log.Debugf("%s %s called with %v", AddTypedCmdName, itemType, args)
+			itemName := args[0]
+
+			fields, err := parseFieldFlags(itemFields, itemSecrets)
+			if err != nil {
+				return fmt.Errorf("cannot add new item to passDB: %s\n", err)
+			}
+			for _, spec := range schema.Fields {
+				value := *schemaValues[spec.Name]
+				if path, isFile := schemaFiles[spec.Name]; isFile && *path != "" {
+					/* #nosec */
+					contents, err := os.ReadFile(*path)
+					if err != nil {
+						return fmt.Errorf("cannot read %s for item: %s\n", spec.Name, err)
+					}
+					value = string(contents)
+				}
+				if value != "" {
+					fields = append(fields, item.Field{Name: spec.Name, Value: value})
+				}
+			}
+			if notesFile != "" {
+				/* #nosec */
+				contents, err := os.ReadFile(notesFile)
+				if err != nil {
+					return fmt.Errorf("cannot read notes for item: %s\n", err)
+				}
+				itemNotes = append(itemNotes, string(contents))
+			}
+
+			newItem, err := item.NewTypedItem(itemType, itemName, itemNotes, fields...)
+			if err != nil {
+				return fmt.Errorf("cannot add new item to passDB: %s\n", err)
+			}
+			err = passDB.SaveNewItem(newItem)
+			if err != nil {
+				return fmt.Errorf("cannot add new item to passDB: %s\n", err)
+			}
+			return writeOutput(cmd, ChangeOutput{
+				Action:  "added",
+				Target:  itemName,
+				Details: map[string]string{"type": string(itemType)},
+				message: fmt.Sprintf(SuccessfullyAddedMessage, itemName),
+			})
+		},
+	}
+
+	for _, spec := range schema.Fields {
+		usage := spec.Description
+		if spec.Required {
+			usage += " (Required)"
+		}
+		schemaValues[spec.Name] = cmd.Flags().String(spec.Name, "", usage)
+		if spec.Multiline {
+			schemaFiles[spec.Name] = cmd.Flags().String(spec.Name+fileFlagSuffix, "", "path to a file containing the "+spec.Description)
+			cmd.MarkFlagsMutuallyExclusive(spec.Name, spec.Name+fileFlagSuffix)
+		}
+	}
+	cmd.Flags().StringArrayVarP(&itemNotes, NotesFlag, NotesShortFlag, nil, "notes for the item")
+	cmd.Flags().StringVar(&notesFile, NotesFileFlag, "", "path to a file containing notes for the item")
+	cmd.Flags().StringArrayVar(&itemFields, FieldFlag, nil, "custom field for the item, given as name=value")
+	cmd.Flags().StringArrayVar(&itemSecrets, SecretFieldFlag, nil, "concealed custom field for the item, given as name=value")
+	return cmd
+}
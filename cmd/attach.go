@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/georgewheatcroft/simple-pass/internal/db"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	AttachCmdName   = "attach"
+	AttachNameFlag  = "name"
+	AttachNameShort = "n"
+
+	SuccessfullyAttachedMessage = "successfully attached '%s' to item '%s'"
+)
+
+func NewAttachCmd(passDB *db.PassDB) *cobra.Command {
+	var (
+		name string
+	)
+
+	cmd := &cobra.Command{
+		Use:   AttachCmdName,
+		Short: "attach a file to an item in your simple-pass",
+		Long: fmt.Sprintf(`e.g.
+			simple-pass %s <existing-item-name> <path-to-file>
+			simple-pass %s <existing-item-name> <path-to-file> --name <attachment-name>
+
+			attachments are encrypted and can be at most %d bytes`, AttachCmdName, AttachCmdName, db.MaxAttachmentSize),
+		PreRunE:           passDBCacheExistsOrErr,
+		ValidArgsFunction: completeItemNameThen(passDB, completeFiles),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log.Debugf("%s called with %v", AttachCmdName, args)
+			if len(args) != 2 {
+				err := cmd.Help()
+				if err != nil {
+					return fmt.Errorf("attempting to show help prompt caused error: %s\n", err)
+				}
+				return fmt.Errorf("expected an item name and a file path")
+			}
+			itemName, path := args[0], args[1]
+			if name == "" {
+				name = filepath.Base(path)
+			}
+
+			info, err := os.Stat(path)
+			if err != nil {
+				return fmt.Errorf("cannot attach file: %s\n", err)
+			}
+			// check before reading so that huge files are not read into memory only to be refused
+			if info.Size() > db.MaxAttachmentSize {
+				return fmt.Errorf("cannot attach file: %s\n", db.ErrAttachmentTooLarge)
+			}
+			/* #nosec */
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("cannot attach file: %s\n", err)
+			}
+
+			err = passDB.AddAttachment(itemName, name, data)
+			if err != nil {
+				return fmt.Errorf("cannot attach file: %s\n", err)
+			}
+			return writeOutput(cmd, ChangeOutput{
+				Action:  "attached",
+				Target:  itemName,
+				Details: map[string]string{"attachment": name},
+				message: fmt.Sprintf(SuccessfullyAttachedMessage, name, itemName),
+			})
+		},
+	}
+	cmd.Flags().StringVarP(&name, AttachNameFlag, AttachNameShort, "", "name for the attachment (defaults to the file name)")
+	return cmd
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/georgewheatcroft/simple-pass/internal/db"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	AttachmentsCmdName = "attachments"
+)
+
+// AttachmentSummary describes a file attached to an item
+type AttachmentSummary struct {
+	Name   string
+	Size   int64
+	Added  time.Time
+	SHA256 string
+}
+
+// AttachmentsOutput is the result of listing the files attached to an item
+type AttachmentsOutput []AttachmentSummary
+
+func (o AttachmentsOutput) columns() []string {
+	return []string{"name", "size", "added", "sha256"}
+}
+
+func (o AttachmentsOutput) rows() [][]string {
+	rows := make([][]string, 0, len(o))
+	for _, attachment := range o {
+		rows = append(rows, []string{attachment.Name, strconv.FormatInt(attachment.Size, 10), formatOutputTime(attachment.Added), attachment.SHA256})
+	}
+	return rows
+}
+
+func NewAttachmentsCmd(passDB *db.PassDB) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   AttachmentsCmdName,
+		Short: "list the files attached to an item in your simple-pass",
+		Long: fmt.Sprintf(`e.g.
+			simple-pass %s <existing-item-name>`, AttachmentsCmdName),
+		PreRunE:           passDBCacheExistsOrErr,
+		ValidArgsFunction: completeItemName(passDB),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log.Debugf("%s called with %v", AttachmentsCmdName, args)
+			if len(args) != 1 {
+				err := cmd.Help()
+				if err != nil {
+					return fmt.Errorf("attempting to show help prompt caused error: %s\n", err)
+				}
+				return fmt.Errorf("expected exactly one item name")
+			}
+
+			passItem, err := passDB.RetrieveItem(args[0])
+			if err != nil {
+				return fmt.Errorf("cannot list attachments: %s\n", err)
+			}
+
+			attachments := make(AttachmentsOutput, 0, len(passItem.Attachments))
+			for _, attachment := range passItem.Attachments {
+				attachments = append(attachments, AttachmentSummary{
+					Name:   attachment.Name,
+					Size:   attachment.Size,
+					Added:  attachment.Added,
+					SHA256: attachment.SHA256,
+				})
+			}
+			return writeOutput(cmd, attachments)
+		},
+	}
+	return cmd
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/georgewheatcroft/simple-pass/internal/db"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	AuditCmdName = "audit"
+	JSONFlag     = "json"
+)
+
+// AuditOutput is the result of auditing the passdb
+type AuditOutput struct {
+	*db.AuditReport
+}
+
+func (o AuditOutput) columns() []string {
+	return []string{"name", "issue", "detail", "related_items"}
+}
+
+func (o AuditOutput) rows() [][]string {
+	rows := make([][]string, 0, len(o.Findings))
+	for _, finding := range o.Findings {
+		rows = append(rows, []string{finding.ItemName, string(finding.Issue), finding.Detail, strings.Join(finding.RelatedItems, ",")})
+	}
+	return rows
+}
+
+func (o AuditOutput) writeText(w io.Writer) error {
+	err := writeTable(w, o)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%d issue(s) found across %d item(s)\n", len(o.Findings), o.ItemsAudited)
+	return err
+}
+
+func NewAuditCmd(passDB *db.PassDB) *cobra.Command {
+	var (
+		asJSON bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   AuditCmdName,
+		Short: "report weak, reused and similar passwords, and incomplete logins, across your simple-pass",
+		Long: fmt.Sprintf(`e.g.
+			simple-pass %s
+			simple-pass %s --%s json
+
+			passwords are reported as weak when they score below the passdb password policy (see: simple-pass %s),
+			or below %d if there is no policy. password values are never included in the report`,
+			AuditCmdName, AuditCmdName, OutputFlag, PasswordPolicyCmdName, db.DefaultAuditMinScore),
+		Args:    cobra.NoArgs,
+		PreRunE: passDBCacheExistsOrErr,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log.Debugf("%s called", AuditCmdName)
+			report, err := passDB.Audit()
+			if err != nil {
+				return fmt.Errorf("cannot audit passdb: %s\n", err)
+			}
+
+			// --json predates --output, and is kept as a shorthand for it
+			if asJSON {
+				err = cmd.Flags().Set(OutputFlag, OutputJSON)
+				if err != nil {
+					return err
+				}
+			}
+			return writeOutput(cmd, AuditOutput{AuditReport: report})
+		},
+	}
+	cmd.Flags().BoolVar(&asJSON, JSONFlag, false, "output the report as json")
+	err := cmd.Flags().MarkDeprecated(JSONFlag, fmt.Sprintf("use --%s %s instead", OutputFlag, OutputJSON))
+	if err != nil {
+		panic(fmt.Sprintf("cannot setup cobra command:%s", err))
+	}
+	return cmd
+}
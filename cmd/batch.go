@@ -0,0 +1,380 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/georgewheatcroft/simple-pass/internal/db"
+	"github.com/georgewheatcroft/simple-pass/internal/item"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	BatchCmdName = "batch"
+
+	BatchOpAdd    = "add"
+	BatchOpUpdate = "update"
+	BatchOpRename = "rename"
+	BatchOpDelete = "delete"
+
+	// the status of each operation reported once the batch has run
+	BatchApplied    = "applied"
+	BatchUnchanged  = "unchanged"
+	BatchFailed     = "failed"
+	BatchRolledBack = "rolled back"
+	BatchNotApplied = "not applied"
+)
+
+var (
+	ErrBatchFailed          = errors.New("batch failed - nothing was applied")
+	ErrUnknownBatchOp       = fmt.Errorf("unknown operation - must be one of %s, %s, %s or %s", BatchOpAdd, BatchOpUpdate, BatchOpRename, BatchOpDelete)
+	ErrBatchOpWithoutName   = errors.New("operation has no name")
+	ErrBatchRenameWithoutTo = errors.New("rename has no name to rename to")
+)
+
+// batchOp is a single operation in a batch, read from one line of json. Fields left out of an update are unchanged
+type batchOp struct {
+	Op   string
+	Name string
+	// To is the name an item is renamed to
+	To           string
+	Type         item.Type
+	Username     *string
+	Password     *string
+	URL          *string
+	Notes        *[]string
+	Tags         *[]string
+	Fields       []batchField
+	RemoveFields []string
+}
+
+type batchField struct {
+	Name      string
+	Value     string
+	Concealed bool
+}
+
+// BatchOutput is the result of running a batch - the status of each of its operations
+type BatchOutput []BatchResult
+
+// BatchResult is the status of one operation in a batch, along with why it failed if it did
+type BatchResult struct {
+	Line   int
+	Op     string
+	Name   string
+	Status string
+	Error  string `json:",omitempty"`
+}
+
+func (o BatchOutput) columns() []string {
+	return []string{"line", "op", "name", "status", "error"}
+}
+
+func (o BatchOutput) rows() [][]string {
+	rows := make([][]string, 0, len(o))
+	for _, result := range o {
+		rows = append(rows, []string{strconv.Itoa(result.Line), result.Op, result.Name, result.Status, result.Error})
+	}
+	return rows
+}
+
+func NewBatchCmd(passDB *db.PassDB) *cobra.Command {
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   BatchCmdName,
+		Short: "add, update, rename and delete many items in your simple-pass at once",
+		Long: fmt.Sprintf(`e.g.
+			simple-pass %s < ops.jsonl
+			simple-pass %s ops.jsonl
+
+			each line of the input is one json operation, e.g.
+				{"op": "%s", "name": "work/db", "username": "admin", "password": "...", "url": "https://db.example.com"}
+				{"op": "%s", "name": "card", "type": "credit-card", "fields": [{"name": "number", "value": "..."}]}
+				{"op": "%s", "name": "work/db", "notes": ["first line", "second line"], "tags": ["prod"]}
+				{"op": "%s", "name": "work/db", "fields": [{"name": "pin", "value": "...", "concealed": true}], "removeFields": ["old"]}
+				{"op": "%s", "name": "work/db", "to": "work/postgres"}
+				{"op": "%s", "name": "old"}
+
+			an %s only changes what it is given. the operations are applied in order, and saved together once they
+			have all succeeded - if any of them fails, nothing is saved. the status of every operation is reported`,
+			BatchCmdName, BatchCmdName, BatchOpAdd, BatchOpAdd, BatchOpUpdate, BatchOpUpdate, BatchOpRename, BatchOpDelete, BatchOpUpdate),
+		Args:    cobra.MaximumNArgs(1),
+		PreRunE: passDBCacheExistsOrErr,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log.Debugf("%s called with %v", BatchCmdName, args)
+			in := cmd.InOrStdin()
+			if len(args) == 1 && args[0] != "-" {
+				file, err := os.Open(args[0])
+				if err != nil {
+					return fmt.Errorf("cannot run batch: %s\n", err)
+				}
+				defer file.Close()
+				in = file
+			}
+
+			ops, results, err := readBatchOps(in)
+			if err != nil {
+				return fmt.Errorf("cannot run batch: %s\n", err)
+			}
+			if failed(results) {
+				return reportBatch(cmd, results)
+			}
+
+			tx, err := passDB.Begin()
+			if err != nil {
+				return fmt.Errorf("cannot run batch: %s\n", err)
+			}
+			for idx, op := range ops {
+				status, err := op.apply(tx, passDB, force)
+				if err != nil {
+					results[idx].Status, results[idx].Error = BatchFailed, strings.TrimSpace(err.Error())
+					for prior := range results[:idx] {
+						results[prior].Status = BatchRolledBack
+					}
+					rollbackErr := tx.Rollback()
+					if rollbackErr != nil {
+						return fmt.Errorf("cannot roll back batch: %s\n", rollbackErr)
+					}
+					return reportBatch(cmd, results)
+				}
+				results[idx].Status = status
+			}
+
+			err = tx.Commit()
+			if err != nil {
+				return fmt.Errorf("cannot save batch - nothing was applied: %s\n", err)
+			}
+			return writeOutput(cmd, BatchOutput(results))
+		},
+	}
+	cmd.Flags().BoolVar(&force, ForceFlag, false, "save passwords even if they are weaker than the passdb password policy allows")
+	return cmd
+}
+
+// readBatchOps reads an operation from each line of the input, along with a result for each of them - which is failed
+// if the operation is not valid
+func readBatchOps(r io.Reader) ([]batchOp, []BatchResult, error) {
+	ops := []batchOp{}
+	results := []BatchResult{}
+	reader := bufio.NewReader(r)
+	for lineNumber := 1; ; lineNumber++ {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, nil, err
+		}
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+			op, opErr := parseBatchOp(trimmed)
+			result := BatchResult{Line: lineNumber, Op: op.Op, Name: op.Name, Status: BatchNotApplied}
+			if opErr != nil {
+				result.Status, result.Error = BatchFailed, opErr.Error()
+			}
+			ops = append(ops, op)
+			results = append(results, result)
+		}
+		if errors.Is(err, io.EOF) {
+			return ops, results, nil
+		}
+	}
+}
+
+func parseBatchOp(line []byte) (batchOp, error) {
+	op := batchOp{}
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&op)
+	if err != nil {
+		return op, err
+	}
+	switch {
+	case op.Op != BatchOpAdd && op.Op != BatchOpUpdate && op.Op != BatchOpRename && op.Op != BatchOpDelete:
+		return op, ErrUnknownBatchOp
+	case op.Name == "":
+		return op, ErrBatchOpWithoutName
+	case op.Op == BatchOpRename && op.To == "":
+		return op, ErrBatchRenameWithoutTo
+	case op.Op != BatchOpRename && op.To != "":
+		return op, fmt.Errorf("only a %s can be given a name to rename to", BatchOpRename)
+	case op.Op != BatchOpUpdate && len(op.RemoveFields) > 0:
+		return op, fmt.Errorf("only an %s can remove fields", BatchOpUpdate)
+	}
+	return op, nil
+}
+
+// apply makes the operation part of the transaction, returning its status
+func (op batchOp) apply(tx *db.Tx, passDB *db.PassDB, force bool) (string, error) {
+	switch op.Op {
+	case BatchOpAdd:
+		return BatchApplied, op.add(tx, passDB, force)
+	case BatchOpUpdate:
+		err := op.update(tx, passDB, force)
+		if errors.Is(err, db.ErrItemUnchanged) {
+			return BatchUnchanged, nil
+		}
+		return BatchApplied, err
+	case BatchOpRename:
+		return BatchApplied, tx.RenameItem(op.Name, op.To)
+	case BatchOpDelete:
+		return BatchApplied, tx.DeleteItem(op.Name)
+	}
+	return "", ErrUnknownBatchOp
+}
+
+func (op batchOp) add(tx *db.Tx, passDB *db.PassDB, force bool) error {
+	itemType := op.Type
+	if itemType == "" {
+		itemType = item.TypeLogin
+	}
+	fields, err := op.fields(itemType)
+	if err != nil {
+		return err
+	}
+	var notes []string
+	if op.Notes != nil {
+		notes = *op.Notes
+	}
+	newItem, err := item.NewItem(op.Name, valueOf(op.Username), valueOf(op.Password), valueOf(op.URL), notes, fields...)
+	if err != nil {
+		return err
+	}
+	newItem.Type = itemType
+	err = op.setTags(newItem)
+	if err != nil {
+		return err
+	}
+	if newItem.Password != "" {
+		err = reportPasswordStrength(passDB, newItem, force)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.SaveNewItem(newItem)
+}
+
+func (op batchOp) update(tx *db.Tx, passDB *db.PassDB, force bool) error {
+	existing, err := tx.RetrieveItem(op.Name)
+	if err != nil {
+		return err
+	}
+	updated := *existing
+	if op.Type != "" {
+		updated.Type = op.Type
+	}
+	err = op.change(&updated)
+	if err != nil {
+		return err
+	}
+	if updated.Password != "" && updated.Password != existing.Password {
+		err = reportPasswordStrength(passDB, &updated, force)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.UpdateItem(&updated)
+}
+
+// change makes the changes given by the operation to an existing item
+func (op batchOp) change(passItem *item.Item) error {
+	if op.Username != nil {
+		passItem.Username = *op.Username
+	}
+	if op.Password != nil {
+		passItem.Password = *op.Password
+	}
+	if op.URL != nil {
+		passItem.URL = *op.URL
+	}
+	if op.Notes != nil {
+		passItem.Notes = *op.Notes
+	}
+	err := op.setTags(passItem)
+	if err != nil {
+		return err
+	}
+
+	fields, err := op.fields(passItem.GetType())
+	if err != nil {
+		return err
+	}
+	// the item may share its fields with the one stored - don't change them in place
+	passItem.Fields = append([]item.Field(nil), passItem.Fields...)
+	for _, field := range fields {
+		err = passItem.SetField(field.Name, field.Value, field.Concealed)
+		if err != nil {
+			return err
+		}
+	}
+	for _, name := range op.RemoveFields {
+		err = passItem.RemoveField(name)
+		if err != nil {
+			return fmt.Errorf("field '%s': %w", name, err)
+		}
+	}
+	return nil
+}
+
+// fields returns the fields given by the operation - those belonging to the item type are always concealed as its
+// schema dictates
+func (op batchOp) fields(itemType item.Type) ([]item.Field, error) {
+	schema, err := item.SchemaFor(itemType)
+	if err != nil {
+		return nil, err
+	}
+	fields := make([]item.Field, 0, len(op.Fields))
+	for _, field := range op.Fields {
+		concealed := field.Concealed
+		if spec := schema.Spec(field.Name); spec != nil {
+			concealed = spec.Concealed
+		}
+		fields = append(fields, item.Field{Name: field.Name, Value: field.Value, Concealed: concealed})
+	}
+	return fields, nil
+}
+
+// setTags replaces the tags of the item, if the operation gives any
+func (op batchOp) setTags(passItem *item.Item) error {
+	if op.Tags == nil {
+		return nil
+	}
+	passItem.Tags = nil
+	for _, tag := range *op.Tags {
+		err := passItem.AddTag(tag)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reportBatch reports the results of a batch which failed
+func reportBatch(cmd *cobra.Command, results []BatchResult) error {
+	err := writeOutput(cmd, BatchOutput(results))
+	if err != nil {
+		return err
+	}
+	return ErrBatchFailed
+}
+
+func failed(results []BatchResult) bool {
+	for _, result := range results {
+		if result.Status == BatchFailed {
+			return true
+		}
+	}
+	return false
+}
+
+func valueOf(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/georgewheatcroft/simple-pass/internal/db"
+	"github.com/georgewheatcroft/simple-pass/pkg/hibp"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	BreachCheckCmdName = "breach-check"
+	HIBPFileFlag       = "hibp-file"
+)
+
+var ErrCompromisedPasswords = fmt.Errorf("items have passwords seen in breaches")
+
+// BreachCheckOutput is the result of checking item passwords against the pwned passwords dataset
+type BreachCheckOutput struct {
+	*db.BreachReport
+}
+
+func (o BreachCheckOutput) columns() []string {
+	return []string{"name", "times_seen"}
+}
+
+func (o BreachCheckOutput) rows() [][]string {
+	rows := make([][]string, 0, len(o.Compromised))
+	for _, breached := range o.Compromised {
+		rows = append(rows, []string{breached.ItemName, strconv.Itoa(breached.TimesSeen)})
+	}
+	return rows
+}
+
+func (o BreachCheckOutput) writeText(w io.Writer) error {
+	if len(o.Compromised) > 0 {
+		err := writeTable(w, o)
+		if err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "%d of %d item password(s) seen in breaches\n", len(o.Compromised), o.ItemsChecked)
+	return err
+}
+
+func NewBreachCheckCmd(passDB *db.PassDB) *cobra.Command {
+	var (
+		hibpFile string
+	)
+
+	cmd := &cobra.Command{
+		Use:   BreachCheckCmdName,
+		Short: "check your item passwords against a local copy of the Have I Been Pwned passwords dataset",
+		Long: fmt.Sprintf(`e.g.
+			simple-pass %s --%s ./pwnedpasswords.txt
+
+			the dataset must be the sha1 version, ordered by hash, as downloaded by
+			https://github.com/HaveIBeenPwned/PwnedPasswordsDownloader. passwords are checked offline -
+			nothing is sent over the network. exits with an error if any password has been seen in a breach`,
+			BreachCheckCmdName, HIBPFileFlag),
+		Args:    cobra.NoArgs,
+		PreRunE: passDBCacheExistsOrErr,
+		// a compromised password is a finding, not a usage error
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log.Debugf("%s called", BreachCheckCmdName)
+			dataset, err := hibp.Open(hibpFile)
+			if err != nil {
+				return fmt.Errorf("cannot open --%s: %s\n", HIBPFileFlag, err)
+			}
+			defer dataset.Close()
+
+			report, err := passDB.CheckBreaches(dataset)
+			if err != nil {
+				return fmt.Errorf("cannot check passwords for breaches: %s\n", err)
+			}
+
+			err = writeOutput(cmd, BreachCheckOutput{BreachReport: report})
+			if err != nil {
+				return err
+			}
+			if len(report.Compromised) > 0 {
+				return fmt.Errorf("%w: %d", ErrCompromisedPasswords, len(report.Compromised))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&hibpFile, HIBPFileFlag, "", "path to the sorted sha1 pwned passwords dataset (Required)")
+
+	err := cmd.MarkFlagRequired(HIBPFileFlag)
+	if err != nil {
+		panic(fmt.Sprintf("cannot setup cobra command:%s", err))
+	}
+	return cmd
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/georgewheatcroft/simple-pass/pkg/clipboard"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	CopyFlag       = "copy"
+	ClearAfterFlag = "clear-after"
+
+	CopiedMessage           = "copied the %s of '%s' to the clipboard (%s)"
+	ClipboardClearedMessage = "cleared the clipboard"
+	ClipboardChangedMessage = "the clipboard has changed since - leaving it as it is"
+)
+
+// DetectClipboard returns the clipboard values are copied to. OSC 52 escape sequences are written to the terminal
+// given
+var DetectClipboard = clipboard.Detect
+
+// copyValue copies the value to the clipboard, then waits to clear it again - unless clearAfter is 0
+func copyValue(cmd *cobra.Command, value ValueOutput, clearAfter time.Duration) error {
+	provider, err := DetectClipboard(cmd.ErrOrStderr())
+	if err != nil {
+		return fmt.Errorf("cannot copy to the clipboard: %s\n", err)
+	}
+	err = provider.Copy(value.Value)
+	if err != nil {
+		return fmt.Errorf("cannot copy to the clipboard: %s\n", err)
+	}
+
+	change := ChangeOutput{
+		Action:  "copied",
+		Target:  value.Item,
+		Details: map[string]string{"field": value.Field, "clipboard": provider.Name()},
+		message: fmt.Sprintf(CopiedMessage, value.Field, value.Item, provider.Name()),
+	}
+	if clearAfter > 0 {
+		change.Details[ClearAfterFlag] = clearAfter.String()
+		change.message += fmt.Sprintf(" - clearing it in %s, or on ctrl-c", clearAfter)
+	}
+	err = writeOutput(cmd, change)
+	if err != nil || clearAfter <= 0 {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	cleared, err := clipboard.ClearAfter(ctx, provider, value.Value, clearAfter)
+	if err != nil {
+		return fmt.Errorf("cannot clear the clipboard: %s\n", err)
+	}
+	if cleared {
+		log.Info(ClipboardClearedMessage)
+	} else {
+		log.Info(ClipboardChangedMessage)
+	}
+	return nil
+}
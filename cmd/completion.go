@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/georgewheatcroft/simple-pass/internal/db"
+	"github.com/georgewheatcroft/simple-pass/internal/item"
+	"github.com/spf13/cobra"
+)
+
+// completionFunc completes the arguments or flag values of a command
+type completionFunc func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective)
+
+// builtinFieldNames are the parts of every item which can be asked for by name, as well as its custom fields
+var builtinFieldNames = []string{"url", "notes", "password", "username"}
+
+// isCompletionRequest returns whether the arguments are a request from the shell for completions, rather than a
+// command being run
+func isCompletionRequest(args []string) bool {
+	return len(args) > 0 && (args[0] == cobra.ShellCompRequestCmd || args[0] == cobra.ShellCompNoDescRequestCmd)
+}
+
+// completeItemName completes the item name given as the first argument of a command, stopping at the next folder in
+// the name so that folders can be completed one at a time. Nothing is completed if the passdb is locked (nil)
+func completeItemName(passDB *db.PassDB) completionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if passDB == nil || len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		names := passDB.ListAllItems()
+		sort.Strings(names)
+
+		directive := cobra.ShellCompDirectiveNoFileComp
+		completions := []string{}
+		for _, name := range names {
+			if !strings.HasPrefix(name, toComplete) {
+				continue
+			}
+			completion := name
+			if idx := strings.Index(name[len(toComplete):], item.FolderSeparator); idx >= 0 {
+				completion = name[:len(toComplete)+idx+len(item.FolderSeparator)]
+				// the rest of the name is completed once the folder has been
+				directive |= cobra.ShellCompDirectiveNoSpace
+			}
+			if len(completions) == 0 || completions[len(completions)-1] != completion {
+				completions = append(completions, completion)
+			}
+		}
+		return completions, directive
+	}
+}
+
+// completeItemNameThen completes the item name given as the first argument of a command, and any further arguments
+// with next
+func completeItemNameThen(passDB *db.PassDB, next completionFunc) completionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) == 0 {
+			return completeItemName(passDB)(cmd, args, toComplete)
+		}
+		return next(cmd, args, toComplete)
+	}
+}
+
+// completeFiles leaves the shell to complete file names
+func completeFiles(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return nil, cobra.ShellCompDirectiveDefault
+}
+
+// completeAttachmentName completes the name of an attachment on the item named by the first argument
+func completeAttachmentName(passDB *db.PassDB) completionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		passItem := completedItem(passDB, args)
+		if passItem == nil || len(args) > 1 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		names := []string{}
+		for _, attachment := range passItem.Attachments {
+			names = append(names, attachment.Name)
+		}
+		return names, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// completeFieldName completes the name of a custom field on the item named by the first argument, along with any
+// extra names given. If suffix is given (e.g. "=" for fields given as name=value) it is added to each name
+func completeFieldName(passDB *db.PassDB, suffix string, extra ...string) completionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		passItem := completedItem(passDB, args)
+		if passItem == nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		names := append([]string(nil), extra...)
+		for _, field := range passItem.Fields {
+			names = append(names, field.Name+suffix)
+		}
+		directive := cobra.ShellCompDirectiveNoFileComp
+		if suffix != "" {
+			directive |= cobra.ShellCompDirectiveNoSpace
+		}
+		return names, directive
+	}
+}
+
+// completedItem returns the item named by the first argument, or nil if there is none (or the passdb is locked)
+func completedItem(passDB *db.PassDB, args []string) *item.Item {
+	if passDB == nil || len(args) == 0 {
+		return nil
+	}
+	passItem, err := passDB.RetrieveItem(args[0])
+	if err != nil {
+		return nil
+	}
+	return passItem
+}
+
+// registerFlagCompletion completes the values of the named flag with fn
+func registerFlagCompletion(cmd *cobra.Command, flag string, fn completionFunc) {
+	err := cmd.RegisterFlagCompletionFunc(flag, fn)
+	if err != nil {
+		panic(fmt.Sprintf("cannot complete flag '%s' of %s: %s", flag, cmd.Name(), err))
+	}
+}
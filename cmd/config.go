@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/georgewheatcroft/simple-pass/internal/common/constants"
+	"github.com/georgewheatcroft/simple-pass/pkg/generate"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	configFileName = ".simple-pass.json"
+
+	defaultClipboardClearAfter = 45 * time.Second
+)
+
+var ErrUnknownPasswordProfile = errors.New("unknown password profile")
+
+// config holds user preferences which are not specific to any one passdb, e.g.
+//
+//	{"passwordProfiles": {"my-bank": "minlength: 8; maxlength: 12; required: lower; required: digit;"}}
+type config struct {
+	// PasswordProfiles are user defined password generation profiles in the passwordrules syntax, by name. These
+	// take precedence over any embedded profile of the same name
+	PasswordProfiles map[string]string `json:"passwordProfiles,omitempty"`
+	// ClipboardClearSeconds is how long values copied to the clipboard are kept there, 0 meaning the default and a
+	// negative number never clearing them
+	ClipboardClearSeconds int `json:"clipboardClearSeconds,omitempty"`
+}
+
+// GetConfigPath returns the location of the simple-pass config file
+func GetConfigPath() string {
+	if path, isSet := os.LookupEnv(constants.ConfigPathEnvVar); isSet {
+		return path
+	}
+	usr, err := user.Current()
+	if err != nil {
+		log.Fatalln("can't determine user's home directory: ", err)
+	}
+	path := filepath.Join(usr.HomeDir, configFileName)
+	// avoid impacting local dev config during tests/dev
+	if isLocalDevExec() {
+		path += ".dev"
+	}
+	return path
+}
+
+// loadConfig reads the simple-pass config file - having no config file is the same as having an empty one
+func loadConfig() (*config, error) {
+	cfg := &config{}
+	/* #nosec */
+	serialised, err := os.ReadFile(GetConfigPath())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return cfg, nil
+		}
+		return nil, err
+	}
+	err = json.Unmarshal(serialised, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse config file %s: %s", GetConfigPath(), err)
+	}
+	return cfg, nil
+}
+
+// passwordProfile describes a named set of password rules, and whether it is embedded or user defined
+type passwordProfile struct {
+	Name        string
+	Rules       string
+	UserDefined bool
+}
+
+// passwordProfiles returns every available password profile, sorted by name
+func passwordProfiles() ([]passwordProfile, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	byName := map[string]passwordProfile{}
+	for name, rules := range generate.BuiltinProfiles() {
+		byName[name] = passwordProfile{Name: name, Rules: rules}
+	}
+	for name, rules := range cfg.PasswordProfiles {
+		byName[name] = passwordProfile{Name: name, Rules: rules, UserDefined: true}
+	}
+
+	profiles := make([]passwordProfile, 0, len(byName))
+	for _, profile := range byName {
+		profiles = append(profiles, profile)
+	}
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].Name < profiles[j].Name })
+	return profiles, nil
+}
+
+// resolvePasswordProfile returns the password rules of a named profile
+func resolvePasswordProfile(name string) (*generate.Rules, error) {
+	profiles, err := passwordProfiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, profile := range profiles {
+		if profile.Name == name {
+			rules, err := generate.ParseRules(profile.Rules)
+			if err != nil {
+				return nil, fmt.Errorf("password profile '%s': %w", name, err)
+			}
+			return rules, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: '%s'", ErrUnknownPasswordProfile, name)
+}
+
+// clipboardClearAfter returns how long values copied to the clipboard are kept there before being cleared, 0 meaning
+// they are never cleared
+func clipboardClearAfter() (time.Duration, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case cfg.ClipboardClearSeconds < 0:
+		return 0, nil
+	case cfg.ClipboardClearSeconds > 0:
+		return time.Duration(cfg.ClipboardClearSeconds) * time.Second, nil
+	default:
+		return defaultClipboardClearAfter, nil
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/georgewheatcroft/simple-pass/internal/db"
+	"github.com/georgewheatcroft/simple-pass/pkg/strength"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
@@ -46,13 +47,21 @@ func NewCreatePassDbCmd() *cobra.Command {
 				//TODO some failure cases may leave an empty passdb on disk - need to avoid this
 				return fmt.Errorf("failed to create passDB - %s", err)
 			}
-			log.Infof(SuccessfullyCreatedPassDBMessage, name, filePath)
+			change := ChangeOutput{
+				Action:  "created",
+				Target:  name,
+				Details: map[string]string{"path": filePath},
+				message: fmt.Sprintf(SuccessfullyCreatedPassDBMessage, name, filePath),
+			}
+			// the passdb password protects every other password, so make its strength known
+			result := strength.Estimate(password, name)
+			logPasswordStrength(&result)
 			err = SetPassDBCache(filePath, password)
 			if err != nil {
 				return fmt.Errorf("failed to update the passDBCache with the details for this new passDB: %s", err)
 			}
 			log.Debugf(SuccessfullySetPassDBCacheMessage)
-			return nil
+			return writeOutput(cmd, change)
 		},
 	}
 	cmd.Flags().StringVarP(&name, PassDBNameFlag, PassDBNameShortFlag, "", "name for the passdb (Required)")
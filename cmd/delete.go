@@ -2,7 +2,6 @@ package cmd
 
 import (
 	"fmt"
-	"os"
 
 	"github.com/georgewheatcroft/simple-pass/internal/db"
 	log "github.com/sirupsen/logrus"
@@ -11,6 +10,8 @@ import (
 
 const (
 	DeleteCmdName = "delete"
+
+	SuccessfullyDeletedMessage = "successfully deleted item '%s' from passDB"
 )
 
 func NewDeleteCmd(passDB *db.PassDB) *cobra.Command {
@@ -19,15 +20,10 @@ func NewDeleteCmd(passDB *db.PassDB) *cobra.Command {
 		Short: "remove items from your simple-pass",
 		Long: fmt.Sprintf(`e.g.
 			   simple-pass %s <existing-item-name>`, DeleteCmdName),
-		PreRunE: passDBCacheExistsOrErr,
+		Args:              cobra.ExactArgs(1),
+		PreRunE:           passDBCacheExistsOrErr,
+		ValidArgsFunction: completeItemName(passDB),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if len(args) != 1 {
-				err := cmd.Help()
-				if err != nil {
-					log.Fatalln(err)
-				}
-				os.Exit(1)
-			}
 			log.Debugf("%s called with %v", DeleteCmdName, args)
 
 			itemName := args[0]
@@ -35,8 +31,7 @@ func NewDeleteCmd(passDB *db.PassDB) *cobra.Command {
 			if err != nil {
 				return fmt.Errorf("cannot delete item from passDB: %s\n", err)
 			}
-			log.Infof("successfully deleted item '%s' from passDB\n", itemName)
-			return nil
+			return writeOutput(cmd, ChangeOutput{Action: "deleted", Target: itemName, message: fmt.Sprintf(SuccessfullyDeletedMessage, itemName)})
 		},
 	}
 	return cmd
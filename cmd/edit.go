@@ -0,0 +1,422 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/georgewheatcroft/simple-pass/internal/db"
+	"github.com/georgewheatcroft/simple-pass/internal/item"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	EditCmdName = "edit"
+
+	// EditorEnvVar names the editor items are opened in
+	EditorEnvVar  = "EDITOR"
+	defaultEditor = "vi"
+
+	ItemUnchangedMessage = "item:'%s' was unchanged"
+)
+
+var (
+	ErrEditorFailed     = errors.New("the editor did not exit successfully")
+	ErrItemNameEdited   = fmt.Errorf("the item name cannot be edited (use: simple-pass %s)", RenameCmdName)
+	ErrNegativeRotation = errors.New("rotation_days cannot be negative")
+)
+
+// editableItem is the part of an item which can be changed in the editor. The rest (e.g. its attachments and when
+// it was created) is kept as it is
+type editableItem struct {
+	Name     string    `yaml:"name"`
+	Type     item.Type `yaml:"type"`
+	Username string    `yaml:"username"`
+	Password string    `yaml:"password"`
+	URL      string    `yaml:"url"`
+	// URLs are given as [<match-mode>=]<url>, as with the match url flag
+	URLs []string `yaml:"urls,omitempty"`
+	// Notes are the lines of the item's notes, so that they can be edited as one multi-line value
+	Notes           string          `yaml:"notes,omitempty"`
+	Tags            []string        `yaml:"tags,omitempty"`
+	Fields          []editableField `yaml:"fields,omitempty"`
+	OTP             string          `yaml:"otp,omitempty"`
+	RotationDays    int             `yaml:"rotation_days,omitempty"`
+	PasswordProfile string          `yaml:"password_profile,omitempty"`
+}
+
+type editableField struct {
+	Name      string `yaml:"name"`
+	Value     string `yaml:"value"`
+	Concealed bool   `yaml:"concealed,omitempty"`
+}
+
+func NewEditCmd(passDB *db.PassDB) *cobra.Command {
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   EditCmdName,
+		Short: "edit an item in your simple-pass with $" + EditorEnvVar,
+		Long: fmt.Sprintf(`e.g.
+			simple-pass %s <existing-item-name>
+
+			the item is opened as yaml in $%s (or %s, if it is not set). once the editor exits, the changes are
+			checked, shown and saved - unless the file was left as it was, or the editor failed.
+
+			the file is written to a temporary directory held in memory where there is one ($XDG_RUNTIME_DIR or
+			/dev/shm), is only readable by you, and is overwritten and removed afterwards. beware that your editor
+			may keep its own copies (e.g. swap or backup files) elsewhere`, EditCmdName, EditorEnvVar, defaultEditor),
+		Args:              cobra.ExactArgs(1),
+		PreRunE:           passDBCacheExistsOrErr,
+		ValidArgsFunction: completeItemName(passDB),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log.Debugf("%s called with %v", EditCmdName, args)
+			itemName := args[0]
+			retrievedItem, err := passDB.RetrieveItem(itemName)
+			if err != nil {
+				return fmt.Errorf("cannot edit item: %s\n", err)
+			}
+
+			before := newEditableItem(retrievedItem)
+			original, err := marshalEditableItem(before)
+			if err != nil {
+				return fmt.Errorf("cannot edit item: %s\n", err)
+			}
+			edited, err := editInEditor(cmd, original)
+			if err != nil {
+				return fmt.Errorf("cannot edit item: %s\n", err)
+			}
+			if bytes.Equal(edited, original) {
+				return writeOutput(cmd, ChangeOutput{Action: "unchanged", Target: itemName, message: fmt.Sprintf(ItemUnchangedMessage, itemName)})
+			}
+
+			after := editableItem{}
+			decoder := yaml.NewDecoder(bytes.NewReader(edited))
+			decoder.KnownFields(true)
+			err = decoder.Decode(&after)
+			if err != nil && !errors.Is(err, io.EOF) {
+				return fmt.Errorf("cannot edit item - the edited item is not valid yaml: %s\n", err)
+			}
+			newItem, err := after.apply(retrievedItem)
+			if err != nil {
+				return fmt.Errorf("cannot edit item: %s\n", err)
+			}
+			err = newItem.Validate()
+			if err != nil {
+				return fmt.Errorf("cannot edit item: %s\n", err)
+			}
+			if newItem.Password != "" && newItem.Password != retrievedItem.Password {
+				err = reportPasswordStrength(passDB, newItem, force)
+				if err != nil {
+					return fmt.Errorf("cannot edit item: %s\n", err)
+				}
+			}
+
+			// the diff is not part of the result, so is kept off stdout when the result must be parseable
+			diffOut := cmd.OutOrStdout()
+			if machineOutput(cmd) {
+				diffOut = cmd.ErrOrStderr()
+			}
+			// the diff is of the item as it will be saved, so that values are masked as they will be concealed
+			err = writeItemDiff(diffOut, before, newEditableItem(newItem))
+			if err != nil {
+				return fmt.Errorf("cannot edit item: %s\n", err)
+			}
+
+			err = passDB.UpdateItem(newItem)
+			if errors.Is(err, db.ErrItemUnchanged) {
+				return writeOutput(cmd, ChangeOutput{Action: "unchanged", Target: itemName, message: fmt.Sprintf(ItemUnchangedMessage, itemName)})
+			}
+			if err != nil {
+				return fmt.Errorf("cannot edit item: %s\n", err)
+			}
+			return writeOutput(cmd, ChangeOutput{Action: "updated", Target: itemName, message: fmt.Sprintf(SuccessfullyUpdatedMessage, itemName)})
+		},
+	}
+	cmd.Flags().BoolVar(&force, ForceFlag, false, "save the password even if it is weaker than the passdb password policy allows")
+	return cmd
+}
+
+func newEditableItem(passItem *item.Item) editableItem {
+	editable := editableItem{
+		Name:            passItem.Name,
+		Type:            passItem.GetType(),
+		Username:        passItem.Username,
+		Password:        passItem.Password,
+		URL:             passItem.URL,
+		Notes:           strings.Join(passItem.Notes, "\n"),
+		Tags:            passItem.Tags,
+		RotationDays:    passItem.RotationDays,
+		PasswordProfile: passItem.PasswordProfile,
+	}
+	for _, itemURL := range passItem.URLs {
+		editable.URLs = append(editable.URLs, fmt.Sprintf("%s=%s", itemURL.Match, itemURL.URL))
+	}
+	for _, field := range passItem.Fields {
+		editable.Fields = append(editable.Fields, editableField{Name: field.Name, Value: field.Value, Concealed: field.Concealed})
+	}
+	if passItem.OTP != nil {
+		editable.OTP = passItem.OTP.URI()
+	}
+	return editable
+}
+
+// apply returns a copy of the existing item with the edits made to it
+func (e editableItem) apply(existing *item.Item) (*item.Item, error) {
+	if e.Name != existing.Name {
+		return nil, ErrItemNameEdited
+	}
+	schema, err := item.SchemaFor(e.Type)
+	if err != nil {
+		return nil, err
+	}
+	if e.RotationDays < 0 {
+		return nil, ErrNegativeRotation
+	}
+	if e.PasswordProfile != "" && e.PasswordProfile != existing.PasswordProfile {
+		_, err = resolvePasswordProfile(e.PasswordProfile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	edited := *existing
+	edited.Type, edited.Username, edited.Password, edited.URL = e.Type, e.Username, e.Password, e.URL
+	edited.RotationDays, edited.PasswordProfile = e.RotationDays, e.PasswordProfile
+
+	edited.URLs, err = parseMatchURLFlags(e.URLs)
+	if err != nil {
+		return nil, err
+	}
+
+	edited.Notes = nil
+	if notes := strings.TrimSuffix(e.Notes, "\n"); notes != "" {
+		edited.Notes = strings.Split(notes, "\n")
+	}
+
+	edited.Tags = nil
+	for _, tag := range e.Tags {
+		err = edited.AddTag(tag)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	edited.Fields = nil
+	for _, field := range e.Fields {
+		// fields belonging to the item type are always concealed as its schema dictates
+		if spec := schema.Spec(field.Name); spec != nil {
+			field.Concealed = spec.Concealed
+		}
+		err = edited.SetField(field.Name, field.Value, field.Concealed)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// the existing key is kept if its uri is unchanged, so that nothing it holds is lost in the round trip
+	if existing.OTP == nil || e.OTP != existing.OTP.URI() {
+		edited.OTP, err = parseOTPFlags(e.OTP, "")
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &edited, nil
+}
+
+func marshalEditableItem(editable editableItem) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# editing '%s' - save and quit to apply your changes, or quit without saving to discard them.\n", editable.Name)
+	fmt.Fprintf(&buf, "# urls are given as [<match-mode>=]<url>, where match-mode is one of %v\n", item.MatchModes())
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	err := encoder.Encode(editable)
+	if err != nil {
+		return nil, err
+	}
+	err = encoder.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// editInEditor opens the content in the user's editor, returning what it was saved as
+func editInEditor(cmd *cobra.Command, content []byte) ([]byte, error) {
+	dir, err := createPrivateTempDir()
+	if err != nil {
+		return nil, err
+	}
+	defer destroyTempDir(dir)
+
+	path := filepath.Join(dir, "item.yaml")
+	err = os.WriteFile(path, content, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	editor := strings.Fields(os.Getenv(EditorEnvVar))
+	if len(editor) == 0 {
+		editor = []string{defaultEditor}
+	}
+	log.Debugf("opening %s in %v", path, editor)
+	editorCmd := exec.CommandContext(cmd.Context(), editor[0], append(editor[1:], path)...)
+	editorCmd.Stdin, editorCmd.Stdout, editorCmd.Stderr = cmd.InOrStdin(), cmd.OutOrStdout(), cmd.ErrOrStderr()
+	err = editorCmd.Run()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrEditorFailed, err)
+	}
+	return os.ReadFile(path)
+}
+
+// createPrivateTempDir creates a directory only the user can use, in a directory held in memory if there is one.
+// Editors write swap and backup files next to the file edited, and often save by replacing it with a new file, so
+// the item is kept private by the directory it is in rather than by the permissions of the file itself
+func createPrivateTempDir() (string, error) {
+	for _, parent := range []string{os.Getenv("XDG_RUNTIME_DIR"), "/dev/shm"} {
+		if parent == "" {
+			continue
+		}
+		dir, err := os.MkdirTemp(parent, "simple-pass-*")
+		if err == nil {
+			return dir, nil
+		}
+		log.Debugf("cannot create temporary directory in %s: %s", parent, err)
+	}
+	dir, err := os.MkdirTemp("", "simple-pass-*")
+	if err != nil {
+		return "", err
+	}
+	log.Warnf("no temporary directory held in memory is available - the item will be written to disk in %s", dir)
+	return dir, nil
+}
+
+// destroyTempDir overwrites every file in the directory before removing it, so that neither the item nor any copy of
+// it the editor made is left behind
+func destroyTempDir(dir string) {
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		file, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			return err
+		}
+		_, err = file.Write(make([]byte, info.Size()))
+		if err == nil {
+			err = file.Sync()
+		}
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		return err
+	})
+	if err != nil {
+		log.Warnf("cannot overwrite temporary files in %s: %s", dir, err)
+	}
+	err = os.RemoveAll(dir)
+	if err != nil {
+		log.Warnf("cannot remove temporary directory %s: %s", dir, err)
+	}
+}
+
+// writeItemDiff writes the lines of the item which were changed. Secret values are masked, noting only whether they
+// were changed
+func writeItemDiff(w io.Writer, before, after editableItem) error {
+	beforeYAML, err := yaml.Marshal(before.masked(before))
+	if err != nil {
+		return err
+	}
+	afterYAML, err := yaml.Marshal(after.masked(before))
+	if err != nil {
+		return err
+	}
+	for _, line := range diffLines(strings.Split(string(beforeYAML), "\n"), strings.Split(string(afterYAML), "\n")) {
+		_, err = fmt.Fprintln(w, line)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// masked returns a copy with its secret values replaced by item.ConcealedMask, noting those which differ from the
+// reference
+func (e editableItem) masked(reference editableItem) editableItem {
+	mask := func(value, referenceValue string) string {
+		switch {
+		case value == "":
+			return ""
+		case value != referenceValue:
+			return item.ConcealedMask + " (changed)"
+		default:
+			return item.ConcealedMask
+		}
+	}
+	referenceFields := map[string]string{}
+	for _, field := range reference.Fields {
+		referenceFields[field.Name] = field.Value
+	}
+
+	masked := e
+	masked.Password = mask(e.Password, reference.Password)
+	masked.OTP = mask(e.OTP, reference.OTP)
+	masked.Fields = make([]editableField, len(e.Fields))
+	for idx, field := range e.Fields {
+		if field.Concealed {
+			field.Value = mask(field.Value, referenceFields[field.Name])
+		}
+		masked.Fields[idx] = field
+	}
+	return masked
+}
+
+// diffLines returns the lines removed from before (prefixed "- ") and added in after (prefixed "+ "), in order
+func diffLines(before, after []string) []string {
+	// lcs[i][j] is the length of the longest common subsequence of before[i:] and after[j:]
+	lcs := make([][]int, len(before)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(after)+1)
+	}
+	for i := len(before) - 1; i >= 0; i-- {
+		for j := len(after) - 1; j >= 0; j-- {
+			switch {
+			case before[i] == after[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	diff := []string{}
+	i, j := 0, 0
+	for i < len(before) || j < len(after) {
+		switch {
+		case i < len(before) && j < len(after) && before[i] == after[j]:
+			i++
+			j++
+		case j == len(after) || (i < len(before) && lcs[i+1][j] >= lcs[i][j+1]):
+			diff = append(diff, "- "+before[i])
+			i++
+		default:
+			diff = append(diff, "+ "+after[j])
+			j++
+		}
+	}
+	return diff
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/georgewheatcroft/simple-pass/internal/db"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	ExpiringCmdName = "expiring"
+	WithinFlag      = "within"
+	WithinShortFlag = "w"
+
+	defaultExpiringWithin = "14d"
+	lastChangedUnknown    = "unknown"
+)
+
+var ErrItemsOverdueForRotation = fmt.Errorf("items are overdue for rotation")
+
+// ExpiringOutput is the result of listing the items overdue, or soon due, for rotation. Due and LastChanged are unset
+// (zero) when it is not known when an item's password was last changed
+type ExpiringOutput []db.RotationStatus
+
+func (o ExpiringOutput) columns() []string {
+	return []string{"name", "status", "due", "last_changed", "policy"}
+}
+
+func (o ExpiringOutput) rows() [][]string {
+	rows := make([][]string, 0, len(o))
+	for _, status := range o {
+		state, due, lastChanged := "due", lastChangedUnknown, lastChangedUnknown
+		if status.Overdue {
+			state = "overdue"
+		}
+		if !status.Due.IsZero() {
+			due = status.Due.Format("2006-01-02")
+			lastChanged = status.LastChanged.Format("2006-01-02")
+		}
+		rows = append(rows, []string{status.ItemName, state, due, lastChanged, fmt.Sprintf("%dd (%s)", status.PolicyDays, status.PolicySource)})
+	}
+	return rows
+}
+
+func NewExpiringCmd(passDB *db.PassDB) *cobra.Command {
+	var (
+		within string
+	)
+
+	cmd := &cobra.Command{
+		Use:   ExpiringCmdName,
+		Short: "list items whose passwords are overdue, or soon due, for rotation",
+		Long: fmt.Sprintf(`e.g.
+			simple-pass %s
+			simple-pass %s --within 30d
+
+			exits non-zero if any item is overdue, so can be run from cron
+			set rotation policies using: simple-pass %s`, ExpiringCmdName, ExpiringCmdName, RotationPolicyCmdName),
+		PreRunE: passDBCacheExistsOrErr,
+		// being overdue is reported as an error, but is not a usage problem
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log.Debugf("%s called with %v", ExpiringCmdName, args)
+			window, err := parseDays(within)
+			if err != nil {
+				return fmt.Errorf("invalid --%s: %s\n", WithinFlag, err)
+			}
+
+			report, err := passDB.RotationReport(time.Now(), window)
+			if err != nil {
+				return fmt.Errorf("cannot determine items due for rotation: %s\n", err)
+			}
+
+			err = writeOutput(cmd, ExpiringOutput(report))
+			if err != nil {
+				return err
+			}
+			overdue := 0
+			for _, status := range report {
+				if status.Overdue {
+					overdue++
+				}
+			}
+
+			if overdue > 0 {
+				return fmt.Errorf("%w: %d", ErrItemsOverdueForRotation, overdue)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&within, WithinFlag, WithinShortFlag, defaultExpiringWithin, "also list items due within this period, e.g. 14d, 2w or 36h")
+	return cmd
+}
+
+// parseDays parses periods given in days (e.g. 14d) or weeks (e.g. 2w), as well as any go duration (e.g. 36h)
+func parseDays(period string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if count, found := strings.CutSuffix(period, suffix); found {
+			n, err := strconv.Atoi(count)
+			if err != nil || n < 0 {
+				return 0, fmt.Errorf("'%s' is not a valid period", period)
+			}
+			return time.Duration(n) * unit, nil
+		}
+	}
+	duration, err := time.ParseDuration(period)
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("'%s' is not a valid period", period)
+	}
+	return duration, nil
+}
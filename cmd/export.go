@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+
+	"github.com/georgewheatcroft/simple-pass/internal/db"
+	"github.com/georgewheatcroft/simple-pass/internal/item"
+	"github.com/georgewheatcroft/simple-pass/internal/transfer"
+	"github.com/georgewheatcroft/simple-pass/pkg/argon2"
+	"github.com/georgewheatcroft/simple-pass/pkg/kdbx"
+	"github.com/georgewheatcroft/simple-pass/pkg/strength"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	ExportCmdName     = "export"
+	ExportKDBXCmdName = "kdbx"
+	CipherFlag        = "cipher"
+	KDFFlag           = "kdf"
+	IterationsFlag    = "iterations"
+	MemoryFlag        = "memory"
+	ParallelismFlag   = "parallelism"
+
+	// the key derivation functions a KeePass database can be exported with
+	KDFArgon2d  = "argon2d"
+	KDFArgon2id = "argon2id"
+
+	SuccessfullyExportedMessage = "exported %d items to %s"
+)
+
+var (
+	ErrUnknownCipher       = fmt.Errorf("unknown cipher - must be one of %v", kdbx.Ciphers())
+	ErrUnknownKDF          = fmt.Errorf("unknown key derivation function - must be %s or %s", KDFArgon2d, KDFArgon2id)
+	ErrEmptyExportPassword = errors.New("the password to protect the KeePass database with cannot be empty")
+)
+
+func NewExportCmd(passDB *db.PassDB) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   ExportCmdName,
+		Short: "export the items in your simple-pass for use in other password managers",
+	}
+	cmd.AddCommand(NewExportKDBXCmd(passDB))
+	return cmd
+}
+
+func NewExportKDBXCmd(passDB *db.PassDB) *cobra.Command {
+	defaults := kdbx.DefaultOptions()
+	var (
+		password    string
+		cipher      string
+		kdf         string
+		iterations  uint32
+		memory      uint32
+		parallelism uint32
+	)
+
+	cmd := &cobra.Command{
+		Use:   ExportKDBXCmdName + " <file>",
+		Short: "export every item to a KeePass (kdbx 4) database",
+		Long: fmt.Sprintf(`e.g.
+			simple-pass %s %s passwords.kdbx --%s <database-password>
+			simple-pass %s %s passwords.kdbx --%s <database-password> --%s %s --%s %s --%s 256
+
+			folders are exported as groups, and items as entries - with their fields as custom strings (protected if
+			concealed), along with their attachments. what KeePass has no place for, such as item types and rotation
+			policies, is kept as custom data of each entry so that it is restored when imported again. an existing
+			file is never overwritten`,
+			ExportCmdName, ExportKDBXCmdName, PasswordFlag,
+			ExportCmdName, ExportKDBXCmdName, PasswordFlag, CipherFlag, kdbx.CipherChaCha20, KDFFlag, KDFArgon2id, MemoryFlag),
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeFiles,
+		PreRunE:           passDBCacheExistsOrErr,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log.Debugf("%s %s called with %v", ExportCmdName, ExportKDBXCmdName, args)
+			// the flag being required does not stop it being given as ""
+			if password == "" {
+				return fmt.Errorf("cannot export kdbx: %s\n", ErrEmptyExportPassword)
+			}
+			options := kdbx.Options{
+				Cipher: kdbx.Cipher(cipher),
+				KDF:    kdbx.KDF{Iterations: iterations, Memory: memory << 10, Parallelism: parallelism},
+			}
+			if cipher != string(kdbx.CipherAES256) && cipher != string(kdbx.CipherChaCha20) {
+				return fmt.Errorf("cannot export kdbx: %s\n", ErrUnknownCipher)
+			}
+			switch kdf {
+			case KDFArgon2d:
+				options.KDF.Mode = argon2.ModeD
+			case KDFArgon2id:
+				options.KDF.Mode = argon2.ModeID
+			default:
+				return fmt.Errorf("cannot export kdbx: %s\n", ErrUnknownKDF)
+			}
+			err := argon2.Params{Mode: options.KDF.Mode, Time: iterations, Memory: options.KDF.Memory, Threads: parallelism}.Validate()
+			if err != nil || memory > math.MaxUint32>>10 {
+				return fmt.Errorf("cannot export kdbx: %s\n", argon2.ErrInvalidParams)
+			}
+
+			// the database password protects every exported password, so make its strength known
+			result := strength.Estimate(password, passDB.GetPassDBName())
+			logPasswordStrength(&result)
+
+			items := []*item.Item{}
+			for _, name := range passDB.ListAllItems() {
+				passItem, err := passDB.RetrieveItem(name)
+				if err != nil {
+					return fmt.Errorf("cannot export kdbx: %s\n", err)
+				}
+				items = append(items, passItem)
+			}
+			// written in full before the file is created, so that a failed export leaves nothing behind
+			buf := &bytes.Buffer{}
+			err = transfer.WriteKDBX(buf, password, options, passDB.GetPassDBName(), items, passDB.ReadAttachment)
+			if err != nil {
+				return fmt.Errorf("cannot export kdbx: %s\n", err)
+			}
+
+			// never overwrite an existing file with the export
+			/* #nosec */
+			fh, err := os.OpenFile(args[0], os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+			if err != nil {
+				return fmt.Errorf("cannot export kdbx: %s\n", err)
+			}
+			defer fh.Close()
+			_, err = fh.Write(buf.Bytes())
+			if err != nil {
+				return fmt.Errorf("cannot export kdbx: %s\n", err)
+			}
+			return writeOutput(cmd, ChangeOutput{
+				Action:  "exported",
+				Target:  args[0],
+				Details: map[string]string{"items": strconv.Itoa(len(items)), "cipher": cipher, "kdf": kdf},
+				message: fmt.Sprintf(SuccessfullyExportedMessage, len(items), args[0]),
+			})
+		},
+	}
+	cmd.Flags().StringVarP(&password, PasswordFlag, PasswordShortFlag, "", "password to protect the KeePass database with (Required)")
+	cmd.Flags().StringVar(&cipher, CipherFlag, string(defaults.Cipher), fmt.Sprintf("cipher to encrypt the database with - one of %v", kdbx.Ciphers()))
+	cmd.Flags().StringVar(&kdf, KDFFlag, KDFArgon2d, fmt.Sprintf("function to derive the key from the password with - %s or %s", KDFArgon2d, KDFArgon2id))
+	cmd.Flags().Uint32Var(&iterations, IterationsFlag, defaults.KDF.Iterations, "iterations of the key derivation function")
+	cmd.Flags().Uint32Var(&memory, MemoryFlag, defaults.KDF.Memory>>10, "memory used by the key derivation function, in MiB")
+	cmd.Flags().Uint32Var(&parallelism, ParallelismFlag, defaults.KDF.Parallelism, "threads used by the key derivation function")
+	err := cmd.MarkFlagRequired(PasswordFlag)
+	if err != nil {
+		panic(fmt.Sprintf("cannot setup cobra command:%s", err))
+	}
+	registerFlagCompletion(cmd, CipherFlag, func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+		ciphers := []string{}
+		for _, cipher := range kdbx.Ciphers() {
+			ciphers = append(ciphers, string(cipher))
+		}
+		return ciphers, cobra.ShellCompDirectiveNoFileComp
+	})
+	registerFlagCompletion(cmd, KDFFlag, func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+		return []string{KDFArgon2d, KDFArgon2id}, cobra.ShellCompDirectiveNoFileComp
+	})
+	return cmd
+}
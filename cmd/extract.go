@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/georgewheatcroft/simple-pass/internal/db"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	ExtractCmdName = "extract"
+	OutFlag        = "out"
+	OutShortFlag   = "o"
+
+	SuccessfullyExtractedMessage = "extracted '%s' to %s"
+)
+
+// AttachmentContentOutput is the decrypted contents of an attachment. In the table format only the contents themselves
+// are written, and in json and yaml they are base64 encoded
+type AttachmentContentOutput struct {
+	Item       string
+	Attachment string
+	Content    []byte
+}
+
+func (o AttachmentContentOutput) columns() []string {
+	return []string{"item", "attachment", "content"}
+}
+
+func (o AttachmentContentOutput) rows() [][]string {
+	return [][]string{{o.Item, o.Attachment, base64.StdEncoding.EncodeToString(o.Content)}}
+}
+
+func (o AttachmentContentOutput) writeText(w io.Writer) error {
+	_, err := w.Write(o.Content)
+	return err
+}
+
+func NewExtractCmd(passDB *db.PassDB) *cobra.Command {
+	var (
+		out string
+	)
+
+	cmd := &cobra.Command{
+		Use:   ExtractCmdName,
+		Short: "decrypt a file attached to an item in your simple-pass",
+		Long: fmt.Sprintf(`e.g.
+			simple-pass %s <existing-item-name> <attachment-name>
+			simple-pass %s <existing-item-name> <attachment-name> --out <path>
+
+			without --out the attachment is written to stdout (base64 encoded in any --output other than table)`, ExtractCmdName, ExtractCmdName),
+		PreRunE:           passDBCacheExistsOrErr,
+		ValidArgsFunction: completeItemNameThen(passDB, completeAttachmentName(passDB)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log.Debugf("%s called with %v", ExtractCmdName, args)
+			if len(args) != 2 {
+				err := cmd.Help()
+				if err != nil {
+					return fmt.Errorf("attempting to show help prompt caused error: %s\n", err)
+				}
+				return fmt.Errorf("expected an item name and an attachment name")
+			}
+			itemName, name := args[0], args[1]
+
+			data, err := passDB.ReadAttachment(itemName, name)
+			if err != nil {
+				return fmt.Errorf("cannot extract attachment: %s\n", err)
+			}
+
+			if out == "" {
+				return writeOutput(cmd, AttachmentContentOutput{Item: itemName, Attachment: name, Content: data})
+			}
+			// never overwrite an existing file with the attachment
+			/* #nosec */
+			fh, err := os.OpenFile(out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+			if err != nil {
+				return fmt.Errorf("cannot extract attachment: %s\n", err)
+			}
+			defer fh.Close()
+			_, err = fh.Write(data)
+			if err != nil {
+				return fmt.Errorf("cannot extract attachment: %s\n", err)
+			}
+			return writeOutput(cmd, ChangeOutput{
+				Action:  "extracted",
+				Target:  itemName,
+				Details: map[string]string{"attachment": name, "path": out},
+				message: fmt.Sprintf(SuccessfullyExtractedMessage, name, out),
+			})
+		},
+	}
+	cmd.Flags().StringVarP(&out, OutFlag, OutShortFlag, "", "path to write the attachment to")
+	return cmd
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/georgewheatcroft/simple-pass/internal/db"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	FindURLCmdName = "find-url"
+	VerboseFlag    = "verbose"
+	VerboseShort   = "v"
+)
+
+var ErrNoItemsMatchURL = fmt.Errorf("no items match the url")
+
+// URLMatch is an item which matches a url, and how it matched
+type URLMatch struct {
+	Name     string
+	Match    string
+	Username string
+}
+
+// URLMatchesOutput is the result of finding the items which match a url, from the most to the least specific match
+type URLMatchesOutput []URLMatch
+
+func (o URLMatchesOutput) columns() []string {
+	return []string{"name", "match", "username"}
+}
+
+func (o URLMatchesOutput) rows() [][]string {
+	rows := make([][]string, 0, len(o))
+	for _, match := range o {
+		rows = append(rows, []string{match.Name, match.Match, match.Username})
+	}
+	return rows
+}
+
+func NewFindURLCmd(passDB *db.PassDB) *cobra.Command {
+	var (
+		verbose bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   FindURLCmdName,
+		Short: "find the items in your simple-pass which match a url",
+		Long: fmt.Sprintf(`e.g.
+			simple-pass %s https://login.example.com/signin
+			simple-pass %s https://login.example.com/signin --verbose
+
+			items are listed from the most to the least specific match`, FindURLCmdName, FindURLCmdName),
+		PreRunE: passDBCacheExistsOrErr,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log.Debugf("%s called with %v", FindURLCmdName, args)
+			if len(args) != 1 {
+				err := cmd.Help()
+				if err != nil {
+					return fmt.Errorf("attempting to show help prompt caused error: %s\n", err)
+				}
+				return fmt.Errorf("expected exactly one url")
+			}
+
+			matches, err := passDB.FindItemsByURL(args[0])
+			if err != nil {
+				return fmt.Errorf("cannot find items for url: %s\n", err)
+			}
+			// no matches is an error so that scripts can easily tell there is no credential to use
+			if len(matches) == 0 {
+				return ErrNoItemsMatchURL
+			}
+
+			if !verbose && !machineOutput(cmd) {
+				for _, match := range matches {
+					fmt.Fprintln(cmd.OutOrStdout(), match.Item.Name)
+				}
+				return nil
+			}
+			found := make(URLMatchesOutput, 0, len(matches))
+			for _, match := range matches {
+				found = append(found, URLMatch{Name: match.Item.Name, Match: string(match.Match), Username: match.Item.Username})
+			}
+			return writeOutput(cmd, found)
+		},
+	}
+	cmd.Flags().BoolVarP(&verbose, VerboseFlag, VerboseShort, false, "show how each item matched")
+	return cmd
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"text/template"
+
+	"github.com/georgewheatcroft/simple-pass/internal/item"
+	"github.com/spf13/cobra"
+)
+
+const (
+	FormatFlag = "format"
+)
+
+var (
+	ErrFormatWithOutput     = fmt.Errorf("--%s cannot be combined with any --%s other than %s", FormatFlag, OutputFlag, OutputTable)
+	ErrFormatConcealedField = fmt.Errorf("concealed fields can only be formatted with --%s", IncludeSecretsFlag)
+)
+
+// formatHelp describes the functions which can be used in --format templates, alongside the item fields
+const formatHelp = `templates are go templates (see https://pkg.go.dev/text/template) executed against each item, whose
+			fields include .Name .Username .Password .URL .Notes .Tags and .Folder. as well as go's own template
+			functions, these can be used:
+				base64 <string>          base64 encode the string
+				urlencode <string>       escape the string to be used within a url, e.g. as a password in a dsn
+				json <value>             the value as json, e.g. {{json .Tags}}
+				field <item> <name>      the value of the named custom field of the item, e.g. {{field . "pin"}}`
+
+// formatFuncs are the functions available to --format templates. Unless secrets are included the field function
+// refuses to format concealed fields, rather than formatting their mask
+func formatFuncs(includeSecrets bool) template.FuncMap {
+	return template.FuncMap{
+		"base64": func(s string) string {
+			return base64.StdEncoding.EncodeToString([]byte(s))
+		},
+		"urlencode": url.QueryEscape,
+		"json": func(v interface{}) (string, error) {
+			serialised, err := json.Marshal(v)
+			return string(serialised), err
+		},
+		"field": func(i *item.Item, name string) (string, error) {
+			field, err := i.GetField(name)
+			if err != nil {
+				return "", fmt.Errorf("%s: '%s'", err, name)
+			}
+			if field.Concealed && !includeSecrets {
+				return "", fmt.Errorf("%s: '%s'", ErrFormatConcealedField, name)
+			}
+			return field.Value, nil
+		},
+	}
+}
+
+// parseFormat parses the template given to --format, which cannot be used with any output format other than table
+// as the template decides the output itself. Unless secrets are included, the template is only given masked items
+// (see writeFormatted)
+func parseFormat(cmd *cobra.Command, format string, includeSecrets bool) (*template.Template, error) {
+	if machineOutput(cmd) {
+		return nil, ErrFormatWithOutput
+	}
+	tmpl, err := template.New(FormatFlag).Option("missingkey=error").Funcs(formatFuncs(includeSecrets)).Parse(format)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --%s: %s\n", FormatFlag, err)
+	}
+	return tmpl, nil
+}
+
+// writeFormatted executes the template for each item, writing each on its own line. Unless secrets are included the
+// template is given masked items, with their password masked too
+func writeFormatted(w io.Writer, tmpl *template.Template, includeSecrets bool, items ...*item.Item) error {
+	for _, formatted := range items {
+		// attachment keys are never needed to format an item, so are never made available
+		data := formatted.WithoutAttachmentKeys()
+		if !includeSecrets {
+			data = formatted.Masked()
+			if data.Password != "" {
+				data.Password = item.ConcealedMask
+			}
+		}
+		err := tmpl.Execute(w, data)
+		if err != nil {
+			return fmt.Errorf("cannot format item '%s': %s\n", formatted.Name, err)
+		}
+		_, err = fmt.Fprintln(w)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/georgewheatcroft/simple-pass/pkg/generate"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const (
+	GenerateCmdName = "generate"
+	GenerateFlag    = "generate"
+
+	LengthFlag           = "length"
+	NoLowerFlag          = "no-lower"
+	NoUpperFlag          = "no-upper"
+	NoDigitsFlag         = "no-digits"
+	NoSymbolsFlag        = "no-symbols"
+	ExcludeAmbiguousFlag = "exclude-ambiguous"
+	MinPerClassFlag      = "min-per-class"
+
+	PassphraseFlag  = "passphrase"
+	WordsFlag       = "words"
+	SeparatorFlag   = "separator"
+	CapitaliseFlag  = "capitalise"
+	InsertDigitFlag = "insert-digit"
+	WordlistFlag    = "wordlist"
+
+	ProfileFlag         = "profile"
+	PasswordProfileFlag = "password-profile"
+
+	EntropyMessage = "entropy: %.1f bits\n"
+)
+
+var ErrPassphraseWithProfile = errors.New("a passphrase cannot be generated following a password profile")
+
+// generatorFlags are the options for generating passwords, shared by every command which can generate them
+type generatorFlags struct {
+	length           int
+	noLower          bool
+	noUpper          bool
+	noDigits         bool
+	noSymbols        bool
+	excludeAmbiguous bool
+	minPerClass      int
+
+	passphrase   bool
+	words        int
+	separator    string
+	capitalise   bool
+	insertDigit  bool
+	wordlistPath string
+
+	flags *pflag.FlagSet
+}
+
+func (g *generatorFlags) register(flags *pflag.FlagSet) {
+	g.flags = flags
+	flags.IntVar(&g.length, LengthFlag, generate.DefaultLength, "length of the generated password")
+	flags.BoolVar(&g.noLower, NoLowerFlag, false, "generate without lower case letters")
+	flags.BoolVar(&g.noUpper, NoUpperFlag, false, "generate without upper case letters")
+	flags.BoolVar(&g.noDigits, NoDigitsFlag, false, "generate without digits")
+	flags.BoolVar(&g.noSymbols, NoSymbolsFlag, false, "generate without symbols")
+	flags.BoolVar(&g.excludeAmbiguous, ExcludeAmbiguousFlag, false, fmt.Sprintf("generate without the easily confused characters %s", generate.Ambiguous))
+	flags.IntVar(&g.minPerClass, MinPerClassFlag, generate.DefaultMinPerClass, "fewest characters of each character class used in the generated password")
+	flags.BoolVar(&g.passphrase, PassphraseFlag, false, "generate a diceware passphrase of random words instead of a password")
+	flags.IntVar(&g.words, WordsFlag, generate.DefaultWords, "number of words in the generated passphrase")
+	flags.StringVar(&g.separator, SeparatorFlag, generate.DefaultSeparator, "separator between the words of the generated passphrase")
+	flags.BoolVar(&g.capitalise, CapitaliseFlag, false, "capitalise each word of the generated passphrase")
+	flags.BoolVar(&g.insertDigit, InsertDigitFlag, false, "add a random digit to a random word of the generated passphrase")
+	flags.StringVar(&g.wordlistPath, WordlistFlag, "", "file of words (one per line) to generate the passphrase from, instead of the EFF long wordlist")
+}
+
+func (g *generatorFlags) options() generate.Options {
+	return generate.Options{
+		Length:           g.length,
+		Lower:            !g.noLower,
+		Upper:            !g.noUpper,
+		Digits:           !g.noDigits,
+		Symbols:          !g.noSymbols,
+		ExcludeAmbiguous: g.excludeAmbiguous,
+		MinPerClass:      g.minPerClass,
+	}
+}
+
+func (g *generatorFlags) passphraseOptions() (generate.PassphraseOptions, error) {
+	opts := generate.PassphraseOptions{
+		Words:       g.words,
+		Separator:   g.separator,
+		Capitalise:  g.capitalise,
+		InsertDigit: g.insertDigit,
+	}
+	if g.wordlistPath == "" {
+		return opts, nil
+	}
+	/* #nosec */
+	fh, err := os.Open(g.wordlistPath)
+	if err != nil {
+		return opts, err
+	}
+	defer fh.Close()
+	opts.Wordlist, err = generate.ParseWordlist(fh)
+	return opts, err
+}
+
+// generate generates a password or passphrase with the options given by the flags, returning its entropy if known.
+// If a password profile is named, its rules take precedence over the character class flags
+func (g *generatorFlags) generate(profile string) (string, float64, error) {
+	if profile != "" {
+		if g.passphrase {
+			return "", 0, ErrPassphraseWithProfile
+		}
+		rules, err := resolvePasswordProfile(profile)
+		if err != nil {
+			return "", 0, err
+		}
+		// follow the profile's preferred length unless one is asked for
+		length := 0
+		if g.flags.Changed(LengthFlag) {
+			length = g.length
+		}
+		password, err := rules.Password(length)
+		return password, 0, err
+	}
+	if !g.passphrase {
+		password, err := generate.Password(g.options())
+		return password, 0, err
+	}
+	opts, err := g.passphraseOptions()
+	if err != nil {
+		return "", 0, err
+	}
+	passphrase, err := generate.Passphrase(opts)
+	if err != nil {
+		return "", 0, err
+	}
+	return passphrase, opts.EntropyBits(), nil
+}
+
+// password generates a password or passphrase with the options given by the flags, following the rules of the named
+// password profile if there is one
+func (g *generatorFlags) password(profile string) (string, error) {
+	password, _, err := g.generate(profile)
+	return password, err
+}
+
+// GeneratedOutput is a generated password. EntropyBits is only given when the entropy of the password is known
+type GeneratedOutput struct {
+	Password    string
+	EntropyBits float64 `json:",omitempty"`
+}
+
+func (o GeneratedOutput) columns() []string {
+	return []string{"password", "entropy_bits"}
+}
+
+func (o GeneratedOutput) rows() [][]string {
+	entropy := ""
+	if o.EntropyBits > 0 {
+		entropy = strconv.FormatFloat(o.EntropyBits, 'f', 1, 64)
+	}
+	return [][]string{{o.Password, entropy}}
+}
+
+func (o GeneratedOutput) writeText(w io.Writer) error {
+	_, err := fmt.Fprintln(w, o.Password)
+	return err
+}
+
+func NewGenerateCmd() *cobra.Command {
+	var (
+		generator generatorFlags
+		profile   string
+	)
+
+	cmd := &cobra.Command{
+		Use:   GenerateCmdName,
+		Short: "generate a random password or passphrase",
+		Long: fmt.Sprintf(`e.g.
+			simple-pass %s
+			simple-pass %s --%s 32 --%s --%s
+			simple-pass %s --%s --%s 6 --%s - --%s --%s
+			simple-pass %s --%s max-16
+
+			generate a password straight into an item (keeping it out of your shell history) with:
+			simple-pass add <new-item-name> --%s
+			simple-pass update <existing-item-name> --%s`, GenerateCmdName, GenerateCmdName, LengthFlag, NoSymbolsFlag, ExcludeAmbiguousFlag,
+			GenerateCmdName, PassphraseFlag, WordsFlag, SeparatorFlag, CapitaliseFlag, InsertDigitFlag,
+			GenerateCmdName, ProfileFlag, GenerateFlag, GenerateFlag),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log.Debugf("%s called", GenerateCmdName)
+			password, entropy, err := generator.generate(profile)
+			if err != nil {
+				return fmt.Errorf("cannot generate password: %s\n", err)
+			}
+			err = writeOutput(cmd, GeneratedOutput{Password: password, EntropyBits: entropy})
+			if err != nil {
+				return err
+			}
+			// keep the output to be piped or copied to just the password
+			if entropy > 0 && !machineOutput(cmd) {
+				fmt.Fprintf(cmd.ErrOrStderr(), EntropyMessage, entropy)
+			}
+			return nil
+		},
+	}
+	generator.register(cmd.Flags())
+	cmd.Flags().StringVar(&profile, ProfileFlag, "", fmt.Sprintf("password profile whose rules the password must follow (see: simple-pass %s)", PasswordProfilesCmdName))
+	return cmd
+}
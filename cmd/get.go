@@ -1,23 +1,154 @@
 package cmd
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
-	"os"
+	"io"
 	"strconv"
 	"strings"
+	"text/template"
+	"time"
 
 	"github.com/georgewheatcroft/simple-pass/internal/db"
+	"github.com/georgewheatcroft/simple-pass/internal/item"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
 const (
 	GetCmdName = "get"
+	RevealFlag = "reveal"
+	ExactFlag  = "exact"
+	TypeFlag   = "type"
 )
 
-var ErrItemDoesNotExist = db.ErrItemDoesNotExist
+var (
+	ErrItemDoesNotExist = db.ErrItemDoesNotExist
+	ErrTooManyGetFlags  = errors.New("only one part of the item can be retrieved at a time")
+)
+
+// ItemOutput is the full display of an item, alongside any details derived from its type (e.g. a key fingerprint)
+type ItemOutput struct {
+	*item.Item
+	Details map[string]string `json:",omitempty"`
+}
+
+func (o ItemOutput) columns() []string {
+	return []string{"field", "value"}
+}
+
+func (o ItemOutput) rows() [][]string {
+	i := o.Item
+	rows := [][]string{
+		{"name", i.Name},
+		{"type", string(i.GetType())},
+		{"username", i.Username},
+		{"password", i.Password},
+		{"url", i.URL},
+	}
+	for _, itemURL := range i.URLs {
+		rows = append(rows, []string{"urls", fmt.Sprintf("%s=%s", itemURL.Match, itemURL.URL)})
+	}
+	for _, line := range i.Notes {
+		rows = append(rows, []string{"notes", line})
+	}
+	if len(i.Tags) > 0 {
+		rows = append(rows, []string{"tags", strings.Join(i.Tags, ",")})
+	}
+	for _, field := range i.Fields {
+		rows = append(rows, []string{db.QueryFieldPrefix + field.Name, field.Value})
+	}
+	for _, attachment := range i.Attachments {
+		rows = append(rows, []string{"attachments", attachment.Name})
+	}
+	if i.OTP != nil {
+		rows = append(rows, []string{"otp", string(i.OTP.Kind)})
+	}
+	if i.RotationDays != 0 {
+		rows = append(rows, []string{"rotation_days", strconv.Itoa(i.RotationDays)})
+	}
+	if i.PasswordProfile != "" {
+		rows = append(rows, []string{"password_profile", i.PasswordProfile})
+	}
+	for _, key := range sortedKeys(o.Details) {
+		rows = append(rows, []string{key, o.Details[key]})
+	}
+	return append(rows,
+		[]string{"created", formatOutputTime(i.Created)},
+		[]string{"modified", formatOutputTime(i.Modified)},
+		[]string{"password_changed", formatOutputTime(i.PasswordChanged)},
+	)
+}
+
+// TypedItemOutput is an item shown as its type - the fields of its type's schema, labelled by name, followed by the
+// details derived from them (e.g. whether a card has expired, or the fingerprint of a key)
+type TypedItemOutput struct {
+	Name    string
+	Type    item.Type
+	Fields  []item.Field      `json:",omitempty"`
+	Details map[string]string `json:",omitempty"`
+}
+
+func newTypedItemOutput(i *item.Item, details map[string]string) (TypedItemOutput, error) {
+	schema, err := item.SchemaFor(i.GetType())
+	if err != nil {
+		return TypedItemOutput{}, err
+	}
+	output := TypedItemOutput{Name: i.Name, Type: schema.Type, Details: details}
+	switch schema.Type {
+	case item.TypeLogin:
+		output.Fields = []item.Field{
+			{Name: "username", Value: i.Username},
+			{Name: "password", Value: i.Password},
+			{Name: "url", Value: i.URL},
+		}
+	case item.TypeSecureNote:
+		output.Fields = []item.Field{{Name: "notes", Value: strings.Join(i.Notes, "\n")}}
+	}
+	for _, spec := range schema.Fields {
+		field, err := i.GetField(spec.Name)
+		if err == nil {
+			output.Fields = append(output.Fields, *field)
+		}
+	}
+	return output, nil
+}
+
+func (o TypedItemOutput) columns() []string {
+	return []string{"field", "value"}
+}
+
+func (o TypedItemOutput) rows() [][]string {
+	rows := [][]string{{"name", o.Name}, {"type", string(o.Type)}}
+	for _, field := range o.Fields {
+		rows = append(rows, []string{field.Name, field.Value})
+	}
+	for _, key := range sortedKeys(o.Details) {
+		rows = append(rows, []string{key, o.Details[key]})
+	}
+	return rows
+}
+
+// ValueOutput is a single value retrieved from an item, e.g. its password. In the table format only the value itself
+// is written, so that it can be piped
+type ValueOutput struct {
+	Item  string
+	Field string
+	Value string
+}
+
+func (o ValueOutput) columns() []string {
+	return []string{"item", "field", "value"}
+}
+
+func (o ValueOutput) rows() [][]string {
+	return [][]string{{o.Item, o.Field, o.Value}}
+}
+
+func (o ValueOutput) writeText(w io.Writer) error {
+	_, err := fmt.Fprint(w, o.Value)
+	return err
+}
 
 func NewGetCmd(passDB *db.PassDB) *cobra.Command {
 	var (
@@ -25,6 +156,13 @@ func NewGetCmd(passDB *db.PassDB) *cobra.Command {
 		notesFlag    bool
 		passwordFlag bool
 		usernameFlag bool
+		fieldFlag    string
+		revealFlag   bool
+		exactFlag    bool
+		typeFlag     bool
+		formatFlag   string
+		copyFlag     bool
+		clearAfter   time.Duration
 	)
 
 	cmd := &cobra.Command{
@@ -33,35 +171,60 @@ func NewGetCmd(passDB *db.PassDB) *cobra.Command {
 		Long: fmt.Sprintf(`e.g.
 
 			   simple-pass %s <existing-item-name>
-			   simple-pass %s <existing-item-name> --password`, GetCmdName, GetCmdName),
-		PreRunE: passDBCacheExistsOrErr,
+			   simple-pass %s <existing-item-name> --password
+			   simple-pass %s <existing-item-name> --%s
+			   simple-pass %s <existing-item-name> --field <custom-field-name>
+			   simple-pass %s <existing-item-name> --%s 'postgres://{{urlencode .Username}}:{{urlencode .Password}}@db:5432'
+			   simple-pass %s <existing-item-name> --%s
+			   simple-pass %s <existing-item-name> --%s --%s username --%s 10s
+
+			   values copied to the clipboard are cleared from it after a while (%s, unless clipboardClearSeconds is set
+			   in %s), or straight away on ctrl-c - unless something else has been copied since
+
+			   if no item has the name given, but exactly one item's name is a close match (e.g. a typo), that item is
+			   retrieved instead - unless --%s is given
+
+			%s`, GetCmdName, GetCmdName, GetCmdName, TypeFlag, GetCmdName, GetCmdName, FormatFlag, GetCmdName, CopyFlag, GetCmdName, CopyFlag,
+			FieldFlag, ClearAfterFlag, defaultClipboardClearAfter, GetConfigPath(), ExactFlag, formatHelp),
+		Args:              cobra.ExactArgs(1),
+		PreRunE:           passDBCacheExistsOrErr,
+		ValidArgsFunction: completeItemName(passDB),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if len(args) != 1 {
-				err := cmd.Help()
-				if err != nil {
-					log.Fatalln(err)
-				}
-				os.Exit(1)
-			}
 			log.Debugf("%s called with %v", GetCmdName, args)
 
 			flags := cmd.Flags()
 			noFlags := flags.NFlag()
+			// these change how the item is found, or where what is retrieved goes, not what is retrieved from it
+			for _, flag := range []string{ExactFlag, OutputFlag, CopyFlag, ClearAfterFlag} {
+				if flags.Changed(flag) {
+					noFlags--
+				}
+			}
+			// the fields of a typed item are revealed the same way as those of any other
+			if typeFlag && revealFlag {
+				noFlags--
+			}
 			// cannot have more than 1 flag currently
 			if noFlags > 1 {
-				err := cmd.Help()
+				return fmt.Errorf("cannot retrieve item details from passDB: %s\n", ErrTooManyGetFlags)
+			}
+
+			var tmpl *template.Template
+			if formatFlag != "" {
+				var err error
+				// a single item is formatted to get at its secrets, e.g. to build a connection string
+				tmpl, err = parseFormat(cmd, formatFlag, true)
 				if err != nil {
-					log.Fatalln(err)
+					return err
 				}
-				os.Exit(1)
 			}
 
 			itemName := args[0]
 			itemRetrieved, err := passDB.RetrieveItem(itemName)
+			if errors.Is(err, db.ErrItemDoesNotExist) && !exactFlag {
+				itemRetrieved, err = retrieveClosestItem(passDB, itemName, cmd.ErrOrStderr())
+			}
 			if err != nil {
-				if errors.Is(err, db.ErrItemDoesNotExist) {
-
-				}
 				return fmt.Errorf("cannot retrieve item details from passDB: %s\n", err)
 			}
 
@@ -75,13 +238,29 @@ func NewGetCmd(passDB *db.PassDB) *cobra.Command {
 					return fmt.Errorf("cannot access flags provided to command: %s\n", err)
 				}
 
-				if noFlags == 0 {
-					json, err := json.Marshal(itemRetrieved)
+				if tmpl != nil {
+					return writeFormatted(cmd.OutOrStdout(), tmpl, true, itemRetrieved)
+				}
+
+				if typeFlag {
+					display := itemRetrieved.WithoutAttachmentKeys()
+					if !revealFlag {
+						display = itemRetrieved.Masked()
+					}
+					typed, err := newTypedItemOutput(display, itemRetrieved.Details())
 					if err != nil {
-						return err
+						return fmt.Errorf("cannot retrieve item details from passDB: %s\n", err)
 					}
-					fmt.Println(string(json))
-					return nil
+					return writeOutput(cmd, typed)
+				}
+
+				if !copyFlag && (noFlags == 0 || revealFlag) {
+					display := itemRetrieved.WithoutAttachmentKeys()
+					// concealed fields are only shown when explicitly asked for
+					if !revealFlag {
+						display = itemRetrieved.Masked()
+					}
+					return writeOutput(cmd, ItemOutput{Item: display, Details: itemRetrieved.Details()})
 				}
 
 				// TODO currently can't find a way to get out a list/something of all flags present in
@@ -93,18 +272,49 @@ func NewGetCmd(passDB *db.PassDB) *cobra.Command {
 					"username": itemRetrieved.Username,
 				}
 
-				for flag, value := range getFlags {
-					exists, err := strconv.ParseBool(flags.Lookup(flag).Value.String())
-					if err != nil {
-						return fmt.Errorf("can't parse boolean flag - %s", err)
+				var value *ValueOutput
+				switch {
+				case fieldFlag != "":
+					// custom fields take precedence over the built in fields of the same name
+					field, err := itemRetrieved.GetField(fieldFlag)
+					builtin, isBuiltin := getFlags[fieldFlag]
+					switch {
+					case err == nil:
+						value = &ValueOutput{Item: itemRetrieved.Name, Field: db.QueryFieldPrefix + field.Name, Value: field.Value}
+					case isBuiltin:
+						value = &ValueOutput{Item: itemRetrieved.Name, Field: fieldFlag, Value: builtin}
+					default:
+						return fmt.Errorf("cannot retrieve field '%s' of item: %s\n", fieldFlag, err)
 					}
-					if exists {
-						fmt.Printf("%s", value)
-						return nil
+				case noFlags == 0:
+					// only reachable when copying, which copies the password unless told otherwise
+					value = &ValueOutput{Item: itemRetrieved.Name, Field: "password", Value: itemRetrieved.Password}
+				default:
+					for flag, flagValue := range getFlags {
+						exists, err := strconv.ParseBool(flags.Lookup(flag).Value.String())
+						if err != nil {
+							return fmt.Errorf("can't parse boolean flag - %s", err)
+						}
+						if exists {
+							value = &ValueOutput{Item: itemRetrieved.Name, Field: flag, Value: flagValue}
+							break
+						}
 					}
 				}
+				if value == nil {
+					return fmt.Errorf("cannot determine what to retrieve for item based on inputs\n")
+				}
 
-				return fmt.Errorf("cannot determine what to retrieve for item based on inputs\n")
+				if copyFlag {
+					if !flags.Changed(ClearAfterFlag) {
+						clearAfter, err = clipboardClearAfter()
+						if err != nil {
+							return fmt.Errorf("cannot load config: %s\n", err)
+						}
+					}
+					return copyValue(cmd, *value, clearAfter)
+				}
+				return writeOutput(cmd, *value)
 			}
 
 		},
@@ -116,6 +326,18 @@ func NewGetCmd(passDB *db.PassDB) *cobra.Command {
 	cmd.Flags().BoolVarP(&passwordFlag, "password", "p", false, "password")
 	cmd.Flags().BoolVarP(&notesFlag, "notes", "n", false, "notes")
 	cmd.Flags().BoolVarP(&urlFlag, "url", "w", false, "url")
-	cmd.MarkFlagsMutuallyExclusive("username", "password", "notes", "url")
+	cmd.Flags().StringVar(&fieldFlag, FieldFlag, "", "value of the named custom field (or of url, notes, password or username)")
+	cmd.Flags().BoolVar(&revealFlag, RevealFlag, false, "show the values of concealed fields")
+	cmd.Flags().BoolVar(&typeFlag, TypeFlag, false, "show only the fields of the item's type, followed by what is derived from them (e.g. a card's expiry, a key's fingerprint)")
+	cmd.Flags().BoolVar(&exactFlag, ExactFlag, false, "only retrieve the item with exactly the name given, never a close match")
+	cmd.Flags().StringVar(&formatFlag, FormatFlag, "", "go template to write the item with, e.g. '{{.Username}}:{{.Password}}'")
+	cmd.Flags().BoolVar(&copyFlag, CopyFlag, false, "copy the value (the password, unless another is asked for) to the clipboard instead of writing it out")
+	cmd.Flags().DurationVar(&clearAfter, ClearAfterFlag, defaultClipboardClearAfter, "how long the copied value is kept on the clipboard, 0 keeping it there")
+	cmd.MarkFlagsMutuallyExclusive("username", "password", "notes", "url", FieldFlag, RevealFlag, FormatFlag)
+	cmd.MarkFlagsMutuallyExclusive(TypeFlag, "username", "password", "notes", "url", FieldFlag, FormatFlag)
+	cmd.MarkFlagsMutuallyExclusive(TypeFlag, CopyFlag)
+	cmd.MarkFlagsMutuallyExclusive(CopyFlag, RevealFlag)
+	cmd.MarkFlagsMutuallyExclusive(CopyFlag, FormatFlag)
+	registerFlagCompletion(cmd, FieldFlag, completeFieldName(passDB, "", builtinFieldNames...))
 	return cmd
 }
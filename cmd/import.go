@@ -0,0 +1,370 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/georgewheatcroft/simple-pass/internal/db"
+	"github.com/georgewheatcroft/simple-pass/internal/item"
+	"github.com/georgewheatcroft/simple-pass/internal/transfer"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	ImportCmdName     = "import"
+	ImportCSVCmdName  = "csv"
+	ImportKDBXCmdName = "kdbx"
+	FromFlag          = "from"
+	MapFlag           = "map"
+	OnConflictFlag    = "on-conflict"
+	DryRunFlag        = "dry-run"
+
+	// what is done with an imported item whose name is already in use
+	ConflictSkip      = "skip"
+	ConflictRename    = "rename"
+	ConflictOverwrite = "overwrite"
+
+	// the status of each imported item reported once the import has run
+	ImportImported    = "imported"
+	ImportRenamed     = "renamed"
+	ImportOverwritten = "overwritten"
+	ImportSkipped     = "skipped"
+	ImportFailed      = "failed"
+)
+
+var (
+	ErrImportFailed    = errors.New("import failed - nothing was imported")
+	ErrUnknownConflict = fmt.Errorf("unknown conflict policy - must be one of %s, %s or %s", ConflictSkip, ConflictRename, ConflictOverwrite)
+)
+
+// ImportOutput is the result of an import - a count of what happened to the items imported, and the status of each
+type ImportOutput struct {
+	// DryRun is set if nothing was saved, as the import was only being tried out
+	DryRun      bool
+	Imported    int
+	Renamed     int
+	Overwritten int
+	Skipped     int
+	Failed      int
+	Items       []ImportResult
+}
+
+// ImportResult is the status of one imported item. Row is where it was read from, e.g. the row of a csv
+type ImportResult struct {
+	Row    int
+	Name   string
+	Status string
+	// Detail is why the item was skipped or failed, or the name a renamed item was imported as
+	Detail string `json:",omitempty"`
+}
+
+func (o ImportOutput) columns() []string {
+	return []string{"row", "name", "status", "detail"}
+}
+
+func (o ImportOutput) rows() [][]string {
+	rows := make([][]string, 0, len(o.Items))
+	for _, result := range o.Items {
+		rows = append(rows, []string{strconv.Itoa(result.Row), result.Name, result.Status, result.Detail})
+	}
+	return rows
+}
+
+// writeText writes what happened to every item which was not simply imported, followed by a summary
+func (o ImportOutput) writeText(w io.Writer) error {
+	for _, result := range o.Items {
+		if result.Status == ImportImported {
+			continue
+		}
+		line := fmt.Sprintf("row %d: %s - %s", result.Row, result.Name, result.Status)
+		if result.Detail != "" {
+			line += " (" + result.Detail + ")"
+		}
+		_, err := fmt.Fprintln(w, line)
+		if err != nil {
+			return err
+		}
+	}
+	summary := fmt.Sprintf("imported %d items (%d renamed, %d overwritten), skipped %d, failed %d",
+		o.Imported+o.Renamed+o.Overwritten, o.Renamed, o.Overwritten, o.Skipped, o.Failed)
+	if o.DryRun {
+		summary += fmt.Sprintf(" - nothing was saved, as --%s was given", DryRunFlag)
+	}
+	_, err := fmt.Fprintln(w, summary)
+	return err
+}
+
+func (o *ImportOutput) add(result ImportResult) {
+	switch result.Status {
+	case ImportImported:
+		o.Imported++
+	case ImportRenamed:
+		o.Renamed++
+	case ImportOverwritten:
+		o.Overwritten++
+	case ImportSkipped:
+		o.Skipped++
+	case ImportFailed:
+		o.Failed++
+	}
+	o.Items = append(o.Items, result)
+}
+
+func NewImportCmd(passDB *db.PassDB) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   ImportCmdName,
+		Short: "import items into your simple-pass from other password managers",
+	}
+	cmd.AddCommand(NewImportCSVCmd(passDB))
+	cmd.AddCommand(NewImportKDBXCmd(passDB))
+	return cmd
+}
+
+func NewImportCSVCmd(passDB *db.PassDB) *cobra.Command {
+	var (
+		from       string
+		mappings   []string
+		onConflict string
+		dryRun     bool
+		force      bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   ImportCSVCmdName + " <file>",
+		Short: "import items from a csv export",
+		Long: fmt.Sprintf(`e.g.
+			simple-pass %s %s passwords.csv
+			simple-pass %s %s export.csv --%s %s --%s %s
+			simple-pass %s %s other.csv --%s name=Title --%s username=Login --%s password=Pass --%s secret.pin=PIN
+
+			exports from %v are recognised from their header, or can be given using --%s. --%s maps a
+			column to what it holds - one of %v, or a custom field given as %s<name> (%s<name> if concealed) -
+			either to describe any other layout, or to adjust that of an export. every item is imported together, and
+			if any cannot be, none are`,
+			ImportCmdName, ImportCSVCmdName,
+			ImportCmdName, ImportCSVCmdName, FromFlag, transfer.FormatBitwarden, OnConflictFlag, ConflictRename,
+			ImportCmdName, ImportCSVCmdName, MapFlag, MapFlag, MapFlag, MapFlag,
+			transfer.Formats(), FromFlag, MapFlag, transfer.Targets(), transfer.FieldTargetPrefix, transfer.SecretFieldTargetPrefix),
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeFiles,
+		PreRunE:           passDBCacheExistsOrErr,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log.Debugf("%s %s called with %v", ImportCmdName, ImportCSVCmdName, args)
+			if onConflict != ConflictSkip && onConflict != ConflictRename && onConflict != ConflictOverwrite {
+				return fmt.Errorf("cannot import csv: %s\n", ErrUnknownConflict)
+			}
+			in := cmd.InOrStdin()
+			if args[0] != "-" {
+				/* #nosec */
+				file, err := os.Open(args[0])
+				if err != nil {
+					return fmt.Errorf("cannot import csv: %s\n", err)
+				}
+				defer file.Close()
+				in = file
+			}
+
+			read, err := transfer.ReadCSV(in, transfer.Format(from), mappings)
+			if err != nil {
+				return fmt.Errorf("cannot import csv: %s\n", err)
+			}
+			if read.Format != "" {
+				log.Infof("importing %s export", read.Format)
+			}
+			return importItems(cmd, passDB, read.Entries, onConflict, dryRun, force)
+		},
+	}
+	cmd.Flags().StringVar(&from, FromFlag, "", fmt.Sprintf("export the csv is from - one of %v (detected from the header by default)", transfer.Formats()))
+	cmd.Flags().StringArrayVar(&mappings, MapFlag, nil, "column to import as part of each item, given as target=column")
+	cmd.Flags().StringVar(&onConflict, OnConflictFlag, ConflictSkip, fmt.Sprintf("what to do with items whose name is already in use - %s, %s or %s", ConflictSkip, ConflictRename, ConflictOverwrite))
+	cmd.Flags().BoolVar(&dryRun, DryRunFlag, false, "report what would be imported, without saving anything")
+	cmd.Flags().BoolVar(&force, ForceFlag, false, "import passwords even if they are weaker than the passdb password policy allows")
+	registerFlagCompletion(cmd, FromFlag, func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+		formats := []string{}
+		for _, format := range transfer.Formats() {
+			formats = append(formats, string(format))
+		}
+		return formats, cobra.ShellCompDirectiveNoFileComp
+	})
+	registerFlagCompletion(cmd, OnConflictFlag, func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+		return []string{ConflictSkip, ConflictRename, ConflictOverwrite}, cobra.ShellCompDirectiveNoFileComp
+	})
+	return cmd
+}
+
+func NewImportKDBXCmd(passDB *db.PassDB) *cobra.Command {
+	var (
+		password   string
+		onConflict string
+		dryRun     bool
+		force      bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   ImportKDBXCmdName + " <file>",
+		Short: "import items from a KeePass (kdbx 4) database",
+		Long: fmt.Sprintf(`e.g.
+			simple-pass %s %s passwords.kdbx --%s <database-password>
+			simple-pass %s %s passwords.kdbx --%s <database-password> --%s %s
+
+			groups are imported as folders, and entries as items - with their custom strings as fields, along with
+			their attachments. entries in the recycle bin are not imported. every item is imported together, and if
+			any cannot be, none are`,
+			ImportCmdName, ImportKDBXCmdName, PasswordFlag,
+			ImportCmdName, ImportKDBXCmdName, PasswordFlag, OnConflictFlag, ConflictRename),
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeFiles,
+		PreRunE:           passDBCacheExistsOrErr,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log.Debugf("%s %s called with %v", ImportCmdName, ImportKDBXCmdName, args)
+			if onConflict != ConflictSkip && onConflict != ConflictRename && onConflict != ConflictOverwrite {
+				return fmt.Errorf("cannot import kdbx: %s\n", ErrUnknownConflict)
+			}
+			in := cmd.InOrStdin()
+			if args[0] != "-" {
+				/* #nosec */
+				file, err := os.Open(args[0])
+				if err != nil {
+					return fmt.Errorf("cannot import kdbx: %s\n", err)
+				}
+				defer file.Close()
+				in = file
+			}
+
+			entries, err := transfer.ReadKDBX(in, password)
+			if err != nil {
+				return fmt.Errorf("cannot import kdbx: %s\n", err)
+			}
+			return importItems(cmd, passDB, entries, onConflict, dryRun, force)
+		},
+	}
+	cmd.Flags().StringVarP(&password, PasswordFlag, PasswordShortFlag, "", "password of the KeePass database (Required)")
+	cmd.Flags().StringVar(&onConflict, OnConflictFlag, ConflictSkip, fmt.Sprintf("what to do with items whose name is already in use - %s, %s or %s", ConflictSkip, ConflictRename, ConflictOverwrite))
+	cmd.Flags().BoolVar(&dryRun, DryRunFlag, false, "report what would be imported, without saving anything")
+	cmd.Flags().BoolVar(&force, ForceFlag, false, "import passwords even if they are weaker than the passdb password policy allows")
+	err := cmd.MarkFlagRequired(PasswordFlag)
+	if err != nil {
+		panic(fmt.Sprintf("cannot setup cobra command:%s", err))
+	}
+	registerFlagCompletion(cmd, OnConflictFlag, func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+		return []string{ConflictSkip, ConflictRename, ConflictOverwrite}, cobra.ShellCompDirectiveNoFileComp
+	})
+	return cmd
+}
+
+// importItems saves every item in a single transaction, reporting what happened to each. If any cannot be saved,
+// or the import is a dry run, nothing is. An item whose name was already imported is renamed rather than overwritten,
+// as overwriting would leave only the last of them
+func importItems(cmd *cobra.Command, passDB *db.PassDB, entries []transfer.Entry, onConflict string, dryRun, force bool) error {
+	policy, err := passDB.PasswordPolicy()
+	if err != nil {
+		return fmt.Errorf("cannot import: %s\n", err)
+	}
+	tx, err := passDB.Begin()
+	if err != nil {
+		return fmt.Errorf("cannot import: %s\n", err)
+	}
+
+	result := ImportOutput{DryRun: dryRun, Items: []ImportResult{}}
+	// the row each name was imported from, as items are never overwritten by others in the same import
+	importedRows := map[string]int{}
+	for _, entry := range entries {
+		if entry.Err != nil {
+			result.add(ImportResult{Row: entry.Row, Status: ImportFailed, Detail: entry.Err.Error()})
+			continue
+		}
+		imported := ImportResult{Row: entry.Row, Name: entry.Item.Name, Status: ImportImported}
+		conflict := onConflict
+		earlierRow, repeated := importedRows[entry.Item.Name]
+		if repeated && conflict == ConflictOverwrite {
+			conflict = ConflictRename
+		}
+		err := importItem(tx, passDB, entry.Item, entry.Attachments, conflict, policy.MinScore > 0, force, &imported)
+		switch {
+		case err != nil:
+			imported.Status, imported.Detail = ImportFailed, strings.TrimSpace(err.Error())
+		case repeated && imported.Status == ImportSkipped:
+			imported.Detail = fmt.Sprintf("name already imported from row %d", earlierRow)
+		case repeated && imported.Status == ImportRenamed:
+			imported.Detail = fmt.Sprintf("name already imported from row %d - imported as %s", earlierRow, entry.Item.Name)
+		}
+		if imported.Status != ImportSkipped && imported.Status != ImportFailed {
+			importedRows[entry.Item.Name] = entry.Row
+		}
+		result.add(imported)
+	}
+
+	if result.Failed > 0 || dryRun {
+		err = tx.Rollback()
+		if err != nil {
+			return fmt.Errorf("cannot roll back import: %s\n", err)
+		}
+		err = writeOutput(cmd, result)
+		if err != nil || dryRun {
+			return err
+		}
+		return ErrImportFailed
+	}
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("cannot save import - nothing was imported: %s\n", err)
+	}
+	return writeOutput(cmd, result)
+}
+
+// importItem saves an item and its attachments as part of an import, settling any conflict with an item which already
+// has its name
+func importItem(tx *db.Tx, passDB *db.PassDB, passItem *item.Item, attachments []transfer.Attachment, onConflict string, checkStrength, force bool, result *ImportResult) error {
+	if _, err := tx.RetrieveItem(passItem.Name); err == nil {
+		switch onConflict {
+		case ConflictSkip:
+			result.Status, result.Detail = ImportSkipped, "name already in use"
+			return nil
+		case ConflictRename:
+			passItem.Name = unusedName(tx, passItem.Name)
+			result.Status, result.Detail = ImportRenamed, "imported as "+passItem.Name
+		case ConflictOverwrite:
+			err = tx.DeleteItem(passItem.Name)
+			if err != nil {
+				return err
+			}
+			result.Status = ImportOverwritten
+		}
+	}
+	if checkStrength && passItem.Password != "" {
+		_, err := passDB.CheckPasswordStrength(passItem)
+		if errors.Is(err, db.ErrPasswordTooWeak) && force {
+			log.Warnf("%s: %s - importing anyway as --%s was given", passItem.Name, err, ForceFlag)
+		} else if errors.Is(err, db.ErrPasswordTooWeak) {
+			return fmt.Errorf("%w (use --%s to import it anyway)", err, ForceFlag)
+		} else if err != nil {
+			return err
+		}
+	}
+	err := tx.SaveNewItem(passItem)
+	if err != nil {
+		return err
+	}
+	for _, attachment := range attachments {
+		err = tx.AddAttachment(passItem.Name, attachment.Name, attachment.Data)
+		if err != nil {
+			return fmt.Errorf("cannot attach '%s': %w", attachment.Name, err)
+		}
+	}
+	return nil
+}
+
+// unusedName returns the name followed by the lowest number which makes it unused, e.g. "name (2)"
+func unusedName(tx *db.Tx, name string) string {
+	for suffix := 2; ; suffix++ {
+		candidate := fmt.Sprintf("%s (%d)", name, suffix)
+		if _, err := tx.RetrieveItem(candidate); errors.Is(err, db.ErrItemDoesNotExist) {
+			return candidate
+		}
+	}
+}
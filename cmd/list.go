@@ -2,7 +2,11 @@ package cmd
 
 import (
 	"fmt"
+	"io"
+	"sort"
 	"strings"
+	"text/template"
+	"time"
 
 	"github.com/georgewheatcroft/simple-pass/internal/db"
 	log "github.com/sirupsen/logrus"
@@ -11,24 +15,144 @@ import (
 
 const (
 	ListCmdName = "list"
+
+	WhereFlag          = "where"
+	SortFlag           = "sort"
+	IncludeSecretsFlag = "include-secrets"
 )
 
+// ItemSummary describes an item listed, without any of its secrets
+type ItemSummary struct {
+	Name     string
+	Folder   string
+	Type     string
+	Username string
+	URL      string
+	Tags     []string
+	Modified time.Time
+}
+
+// ItemSummariesOutput is the result of listing items. In the table format only the item names are written, one per
+// line
+type ItemSummariesOutput []ItemSummary
+
+func (o ItemSummariesOutput) columns() []string {
+	return []string{"name", "folder", "type", "username", "url", "tags", "modified"}
+}
+
+func (o ItemSummariesOutput) rows() [][]string {
+	rows := make([][]string, 0, len(o))
+	for _, summary := range o {
+		rows = append(rows, []string{summary.Name, summary.Folder, summary.Type, summary.Username, summary.URL,
+			strings.Join(summary.Tags, ","), formatOutputTime(summary.Modified)})
+	}
+	return rows
+}
+
+func (o ItemSummariesOutput) writeText(w io.Writer) error {
+	for _, summary := range o {
+		_, err := fmt.Fprintln(w, summary.Name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func NewListCmd(passDB *db.PassDB) *cobra.Command {
+	var (
+		where          string
+		sortBy         []string
+		limit          int
+		includeSecrets bool
+		format         string
+	)
+
 	cmd := &cobra.Command{
 		Use:   ListCmdName,
 		Short: "display the names of all items in your simple-pass",
 		Long: fmt.Sprintf(`e.g.
-			simple-pass %s`, ListCmdName),
+			simple-pass %s
+			simple-pass %s --%s 'folder == "prod" && username == "admin" && modified < "2024-01-01"'
+			simple-pass %s --%s 'url ~ "aws" || tags == "cloud"' --%s=-modified --%s 5
+			simple-pass %s --%s '{{.Name}} {{.Username}} {{json .Tags}}'
+
+			filter expressions compare item fields with == != < <= > >=, match them against regular expressions
+			with ~ and !~, and combine comparisons with && || ! and parentheses. strings are quoted, and times are
+			given as 2006-01-02 or 2006-01-02T15:04:05Z. list fields (e.g. tags) match if any of their values do.
+			the password and concealed custom fields can only be filtered, sorted on or formatted with --%s.
+
+			items are listed by name unless sorted otherwise - prefix a sort field with - to sort in descending order.
+			fields which can be filtered and sorted on:
+%s
+
+			%s`,
+			ListCmdName, ListCmdName, WhereFlag, ListCmdName, WhereFlag, SortFlag, LimitFlag, ListCmdName, FormatFlag,
+			IncludeSecretsFlag, queryFieldsHelp(), formatHelp),
+		Args:    cobra.NoArgs,
 		PreRunE: passDBCacheExistsOrErr,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			log.Debugf("%s called\n", ListCmdName)
-			items := passDB.ListAllItems()
-			log.Debugf("retrieved the following item names: %v", items)
+			var tmpl *template.Template
+			if format != "" {
+				var err error
+				tmpl, err = parseFormat(cmd, format, includeSecrets)
+				if err != nil {
+					return err
+				}
+			}
+			items, err := passDB.Query(db.Query{
+				Where:          where,
+				Sort:           sortBy,
+				Limit:          limit,
+				IncludeSecrets: includeSecrets,
+			})
+			if err != nil {
+				return fmt.Errorf("cannot list items: %s\n", err)
+			}
+			if tmpl != nil {
+				return writeFormatted(cmd.OutOrStdout(), tmpl, includeSecrets, items...)
+			}
 
-			// TODO nicer/pretty output would be good
-			fmt.Println(strings.Join(items, "\n"))
-			return nil
+			names := make([]string, 0, len(items))
+			summaries := make(ItemSummariesOutput, 0, len(items))
+			for _, listed := range items {
+				names = append(names, listed.Name)
+				summaries = append(summaries, ItemSummary{
+					Name:     listed.Name,
+					Folder:   listed.Folder(),
+					Type:     string(listed.GetType()),
+					Username: listed.Username,
+					URL:      listed.URL,
+					Tags:     append([]string{}, listed.Tags...),
+					Modified: listed.Modified,
+				})
+			}
+			log.Debugf("retrieved the following item names: %v", names)
+			return writeOutput(cmd, summaries)
 		},
 	}
+	cmd.Flags().StringVar(&where, WhereFlag, "", "only list items matching the filter expression")
+	cmd.Flags().StringSliceVar(&sortBy, SortFlag, nil, "fields to sort by, e.g. folder,-modified")
+	cmd.Flags().IntVar(&limit, LimitFlag, 0, "most items to list (0 lists every item)")
+	cmd.Flags().BoolVar(&includeSecrets, IncludeSecretsFlag, false, "allow the password and concealed custom fields to be filtered and sorted on, and formatted with --"+FormatFlag)
+	cmd.Flags().StringVar(&format, FormatFlag, "", "go template to write each item with, one item per line")
 	return cmd
 }
+
+// queryFieldsHelp describes the item fields which can be queried, one per line
+func queryFieldsHelp() string {
+	fields := db.QueryFields()
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lines := make([]string, 0, len(names)+1)
+	for _, name := range names {
+		lines = append(lines, fmt.Sprintf("\t\t\t\t%-18s %s", name, fields[name]))
+	}
+	lines = append(lines, fmt.Sprintf("\t\t\t\t%-18s %s", db.QueryFieldPrefix+"<name>", "the value of a custom field"))
+	return strings.Join(lines, "\n")
+}
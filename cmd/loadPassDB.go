@@ -29,13 +29,13 @@ func NewLoadPassDbCmd() *cobra.Command {
 			if err != nil {
 				return fmt.Errorf("failed to load passDB - %s", err)
 			}
-			log.Infof(SuccessfullyLoadedPassDBMessage, filePath)
+			change := ChangeOutput{Action: "loaded", Target: filePath, message: fmt.Sprintf(SuccessfullyLoadedPassDBMessage, filePath)}
 			err = SetPassDBCache(filePath, password)
 			if err != nil {
 				return fmt.Errorf("failed to update the passDBCache with the details for this new passDB: %s", err)
 			}
 			log.Debugf(SuccessfullySetPassDBCacheMessage)
-			return nil
+			return writeOutput(cmd, change)
 		},
 	}
 	cmd.Flags().StringVarP(&password, PassDBPasswordFlag, PassDBPasswordShortFlag, "", "password for the passdb (Required)")
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/georgewheatcroft/simple-pass/internal/db"
+	"github.com/georgewheatcroft/simple-pass/pkg/otp"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	OTPCmdName        = "otp"
+	OTPURIFlag        = "otp-uri"
+	OTPSecretFlag     = "otp-secret"
+	CodeOnlyFlag      = "code-only"
+	CodeOnlyShortFlag = "c"
+)
+
+// OTPOutput is the result of generating a one time password. RemainingSeconds is 0 for counter based (hotp) codes,
+// which do not expire
+type OTPOutput struct {
+	Item             string
+	Code             string
+	RemainingSeconds int
+
+	codeOnly bool
+}
+
+func (o OTPOutput) columns() []string {
+	return []string{"item", "code", "remaining_seconds"}
+}
+
+func (o OTPOutput) rows() [][]string {
+	return [][]string{{o.Item, o.Code, strconv.Itoa(o.RemainingSeconds)}}
+}
+
+func (o OTPOutput) writeText(w io.Writer) error {
+	if o.codeOnly || o.RemainingSeconds == 0 {
+		_, err := fmt.Fprintln(w, o.Code)
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%s (%ds remaining)\n", o.Code, o.RemainingSeconds)
+	return err
+}
+
+func NewOTPCmd(passDB *db.PassDB) *cobra.Command {
+	var (
+		codeOnly bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   OTPCmdName,
+		Short: "generate the current one time password for an item in your simple-pass",
+		Long: fmt.Sprintf(`e.g.
+			simple-pass %s <existing-item-name>
+			simple-pass %s <existing-item-name> --code-only
+
+			set up an item's otp secret with --%s or --%s when adding or updating it
+			NOTE each code generated for a counter based (hotp) item advances its counter`, OTPCmdName, OTPCmdName, OTPURIFlag, OTPSecretFlag),
+		PreRunE:           passDBCacheExistsOrErr,
+		ValidArgsFunction: completeItemName(passDB),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log.Debugf("%s called with %v", OTPCmdName, args)
+			if len(args) != 1 {
+				err := cmd.Help()
+				if err != nil {
+					return fmt.Errorf("attempting to show help prompt caused error: %s\n", err)
+				}
+				return fmt.Errorf("expected exactly one item name")
+			}
+
+			code, err := passDB.GenerateOTPCode(args[0], otp.SystemClock{})
+			if err != nil {
+				return fmt.Errorf("cannot generate one time password: %s\n", err)
+			}
+			return writeOutput(cmd, OTPOutput{
+				Item:             args[0],
+				Code:             code.Value,
+				RemainingSeconds: int(code.Remaining.Seconds()),
+				codeOnly:         codeOnly,
+			})
+		},
+	}
+	cmd.Flags().BoolVarP(&codeOnly, CodeOnlyFlag, CodeOnlyShortFlag, false, "only print the code")
+	return cmd
+}
+
+// parseOTPFlags returns the otp key given by either an otpauth:// uri or a base32 (totp) secret, if one was given
+func parseOTPFlags(uri, secret string) (*otp.Key, error) {
+	switch {
+	case uri != "" && secret != "":
+		return nil, fmt.Errorf("only one of --%s and --%s can be given", OTPURIFlag, OTPSecretFlag)
+	case uri != "":
+		return otp.ParseURI(uri)
+	case secret != "":
+		return otp.NewTOTPKey(secret)
+	default:
+		return nil, nil
+	}
+}
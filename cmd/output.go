@@ -0,0 +1,280 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+/*
+	Every command writes its result in the format given by the global --output flag:
+
+		table  for people - the default, which is the same as before there was a choice of format
+		json   the exported fields of the command's result type (see the types named *Output in this package)
+		yaml   exactly the same structure as json
+		csv    a header row, then one row per record (e.g. per item listed)
+
+	In any format other than table, log messages are written to stderr rather than stdout so that stdout can be
+	parsed, and errors are written to stderr as a json ErrorOutput
+*/
+
+const (
+	OutputFlag = "output"
+
+	OutputTable = "table"
+	OutputJSON  = "json"
+	OutputYAML  = "yaml"
+	OutputCSV   = "csv"
+)
+
+var ErrInvalidOutputFormat = fmt.Errorf("invalid --%s, must be one of %s", OutputFlag, strings.Join(OutputFormats(), ", "))
+
+// OutputFormats returns the formats which command output can be written in
+func OutputFormats() []string {
+	return []string{OutputTable, OutputJSON, OutputYAML, OutputCSV}
+}
+
+// ErrorOutput is written to stderr when a command fails, in any output format other than table
+type ErrorOutput struct {
+	Error string
+	// Command is the full command which failed, e.g. "simple-pass get"
+	Command string
+}
+
+// ChangeOutput is the result of commands which change an item, the passdb, or its settings
+type ChangeOutput struct {
+	// Action is what was done, e.g. added, updated, deleted, renamed
+	Action string
+	// Target is what the action was done to, e.g. the name of an item
+	Target string
+	// Details are anything else about the change, e.g. the new name of a renamed item
+	Details map[string]string `json:",omitempty"`
+
+	// message is logged in place of the change in the table format
+	message string
+}
+
+func (c ChangeOutput) columns() []string {
+	return []string{"action", "target", "details"}
+}
+
+func (c ChangeOutput) rows() [][]string {
+	details := []string{}
+	for _, key := range sortedKeys(c.Details) {
+		details = append(details, fmt.Sprintf("%s=%s", key, c.Details[key]))
+	}
+	return [][]string{{c.Action, c.Target, strings.Join(details, ",")}}
+}
+
+func (c ChangeOutput) writeText(io.Writer) error {
+	log.Info(c.message)
+	return nil
+}
+
+// output is implemented by the result of every command. In the json and yaml formats results are written as they
+// are, and in the csv and (by default) table formats as their columns and rows
+type output interface {
+	columns() []string
+	rows() [][]string
+}
+
+// textOutput is implemented by results which are written as something other than a table of their rows in the table
+// format
+type textOutput interface {
+	writeText(w io.Writer) error
+}
+
+// outputFormatValue is the value of the output flag, which must be one of OutputFormats
+type outputFormatValue string
+
+func (v *outputFormatValue) String() string {
+	return string(*v)
+}
+
+func (v *outputFormatValue) Set(value string) error {
+	for _, format := range OutputFormats() {
+		if value == format {
+			*v = outputFormatValue(value)
+			return nil
+		}
+	}
+	return ErrInvalidOutputFormat
+}
+
+func (v *outputFormatValue) Type() string {
+	return "format"
+}
+
+// addOutputFlag adds the global output flag to the root command. Log messages are moved to stderr once a format other
+// than table is given, so that they are not mixed in with the output
+func addOutputFlag(rootCmd *cobra.Command) {
+	format := outputFormatValue(OutputTable)
+	rootCmd.PersistentFlags().Var(&format, OutputFlag, fmt.Sprintf("output format - one of %s", strings.Join(OutputFormats(), ", ")))
+	rootCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		useOutputFormat(cmd)
+	}
+}
+
+// useOutputFormat moves log messages to stderr, and leaves errors to ReportError, if the command's output is for
+// parsing
+func useOutputFormat(cmd *cobra.Command) {
+	if outputFormat(cmd) != OutputTable {
+		log.SetOutput(cmd.ErrOrStderr())
+		// errors are reported by ReportError instead
+		cmd.Root().SilenceErrors = true
+		cmd.Root().SilenceUsage = true
+	}
+}
+
+// PreparseOutputFlag sets the output format from the args a root command is about to be executed with, before cobra
+// parses them. Cobra parses none of the flags given when any of them are invalid, so without this failures to parse
+// them (or to load the passdb) would be reported as text whatever the output format given
+func PreparseOutputFlag(rootCmd *cobra.Command, args []string) {
+	flag := rootCmd.PersistentFlags().Lookup(OutputFlag)
+	if flag == nil {
+		return
+	}
+	for idx, arg := range args {
+		if arg == "--" {
+			break
+		}
+		value, found := strings.CutPrefix(arg, "--"+OutputFlag+"=")
+		if !found && arg == "--"+OutputFlag && idx+1 < len(args) {
+			value, found = args[idx+1], true
+		}
+		if found {
+			// an invalid format is reported when the args are parsed
+			_ = flag.Value.Set(value)
+		}
+	}
+	useOutputFormat(rootCmd)
+}
+
+// outputFormat returns the output format given to a command. The output flag is a persistent flag of the root
+// command, so is looked up there in case the command's own flags have not been merged with it yet
+func outputFormat(cmd *cobra.Command) string {
+	flag := cmd.Root().PersistentFlags().Lookup(OutputFlag)
+	if flag == nil {
+		return OutputTable
+	}
+	return flag.Value.String()
+}
+
+// machineOutput returns whether the command's output is for parsing, rather than for people
+func machineOutput(cmd *cobra.Command) bool {
+	return outputFormat(cmd) != OutputTable
+}
+
+// writeOutput writes the result of a command to its stdout, in the output format given to it
+func writeOutput(cmd *cobra.Command, result output) error {
+	w := cmd.OutOrStdout()
+	switch outputFormat(cmd) {
+	case OutputJSON:
+		serialised, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(serialised))
+		return err
+	case OutputYAML:
+		serialised, err := marshalYAML(result)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(serialised)
+		return err
+	case OutputCSV:
+		csvWriter := csv.NewWriter(w)
+		err := csvWriter.Write(result.columns())
+		if err != nil {
+			return err
+		}
+		err = csvWriter.WriteAll(result.rows())
+		if err != nil {
+			return err
+		}
+		return csvWriter.Error()
+	default:
+		if text, ok := result.(textOutput); ok {
+			return text.writeText(w)
+		}
+		return writeTable(w, result)
+	}
+}
+
+// writeTable writes the rows of a result beneath a header of its upper cased columns
+func writeTable(w io.Writer, result output) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	header := make([]string, 0, len(result.columns()))
+	for _, column := range result.columns() {
+		header = append(header, strings.ToUpper(strings.ReplaceAll(column, "_", " ")))
+	}
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range result.rows() {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// marshalYAML writes the result as yaml with exactly the same structure, keys and order as its json. json is a subset
+// of yaml, so the json is parsed as yaml and written back out in yaml's own (block) style
+func marshalYAML(result interface{}) ([]byte, error) {
+	serialised, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	var document yaml.Node
+	err = yaml.Unmarshal(serialised, &document)
+	if err != nil {
+		return nil, err
+	}
+	resetYAMLStyle(&document)
+	return yaml.Marshal(&document)
+}
+
+func resetYAMLStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		resetYAMLStyle(child)
+	}
+}
+
+// ReportError writes the error a command failed with to its stderr, as an ErrorOutput in any output format other
+// than table. In the table format errors are reported by cobra
+func ReportError(cmd *cobra.Command, err error) {
+	if cmd == nil || err == nil || !machineOutput(cmd) {
+		return
+	}
+	serialised, marshalErr := json.Marshal(ErrorOutput{Error: strings.TrimSpace(err.Error()), Command: cmd.CommandPath()})
+	if marshalErr != nil {
+		fmt.Fprintln(cmd.ErrOrStderr(), err)
+		return
+	}
+	fmt.Fprintln(cmd.ErrOrStderr(), string(serialised))
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// formatOutputTime formats times in results written as text, leaving times which were never set empty
+func formatOutputTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
@@ -76,6 +76,21 @@ func GetPassDBCachePath() string {
 	return path
 }
 
+// readPassDBCache reads the cache of the current active passdb
+func readPassDBCache() (*passDBCache, error) {
+	cache, err := os.ReadFile(GetPassDBCachePath())
+	if err != nil {
+		return nil, err
+	}
+
+	var deserialised passDBCache
+	err = json.Unmarshal(cache, &deserialised)
+	if err != nil {
+		return nil, err
+	}
+	return &deserialised, nil
+}
+
 // getPassDBPath reads the current active passdb location path
 func getPassDBPath() string {
 	path := []byte{}
@@ -89,18 +104,11 @@ func getPassDBPath() string {
 		return string(path)
 	}
 
-	cache, err := os.ReadFile(GetPassDBCachePath())
+	cache, err := readPassDBCache()
 	if err != nil {
 		return resortToPromptFn(err)
 	}
-
-	var deserialised passDBCache
-	err = json.Unmarshal(cache, &deserialised)
-	if err != nil {
-		return resortToPromptFn(err)
-	}
-
-	return deserialised.DBPath
+	return cache.DBPath
 }
 
 // getPassDBPassword reads the current active passdb password from the cache
@@ -117,24 +125,33 @@ func getPassDBPassword() string {
 		return string(pass)
 	}
 
-	cache, err := os.ReadFile(GetPassDBCachePath())
+	cache, err := readPassDBCache()
 	if err != nil {
 		return resortToPromptFn(err)
 	}
+	return cache.Password
+}
 
-	var deserialised passDBCache
-	err = json.Unmarshal(cache, &deserialised)
+func loadPassDB(path, password string) (*db.PassDB, error) {
+	passDB, err := db.LoadExistingPassDB(path, password)
 	if err != nil {
-		return resortToPromptFn(err)
+		return nil, fmt.Errorf("can't load pass db at %s - %s", path, err)
 	}
-
-	return deserialised.Password
+	return passDB, nil
 }
 
-func loadPassDB(path, password string) *db.PassDB {
-	passDB, err := db.LoadExistingPassDB(path, password)
+// loadUnlockedPassDB loads the current active passdb if the cache holds everything needed to unlock it, or returns nil
+// if it is locked. Unlike loadPassDB it never prompts, so can be used where nobody is there to answer (e.g.
+// shell completion)
+func loadUnlockedPassDB() *db.PassDB {
+	cache, err := readPassDBCache()
+	if err != nil || cache.DBPath == "" || cache.Password == "" {
+		return nil
+	}
+	passDB, err := db.LoadExistingPassDB(cache.DBPath, cache.Password)
 	if err != nil {
-		log.Fatalf("can't load pass db at %s - %s", path, err)
+		log.Debugf("cannot load pass db at %s - %s", cache.DBPath, err)
+		return nil
 	}
 	return passDB
 }
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/georgewheatcroft/simple-pass/internal/db"
+	"github.com/georgewheatcroft/simple-pass/internal/item"
+	"github.com/georgewheatcroft/simple-pass/pkg/strength"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	PasswordPolicyCmdName = "password-policy"
+	MinScoreFlag          = "min-score"
+	ForceFlag             = "force"
+
+	PasswordStrengthMessage = "password strength: %d/%d (would take %s to crack)"
+	/* #nosec */
+	SuccessfullySetPasswordPolicyMessage = "minimum password score set to %d"
+)
+
+// PasswordPolicyOutput is the passdb password policy, alongside the highest score a password can have
+type PasswordPolicyOutput struct {
+	MinScore int
+	MaxScore int
+}
+
+func (o PasswordPolicyOutput) columns() []string {
+	return []string{"min_score", "max_score"}
+}
+
+func (o PasswordPolicyOutput) rows() [][]string {
+	return [][]string{{strconv.Itoa(o.MinScore), strconv.Itoa(o.MaxScore)}}
+}
+
+func (o PasswordPolicyOutput) writeText(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "minimum password score: %d/%d\n", o.MinScore, o.MaxScore)
+	return err
+}
+
+func NewPasswordPolicyCmd(passDB *db.PassDB) *cobra.Command {
+	var (
+		minScore int
+	)
+
+	cmd := &cobra.Command{
+		Use:   PasswordPolicyCmdName,
+		Short: "show or set the minimum strength of item passwords",
+		Long: fmt.Sprintf(`e.g.
+			simple-pass %s
+			simple-pass %s --%s 3
+
+			scores range from 0 (too guessable) to %d (very unguessable), and 0 accepts any password.
+			passwords weaker than the minimum are refused when adding or updating an item, unless --%s is given`,
+			PasswordPolicyCmdName, PasswordPolicyCmdName, MinScoreFlag, strength.MaxScore, ForceFlag),
+		PreRunE: passDBCacheExistsOrErr,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log.Debugf("%s called with %v", PasswordPolicyCmdName, args)
+			if !cmd.Flags().Changed(MinScoreFlag) {
+				policy, err := passDB.PasswordPolicy()
+				if err != nil {
+					return fmt.Errorf("cannot retrieve password policy: %s\n", err)
+				}
+				return writeOutput(cmd, PasswordPolicyOutput{MinScore: policy.MinScore, MaxScore: strength.MaxScore})
+			}
+
+			err := passDB.SetMinPasswordScore(minScore)
+			if err != nil {
+				return fmt.Errorf("cannot set password policy: %s\n", err)
+			}
+			return writeOutput(cmd, ChangeOutput{
+				Action:  "set-password-policy",
+				Target:  "the passdb",
+				Details: map[string]string{"min-score": strconv.Itoa(minScore)},
+				message: fmt.Sprintf(SuccessfullySetPasswordPolicyMessage, minScore),
+			})
+		},
+	}
+	cmd.Flags().IntVar(&minScore, MinScoreFlag, 0, fmt.Sprintf("lowest strength score (0-%d) item passwords can have", strength.MaxScore))
+	return cmd
+}
+
+// reportPasswordStrength reports the strength of an item's password, refusing it if it is weaker than the passdb
+// password policy allows - unless forced
+func reportPasswordStrength(passDB *db.PassDB, passItem *item.Item, force bool) error {
+	result, err := passDB.CheckPasswordStrength(passItem)
+	if result != nil {
+		logPasswordStrength(result)
+	}
+	if errors.Is(err, db.ErrPasswordTooWeak) {
+		if force {
+			log.Warnf("%s - continuing as --%s was given", err, ForceFlag)
+			return nil
+		}
+		return fmt.Errorf("%w (use --%s to set it anyway)", err, ForceFlag)
+	}
+	return err
+}
+
+func logPasswordStrength(result *strength.Result) {
+	log.Infof(PasswordStrengthMessage, result.Score, strength.MaxScore, result.CrackTimeDisplay())
+	if result.Warning != "" {
+		log.Warnf("weak password: %s", result.Warning)
+	}
+}
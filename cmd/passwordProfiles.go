@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	PasswordProfilesCmdName = "password-profiles"
+)
+
+// PasswordProfileSummary describes a password profile, and whether it is embedded or defined in the config
+type PasswordProfileSummary struct {
+	Name   string
+	Source string
+	Rules  string
+}
+
+// PasswordProfilesOutput is the result of listing the password profiles
+type PasswordProfilesOutput []PasswordProfileSummary
+
+func (o PasswordProfilesOutput) columns() []string {
+	return []string{"name", "source", "rules"}
+}
+
+func (o PasswordProfilesOutput) rows() [][]string {
+	rows := make([][]string, 0, len(o))
+	for _, profile := range o {
+		rows = append(rows, []string{profile.Name, profile.Source, profile.Rules})
+	}
+	return rows
+}
+
+func NewPasswordProfilesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   PasswordProfilesCmdName,
+		Short: "list the profiles of site password rules passwords can be generated to follow",
+		Long: fmt.Sprintf(`e.g.
+			simple-pass %s
+
+			profiles use the syntax of the passwordrules attribute - see https://developer.apple.com/password-rules/
+			define your own in %s, e.g.
+				{"passwordProfiles": {"my-bank": "minlength: 8; maxlength: 12; required: lower; required: digit;"}}
+
+			attach a profile to an item so that generating its password follows the profile's rules:
+			simple-pass %s <existing-item-name> --%s my-bank --%s`, PasswordProfilesCmdName, GetConfigPath(), UpdateCmdName, PasswordProfileFlag, GenerateFlag),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log.Debugf("%s called", PasswordProfilesCmdName)
+			profiles, err := passwordProfiles()
+			if err != nil {
+				return fmt.Errorf("cannot list password profiles: %s\n", err)
+			}
+
+			summaries := make(PasswordProfilesOutput, 0, len(profiles))
+			for _, profile := range profiles {
+				source := "embedded"
+				if profile.UserDefined {
+					source = "config"
+				}
+				summaries = append(summaries, PasswordProfileSummary{Name: profile.Name, Source: source, Rules: profile.Rules})
+			}
+			return writeOutput(cmd, summaries)
+		},
+	}
+	return cmd
+}
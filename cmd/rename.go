@@ -30,6 +30,7 @@ func NewRenameCmd(passDB *db.PassDB) *cobra.Command {
 		Short: "renames an item",
 		Long: fmt.Sprintf(`e.g.
 			simple-pass %s <item-name> --to <non-blank-name>`, RenameCmdName),
+		ValidArgsFunction: completeItemName(passDB),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			log.Debugf("%s called with %v", RenameCmdName, args)
 			if len(args) != 1 {
@@ -44,9 +45,12 @@ func NewRenameCmd(passDB *db.PassDB) *cobra.Command {
 			if err != nil {
 				return fmt.Errorf("cannot rename item %s to %s: %s\n", oldItemName, to, err)
 			}
-			log.Infof(SuccessfullyRenamedMessage, oldItemName, to)
-
-			return nil
+			return writeOutput(cmd, ChangeOutput{
+				Action:  "renamed",
+				Target:  oldItemName,
+				Details: map[string]string{"to": to},
+				message: fmt.Sprintf(SuccessfullyRenamedMessage, oldItemName, to),
+			})
 		},
 	}
 	cmd.Flags().StringVarP(&to, ToFlag, ToShortFlag, "", "name to change item to")
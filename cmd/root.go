@@ -50,6 +50,7 @@ For example:
 	cmd.Version = cliVersion
 	cmd.SetOutput(setOut)
 	cmd.SetErr(setErr)
+	addOutputFlag(cmd)
 
 	return cmd
 }
@@ -60,30 +61,73 @@ func Execute(setOut, setErr io.Writer) {
 	rootCmd := NewRootCmd(setOut, setErr)
 
 	log.SetOutput(rootCmd.OutOrStdout())
+	PreparseOutputFlag(rootCmd, os.Args[1:])
 	var passDB *db.PassDB
-	if passDBCacheExists() {
-		passDB = loadPassDB(getPassDBPath(), getPassDBPassword())
-
+	if isCompletionRequest(os.Args[1:]) {
+		// completing must never wait on a prompt for the password, or mix logs in with the completions
+		log.SetOutput(io.Discard)
+		passDB = loadUnlockedPassDB()
+	} else if passDBCacheExists() {
+		var err error
+		passDB, err = loadPassDB(getPassDBPath(), getPassDBPassword())
+		if err != nil {
+			// reported as an error of the command which cannot be run without the passdb
+			rootCmd.AddCommand(newCommands(nil)...)
+			failedCmd, _, findErr := rootCmd.Find(os.Args[1:])
+			if findErr != nil {
+				failedCmd = rootCmd
+			}
+			if !machineOutput(failedCmd) {
+				log.Fatalln(err)
+			}
+			ReportError(failedCmd, err)
+			os.Exit(1)
+		}
 	}
 
 	//add all of the commands currently in use before exec (TODO tidy up with command groups?)
 	// - opportunity to dynamically load commands based on os.Args if required
-	rootCmd.AddCommand(
+	rootCmd.AddCommand(newCommands(passDB)...)
+
+	executedCmd, err := rootCmd.ExecuteC()
+	if err != nil {
+		// err printing logging appears to be handled by cobra fw, other than for machine readable output
+		ReportError(executedCmd, err)
+		os.Exit(1)
+	}
+}
+
+// newCommands returns every command, all using the passDB given
+func newCommands(passDB *db.PassDB) []*cobra.Command {
+	return []*cobra.Command{
 		NewCreatePassDbCmd(),
 		NewLoadPassDbCmd(),
 		NewAddCmd(passDB),
+		NewAddTypedCmd(passDB),
 		NewGetCmd(passDB),
 		NewStatusCmd(passDB),
 		NewListCmd(passDB),
 		NewUpdateCmd(passDB),
+		NewEditCmd(passDB),
 		NewRenameCmd(passDB),
 		NewDeleteCmd(passDB),
-	)
-
-	err := rootCmd.Execute()
-	if err != nil {
-		// err printing logging appears to be handled by cobra fw - just exit
-
-		os.Exit(1)
+		NewAttachCmd(passDB),
+		NewAttachmentsCmd(passDB),
+		NewExtractCmd(passDB),
+		NewOTPCmd(passDB),
+		NewFindURLCmd(passDB),
+		NewExpiringCmd(passDB),
+		NewRotationPolicyCmd(passDB),
+		NewGenerateCmd(),
+		NewPasswordProfilesCmd(),
+		NewPasswordPolicyCmd(passDB),
+		NewAuditCmd(passDB),
+		NewBreachCheckCmd(passDB),
+		NewSearchCmd(passDB),
+		NewUICmd(passDB),
+		NewShellCmd(passDB),
+		NewBatchCmd(passDB),
+		NewImportCmd(passDB),
+		NewExportCmd(passDB),
 	}
 }
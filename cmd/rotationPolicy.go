@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/georgewheatcroft/simple-pass/internal/db"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	RotationPolicyCmdName = "rotation-policy"
+	DaysFlag              = "days"
+	DaysShortFlag         = "d"
+	FolderFlag            = "folder"
+
+	SuccessfullySetRotationPolicyMessage = "rotation policy for %s set to %d days"
+)
+
+// FolderRotationPolicy is the rotation policy set on a folder
+type FolderRotationPolicy struct {
+	Folder string
+	Days   int
+}
+
+// RotationPoliciesOutput is the rotation policies set on the passdb. PassDBDays is 0 when there is no passdb wide
+// policy
+type RotationPoliciesOutput struct {
+	PassDBDays int
+	Folders    []FolderRotationPolicy
+}
+
+func (o RotationPoliciesOutput) columns() []string {
+	return []string{"scope", "days"}
+}
+
+func (o RotationPoliciesOutput) rows() [][]string {
+	rows := [][]string{{"passdb", strconv.Itoa(o.PassDBDays)}}
+	for _, policy := range o.Folders {
+		rows = append(rows, []string{"folder " + policy.Folder, strconv.Itoa(policy.Days)})
+	}
+	return rows
+}
+
+func (o RotationPoliciesOutput) writeText(w io.Writer) error {
+	if o.PassDBDays > 0 {
+		fmt.Fprintf(w, "passdb: %d days\n", o.PassDBDays)
+	} else {
+		fmt.Fprintln(w, "passdb: none")
+	}
+	for _, policy := range o.Folders {
+		_, err := fmt.Fprintf(w, "folder %s: %d days\n", policy.Folder, policy.Days)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func NewRotationPolicyCmd(passDB *db.PassDB) *cobra.Command {
+	var (
+		days   int
+		folder string
+	)
+
+	cmd := &cobra.Command{
+		Use:   RotationPolicyCmdName,
+		Short: "show or set how many days passwords can go unchanged before they must be rotated",
+		Long: fmt.Sprintf(`e.g.
+			simple-pass %s
+			simple-pass %s --days 90
+			simple-pass %s --folder prod --days 30
+			simple-pass %s --folder prod --days 0
+
+			the most specific policy applies - an item's own policy (set using: simple-pass %s <item> --%s <days>),
+			then that of its nearest folder, then the passdb wide policy. 0 days removes a policy`,
+			RotationPolicyCmdName, RotationPolicyCmdName, RotationPolicyCmdName, RotationPolicyCmdName, UpdateCmdName, RotationDaysFlag),
+		PreRunE: passDBCacheExistsOrErr,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log.Debugf("%s called with %v", RotationPolicyCmdName, args)
+			if !cmd.Flags().Changed(DaysFlag) {
+				if cmd.Flags().Changed(FolderFlag) {
+					return fmt.Errorf("--%s is required when setting a folder policy", DaysFlag)
+				}
+				return printRotationPolicies(cmd, passDB)
+			}
+
+			var err error
+			target := "the passdb"
+			if cmd.Flags().Changed(FolderFlag) {
+				target = "folder " + folder
+				err = passDB.SetFolderRotationPolicy(folder, days)
+			} else {
+				err = passDB.SetVaultRotationPolicy(days)
+			}
+			if err != nil {
+				return fmt.Errorf("cannot set rotation policy: %s\n", err)
+			}
+			return writeOutput(cmd, ChangeOutput{
+				Action:  "set-rotation-policy",
+				Target:  target,
+				Details: map[string]string{"days": strconv.Itoa(days)},
+				message: fmt.Sprintf(SuccessfullySetRotationPolicyMessage, target, days),
+			})
+		},
+	}
+	cmd.Flags().IntVarP(&days, DaysFlag, DaysShortFlag, 0, "number of days passwords can go unchanged (0 removes the policy)")
+	cmd.Flags().StringVar(&folder, FolderFlag, "", "folder to set the policy for (defaults to the whole passdb)")
+	return cmd
+}
+
+func printRotationPolicies(cmd *cobra.Command, passDB *db.PassDB) error {
+	policies, err := passDB.RotationPolicies()
+	if err != nil {
+		return fmt.Errorf("cannot retrieve rotation policies: %s\n", err)
+	}
+	folders := make([]string, 0, len(policies.FolderDays))
+	for folder := range policies.FolderDays {
+		folders = append(folders, folder)
+	}
+	sort.Strings(folders)
+	result := RotationPoliciesOutput{PassDBDays: policies.VaultDays, Folders: []FolderRotationPolicy{}}
+	for _, folder := range folders {
+		result.Folders = append(result.Folders, FolderRotationPolicy{Folder: folder, Days: policies.FolderDays[folder]})
+	}
+	return writeOutput(cmd, result)
+}
@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/georgewheatcroft/simple-pass/internal/db"
+	"github.com/georgewheatcroft/simple-pass/internal/item"
+	"github.com/georgewheatcroft/simple-pass/pkg/fuzzy"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	SearchCmdName    = "search"
+	IncludeNotesFlag = "include-notes"
+	LimitFlag        = "limit"
+	ColorFlag        = "color"
+
+	ColorAuto   = "auto"
+	ColorAlways = "always"
+	ColorNever  = "never"
+
+	defaultSearchLimit = 10
+	// maxSuggestions is how many similarly named items are suggested when an item does not exist
+	maxSuggestions = 3
+
+	highlightStart = "\x1b[1;4m"
+	highlightEnd   = "\x1b[0m"
+
+	ResolvedItemMessage = "no item named '%s' - using '%s'\n"
+)
+
+var (
+	ErrNoSearchResults = fmt.Errorf("no items match the search")
+	ErrInvalidColor    = fmt.Errorf("invalid --%s, must be one of %s, %s or %s", ColorFlag, ColorAuto, ColorAlways, ColorNever)
+)
+
+// SearchOutput is the result of searching items, from the best to the worst match. Match ranges are the rune offsets
+// of the matched parts of each value
+type SearchOutput []db.SearchResult
+
+func (o SearchOutput) columns() []string {
+	return []string{"name", "score", "matches"}
+}
+
+func (o SearchOutput) rows() [][]string {
+	return searchRows(o, false)
+}
+
+// highlightedSearchOutput is written with the matched parts of each value highlighted for a terminal
+type highlightedSearchOutput struct {
+	SearchOutput
+}
+
+func (o highlightedSearchOutput) rows() [][]string {
+	return searchRows(o.SearchOutput, true)
+}
+
+func searchRows(results SearchOutput, highlight bool) [][]string {
+	rows := make([][]string, 0, len(results))
+	for _, result := range results {
+		matches := make([]string, 0, len(result.Matches))
+		for _, match := range result.Matches {
+			value := match.Value
+			if highlight {
+				value = fuzzy.Highlight(value, match.Ranges, highlightStart, highlightEnd)
+			}
+			matches = append(matches, fmt.Sprintf("%s=%s", match.Field, value))
+		}
+		rows = append(rows, []string{result.ItemName, strconv.Itoa(result.Score), strings.Join(matches, ", ")})
+	}
+	return rows
+}
+
+func NewSearchCmd(passDB *db.PassDB) *cobra.Command {
+	var (
+		includeNotes bool
+		limit        int
+		color        string
+	)
+
+	cmd := &cobra.Command{
+		Use:   SearchCmdName,
+		Short: "fuzzy search the names, usernames, urls and tags of the items in your simple-pass",
+		Long: fmt.Sprintf(`e.g.
+			simple-pass %s github
+			simple-pass %s "gihtub work"
+			simple-pass %s recovery --%s
+
+			items are listed from the best to the worst match, with the matching parts highlighted. queries of
+			several words only match items which match every word`, SearchCmdName, SearchCmdName, SearchCmdName, IncludeNotesFlag),
+		PreRunE: passDBCacheExistsOrErr,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log.Debugf("%s called with %v", SearchCmdName, args)
+			if len(args) == 0 {
+				err := cmd.Help()
+				if err != nil {
+					return fmt.Errorf("attempting to show help prompt caused error: %s\n", err)
+				}
+				return fmt.Errorf("expected a search query")
+			}
+			if limit < 0 {
+				return fmt.Errorf("--%s cannot be negative\n", LimitFlag)
+			}
+			highlight, err := shouldHighlight(color, cmd.OutOrStdout())
+			if err != nil {
+				return err
+			}
+
+			fields := db.DefaultSearchFields()
+			if includeNotes {
+				fields = append(fields, db.SearchFieldNotes)
+			}
+			results, err := passDB.Search(strings.Join(args, " "), fields...)
+			if err != nil {
+				return fmt.Errorf("cannot search items: %s\n", err)
+			}
+			// no results is an error so that scripts can easily tell nothing matched
+			if len(results) == 0 {
+				return ErrNoSearchResults
+			}
+			if limit > 0 && len(results) > limit {
+				results = results[:limit]
+			}
+
+			var found output = SearchOutput(results)
+			// escape codes are only ever written for people to read
+			if highlight && !machineOutput(cmd) {
+				found = highlightedSearchOutput{SearchOutput(results)}
+			}
+			return writeOutput(cmd, found)
+		},
+	}
+	cmd.Flags().BoolVar(&includeNotes, IncludeNotesFlag, false, "search the notes of items too")
+	cmd.Flags().IntVar(&limit, LimitFlag, defaultSearchLimit, "most items to list (0 lists every match)")
+	cmd.Flags().StringVar(&color, ColorFlag, ColorAuto, fmt.Sprintf("highlight matches - %s, %s or %s (only when writing to a terminal)", ColorAlways, ColorNever, ColorAuto))
+	return cmd
+}
+
+// shouldHighlight returns whether output written to w should be highlighted with terminal escape codes
+func shouldHighlight(color string, w io.Writer) (bool, error) {
+	switch color {
+	case ColorAlways:
+		return true, nil
+	case ColorNever:
+		return false, nil
+	case ColorAuto:
+		fh, ok := w.(*os.File)
+		if !ok {
+			return false, nil
+		}
+		info, err := fh.Stat()
+		if err != nil {
+			return false, nil
+		}
+		return info.Mode()&os.ModeCharDevice != 0, nil
+	default:
+		return false, ErrInvalidColor
+	}
+}
+
+// retrieveClosestItem is used when no item has the given name. If exactly one item name is a strong match for it,
+// that item is retrieved (saying so on w) - otherwise the error suggests the closest matching names, if any
+func retrieveClosestItem(passDB *db.PassDB, name string, w io.Writer) (*item.Item, error) {
+	results, err := passDB.Search(name, db.SearchFieldName)
+	if err != nil {
+		return nil, err
+	}
+
+	strong := []db.SearchResult{}
+	for _, result := range results {
+		if result.Score >= fuzzy.StrongScore {
+			strong = append(strong, result)
+		}
+	}
+	if len(strong) == 1 {
+		fmt.Fprintf(w, ResolvedItemMessage, name, strong[0].ItemName)
+		return passDB.RetrieveItem(strong[0].ItemName)
+	}
+
+	if len(results) == 0 {
+		return nil, db.ErrItemDoesNotExist
+	}
+	if len(results) > maxSuggestions {
+		results = results[:maxSuggestions]
+	}
+	suggestions := make([]string, 0, len(results))
+	for _, result := range results {
+		suggestions = append(suggestions, fmt.Sprintf("'%s'", result.ItemName))
+	}
+	return nil, fmt.Errorf("%w - did you mean %s?", db.ErrItemDoesNotExist, strings.Join(suggestions, " or "))
+}
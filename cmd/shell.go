@@ -0,0 +1,485 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/georgewheatcroft/simple-pass/internal/db"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+const (
+	ShellCmdName    = "shell"
+	IdleTimeoutFlag = "idle-timeout"
+
+	defaultShellIdleTimeout = 5 * time.Minute
+
+	ShellLockedMessage       = "locked after %s without input - the password is needed to run anything else"
+	ShellUnavailableMessage  = "'%s' cannot be run in the %s"
+	ShellUnlockFailedMessage = "cannot unlock: %s"
+	shellPasswordPrompt      = "password: "
+)
+
+var (
+	ErrUnterminatedQuote   = errors.New("unterminated quote")
+	ErrNegativeIdleTimeout = errors.New("the idle timeout cannot be negative")
+)
+
+// shellUnavailableCmds cannot be run from within the shell - they would start another shell, or change which passdb is
+// in use underneath it
+var shellUnavailableCmds = []string{ShellCmdName, CreatePassDBCmdName, LoadPassDBCmdName}
+
+// shellExitCmds end the shell, as does the end of its input (e.g. ctrl-d)
+var shellExitCmds = []string{"exit", "quit"}
+
+func NewShellCmd(passDB *db.PassDB) *cobra.Command {
+	var idleTimeout time.Duration
+
+	cmd := &cobra.Command{
+		Use:   ShellCmdName,
+		Short: "run commands against your simple-pass, unlocking it only once",
+		Long: fmt.Sprintf(`e.g.
+			simple-pass %s
+			simple-pass %s --%s 1m
+			simple-pass %s < commands.txt
+
+			reads commands (without the leading simple-pass) a line at a time, running them against the passdb unlocked
+			when the shell started - rather than unlocking it again for every command. '%s' or ctrl-d ends the shell.
+
+			once nothing has been entered for the idle timeout (%s by default, 0 never locks) the passdb is locked,
+			and its password must be entered before anything else is run. when the shell ends, the unlocked passdb is
+			cleared from memory.
+
+			in a terminal, previous commands can be recalled with up/down and completed with tab. the history is only
+			kept in memory for the session, as commands may hold secrets.
+
+			not available in the shell: %s`,
+			ShellCmdName, ShellCmdName, IdleTimeoutFlag, ShellCmdName, shellExitCmds[0], defaultShellIdleTimeout,
+			strings.Join(shellUnavailableCmds, ", ")),
+		Args:    cobra.NoArgs,
+		PreRunE: passDBCacheExistsOrErr,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log.Debugf("%s called", ShellCmdName)
+			if idleTimeout < 0 {
+				return fmt.Errorf("cannot start %s: %s\n", ShellCmdName, ErrNegativeIdleTimeout)
+			}
+			session := &shellSession{
+				cmd:         cmd,
+				passDB:      passDB,
+				path:        passDB.Path(),
+				idleTimeout: idleTimeout,
+			}
+			defer session.lock("")
+
+			in := cmd.InOrStdin()
+			if file, ok := in.(*os.File); ok && term.IsTerminal(int(file.Fd())) {
+				lines, err := newTerminalLines(file, cmd.OutOrStdout(), passDB.GetPassDBName()+"> ")
+				if err != nil {
+					return fmt.Errorf("cannot start %s: %s\n", ShellCmdName, err)
+				}
+				lines.terminal.AutoCompleteCallback = session.complete
+				session.lines = lines
+			} else {
+				session.lines = &plainLines{reader: bufio.NewReader(in), out: cmd.OutOrStdout()}
+			}
+
+			err := session.run()
+			if err != nil {
+				return fmt.Errorf("%s ended: %s\n", ShellCmdName, err)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().DurationVar(&idleTimeout, IdleTimeoutFlag, defaultShellIdleTimeout, "how long the shell can go without input before the passdb is locked (0 never locks)")
+	return cmd
+}
+
+// shellLines reads the lines entered into the shell
+type shellLines interface {
+	readLine() (string, error)
+	readPassword(prompt string) (string, error)
+	// notify tells whoever is entering lines something, while they may be part way through a line
+	notify(message string)
+}
+
+// shellSession holds the passdb for as long as the shell runs, or until it is locked
+type shellSession struct {
+	cmd         *cobra.Command
+	lines       shellLines
+	path        string
+	idleTimeout time.Duration
+	// idle locks the passdb once it fires, and only runs while a line is being read
+	idle *time.Timer
+
+	// mu guards passDB, which is nil while the shell is locked
+	mu     sync.Mutex
+	passDB *db.PassDB
+}
+
+// run reads and runs commands until the input ends or the shell is exited
+func (s *shellSession) run() error {
+	for {
+		line, err := s.readLine()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		args, err := splitArgs(line)
+		if err != nil {
+			fmt.Fprintln(s.cmd.ErrOrStderr(), err)
+			continue
+		}
+		if len(args) == 0 {
+			continue
+		}
+		if contains(shellExitCmds, args[0]) {
+			return nil
+		}
+
+		passDB, err := s.unlocked()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			fmt.Fprintf(s.cmd.ErrOrStderr(), ShellUnlockFailedMessage+"\n", err)
+			continue
+		}
+		s.execute(passDB, args)
+	}
+}
+
+// readLine reads the next line, locking the passdb if it takes longer than the idle timeout
+func (s *shellSession) readLine() (string, error) {
+	if s.idleTimeout == 0 {
+		return s.lines.readLine()
+	}
+	locked := make(chan struct{})
+	s.idle = time.AfterFunc(s.idleTimeout, func() {
+		defer close(locked)
+		s.lock(fmt.Sprintf(ShellLockedMessage, s.idleTimeout))
+	})
+	line, err := s.lines.readLine()
+	// a command must never run while the passdb is being locked underneath it
+	if !s.idle.Stop() {
+		<-locked
+	}
+	s.idle = nil
+	return line, err
+}
+
+// active restarts the idle timeout while a line is being read, e.g. as keys are pressed
+func (s *shellSession) active() {
+	if s.idle != nil && s.idle.Stop() {
+		s.idle.Reset(s.idleTimeout)
+	}
+}
+
+// lock clears the unlocked passdb from memory, if it is unlocked, notifying the message given
+func (s *shellSession) lock(message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.passDB == nil {
+		return
+	}
+	s.passDB.Close()
+	s.passDB = nil
+	if message != "" {
+		s.lines.notify(message)
+	}
+}
+
+// unlocked returns the unlocked passdb, first asking for the password if it has been locked
+func (s *shellSession) unlocked() (*db.PassDB, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.passDB != nil {
+		return s.passDB, nil
+	}
+	password, err := s.lines.readPassword(shellPasswordPrompt)
+	if err != nil {
+		return nil, err
+	}
+	s.passDB, err = db.LoadExistingPassDB(s.path, password)
+	return s.passDB, err
+}
+
+// execute runs the command given by the args, just as if it had been run outside of the shell
+func (s *shellSession) execute(passDB *db.PassDB, args []string) {
+	if contains(shellUnavailableCmds, args[0]) {
+		fmt.Fprintf(s.cmd.ErrOrStderr(), ShellUnavailableMessage+"\n", args[0], ShellCmdName)
+		return
+	}
+	// the output flag may move logging for the one command
+	logOutput := log.StandardLogger().Out
+	defer log.SetOutput(logOutput)
+
+	// values are written without a new line after them (e.g. get --password), which would run into the next prompt
+	out := &lineEndingWriter{Writer: s.cmd.OutOrStdout()}
+	defer out.endLine()
+
+	rootCmd := s.newRootCmd(passDB, out, s.cmd.ErrOrStderr())
+	rootCmd.SetArgs(args)
+	executedCmd, err := rootCmd.ExecuteContextC(s.cmd.Context())
+	if err != nil {
+		ReportError(executedCmd, err)
+	}
+}
+
+// lineEndingWriter remembers whether what was last written to it ended the line
+type lineEndingWriter struct {
+	io.Writer
+	midLine bool
+}
+
+func (w *lineEndingWriter) Write(p []byte) (int, error) {
+	if len(p) > 0 {
+		w.midLine = p[len(p)-1] != '\n'
+	}
+	return w.Writer.Write(p)
+}
+
+// endLine ends the line, if anything written has not
+func (w *lineEndingWriter) endLine() {
+	if w.midLine {
+		fmt.Fprintln(w.Writer)
+	}
+}
+
+func (s *shellSession) newRootCmd(passDB *db.PassDB, out, errOut io.Writer) *cobra.Command {
+	rootCmd := NewRootCmd(out, errOut)
+	rootCmd.SetIn(s.cmd.InOrStdin())
+	for _, cmd := range newCommands(passDB) {
+		if !contains(shellUnavailableCmds, cmd.Name()) {
+			rootCmd.AddCommand(cmd)
+		}
+	}
+	return rootCmd
+}
+
+// complete is called for each key pressed in the terminal, completing the word before the cursor when tab is pressed
+// just as the shell would complete it outside of the shell
+func (s *shellSession) complete(line string, pos int, key rune) (string, int, bool) {
+	// this is called from the terminal while the line is being read
+	s.active()
+	if key != '\t' {
+		return "", 0, false
+	}
+	toComplete := line[strings.LastIndex(line[:pos], " ")+1 : pos]
+	args, err := splitArgs(line[:pos-len(toComplete)])
+	if err != nil {
+		return "", 0, false
+	}
+	// nothing is completed while locked, as completing must never ask for the password. the passdb is held until
+	// completing is done, so that it cannot be locked (and closed) underneath the completion
+	s.mu.Lock()
+	completions, directive := s.completions(s.passDB, append(args, toComplete))
+	s.mu.Unlock()
+	matching := []string{}
+	for _, completion := range completions {
+		if strings.HasPrefix(completion, toComplete) {
+			matching = append(matching, completion)
+		}
+	}
+
+	var completed string
+	switch {
+	case len(matching) == 0:
+		return "", 0, false
+	case len(matching) == 1:
+		completed = matching[0]
+		if directive&cobra.ShellCompDirectiveNoSpace == 0 {
+			completed += " "
+		}
+	default:
+		completed = commonPrefix(matching)
+		if completed == toComplete {
+			s.lines.notify(strings.Join(matching, "  "))
+			return "", 0, false
+		}
+	}
+	start := pos - len(toComplete)
+	return line[:start] + completed + line[pos:], start + len(completed), true
+}
+
+// completions asks cobra for the completions of the last arg
+func (s *shellSession) completions(passDB *db.PassDB, args []string) ([]string, cobra.ShellCompDirective) {
+	// completing an item may log it
+	logOutput := log.StandardLogger().Out
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(logOutput)
+
+	var out bytes.Buffer
+	rootCmd := s.newRootCmd(passDB, &out, io.Discard)
+	rootCmd.SetArgs(append([]string{cobra.ShellCompNoDescRequestCmd}, args...))
+	err := rootCmd.Execute()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	completions := []string{}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		if directive, found := strings.CutPrefix(line, ":"); found {
+			value, err := strconv.Atoi(directive)
+			if err != nil {
+				return nil, cobra.ShellCompDirectiveError
+			}
+			return completions, cobra.ShellCompDirective(value)
+		}
+		completions = append(completions, line)
+	}
+	return completions, cobra.ShellCompDirectiveDefault
+}
+
+// splitArgs splits a line into args as a posix shell would, honouring quotes and backslash escapes
+func splitArgs(line string) ([]string, error) {
+	args := []string{}
+	var (
+		current strings.Builder
+		inArg   bool
+		quote   rune
+		escaped bool
+	)
+	for _, r := range line {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\\':
+			escaped, inArg = true, true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, ErrUnterminatedQuote
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+func commonPrefix(values []string) string {
+	prefix := values[0]
+	for _, value := range values[1:] {
+		for !strings.HasPrefix(value, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// terminalLines reads lines from a terminal, with history and completion. The terminal is only put into raw mode while
+// a line is being read, so that commands run in between see it as they would outside of the shell
+type terminalLines struct {
+	fd       int
+	terminal *term.Terminal
+}
+
+func newTerminalLines(in *os.File, out io.Writer, prompt string) (*terminalLines, error) {
+	fd := int(in.Fd())
+	terminal := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{in, out}, prompt)
+	width, height, err := term.GetSize(fd)
+	if err != nil {
+		return nil, err
+	}
+	err = terminal.SetSize(width, height)
+	if err != nil {
+		return nil, err
+	}
+	return &terminalLines{fd: fd, terminal: terminal}, nil
+}
+
+func (t *terminalLines) readLine() (string, error) {
+	return t.raw(t.terminal.ReadLine)
+}
+
+func (t *terminalLines) readPassword(prompt string) (string, error) {
+	return t.raw(func() (string, error) { return t.terminal.ReadPassword(prompt) })
+}
+
+func (t *terminalLines) notify(message string) {
+	_, _ = t.terminal.Write([]byte(message + "\n"))
+}
+
+func (t *terminalLines) raw(read func() (string, error)) (string, error) {
+	state, err := term.MakeRaw(t.fd)
+	if err != nil {
+		return "", err
+	}
+	defer term.Restore(t.fd, state)
+	// the terminal may have been resized while a command ran
+	if width, height, err := term.GetSize(t.fd); err == nil {
+		_ = t.terminal.SetSize(width, height)
+	}
+	return read()
+}
+
+// plainLines reads lines from anything other than a terminal, e.g. a file of commands
+type plainLines struct {
+	reader *bufio.Reader
+	out    io.Writer
+}
+
+func (p *plainLines) readLine() (string, error) {
+	line, err := p.reader.ReadString('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func (p *plainLines) readPassword(prompt string) (string, error) {
+	fmt.Fprint(p.out, prompt)
+	return p.readLine()
+}
+
+func (p *plainLines) notify(message string) {
+	fmt.Fprintln(p.out, message)
+}
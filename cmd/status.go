@@ -2,17 +2,46 @@ package cmd
 
 import (
 	"fmt"
+	"io"
+	"strconv"
 	"text/template"
+	"time"
 
 	"github.com/georgewheatcroft/simple-pass/internal/db"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
+// Status is the result of the status command
 type Status struct {
-	PassDBSet  bool
-	PassDBName string
-	TotalItems int
+	PassDBSet          bool
+	PassDBName         string
+	TotalItems         int
+	OverdueForRotation int
+}
+
+func (s Status) columns() []string {
+	return []string{"passdb_set", "passdb_name", "total_items", "overdue_for_rotation"}
+}
+
+func (s Status) rows() [][]string {
+	return [][]string{{strconv.FormatBool(s.PassDBSet), s.PassDBName, strconv.Itoa(s.TotalItems), strconv.Itoa(s.OverdueForRotation)}}
+}
+
+func (s Status) writeText(w io.Writer) error {
+	if !s.PassDBSet {
+		log.Println(PassDBNotLoadedMsg)
+		return nil
+	}
+	tmpl, err := template.New("status").Parse(statusTmpl)
+	if err != nil {
+		return fmt.Errorf("cant parse template: %s\n", err)
+	}
+	err = tmpl.Execute(w, s)
+	if err != nil {
+		return fmt.Errorf("failed to execute template: %s\n", err)
+	}
+	return nil
 }
 
 const (
@@ -23,6 +52,7 @@ const (
 {{- if .PassDBSet }}
 passDB Name: {{ .PassDBName }}
 TotalItems: {{ .TotalItems }}
+OverdueForRotation: {{ .OverdueForRotation }}
 {{- end }}
 `
 )
@@ -39,20 +69,17 @@ func NewStatusCmd(passDB *db.PassDB) *cobra.Command {
 			var status Status
 			if passDB != nil {
 				status = Status{PassDBSet: true, PassDBName: passDB.GetPassDBName(), TotalItems: len(passDB.ListAllItems())}
-			} else {
-				log.Println(PassDBNotLoadedMsg)
-				return nil
-			}
-
-			tmpl, err := template.New("status").Parse(statusTmpl)
-			if err != nil {
-				return fmt.Errorf("cant parse template: %s\n", err)
-			}
-			err = tmpl.Execute(cmd.OutOrStdout(), status)
-			if err != nil {
-				return fmt.Errorf("failed to execute template: %s\n", err)
+				report, err := passDB.RotationReport(time.Now(), 0)
+				if err != nil {
+					return fmt.Errorf("cannot determine items overdue for rotation: %s\n", err)
+				}
+				for _, rotationStatus := range report {
+					if rotationStatus.Overdue {
+						status.OverdueForRotation++
+					}
+				}
 			}
-			return nil
+			return writeOutput(cmd, status)
 		},
 	}
 	return cmd
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/georgewheatcroft/simple-pass/internal/db"
+	"github.com/georgewheatcroft/simple-pass/internal/tui"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	UICmdName = "ui"
+)
+
+func NewUICmd(passDB *db.PassDB) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   UICmdName,
+		Short: "browse and edit your simple-pass in a full screen terminal ui",
+		Long: fmt.Sprintf(`e.g.
+			simple-pass %s
+
+			keys:
+				j/k or up/down   move between items
+				/                search items (esc clears the search)
+				r                reveal the password and concealed fields of the selected item
+				c / u            copy the password / username of the selected item
+				a / e / n / d    add / edit / rename / delete an item
+				q or ctrl-c      quit
+
+			copied values are cleared from the clipboard after a while (%s, unless clipboardClearSeconds is set in
+			%s), or when the ui is quit - unless something else has been copied since`,
+			UICmdName, defaultClipboardClearAfter, GetConfigPath()),
+		Args:    cobra.NoArgs,
+		PreRunE: passDBCacheExistsOrErr,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log.Debugf("%s called", UICmdName)
+			clearAfter, err := clipboardClearAfter()
+			if err != nil {
+				return fmt.Errorf("cannot load config: %s\n", err)
+			}
+			// the ui reports that nothing can be copied when there is no clipboard, rather than refusing to start
+			provider, err := DetectClipboard(cmd.OutOrStdout())
+			if err != nil {
+				log.Debugf("no clipboard available to the ui: %s", err)
+				provider = nil
+			}
+
+			// logging would draw over the ui
+			logOutput := log.StandardLogger().Out
+			log.SetOutput(io.Discard)
+			defer log.SetOutput(logOutput)
+
+			err = tui.Run(cmd.Context(), passDB, provider, clearAfter, os.Stdin, cmd.OutOrStdout())
+			if err != nil {
+				return fmt.Errorf("cannot run ui: %s\n", err)
+			}
+			return nil
+		},
+	}
+	return cmd
+}
@@ -5,12 +5,21 @@ import (
 	"strings"
 
 	"github.com/georgewheatcroft/simple-pass/internal/db"
+	"github.com/georgewheatcroft/simple-pass/internal/item"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
 const (
-	UpdateCmdName = "update"
+	UpdateCmdName   = "update"
+	RemoveFieldFlag = "remove-field"
+	RemoveOTPFlag   = "remove-otp"
+
+	RemoveMatchURLFlag = "remove-match-url"
+	RemoveTagFlag      = "remove-tag"
+	RotationDaysFlag   = "rotation-days"
+
+	SuccessfullyUpdatedMessage = "updated item:'%s'"
 )
 
 func NewUpdateCmd(passDB *db.PassDB) *cobra.Command {
@@ -19,6 +28,21 @@ func NewUpdateCmd(passDB *db.PassDB) *cobra.Command {
 		itemPassword string
 		itemNotes    []string
 		itemURL      string
+		itemFields   []string
+		itemSecrets  []string
+		removeFields []string
+		otpURI       string
+		otpSecret    string
+		removeOTP    bool
+		matchURLs    []string
+		removeURLs   []string
+		tags         []string
+		removeTags   []string
+		rotationDays int
+		generatePass bool
+		generator    generatorFlags
+		profile      string
+		force        bool
 	)
 
 	cmd := &cobra.Command{
@@ -27,7 +51,8 @@ func NewUpdateCmd(passDB *db.PassDB) *cobra.Command {
 		Long: fmt.Sprintf(`e.g.
 			simple-pass %s <existing-item-name> --url <new value> 
 			simple-pass %s <existing-item-name> --notes <new value> --password <new value>`, UpdateCmdName, UpdateCmdName),
-		PreRunE: passDBCacheExistsOrErr,
+		PreRunE:           passDBCacheExistsOrErr,
+		ValidArgsFunction: completeItemName(passDB),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			log.Debugf("%s called with %v", UpdateCmdName, args)
 			if len(args) != 1 {
@@ -61,6 +86,22 @@ func NewUpdateCmd(passDB *db.PassDB) *cobra.Command {
 			if setPassword.Changed {
 				newItem.Password = setPassword.Value.String()
 			}
+			// a profile must be attached before generating, so that the new password follows it
+			if flags.Changed(PasswordProfileFlag) {
+				if profile != "" {
+					_, err = resolvePasswordProfile(profile)
+					if err != nil {
+						return fmt.Errorf("cannot update item: %s\n", err)
+					}
+				}
+				newItem.PasswordProfile = profile
+			}
+			if generatePass {
+				newItem.Password, err = generator.password(newItem.PasswordProfile)
+				if err != nil {
+					return fmt.Errorf("cannot update item: %s\n", err)
+				}
+			}
 			if setNotes.Changed {
 				newItem.Notes = strings.Split(setNotes.Value.String(), "\n")
 			}
@@ -68,17 +109,121 @@ func NewUpdateCmd(passDB *db.PassDB) *cobra.Command {
 				newItem.URL = setURL.Value.String()
 			}
 
+			fields, err := parseFieldFlags(itemFields, itemSecrets)
+			if err != nil {
+				return fmt.Errorf("cannot update item: %s\n", err)
+			}
+			// the retrieved item shares its fields with the copy - don't mutate them in place
+			newItem.Fields = append([]item.Field(nil), retrievedItem.Fields...)
+			schema, err := item.SchemaFor(newItem.GetType())
+			if err != nil {
+				return fmt.Errorf("cannot update item: %s\n", err)
+			}
+			for _, field := range fields {
+				// fields belonging to the item type are always concealed as its schema dictates
+				if spec := schema.Spec(field.Name); spec != nil {
+					field.Concealed = spec.Concealed
+				}
+				err = newItem.SetField(field.Name, field.Value, field.Concealed)
+				if err != nil {
+					return fmt.Errorf("cannot update item: %s\n", err)
+				}
+			}
+			for _, name := range removeFields {
+				err = newItem.RemoveField(name)
+				if err != nil {
+					return fmt.Errorf("cannot update item - field '%s': %s\n", name, err)
+				}
+			}
+
+			otpKey, err := parseOTPFlags(otpURI, otpSecret)
+			if err != nil {
+				return fmt.Errorf("cannot update item: %s\n", err)
+			}
+			if otpKey != nil {
+				newItem.OTP = otpKey
+			}
+			if removeOTP {
+				newItem.OTP = nil
+			}
+
+			addedURLs, err := parseMatchURLFlags(matchURLs)
+			if err != nil {
+				return fmt.Errorf("cannot update item: %s\n", err)
+			}
+			newItem.URLs = append([]item.ItemURL(nil), retrievedItem.URLs...)
+			for _, removeURL := range removeURLs {
+				remaining := []item.ItemURL{}
+				for _, existing := range newItem.URLs {
+					if existing.URL != removeURL {
+						remaining = append(remaining, existing)
+					}
+				}
+				if len(remaining) == len(newItem.URLs) {
+					return fmt.Errorf("cannot update item - item has no additional url '%s'\n", removeURL)
+				}
+				newItem.URLs = remaining
+			}
+			newItem.URLs = append(newItem.URLs, addedURLs...)
+
+			newItem.Tags = append([]string(nil), retrievedItem.Tags...)
+			for _, tag := range removeTags {
+				err = newItem.RemoveTag(tag)
+				if err != nil {
+					return fmt.Errorf("cannot update item - tag '%s': %s\n", tag, err)
+				}
+			}
+			for _, tag := range tags {
+				err = newItem.AddTag(tag)
+				if err != nil {
+					return fmt.Errorf("cannot update item: %s\n", err)
+				}
+			}
+
+			if flags.Changed(RotationDaysFlag) {
+				if rotationDays < 0 {
+					return fmt.Errorf("cannot update item - --%s cannot be negative\n", RotationDaysFlag)
+				}
+				newItem.RotationDays = rotationDays
+			}
+
+			if newItem.Password != "" && newItem.Password != retrievedItem.Password {
+				err = reportPasswordStrength(passDB, &newItem, force)
+				if err != nil {
+					return fmt.Errorf("cannot update item: %s\n", err)
+				}
+			}
+
 			err = passDB.UpdateItem(&newItem)
 			if err != nil {
 				return fmt.Errorf("can't update item - %s", err)
 			}
-			fmt.Printf("updated item:'%s'", itemName)
-			return nil
+			return writeOutput(cmd, ChangeOutput{Action: "updated", Target: itemName, message: fmt.Sprintf(SuccessfullyUpdatedMessage, itemName)})
 		},
 	}
 	cmd.Flags().StringVarP(&itemUsername, "username", "u", "", "username for the item")
 	cmd.Flags().StringVarP(&itemPassword, "password", "p", "", "password for the item")
 	cmd.Flags().StringArrayVarP(&itemNotes, "notes", "n", nil, "notes for the item")
 	cmd.Flags().StringVarP(&itemURL, "url", "w", "", "url for the item")
+	cmd.Flags().StringArrayVar(&itemFields, FieldFlag, nil, "custom field to set on the item, given as name=value")
+	cmd.Flags().StringArrayVar(&itemSecrets, SecretFieldFlag, nil, "concealed custom field to set on the item, given as name=value")
+	cmd.Flags().StringArrayVar(&removeFields, RemoveFieldFlag, nil, "name of a custom field to remove from the item")
+	cmd.Flags().StringVar(&otpURI, OTPURIFlag, "", "otpauth:// uri holding the item's one time password secret")
+	cmd.Flags().StringVar(&otpSecret, OTPSecretFlag, "", "base32 secret for the item's time based one time passwords")
+	cmd.Flags().BoolVar(&removeOTP, RemoveOTPFlag, false, "remove the item's one time password secret")
+	cmd.MarkFlagsMutuallyExclusive(OTPURIFlag, OTPSecretFlag, RemoveOTPFlag)
+	cmd.Flags().StringArrayVar(&matchURLs, MatchURLFlag, nil, matchURLUsage)
+	cmd.Flags().StringArrayVar(&removeURLs, RemoveMatchURLFlag, nil, "additional url to remove from the item")
+	cmd.Flags().StringArrayVar(&tags, TagFlag, nil, "tag to add to the item")
+	cmd.Flags().StringArrayVar(&removeTags, RemoveTagFlag, nil, "tag to remove from the item")
+	cmd.Flags().IntVar(&rotationDays, RotationDaysFlag, 0, "days the item's password can go unchanged, overriding any folder or passdb policy (0 removes the override)")
+	cmd.Flags().BoolVar(&generatePass, GenerateFlag, false, "generate a new password for the item (see the generate command for options)")
+	generator.register(cmd.Flags())
+	cmd.MarkFlagsMutuallyExclusive(PasswordFlag, GenerateFlag)
+	cmd.Flags().StringVar(&profile, PasswordProfileFlag, "", fmt.Sprintf("password profile whose rules generated passwords for the item must follow, or \"\" to remove it (see: simple-pass %s)", PasswordProfilesCmdName))
+	cmd.Flags().BoolVar(&force, ForceFlag, false, "set the password even if it is weaker than the passdb password policy allows")
+	registerFlagCompletion(cmd, FieldFlag, completeFieldName(passDB, "="))
+	registerFlagCompletion(cmd, SecretFieldFlag, completeFieldName(passDB, "="))
+	registerFlagCompletion(cmd, RemoveFieldFlag, completeFieldName(passDB, ""))
 	return cmd
 }
@@ -3,4 +3,6 @@ package constants
 const (
 	/* #nosec */
 	PassDBLocalDevEnvVar = "PASSDB_DEV"
+	// ConfigPathEnvVar overrides the location of the simple-pass config file
+	ConfigPathEnvVar = "SIMPLE_PASS_CONFIG"
 )
@@ -0,0 +1,193 @@
+package db
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/georgewheatcroft/simple-pass/internal/item"
+	"github.com/georgewheatcroft/simple-pass/pkg/crypt"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+/*
+	Attachments are held outside of the store data, so that loading the passdb does not require reading and
+	decrypting every attached file. Each attachment is encrypted with its own random key, which is held on the item
+	in the (password encrypted) store data - so an attachment cannot be read without the passdb password, and
+	attachment files cannot be swapped between items without decryption failing.
+*/
+
+const (
+	// MaxAttachmentSize is the largest file (in bytes) which can be attached to an item
+	MaxAttachmentSize = 16 << 20
+	// MaxAttachmentsPerItem is the most attachments an individual item can hold
+	MaxAttachmentsPerItem = 32
+
+	attachmentsDirSuffix = ".attachments"
+)
+
+var (
+	ErrAttachmentTooLarge        = fmt.Errorf("attachment exceeds the maximum size of %d bytes", MaxAttachmentSize)
+	ErrAttachmentEmpty           = errors.New("attachment is empty")
+	ErrTooManyAttachments        = fmt.Errorf("item cannot hold more than %d attachments", MaxAttachmentsPerItem)
+	ErrAttachmentNameInvalid     = errors.New("attachment name must be non-blank and cannot contain path separators")
+	ErrAttachmentNameInUse       = errors.New("item already has an attachment with this name")
+	ErrAttachmentIntegrityFailed = errors.New("attachment contents do not match those which were attached")
+)
+
+// attachmentsDir is where the encrypted attachments for the passdb are held
+func (db *PassDB) attachmentsDir() string {
+	return db.path + attachmentsDirSuffix
+}
+
+func (db *PassDB) attachmentPath(id uuid.UUID) string {
+	return filepath.Join(db.attachmentsDir(), id.String())
+}
+
+// AddAttachment encrypts and stores data as a named attachment of an existing item
+func (db *PassDB) AddAttachment(itemName, name string, data []byte) error {
+	return db.apply(func(tx *Tx) error {
+		return tx.AddAttachment(itemName, name, data)
+	})
+}
+
+// addAttachment encrypts and stores data as a named attachment of an existing item, updating the item in the store
+// data held in memory without committing it. The id of the stored file is returned, so that it can be removed if the
+// change is never committed
+func (db *PassDB) addAttachment(itemName, name string, data []byte) (uuid.UUID, error) {
+	if name == "" || filepath.Base(name) != name {
+		return uuid.Nil, ErrAttachmentNameInvalid
+	}
+	if len(data) == 0 {
+		return uuid.Nil, ErrAttachmentEmpty
+	}
+	if len(data) > MaxAttachmentSize {
+		return uuid.Nil, ErrAttachmentTooLarge
+	}
+	passItem, err := db.RetrieveItem(itemName)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if len(passItem.Attachments) >= MaxAttachmentsPerItem {
+		return uuid.Nil, ErrTooManyAttachments
+	}
+	if _, err := passItem.GetAttachment(name); err == nil {
+		return uuid.Nil, ErrAttachmentNameInUse
+	}
+
+	key, err := crypt.NewKey()
+	if err != nil {
+		return uuid.Nil, err
+	}
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return uuid.Nil, err
+	}
+	encrypted, err := crypt.EncryptWithKey(data, key)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	digest := sha256.Sum256(data)
+
+	err = os.MkdirAll(db.attachmentsDir(), 0o700)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	path := db.attachmentPath(id)
+	// write to a temporary file first, so that a partially written attachment is never left in place
+	err = os.WriteFile(path+".tmp", encrypted, 0o600)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	err = os.Rename(path+".tmp", path)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	passItem.Attachments = append(passItem.Attachments, item.Attachment{
+		Name:   name,
+		ID:     id,
+		Size:   int64(len(data)),
+		Added:  time.Now().UTC(),
+		SHA256: hex.EncodeToString(digest[:]),
+		Key:    key,
+	})
+	err = db.updateItem(passItem)
+	if err != nil {
+		// the attachment is unreachable without the item referencing it
+		db.removeAttachmentFiles(id)
+		return uuid.Nil, err
+	}
+	return id, nil
+}
+
+// ReadAttachment decrypts and returns the contents of a named attachment of an item, having checked that these are
+// exactly what was attached
+func (db *PassDB) ReadAttachment(itemName, name string) ([]byte, error) {
+	passItem, err := db.RetrieveItem(itemName)
+	if err != nil {
+		return nil, err
+	}
+	attachment, err := passItem.GetAttachment(name)
+	if err != nil {
+		return nil, err
+	}
+
+	/* #nosec */
+	encrypted, err := os.ReadFile(db.attachmentPath(attachment.ID))
+	if err != nil {
+		return nil, err
+	}
+	data, err := crypt.DecryptWithKey(encrypted, attachment.Key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrAttachmentIntegrityFailed, err)
+	}
+	digest := sha256.Sum256(data)
+	if int64(len(data)) != attachment.Size || hex.EncodeToString(digest[:]) != attachment.SHA256 {
+		return nil, ErrAttachmentIntegrityFailed
+	}
+	return data, nil
+}
+
+// DeleteAttachment removes a named attachment from an item, along with its contents
+func (db *PassDB) DeleteAttachment(itemName, name string) error {
+	passItem, err := db.RetrieveItem(itemName)
+	if err != nil {
+		return err
+	}
+	attachment, err := passItem.GetAttachment(name)
+	if err != nil {
+		return err
+	}
+	id := attachment.ID
+
+	remaining := []item.Attachment{}
+	for _, existing := range passItem.Attachments {
+		if existing.Name != name {
+			remaining = append(remaining, existing)
+		}
+	}
+	passItem.Attachments = remaining
+	err = db.UpdateItem(passItem)
+	if err != nil {
+		return err
+	}
+	db.removeAttachmentFiles(id)
+	return nil
+}
+
+// removeAttachmentFiles removes the contents of attachments which are no longer (or were never) referenced by any
+// item. Failure to do so only leaves behind files that cannot be decrypted, so is not treated as an error
+func (db *PassDB) removeAttachmentFiles(ids ...uuid.UUID) {
+	for _, id := range ids {
+		err := os.Remove(db.attachmentPath(id))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Warnf("failed to remove contents of deleted attachment %s: %s", id, err)
+		}
+	}
+}
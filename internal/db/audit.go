@@ -0,0 +1,231 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/georgewheatcroft/simple-pass/internal/item"
+	"github.com/georgewheatcroft/simple-pass/pkg/strength"
+)
+
+// AuditIssue is a kind of problem found with an item when auditing the passdb
+type AuditIssue string
+
+const (
+	AuditIssueReused          AuditIssue = "reused"
+	AuditIssueSimilar         AuditIssue = "similar"
+	AuditIssueWeak            AuditIssue = "weak"
+	AuditIssueEmptyPassword   AuditIssue = "empty-password"
+	AuditIssueMissingURL      AuditIssue = "missing-url"
+	AuditIssueMissingUsername AuditIssue = "missing-username"
+)
+
+const (
+	// DefaultAuditMinScore is the lowest password score not reported as weak, when there is no password policy
+	DefaultAuditMinScore = 3
+	// maxSimilarEditDistance is the most single character edits by which passwords can differ and be reported as
+	// similar
+	maxSimilarEditDistance = 2
+	// minSimilarLength avoids reporting short passwords which are only a few edits away from anything
+	minSimilarLength = 6
+	// minStemLength is the shortest stem shared by passwords which is reported
+	minStemLength = 4
+)
+
+// AuditFinding is a problem found with an item. Findings never include password values
+type AuditFinding struct {
+	ItemName string
+	Issue    AuditIssue
+	Detail   string
+	// RelatedItems are the other items involved in the finding, e.g. those which share a reused password
+	RelatedItems []string `json:",omitempty"`
+}
+
+// AuditReport is the outcome of auditing every item in the passdb
+type AuditReport struct {
+	ItemsAudited int
+	Findings     []AuditFinding
+}
+
+type auditedPassword struct {
+	itemName string
+	password string
+	stem     string
+}
+
+// Audit checks every item in the passdb for reused, similar and weak passwords, for logins without a password, and
+// for logins missing the url or username needed to use them. Findings are ordered by item name, then issue
+func (db *PassDB) Audit() (*AuditReport, error) {
+	policy, err := db.PasswordPolicy()
+	if err != nil {
+		return nil, err
+	}
+	minScore := DefaultAuditMinScore
+	if policy.MinScore > 0 {
+		minScore = policy.MinScore
+	}
+
+	report := &AuditReport{Findings: []AuditFinding{}}
+	passwords := []auditedPassword{}
+	for _, name := range db.ListAllItems() {
+		passItem, err := db.RetrieveItem(name)
+		if err != nil {
+			return nil, err
+		}
+		report.ItemsAudited++
+
+		if passItem.GetType() == item.TypeLogin {
+			if passItem.Password == "" {
+				report.Findings = append(report.Findings, AuditFinding{ItemName: name, Issue: AuditIssueEmptyPassword, Detail: "login has no password"})
+			}
+			if len(passItem.AllURLs()) == 0 {
+				report.Findings = append(report.Findings, AuditFinding{ItemName: name, Issue: AuditIssueMissingURL, Detail: "login has no url"})
+			}
+			if passItem.Username == "" {
+				report.Findings = append(report.Findings, AuditFinding{ItemName: name, Issue: AuditIssueMissingUsername, Detail: "login has no username"})
+			}
+		}
+		if passItem.Password == "" {
+			continue
+		}
+
+		result, err := db.CheckPasswordStrength(passItem)
+		if err != nil && result == nil {
+			return nil, err
+		}
+		if result.Score < minScore {
+			detail := fmt.Sprintf("scored %d/%d, would take %s to crack", result.Score, strength.MaxScore, result.CrackTimeDisplay())
+			if result.Warning != "" {
+				detail += " - " + result.Warning
+			}
+			report.Findings = append(report.Findings, AuditFinding{ItemName: name, Issue: AuditIssueWeak, Detail: detail})
+		}
+		passwords = append(passwords, auditedPassword{itemName: name, password: passItem.Password, stem: passwordStem(passItem.Password)})
+	}
+
+	report.Findings = append(report.Findings, reuseFindings(passwords)...)
+	sort.SliceStable(report.Findings, func(i, j int) bool {
+		if report.Findings[i].ItemName != report.Findings[j].ItemName {
+			return report.Findings[i].ItemName < report.Findings[j].ItemName
+		}
+		return report.Findings[i].Issue < report.Findings[j].Issue
+	})
+	return report, nil
+}
+
+// reuseFindings reports passwords used by more than one item, and those which are near duplicates of one another
+func reuseFindings(passwords []auditedPassword) []AuditFinding {
+	reused := map[string][]string{}
+	similar := map[string]map[string]string{}
+	for i, a := range passwords {
+		for _, b := range passwords[i+1:] {
+			reason := ""
+			switch {
+			case a.password == b.password:
+				reused[a.itemName] = append(reused[a.itemName], b.itemName)
+				reused[b.itemName] = append(reused[b.itemName], a.itemName)
+				continue
+			case len(a.stem) >= minStemLength && a.stem == b.stem:
+				reason = "shares a stem"
+			case len(a.password) >= minSimilarLength && len(b.password) >= minSimilarLength &&
+				editDistance(a.password, b.password) <= maxSimilarEditDistance:
+				reason = fmt.Sprintf("differs by at most %d characters", maxSimilarEditDistance)
+			default:
+				continue
+			}
+			for _, pair := range [][2]string{{a.itemName, b.itemName}, {b.itemName, a.itemName}} {
+				if similar[pair[0]] == nil {
+					similar[pair[0]] = map[string]string{}
+				}
+				similar[pair[0]][pair[1]] = reason
+			}
+		}
+	}
+
+	findings := []AuditFinding{}
+	for name, others := range reused {
+		sort.Strings(others)
+		findings = append(findings, AuditFinding{
+			ItemName:     name,
+			Issue:        AuditIssueReused,
+			Detail:       fmt.Sprintf("password is also used by %d other item(s)", len(others)),
+			RelatedItems: others,
+		})
+	}
+	for name, others := range similar {
+		related := make([]string, 0, len(others))
+		reasons := map[string]bool{}
+		for other, reason := range others {
+			related = append(related, other)
+			reasons[reason] = true
+		}
+		sort.Strings(related)
+		detail := make([]string, 0, len(reasons))
+		for reason := range reasons {
+			detail = append(detail, reason)
+		}
+		sort.Strings(detail)
+		findings = append(findings, AuditFinding{
+			ItemName:     name,
+			Issue:        AuditIssueSimilar,
+			Detail:       fmt.Sprintf("password %s with that of %d other item(s)", strings.Join(detail, " or "), len(related)),
+			RelatedItems: related,
+		})
+	}
+	return findings
+}
+
+// passwordStem is the word at the root of a password, with the case, digits and symbols commonly varied between
+// passwords removed - e.g. Summer2023! and summer24 share the stem "summer". Passwords which are not mostly their
+// leading word (e.g. passphrases) have no stem
+func passwordStem(password string) string {
+	letters := []rune{}
+	for _, r := range strings.ToLower(password) {
+		if unicode.IsLetter(r) {
+			letters = append(letters, r)
+			continue
+		}
+		// only the leading run of letters is the stem
+		if len(letters) > 0 {
+			break
+		}
+	}
+	if len(letters)*2 < len([]rune(password)) {
+		return ""
+	}
+	return string(letters)
+}
+
+// editDistance returns the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
+
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
@@ -0,0 +1,55 @@
+package db
+
+import (
+	"sort"
+
+	"github.com/georgewheatcroft/simple-pass/pkg/hibp"
+)
+
+// BreachedItem is an item whose password appears in a breach dataset. Password values are never included
+type BreachedItem struct {
+	ItemName string
+	// TimesSeen is how many times the password was seen in breaches
+	TimesSeen int
+}
+
+// BreachReport is the outcome of checking every item password against a breach dataset
+type BreachReport struct {
+	ItemsChecked int
+	Compromised  []BreachedItem
+}
+
+// CheckBreaches looks up the password of every item in the given Pwned Passwords dataset. Items without a password
+// are not checked. Compromised items are ordered by name
+func (db *PassDB) CheckBreaches(dataset *hibp.Dataset) (*BreachReport, error) {
+	report := &BreachReport{Compromised: []BreachedItem{}}
+	// reused passwords are only looked up once
+	seen := map[string]int{}
+	for _, name := range db.ListAllItems() {
+		passItem, err := db.RetrieveItem(name)
+		if err != nil {
+			return nil, err
+		}
+		if passItem.Password == "" {
+			continue
+		}
+		report.ItemsChecked++
+
+		hash := hibp.HashPassword(passItem.Password)
+		count, found := seen[hash]
+		if !found {
+			count, err = dataset.LookupHash(hash)
+			if err != nil {
+				return nil, err
+			}
+			seen[hash] = count
+		}
+		if count > 0 {
+			report.Compromised = append(report.Compromised, BreachedItem{ItemName: name, TimesSeen: count})
+		}
+	}
+	sort.Slice(report.Compromised, func(i, j int) bool {
+		return report.Compromised[i].ItemName < report.Compromised[j].ItemName
+	})
+	return report, nil
+}
@@ -3,10 +3,14 @@ package db
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
+	"sort"
+	"time"
 
 	"github.com/georgewheatcroft/simple-pass/internal/item"
 	"github.com/georgewheatcroft/simple-pass/internal/store"
+	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -19,6 +23,7 @@ var (
 	ErrItemUnchanged                  = errors.New("item was unchanged")
 	ErrCannotRenameToExistingItemName = errors.New("item cannot be renamed to the name it already has")
 	ErrItemNameAlreadyInUse           = errors.New("cannot rename item - name already in use by another item")
+	ErrPassDBClosed                   = errors.New("passdb has been closed")
 )
 
 // TODO perhaps turn this into an interface and have different types (e.g. file db, remote db,  etc) implement this
@@ -26,6 +31,10 @@ type PassDB struct {
 	store *store.Store
 	// this is very specific to a file or webserver related implementation - TODO some type infront of this?
 	path string
+	// closed is set once the decrypted passdb has been cleared from memory
+	closed bool
+	// tx is the transaction in progress, if there is one
+	tx *Tx
 }
 
 // createDBLocalFile creates the file for the  file based db on local disk
@@ -91,15 +100,37 @@ func (db *PassDB) ListAllItems() []string {
 	return ret
 }
 
+// Path returns where the passdb is stored
+func (db *PassDB) Path() string {
+	return db.path
+}
+
+// Close clears the decrypted items and password held in memory. Nothing can be read from or saved to the passdb once
+// it is closed - it must be loaded again
+func (db *PassDB) Close() {
+	db.store.Clear()
+	db.closed = true
+}
+
 func (db *PassDB) GetPassDBName() string {
 	return db.store.GetStoreName()
 }
 
 // SaveNewItem writes new items to db storage
 func (db *PassDB) SaveNewItem(passItem *item.Item) error {
+	return db.apply(func(tx *Tx) error {
+		return tx.SaveNewItem(passItem)
+	})
+}
+
+// saveNewItem adds a new item to the store data held in memory, without committing it
+func (db *PassDB) saveNewItem(passItem *item.Item) error {
 	if passItem == nil {
 		return ErrInvalidItem
 	}
+	if err := passItem.Validate(); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidItem, err)
+	}
 	serialisedItem, err := serialiseItem(passItem)
 	if err != nil {
 		log.Debugf("failed to serialise item:%s", err)
@@ -111,7 +142,7 @@ func (db *PassDB) SaveNewItem(passItem *item.Item) error {
 		log.Debugf("failed to create new db storedata key:%s", err)
 		return err
 	}
-	return db.commit()
+	return nil
 }
 
 // RetrieveItem returns items which are stored in the db
@@ -132,41 +163,74 @@ func (db *PassDB) RetrieveItem(itemName string) (*item.Item, error) {
 		log.Debugf("failed to retrieve item:%s - %s", itemName, err)
 		return nil, err
 	}
+	// items stored before item types were introduced are all logins
+	if retItem.Type == "" {
+		retItem.Type = item.TypeLogin
+	}
 	return &retItem, nil
 }
 
 // UpdateItem updates the item stored for a given item name, if it exists in the db
 func (db *PassDB) UpdateItem(passItem *item.Item) error {
+	return db.apply(func(tx *Tx) error {
+		return tx.UpdateItem(passItem)
+	})
+}
+
+// updateItem updates an existing item in the store data held in memory, without committing it
+func (db *PassDB) updateItem(passItem *item.Item) error {
 	if passItem == nil {
 		return ErrInvalidItem
 	}
-	_, err := db.store.GetStoreDataKeyValue(passItem.Name)
+	if err := passItem.Validate(); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidItem, err)
+	}
+	existing, err := db.RetrieveItem(passItem.Name)
 	if err != nil {
-		if errors.Is(err, store.ErrStoreDataKeyDoesNotExist) {
-			return ErrItemDoesNotExist
-		}
 		return err
 	}
+	// only stamp the item as modified (and its password as changed) if it actually has been
+	passItem.Modified = existing.Modified
+	passItem.PasswordChanged = existing.PasswordChanged
 	serialised, err := serialiseItem(passItem)
 	if err != nil {
 		return err
 	}
-
-	err = db.store.UpdateStoreDataKeyValue(passItem.Name, serialised)
-	if err != nil {
-		if errors.Is(err, store.ErrNoChangeToStoreDataKeyValueMade) {
-			return ErrItemUnchanged
+	if existingSerialised, _ := db.store.GetStoreDataKeyValue(passItem.Name); existingSerialised != serialised {
+		now := time.Now().UTC()
+		passItem.Modified = now
+		if passItem.Password != existing.Password {
+			passItem.PasswordChanged = now
+		}
+		serialised, err = serialiseItem(passItem)
+		if err != nil {
+			return err
 		}
 	}
 
-	return db.commit()
+	err = db.store.UpdateStoreDataKeyValue(passItem.Name, serialised)
+	if errors.Is(err, store.ErrNoChangeToStoreDataKeyValueMade) {
+		return ErrItemUnchanged
+	}
+	return err
 }
 
 // RenameItem renames an existing item in persistent store or aborts the change
 func (db *PassDB) RenameItem(current, desired string) error {
+	return db.apply(func(tx *Tx) error {
+		return tx.RenameItem(current, desired)
+	})
+}
+
+// renameItem renames an existing item in the store data held in memory, without committing it. Everything which
+// could stop the rename is checked before anything is changed, so it either happens completely or not at all
+func (db *PassDB) renameItem(current, desired string) error {
 	if current == desired {
 		return ErrCannotRenameToExistingItemName
 	}
+	if desired == "" {
+		return store.ErrInvalidStoreDataKey
+	}
 	//what we are renaming should already exist
 	retrieved, err := db.store.GetStoreDataKeyValue(current)
 	if err != nil {
@@ -207,7 +271,7 @@ func (db *PassDB) RenameItem(current, desired string) error {
 		return err
 	}
 	//we have removed the old item name (key) and the new one exists
-	return db.commit()
+	return nil
 }
 
 // TODO current implementation relys heavily on persistent storage medium being a local file (see below).
@@ -215,6 +279,9 @@ func (db *PassDB) RenameItem(current, desired string) error {
 
 // commit ensures that any changes to items are writen to file
 func (db *PassDB) commit() error {
+	if db.closed {
+		return ErrPassDBClosed
+	}
 	tmpPath := db.path + ".tmp"
 	/* #nosec */
 	fh, err := os.Create(tmpPath)
@@ -222,30 +289,76 @@ func (db *PassDB) commit() error {
 		return err
 	}
 
-	// after changing file contents, close it and only then replace the now stale passDB with the updated passDB
-	defer func() error {
-		err := fh.Close()
-		if err != nil {
-			return err
-		}
-		return os.Rename(tmpPath, db.path)
-	}()
-
-	return db.store.Save(fh)
+	err = db.store.Save(fh)
+	if err == nil {
+		err = fh.Sync()
+	}
+	closeErr := fh.Close()
+	if err != nil {
+		return err
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+	// only once the updated passDB is completely written, replace the now stale passDB with it
+	return os.Rename(tmpPath, db.path)
 }
 
+// DeleteItem deletes an existing item, along with the contents of its attachments
 func (db *PassDB) DeleteItem(name string) error {
-	_, err := db.store.GetStoreDataKeyValue(name)
+	return db.apply(func(tx *Tx) error {
+		return tx.DeleteItem(name)
+	})
+}
+
+// deleteItem removes an item from the store data held in memory, without committing it. The files of its
+// attachments are left to be removed once it is committed, returning their ids
+func (db *PassDB) deleteItem(name string) ([]uuid.UUID, error) {
+	passItem, err := db.RetrieveItem(name)
 	if err != nil {
-		if errors.Is(err, store.ErrStoreDataKeyDoesNotExist) {
-			return ErrItemDoesNotExist
-		}
-		return err
+		return nil, err
 	}
 
 	err = db.store.DeleteStoreDataKey(name)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	attachmentIDs := []uuid.UUID{}
+	for _, attachment := range passItem.Attachments {
+		attachmentIDs = append(attachmentIDs, attachment.ID)
+	}
+	return attachmentIDs, nil
+}
+
+// URLMatch is an item which matched a url being looked up, and the most specific way in which it matched
+type URLMatch struct {
+	Item  *item.Item
+	Match item.MatchMode
+}
+
+// FindItemsByURL returns the items with a url matching the given url, the most specific matches first
+func (db *PassDB) FindItemsByURL(target string) ([]URLMatch, error) {
+	matches := []URLMatch{}
+	for _, name := range db.ListAllItems() {
+		passItem, err := db.RetrieveItem(name)
+		if err != nil {
+			return nil, err
+		}
+		if mode, matched := passItem.MatchURL(target); matched {
+			matches = append(matches, URLMatch{Item: passItem, Match: mode})
+		}
 	}
-	return db.commit()
+
+	rank := map[item.MatchMode]int{}
+	for idx, mode := range item.MatchModes() {
+		rank[mode] = idx
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if rank[matches[i].Match] != rank[matches[j].Match] {
+			return rank[matches[i].Match] < rank[matches[j].Match]
+		}
+		return matches[i].Item.Name < matches[j].Item.Name
+	})
+	return matches, nil
 }
@@ -4,10 +4,13 @@ import (
 	"errors"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/georgewheatcroft/simple-pass/internal/common/constants"
 	"github.com/georgewheatcroft/simple-pass/internal/db"
 	"github.com/georgewheatcroft/simple-pass/internal/item"
+	"github.com/georgewheatcroft/simple-pass/pkg/fuzzy"
+	"github.com/georgewheatcroft/simple-pass/pkg/otp"
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/require"
 )
@@ -291,3 +294,497 @@ func TestListAllItems(t *testing.T) {
 		require.Contains(t, retrievedItemNames, inputName)
 	}
 }
+
+func TestShouldAddReadAndDeleteAttachments(t *testing.T) {
+	err := ensureNotExists(testFileDBPath)
+	require.NoError(t, err)
+	attachmentsDir := testFileDBPath + ".attachments"
+	defer os.RemoveAll(attachmentsDir)
+
+	passDB, err := db.CreatePassDB(testFileDBPath, dbName, dbPassword)
+	require.NoError(t, err)
+
+	validItem, err := item.NewItem("foobar", "foobar", "foobar", "foobar", nil)
+	require.NoError(t, err)
+	err = passDB.SaveNewItem(validItem)
+	require.NoError(t, err)
+
+	contents := []byte("apiVersion: v1\nkind: Config\n")
+	err = passDB.AddAttachment(validItem.Name, "kubeconfig", contents)
+	require.NoError(t, err)
+
+	err = passDB.AddAttachment(validItem.Name, "kubeconfig", contents)
+	require.ErrorIs(t, err, db.ErrAttachmentNameInUse)
+	err = passDB.AddAttachment(validItem.Name, "../escape", contents)
+	require.ErrorIs(t, err, db.ErrAttachmentNameInvalid)
+	err = passDB.AddAttachment(validItem.Name, "too-large", make([]byte, db.MaxAttachmentSize+1))
+	require.ErrorIs(t, err, db.ErrAttachmentTooLarge)
+	err = passDB.AddAttachment("does not exist", "kubeconfig", contents)
+	require.ErrorIs(t, err, db.ErrItemDoesNotExist)
+
+	// the attachment must survive reloading the passdb, and never be held in plain text on disk
+	loadedPassDB, err := db.LoadExistingPassDB(testFileDBPath, dbPassword)
+	require.NoError(t, err)
+	read, err := loadedPassDB.ReadAttachment(validItem.Name, "kubeconfig")
+	require.NoError(t, err)
+	require.Equal(t, contents, read)
+
+	files, err := os.ReadDir(attachmentsDir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	onDisk, err := os.ReadFile(attachmentsDir + "/" + files[0].Name())
+	require.NoError(t, err)
+	require.NotContains(t, string(onDisk), "kubeconfig")
+	require.NotContains(t, string(onDisk), "apiVersion")
+
+	// tampering with the stored attachment must be detected
+	onDisk[len(onDisk)-1] ^= 0xff
+	err = os.WriteFile(attachmentsDir+"/"+files[0].Name(), onDisk, 0o600)
+	require.NoError(t, err)
+	_, err = loadedPassDB.ReadAttachment(validItem.Name, "kubeconfig")
+	require.ErrorIs(t, err, db.ErrAttachmentIntegrityFailed)
+
+	// deleting the item removes its attachments
+	err = loadedPassDB.DeleteItem(validItem.Name)
+	require.NoError(t, err)
+	files, err = os.ReadDir(attachmentsDir)
+	require.NoError(t, err)
+	require.Len(t, files, 0)
+}
+
+func TestShouldGenerateOTPCodesAndCommitHOTPCounter(t *testing.T) {
+	err := ensureNotExists(testFileDBPath)
+	require.NoError(t, err)
+
+	passDB, err := db.CreatePassDB(testFileDBPath, dbName, dbPassword)
+	require.NoError(t, err)
+
+	// RFC 4226 test secret
+	hotpKey, err := otp.ParseURI("otpauth://hotp/alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&counter=0")
+	require.NoError(t, err)
+	hotpItem, err := item.NewItem("hotp", "alice", "", "", nil)
+	require.NoError(t, err)
+	hotpItem.OTP = hotpKey
+	err = passDB.SaveNewItem(hotpItem)
+	require.NoError(t, err)
+
+	for _, expected := range []string{"755224", "287082", "359152"} {
+		code, err := passDB.GenerateOTPCode(hotpItem.Name, otp.SystemClock{})
+		require.NoError(t, err)
+		require.Equal(t, expected, code.Value)
+	}
+
+	// the advanced counter must have been committed
+	loadedPassDB, err := db.LoadExistingPassDB(testFileDBPath, dbPassword)
+	require.NoError(t, err)
+	retItem, err := loadedPassDB.RetrieveItem(hotpItem.Name)
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), retItem.OTP.Counter)
+
+	// if the counter cannot be committed no code is handed out, and the counter does not move
+	// (a directory in the way of the temporary file written on commit causes the commit to fail)
+	err = os.Mkdir(testFileDBPath+".tmp", 0o700)
+	require.NoError(t, err)
+	_, err = loadedPassDB.GenerateOTPCode(hotpItem.Name, otp.SystemClock{})
+	require.Error(t, err)
+	err = os.Remove(testFileDBPath + ".tmp")
+	require.NoError(t, err)
+	retItem, err = loadedPassDB.RetrieveItem(hotpItem.Name)
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), retItem.OTP.Counter)
+
+	noOTPItem, err := item.NewItem("no-otp", "alice", "", "", nil)
+	require.NoError(t, err)
+	err = loadedPassDB.SaveNewItem(noOTPItem)
+	require.NoError(t, err)
+	_, err = loadedPassDB.GenerateOTPCode(noOTPItem.Name, otp.SystemClock{})
+	require.ErrorIs(t, err, db.ErrItemHasNoOTP)
+}
+
+func TestShouldReportItemsDueForRotation(t *testing.T) {
+	err := ensureNotExists(testFileDBPath)
+	require.NoError(t, err)
+
+	passDB, err := db.CreatePassDB(testFileDBPath, dbName, dbPassword)
+	require.NoError(t, err)
+
+	for _, name := range []string{"root-item", "prod/db/admin", "prod/web", "dev/app"} {
+		newItem, err := item.NewItem(name, "user", "password", "", nil)
+		require.NoError(t, err)
+		err = passDB.SaveNewItem(newItem)
+		require.NoError(t, err)
+	}
+	noPassword, err := item.NewItem("no-password", "user", "", "", nil)
+	require.NoError(t, err)
+	err = passDB.SaveNewItem(noPassword)
+	require.NoError(t, err)
+
+	// nothing is due without a policy
+	report, err := passDB.RotationReport(time.Now().AddDate(1, 0, 0), 0)
+	require.NoError(t, err)
+	require.Empty(t, report)
+
+	require.NoError(t, passDB.SetVaultRotationPolicy(90))
+	require.NoError(t, passDB.SetFolderRotationPolicy("prod", 30))
+	require.NoError(t, passDB.SetFolderRotationPolicy("prod/db", 7))
+	require.ErrorIs(t, passDB.SetVaultRotationPolicy(-1), db.ErrInvalidRotationDays)
+	// the root is not a folder of its own - the passdb wide policy covers it
+	require.ErrorIs(t, passDB.SetFolderRotationPolicy("/", 30), db.ErrNoRotationFolder)
+
+	devItem, err := passDB.RetrieveItem("dev/app")
+	require.NoError(t, err)
+	devItem.RotationDays = 365
+	require.NoError(t, passDB.UpdateItem(devItem))
+
+	// policies must be persisted with the passdb
+	passDB, err = db.LoadExistingPassDB(testFileDBPath, dbPassword)
+	require.NoError(t, err)
+
+	report, err = passDB.RotationReport(time.Now().AddDate(0, 0, 10), 0)
+	require.NoError(t, err)
+	require.Len(t, report, 1)
+	require.Equal(t, "prod/db/admin", report[0].ItemName)
+	require.Equal(t, "folder:prod/db", report[0].PolicySource)
+	require.True(t, report[0].Overdue)
+
+	// items soon due are included when within the window, but are not overdue
+	report, err = passDB.RotationReport(time.Now().AddDate(0, 0, 10), 30*24*time.Hour)
+	require.NoError(t, err)
+	require.Len(t, report, 2)
+	require.Equal(t, "prod/web", report[1].ItemName)
+	require.False(t, report[1].Overdue)
+
+	report, err = passDB.RotationReport(time.Now().AddDate(0, 0, 100), 0)
+	require.NoError(t, err)
+	require.Len(t, report, 3)
+	for _, status := range report {
+		require.NotEqual(t, "dev/app", status.ItemName)
+	}
+
+	// changing the password resets when it is due
+	prodItem, err := passDB.RetrieveItem("prod/db/admin")
+	require.NoError(t, err)
+	before := prodItem.PasswordChanged
+	prodItem.Password = "rotated"
+	require.NoError(t, passDB.UpdateItem(prodItem))
+	prodItem, err = passDB.RetrieveItem("prod/db/admin")
+	require.NoError(t, err)
+	require.True(t, prodItem.PasswordChanged.After(before))
+
+	// removing a folder policy falls back to the parent folder's policy
+	require.NoError(t, passDB.SetFolderRotationPolicy("prod/db", 0))
+	policies, err := passDB.RotationPolicies()
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"prod": 30}, policies.FolderDays)
+}
+
+func TestShouldEnforcePasswordPolicy(t *testing.T) {
+	err := ensureNotExists(testFileDBPath)
+	require.NoError(t, err)
+
+	passDB, err := db.CreatePassDB(testFileDBPath, dbName, dbPassword)
+	require.NoError(t, err)
+
+	weakItem, err := item.NewItem("example", "jsmith", "jsmith1987", "https://example.com", nil)
+	require.NoError(t, err)
+
+	// any password is accepted until a policy is set
+	result, err := passDB.CheckPasswordStrength(weakItem)
+	require.NoError(t, err)
+	require.LessOrEqual(t, result.Score, 1)
+
+	require.ErrorIs(t, passDB.SetMinPasswordScore(5), db.ErrInvalidMinScore)
+	require.NoError(t, passDB.SetMinPasswordScore(3))
+
+	passDB, err = db.LoadExistingPassDB(testFileDBPath, dbPassword)
+	require.NoError(t, err)
+	policy, err := passDB.PasswordPolicy()
+	require.NoError(t, err)
+	require.Equal(t, 3, policy.MinScore)
+
+	result, err = passDB.CheckPasswordStrength(weakItem)
+	require.ErrorIs(t, err, db.ErrPasswordTooWeak)
+	require.NotNil(t, result)
+
+	weakItem.Password = "correct-horse-battery-staple"
+	_, err = passDB.CheckPasswordStrength(weakItem)
+	require.NoError(t, err)
+}
+
+func TestShouldAuditItems(t *testing.T) {
+	err := ensureNotExists(testFileDBPath)
+	require.NoError(t, err)
+
+	passDB, err := db.CreatePassDB(testFileDBPath, dbName, dbPassword)
+	require.NoError(t, err)
+
+	for _, details := range [][4]string{
+		{"reused-a", "user", "kX9#mQ2$vL7!pR4@", "https://a.example.com"},
+		{"reused-b", "user", "kX9#mQ2$vL7!pR4@", "https://b.example.com"},
+		{"stem-a", "user", "Sunshine2023!", "https://c.example.com"},
+		{"stem-b", "user", "sunshine24", "https://d.example.com"},
+		{"edit-a", "user", "zq8Fh3Lw9Tbe", "https://e.example.com"},
+		{"edit-b", "user", "zq8Fh3Lw9Tbx", "https://f.example.com"},
+		{"no-details", "", "correct-horse-battery-staple", ""},
+		{"no-password", "user", "", "https://g.example.com"},
+	} {
+		newItem, err := item.NewItem(details[0], details[1], details[2], details[3], nil)
+		require.NoError(t, err)
+		require.NoError(t, passDB.SaveNewItem(newItem))
+	}
+	note, err := item.NewTypedItem(item.TypeSecureNote, "a-note", []string{"not a login"})
+	require.NoError(t, err)
+	require.NoError(t, passDB.SaveNewItem(note))
+
+	report, err := passDB.Audit()
+	require.NoError(t, err)
+	require.Equal(t, 9, report.ItemsAudited)
+
+	issues := map[string][]db.AuditIssue{}
+	for _, finding := range report.Findings {
+		issues[finding.ItemName] = append(issues[finding.ItemName], finding.Issue)
+		if finding.Issue == db.AuditIssueReused {
+			require.Len(t, finding.RelatedItems, 1)
+		}
+	}
+	require.Equal(t, []db.AuditIssue{db.AuditIssueReused}, issues["reused-a"])
+	require.Equal(t, []db.AuditIssue{db.AuditIssueReused}, issues["reused-b"])
+	require.Contains(t, issues["stem-a"], db.AuditIssueSimilar)
+	require.Contains(t, issues["stem-b"], db.AuditIssueSimilar)
+	require.Equal(t, []db.AuditIssue{db.AuditIssueSimilar}, issues["edit-a"])
+	require.Equal(t, []db.AuditIssue{db.AuditIssueMissingURL, db.AuditIssueMissingUsername}, issues["no-details"])
+	require.Equal(t, []db.AuditIssue{db.AuditIssueEmptyPassword}, issues["no-password"])
+	require.Contains(t, issues["stem-b"], db.AuditIssueWeak)
+	require.Empty(t, issues["a-note"])
+}
+
+func TestShouldSearchItems(t *testing.T) {
+	err := ensureNotExists(testFileDBPath)
+	require.NoError(t, err)
+
+	passDB, err := db.CreatePassDB(testFileDBPath, dbName, dbPassword)
+	require.NoError(t, err)
+
+	for _, details := range [][3]string{
+		{"work/github", "jsmith-work", "https://github.com"},
+		{"personal/github", "jsmith", "https://github.com"},
+		{"bank", "jsmith", "https://onlinebanking.example.com"},
+		{"email", "jsmith@example.com", "https://mail.example.com"},
+	} {
+		newItem, err := item.NewItem(details[0], details[1], "password", details[2], []string{"recovery codes are in the safe"})
+		require.NoError(t, err)
+		if details[0] == "bank" {
+			require.NoError(t, newItem.AddTag("finance"))
+		}
+		require.NoError(t, passDB.SaveNewItem(newItem))
+	}
+
+	results, err := passDB.Search("github")
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	// both match on name and url equally well
+	require.Equal(t, "personal/github", results[0].ItemName)
+	require.Equal(t, "work/github", results[1].ItemName)
+	require.Equal(t, db.SearchFieldName, results[0].Matches[0].Field)
+	require.Equal(t, []fuzzy.Range{{Start: 9, End: 15}}, results[0].Matches[0].Ranges)
+
+	// every word must match, and typos are tolerated
+	results, err = passDB.Search("gihtub work")
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.Equal(t, "work/github", results[0].ItemName)
+
+	results, err = passDB.Search("finance")
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.Equal(t, "bank", results[0].ItemName)
+	require.Equal(t, db.SearchFieldTag, results[0].Matches[0].Field)
+
+	// notes are only searched when asked for
+	results, err = passDB.Search("recovery")
+	require.NoError(t, err)
+	require.Empty(t, results)
+	results, err = passDB.Search("recovery", append(db.DefaultSearchFields(), db.SearchFieldNotes)...)
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+
+	results, err = passDB.Search("   ")
+	require.NoError(t, err)
+	require.Empty(t, results)
+}
+
+func TestShouldQueryItems(t *testing.T) {
+	err := ensureNotExists(testFileDBPath)
+	require.NoError(t, err)
+
+	passDB, err := db.CreatePassDB(testFileDBPath, dbName, dbPassword)
+	require.NoError(t, err)
+
+	for _, details := range [][3]string{
+		{"prod/db", "admin", "https://db.aws.example.com"},
+		{"prod/web", "admin", "https://web.example.com"},
+		{"dev/db", "admin", "https://db.aws.example.com"},
+		{"prod/api", "deploy", "https://api.aws.example.com"},
+	} {
+		newItem, err := item.NewItem(details[0], details[1], details[0]+"-password", details[2], nil,
+			item.Field{Name: "pin", Value: "1234", Concealed: true})
+		require.NoError(t, err)
+		require.NoError(t, passDB.SaveNewItem(newItem))
+	}
+
+	names := func(items []*item.Item) []string {
+		ret := []string{}
+		for _, i := range items {
+			ret = append(ret, i.Name)
+		}
+		return ret
+	}
+
+	items, err := passDB.Query(db.Query{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"dev/db", "prod/api", "prod/db", "prod/web"}, names(items))
+
+	items, err = passDB.Query(db.Query{Where: `folder == "prod" && username == "admin" && url ~ "aws"`})
+	require.NoError(t, err)
+	require.Equal(t, []string{"prod/db"}, names(items))
+
+	items, err = passDB.Query(db.Query{Where: `modified < "2000-01-01"`})
+	require.NoError(t, err)
+	require.Empty(t, items)
+
+	items, err = passDB.Query(db.Query{Sort: []string{"-username", "-name"}, Limit: 2})
+	require.NoError(t, err)
+	require.Equal(t, []string{"prod/api", "prod/web"}, names(items))
+
+	// concealed values are only queried when secrets are included
+	for _, query := range []db.Query{
+		{Where: `password == "prod/db-password"`},
+		{Where: `field.pin == "1234"`},
+		{Sort: []string{"password"}},
+	} {
+		_, err = passDB.Query(query)
+		require.ErrorIs(t, err, db.ErrConcealedQueryField, query)
+		query.IncludeSecrets = true
+		_, err = passDB.Query(query)
+		require.NoError(t, err, query)
+	}
+	items, err = passDB.Query(db.Query{Where: `password == "prod/db-password"`, IncludeSecrets: true})
+	require.NoError(t, err)
+	require.Equal(t, []string{"prod/db"}, names(items))
+
+	_, err = passDB.Query(db.Query{Where: `colour == "red"`})
+	require.ErrorIs(t, err, db.ErrUnknownQueryField)
+	_, err = passDB.Query(db.Query{Sort: []string{"tags"}})
+	require.Error(t, err)
+	_, err = passDB.Query(db.Query{Limit: -1})
+	require.ErrorIs(t, err, db.ErrInvalidQueryLimit)
+}
+
+func TestShouldClearClosedPassDBFromMemory(t *testing.T) {
+	err := ensureNotExists(testFileDBPath)
+	require.NoError(t, err)
+
+	passDB, err := db.CreatePassDB(testFileDBPath, dbName, dbPassword)
+	require.NoError(t, err)
+	newItem, err := item.NewItem("closed", "user", "Closed-Password-1", "", nil)
+	require.NoError(t, err)
+	require.NoError(t, passDB.SaveNewItem(newItem))
+
+	passDB.Close()
+	require.Empty(t, passDB.ListAllItems())
+	_, err = passDB.RetrieveItem("closed")
+	require.ErrorIs(t, err, db.ErrItemDoesNotExist)
+
+	// the emptied passdb must never be saved over the one stored
+	another, err := item.NewItem("another", "user", "Another-Password-1", "", nil)
+	require.NoError(t, err)
+	require.ErrorIs(t, passDB.SaveNewItem(another), db.ErrPassDBClosed)
+
+	reloaded, err := db.LoadExistingPassDB(passDB.Path(), dbPassword)
+	require.NoError(t, err)
+	require.Equal(t, []string{"closed"}, reloaded.ListAllItems())
+}
+
+func TestShouldCommitOrRollBackTransactions(t *testing.T) {
+	err := ensureNotExists(testFileDBPath)
+	require.NoError(t, err)
+
+	passDB, err := db.CreatePassDB(testFileDBPath, dbName, dbPassword)
+	require.NoError(t, err)
+	existing, err := item.NewItem("existing", "user", "Existing-Password-1", "", nil)
+	require.NoError(t, err)
+	require.NoError(t, passDB.SaveNewItem(existing))
+
+	tx, err := passDB.Begin()
+	require.NoError(t, err)
+	_, err = passDB.Begin()
+	require.ErrorIs(t, err, db.ErrTxInProgress)
+	added, err := item.NewItem("added", "user", "Added-Password-1", "", nil)
+	require.NoError(t, err)
+	require.NoError(t, tx.SaveNewItem(added))
+	updated := *existing
+	updated.Username = "changed"
+	require.NoError(t, tx.UpdateItem(&updated))
+	require.NoError(t, tx.RenameItem("existing", "renamed"))
+	require.ElementsMatch(t, []string{"added", "renamed"}, tx.ListAllItems())
+	// the passdb can only be changed through the transaction while it is in progress
+	require.ErrorIs(t, passDB.DeleteItem("added"), db.ErrTxInProgress)
+	require.ErrorIs(t, passDB.SetMinPasswordScore(1), db.ErrTxInProgress)
+	require.NoError(t, tx.Rollback())
+	require.ErrorIs(t, tx.Commit(), db.ErrTxDone)
+	require.Equal(t, []string{"existing"}, passDB.ListAllItems())
+	retrieved, err := passDB.RetrieveItem("existing")
+	require.NoError(t, err)
+	require.Equal(t, *existing, *retrieved)
+
+	// a rename which cannot be made changes nothing
+	require.Error(t, passDB.RenameItem("existing", ""))
+	require.Equal(t, []string{"existing"}, passDB.ListAllItems())
+
+	tx, err = passDB.Begin()
+	require.NoError(t, err)
+	require.NoError(t, tx.SaveNewItem(added))
+	require.NoError(t, tx.DeleteItem("existing"))
+	// nothing is written until the transaction is committed
+	reloaded, err := db.LoadExistingPassDB(passDB.Path(), dbPassword)
+	require.NoError(t, err)
+	require.Equal(t, []string{"existing"}, reloaded.ListAllItems())
+	require.NoError(t, tx.Commit())
+
+	reloaded, err = db.LoadExistingPassDB(passDB.Path(), dbPassword)
+	require.NoError(t, err)
+	require.Equal(t, []string{"added"}, reloaded.ListAllItems())
+}
+
+func TestShouldOnlyKeepAttachmentsAddedInCommittedTransactions(t *testing.T) {
+	err := ensureNotExists(testFileDBPath)
+	require.NoError(t, err)
+	passDB, err := db.CreatePassDB(testFileDBPath, dbName, dbPassword)
+	require.NoError(t, err)
+	defer os.RemoveAll(passDB.Path() + ".attachments")
+	attachmentFiles := func() []os.DirEntry {
+		entries, _ := os.ReadDir(passDB.Path() + ".attachments")
+		return entries
+	}
+
+	tx, err := passDB.Begin()
+	require.NoError(t, err)
+	added, err := item.NewItem("added", "user", "Added-Password-1", "", nil)
+	require.NoError(t, err)
+	require.NoError(t, tx.SaveNewItem(added))
+	require.NoError(t, tx.AddAttachment("added", "notes.txt", []byte("some notes")))
+	require.ErrorIs(t, passDB.AddAttachment("added", "other.txt", []byte("other")), db.ErrTxInProgress)
+	require.Len(t, attachmentFiles(), 1)
+	require.NoError(t, tx.Rollback())
+	require.Empty(t, attachmentFiles())
+
+	tx, err = passDB.Begin()
+	require.NoError(t, err)
+	require.NoError(t, tx.SaveNewItem(added))
+	require.NoError(t, tx.AddAttachment("added", "notes.txt", []byte("some notes")))
+	require.NoError(t, tx.Commit())
+	require.Len(t, attachmentFiles(), 1)
+	data, err := passDB.ReadAttachment("added", "notes.txt")
+	require.NoError(t, err)
+	require.Equal(t, "some notes", string(data))
+}
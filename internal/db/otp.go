@@ -0,0 +1,37 @@
+package db
+
+import (
+	"errors"
+
+	"github.com/georgewheatcroft/simple-pass/pkg/otp"
+)
+
+var ErrItemHasNoOTP = errors.New("item does not hold an otp secret")
+
+// GenerateOTPCode returns the current one time password for an item. For HOTP items the counter is advanced and
+// committed before the code is returned, so that the same code can never be handed out twice - if the commit fails
+// no code is returned and the passdb is left as it was
+func (db *PassDB) GenerateOTPCode(itemName string, clock otp.Clock) (*otp.Code, error) {
+	passItem, err := db.RetrieveItem(itemName)
+	if err != nil {
+		return nil, err
+	}
+	if passItem.OTP == nil {
+		return nil, ErrItemHasNoOTP
+	}
+
+	code, err := passItem.OTP.Generate(clock)
+	if err != nil {
+		return nil, err
+	}
+	if passItem.OTP.Kind != otp.HOTP {
+		return code, nil
+	}
+
+	passItem.OTP.Counter++
+	err = db.UpdateItem(passItem)
+	if err != nil {
+		return nil, err
+	}
+	return code, nil
+}
@@ -0,0 +1,80 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/georgewheatcroft/simple-pass/internal/item"
+	"github.com/georgewheatcroft/simple-pass/pkg/strength"
+)
+
+const passwordPolicyMetaKey = "password-policy"
+
+var (
+	ErrPasswordTooWeak = errors.New("password is weaker than the passdb password policy allows")
+	ErrInvalidMinScore = fmt.Errorf("minimum password score must be between 0 and %d", strength.MaxScore)
+)
+
+// PasswordPolicy are the passdb wide requirements of item passwords
+type PasswordPolicy struct {
+	// MinScore is the lowest strength score (see strength.Estimate) an item password can have - 0 accepts any
+	MinScore int `json:"minScore,omitempty"`
+}
+
+// PasswordPolicy returns the password policy set on the passdb
+func (db *PassDB) PasswordPolicy() (*PasswordPolicy, error) {
+	policy := &PasswordPolicy{}
+	serialised, exists := db.store.GetStoreMetaValue(passwordPolicyMetaKey)
+	if !exists {
+		return policy, nil
+	}
+	err := json.Unmarshal([]byte(serialised), policy)
+	if err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// SetMinPasswordScore sets the lowest strength score item passwords can have. 0 removes the requirement
+func (db *PassDB) SetMinPasswordScore(score int) error {
+	if score < 0 || score > strength.MaxScore {
+		return ErrInvalidMinScore
+	}
+	value := ""
+	if score != 0 {
+		serialised, err := json.Marshal(PasswordPolicy{MinScore: score})
+		if err != nil {
+			return err
+		}
+		value = string(serialised)
+	}
+	return db.apply(func(*Tx) error {
+		return db.store.SetStoreMetaValue(passwordPolicyMetaKey, value)
+	})
+}
+
+// CheckPasswordStrength estimates the strength of an item's password, taking into account the details of the item
+// an attacker would also try. ErrPasswordTooWeak is returned (along with the estimate) if the password policy does not
+// allow a password so weak
+func (db *PassDB) CheckPasswordStrength(passItem *item.Item) (*strength.Result, error) {
+	policy, err := db.PasswordPolicy()
+	if err != nil {
+		return nil, err
+	}
+	userInputs := []string{passItem.Name, passItem.Username}
+	for _, itemURL := range passItem.AllURLs() {
+		if parsed, err := url.Parse(itemURL.URL); err == nil && parsed.Hostname() != "" {
+			userInputs = append(userInputs, parsed.Hostname())
+		} else {
+			userInputs = append(userInputs, itemURL.URL)
+		}
+	}
+
+	result := strength.Estimate(passItem.Password, userInputs...)
+	if result.Score < policy.MinScore {
+		return &result, fmt.Errorf("%w: scored %d/%d, but at least %d is required", ErrPasswordTooWeak, result.Score, strength.MaxScore, policy.MinScore)
+	}
+	return &result, nil
+}
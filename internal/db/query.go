@@ -0,0 +1,258 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/georgewheatcroft/simple-pass/internal/item"
+	"github.com/georgewheatcroft/simple-pass/pkg/filter"
+)
+
+var (
+	ErrUnknownQueryField   = errors.New("unknown item field")
+	ErrConcealedQueryField = errors.New("field holds a concealed value - secrets must be included to query it")
+	ErrInvalidQueryLimit   = errors.New("query limit cannot be negative")
+)
+
+// QueryFieldPrefix is followed by the name of a custom field in query expressions, e.g. field.account-number
+const QueryFieldPrefix = "field."
+
+// queryFields describe each field of an item which can be queried. Lists can be filtered on, but not sorted by
+var queryFields = map[string]struct {
+	description string
+	list        bool
+	secret      bool
+}{
+	"name":             {description: "the item name"},
+	"folder":           {description: "the folder the item is held in"},
+	"type":             {description: "the item type"},
+	"username":         {description: "the username"},
+	"password":         {description: "the password", secret: true},
+	"url":              {description: "the primary url"},
+	"urls":             {description: "every url of the item", list: true},
+	"notes":            {description: "each line of the notes", list: true},
+	"tags":             {description: "the tags", list: true},
+	"fields":           {description: "the names of the custom fields", list: true},
+	"attachments":      {description: "the names of the attachments", list: true},
+	"has_otp":          {description: "whether the item has a one time password secret"},
+	"rotation_days":    {description: "the item's own rotation period, or 0"},
+	"password_profile": {description: "the password profile"},
+	"created":          {description: "when the item was created"},
+	"modified":         {description: "when the item was last modified"},
+	"password_changed": {description: "when the password was last changed"},
+}
+
+// QueryFields returns the names of the item fields which can be queried, and a description of each. Custom fields are
+// queried as QueryFieldPrefix followed by their name
+func QueryFields() map[string]string {
+	fields := map[string]string{}
+	for name, field := range queryFields {
+		fields[name] = field.description
+	}
+	return fields
+}
+
+// Query selects, orders and limits items
+type Query struct {
+	// Where is a filter expression (see pkg/filter) which items must match, or "" to match every item
+	Where string
+	// Sort are the fields to order by, each prefixed with - to order in descending order. Items are ordered by name
+	// when no fields are given, and to break ties
+	Sort []string
+	// Limit is the most items to return, or 0 for no limit
+	Limit int
+	// IncludeSecrets allows the password and concealed custom fields to be filtered and sorted on
+	IncludeSecrets bool
+}
+
+// Query returns the items matching the query, in the order it asks for
+func (db *PassDB) Query(query Query) ([]*item.Item, error) {
+	if query.Limit < 0 {
+		return nil, ErrInvalidQueryLimit
+	}
+	var where *filter.Filter
+	if strings.TrimSpace(query.Where) != "" {
+		var err error
+		where, err = filter.Compile(query.Where)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range where.Identifiers() {
+			err = checkQueryField(name, query.IncludeSecrets, false)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	sortFields := []string{}
+	descending := map[string]bool{}
+	for _, key := range query.Sort {
+		name := strings.TrimPrefix(key, "-")
+		err := checkQueryField(name, query.IncludeSecrets, true)
+		if err != nil {
+			return nil, err
+		}
+		sortFields = append(sortFields, name)
+		descending[name] = strings.HasPrefix(key, "-")
+	}
+
+	items := []*item.Item{}
+	for _, name := range db.ListAllItems() {
+		passItem, err := db.RetrieveItem(name)
+		if err != nil {
+			return nil, err
+		}
+		if where != nil {
+			matched, err := where.Match(itemEnv{item: passItem, includeSecrets: query.IncludeSecrets})
+			if err != nil {
+				return nil, fmt.Errorf("cannot query item '%s': %w", name, err)
+			}
+			if !matched {
+				continue
+			}
+		}
+		items = append(items, passItem)
+	}
+
+	var sortErr error
+	sort.SliceStable(items, func(i, j int) bool {
+		a := itemEnv{item: items[i], includeSecrets: query.IncludeSecrets}
+		b := itemEnv{item: items[j], includeSecrets: query.IncludeSecrets}
+		for _, name := range sortFields {
+			order, err := compareQueryField(a, b, name)
+			if err != nil {
+				sortErr = err
+				return false
+			}
+			if order != 0 {
+				return (order < 0) != descending[name]
+			}
+		}
+		return items[i].Name < items[j].Name
+	})
+	if sortErr != nil {
+		return nil, sortErr
+	}
+
+	if query.Limit > 0 && len(items) > query.Limit {
+		items = items[:query.Limit]
+	}
+	return items, nil
+}
+
+// checkQueryField checks a field named in a query exists, and can be queried
+func checkQueryField(name string, includeSecrets, sorting bool) error {
+	if strings.HasPrefix(name, QueryFieldPrefix) && len(name) > len(QueryFieldPrefix) {
+		// whether custom fields are concealed is only known once each item is looked at
+		return nil
+	}
+	field, found := queryFields[name]
+	if !found {
+		return fmt.Errorf("%w: '%s'", ErrUnknownQueryField, name)
+	}
+	if field.secret && !includeSecrets {
+		return fmt.Errorf("%w: '%s'", ErrConcealedQueryField, name)
+	}
+	if field.list && sorting {
+		return fmt.Errorf("cannot sort by '%s' as it is a list", name)
+	}
+	return nil
+}
+
+func compareQueryField(a, b itemEnv, name string) (int, error) {
+	aValue, err := a.Lookup(name)
+	if err != nil {
+		return 0, err
+	}
+	bValue, err := b.Lookup(name)
+	if err != nil {
+		return 0, err
+	}
+	return filter.Compare(aValue, bValue)
+}
+
+// itemEnv looks up the fields of an item for filter expressions
+type itemEnv struct {
+	item           *item.Item
+	includeSecrets bool
+}
+
+func (e itemEnv) Lookup(name string) (interface{}, error) {
+	i := e.item
+	if strings.HasPrefix(name, QueryFieldPrefix) {
+		field, err := i.GetField(strings.TrimPrefix(name, QueryFieldPrefix))
+		if err != nil {
+			return nil, nil
+		}
+		if field.Concealed && !e.includeSecrets {
+			return nil, fmt.Errorf("%w: '%s'", ErrConcealedQueryField, name)
+		}
+		return field.Value, nil
+	}
+
+	switch name {
+	case "name":
+		return i.Name, nil
+	case "folder":
+		return i.Folder(), nil
+	case "type":
+		return string(i.GetType()), nil
+	case "username":
+		return i.Username, nil
+	case "password":
+		if !e.includeSecrets {
+			return nil, fmt.Errorf("%w: '%s'", ErrConcealedQueryField, name)
+		}
+		return i.Password, nil
+	case "url":
+		return i.URL, nil
+	case "urls":
+		urls := []string{}
+		for _, itemURL := range i.AllURLs() {
+			urls = append(urls, itemURL.URL)
+		}
+		return urls, nil
+	case "notes":
+		return append([]string{}, i.Notes...), nil
+	case "tags":
+		return append([]string{}, i.Tags...), nil
+	case "fields":
+		names := []string{}
+		for _, field := range i.Fields {
+			names = append(names, field.Name)
+		}
+		return names, nil
+	case "attachments":
+		names := []string{}
+		for _, attachment := range i.Attachments {
+			names = append(names, attachment.Name)
+		}
+		return names, nil
+	case "has_otp":
+		return i.OTP != nil, nil
+	case "rotation_days":
+		return float64(i.RotationDays), nil
+	case "password_profile":
+		return i.PasswordProfile, nil
+	case "created":
+		return timeOrNil(i.Created), nil
+	case "modified":
+		return timeOrNil(i.Modified), nil
+	case "password_changed":
+		return timeOrNil(i.PasswordChanged), nil
+	default:
+		return nil, fmt.Errorf("%w: '%s'", ErrUnknownQueryField, name)
+	}
+}
+
+// timeOrNil treats unset times as missing, so that e.g. items whose password was never set are not "changed before"
+// any time
+func timeOrNil(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
@@ -0,0 +1,176 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/georgewheatcroft/simple-pass/internal/item"
+)
+
+const rotationPoliciesMetaKey = "rotation-policies"
+
+var (
+	ErrInvalidRotationDays = errors.New("rotation policy must be a positive number of days, or 0 to remove it")
+	ErrNoRotationFolder    = errors.New("no folder given for the rotation policy - set the passdb wide policy to cover every item")
+)
+
+// RotationPolicies are the passdb wide and per folder limits on how many days an item password can go unchanged.
+// Per item limits are held on the items themselves (see item.Item.RotationDays)
+type RotationPolicies struct {
+	VaultDays  int            `json:"vaultDays,omitempty"`
+	FolderDays map[string]int `json:"folderDays,omitempty"`
+}
+
+// RotationStatus describes when an item password is due to be rotated under the policy which applies to it
+type RotationStatus struct {
+	ItemName   string
+	PolicyDays int
+	// PolicySource is where the policy applying to the item was set - "item", "folder:<name>" or "vault"
+	PolicySource string
+	// LastChanged is zero when it is not known when the password was last changed
+	LastChanged time.Time
+	// Due is zero when it is not known when the password was last changed, in which case it is treated as overdue
+	Due     time.Time
+	Overdue bool
+}
+
+// RotationPolicies returns the rotation policies set on the passdb
+func (db *PassDB) RotationPolicies() (*RotationPolicies, error) {
+	policies := &RotationPolicies{}
+	serialised, exists := db.store.GetStoreMetaValue(rotationPoliciesMetaKey)
+	if !exists {
+		return policies, nil
+	}
+	err := json.Unmarshal([]byte(serialised), policies)
+	if err != nil {
+		return nil, err
+	}
+	return policies, nil
+}
+
+// SetVaultRotationPolicy sets the number of days any item password can go unchanged, unless overridden for its
+// folder or the item itself. 0 removes the policy
+func (db *PassDB) SetVaultRotationPolicy(days int) error {
+	if days < 0 {
+		return ErrInvalidRotationDays
+	}
+	policies, err := db.RotationPolicies()
+	if err != nil {
+		return err
+	}
+	policies.VaultDays = days
+	return db.saveRotationPolicies(policies)
+}
+
+// SetFolderRotationPolicy sets the number of days the passwords of items in a folder (and its subfolders) can go
+// unchanged, unless overridden for a subfolder or the item itself. 0 removes the policy
+func (db *PassDB) SetFolderRotationPolicy(folder string, days int) error {
+	if days < 0 {
+		return ErrInvalidRotationDays
+	}
+	folder = strings.Trim(folder, item.FolderSeparator)
+	if folder == "" {
+		return ErrNoRotationFolder
+	}
+	policies, err := db.RotationPolicies()
+	if err != nil {
+		return err
+	}
+	if days == 0 {
+		delete(policies.FolderDays, folder)
+	} else {
+		if policies.FolderDays == nil {
+			policies.FolderDays = map[string]int{}
+		}
+		policies.FolderDays[folder] = days
+	}
+	return db.saveRotationPolicies(policies)
+}
+
+func (db *PassDB) saveRotationPolicies(policies *RotationPolicies) error {
+	value := ""
+	if policies.VaultDays != 0 || len(policies.FolderDays) != 0 {
+		serialised, err := json.Marshal(policies)
+		if err != nil {
+			return err
+		}
+		value = string(serialised)
+	}
+	return db.apply(func(*Tx) error {
+		return db.store.SetStoreMetaValue(rotationPoliciesMetaKey, value)
+	})
+}
+
+// policyFor returns the number of days the password of an item can go unchanged, and where that policy was set.
+// The most specific policy applies - the item's own, then that of its nearest folder, then the passdb wide one
+func (p *RotationPolicies) policyFor(passItem *item.Item) (int, string) {
+	if passItem.RotationDays > 0 {
+		return passItem.RotationDays, "item"
+	}
+	for folder := passItem.Folder(); folder != ""; {
+		if days, exists := p.FolderDays[folder]; exists {
+			return days, "folder:" + folder
+		}
+		idx := strings.LastIndex(folder, item.FolderSeparator)
+		if idx < 0 {
+			break
+		}
+		folder = folder[:idx]
+	}
+	if p.VaultDays > 0 {
+		return p.VaultDays, "vault"
+	}
+	return 0, ""
+}
+
+// RotationReport returns the rotation status of every item password which is subject to a rotation policy and is
+// either overdue or due within the given window at the given time. The most overdue items are listed first
+func (db *PassDB) RotationReport(now time.Time, within time.Duration) ([]RotationStatus, error) {
+	policies, err := db.RotationPolicies()
+	if err != nil {
+		return nil, err
+	}
+
+	report := []RotationStatus{}
+	for _, name := range db.ListAllItems() {
+		passItem, err := db.RetrieveItem(name)
+		if err != nil {
+			return nil, err
+		}
+		// only items which have a password can have it rotated
+		if passItem.Password == "" {
+			continue
+		}
+		days, source := policies.policyFor(passItem)
+		if days == 0 {
+			continue
+		}
+
+		status := RotationStatus{ItemName: name, PolicyDays: days, PolicySource: source, LastChanged: passItem.PasswordChanged}
+		// items created before changes were tracked fall back to when they were created, if known
+		if status.LastChanged.IsZero() {
+			status.LastChanged = passItem.Created
+		}
+		if status.LastChanged.IsZero() {
+			status.Overdue = true
+		} else {
+			status.Due = status.LastChanged.AddDate(0, 0, days)
+			status.Overdue = !now.Before(status.Due)
+			if !status.Overdue && status.Due.Sub(now) > within {
+				continue
+			}
+		}
+		report = append(report, status)
+	}
+
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Due.Equal(report[j].Due) {
+			return report[i].ItemName < report[j].ItemName
+		}
+		return report[i].Due.Before(report[j].Due)
+	})
+	return report, nil
+}
@@ -0,0 +1,143 @@
+package db
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/georgewheatcroft/simple-pass/internal/item"
+	"github.com/georgewheatcroft/simple-pass/pkg/fuzzy"
+)
+
+// SearchField is a part of an item which is searched
+type SearchField string
+
+const (
+	SearchFieldName     SearchField = "name"
+	SearchFieldUsername SearchField = "username"
+	SearchFieldURL      SearchField = "url"
+	SearchFieldTag      SearchField = "tag"
+	SearchFieldNotes    SearchField = "notes"
+)
+
+// searchFieldWeights scale the scores of matches by the field matched, in percent - a match on the name is what is
+// most likely to be meant
+var searchFieldWeights = map[SearchField]int{
+	SearchFieldName:     100,
+	SearchFieldTag:      90,
+	SearchFieldUsername: 80,
+	SearchFieldURL:      80,
+	SearchFieldNotes:    60,
+}
+
+// DefaultSearchFields are searched when no fields are given. Notes are only searched when asked for, as they are
+// long and match almost anything
+func DefaultSearchFields() []SearchField {
+	return []SearchField{SearchFieldName, SearchFieldUsername, SearchFieldURL, SearchFieldTag}
+}
+
+// SearchMatch is a value of an item which matched a search, along with the (rune) ranges of it which matched
+type SearchMatch struct {
+	Field  SearchField
+	Value  string
+	Ranges []fuzzy.Range
+}
+
+// SearchResult is an item which matched a search. Score is out of fuzzy.ScoreExact
+type SearchResult struct {
+	ItemName string
+	Score    int
+	Matches  []SearchMatch
+}
+
+// Search fuzzy matches the query against the given fields of every item (or DefaultSearchFields, if none are given),
+// returning the matching items from the best to the worst match. Queries of several words match items which match
+// every word, anywhere in the fields searched
+func (db *PassDB) Search(query string, fields ...SearchField) ([]SearchResult, error) {
+	if len(fields) == 0 {
+		fields = DefaultSearchFields()
+	}
+	terms := strings.Fields(query)
+	results := []SearchResult{}
+	if len(terms) == 0 {
+		return results, nil
+	}
+
+	for _, name := range db.ListAllItems() {
+		passItem, err := db.RetrieveItem(name)
+		if err != nil {
+			return nil, err
+		}
+		if result, matched := searchItem(passItem, terms, fields); matched {
+			results = append(results, result)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].ItemName < results[j].ItemName
+	})
+	return results, nil
+}
+
+// searchItem matches every term against the item, scoring the item by the average of the best match for each term
+func searchItem(passItem *item.Item, terms []string, fields []SearchField) (SearchResult, bool) {
+	values := searchValues(passItem, fields)
+	ranges := make([][]fuzzy.Range, len(values))
+	total := 0
+	for _, term := range terms {
+		best := 0
+		for idx, value := range values {
+			match, matched := fuzzy.Find(term, value.Value)
+			if !matched {
+				continue
+			}
+			ranges[idx] = append(ranges[idx], match.Ranges...)
+			if score := match.Score * searchFieldWeights[value.Field] / 100; score > best {
+				best = score
+			}
+		}
+		if best == 0 {
+			return SearchResult{}, false
+		}
+		total += best
+	}
+
+	result := SearchResult{ItemName: passItem.Name, Score: total / len(terms), Matches: []SearchMatch{}}
+	for idx, value := range values {
+		if len(ranges[idx]) == 0 {
+			continue
+		}
+		value.Ranges = fuzzy.MergeRanges(ranges[idx])
+		result.Matches = append(result.Matches, value)
+	}
+	return result, true
+}
+
+// searchValues returns the values of the item held in the given fields
+func searchValues(passItem *item.Item, fields []SearchField) []SearchMatch {
+	values := []SearchMatch{}
+	for _, field := range fields {
+		var fieldValues []string
+		switch field {
+		case SearchFieldName:
+			fieldValues = []string{passItem.Name}
+		case SearchFieldUsername:
+			fieldValues = []string{passItem.Username}
+		case SearchFieldURL:
+			for _, itemURL := range passItem.AllURLs() {
+				fieldValues = append(fieldValues, itemURL.URL)
+			}
+		case SearchFieldTag:
+			fieldValues = passItem.Tags
+		case SearchFieldNotes:
+			fieldValues = passItem.Notes
+		}
+		for _, value := range fieldValues {
+			if value != "" {
+				values = append(values, SearchMatch{Field: field, Value: value})
+			}
+		}
+	}
+	return values
+}
@@ -0,0 +1,156 @@
+package db
+
+import (
+	"errors"
+
+	"github.com/georgewheatcroft/simple-pass/internal/item"
+	"github.com/georgewheatcroft/simple-pass/internal/store"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrTxInProgress = errors.New("a transaction is already in progress on the passdb")
+	ErrTxDone       = errors.New("transaction has already been committed or rolled back")
+)
+
+// Tx is a set of changes to the items in a passdb, which are only written once it is committed - all of them
+// together, or (if it is rolled back, or committing fails) none of them. Only one transaction can be in progress on
+// a passdb at a time, and the passdb cannot be changed other than through it until it is done
+type Tx struct {
+	db *PassDB
+	// snapshot is the store data as it was when the transaction began, which is restored if it is rolled back
+	snapshot *store.Snapshot
+	// removedAttachments are the attachments of deleted items, whose files are only removed once committed
+	removedAttachments []uuid.UUID
+	// addedAttachments are the attachments added to items, whose files are removed if rolled back
+	addedAttachments []uuid.UUID
+	done             bool
+}
+
+// Begin starts a transaction on the passdb
+func (db *PassDB) Begin() (*Tx, error) {
+	if db.closed {
+		return nil, ErrPassDBClosed
+	}
+	if db.tx != nil {
+		return nil, ErrTxInProgress
+	}
+	db.tx = &Tx{db: db, snapshot: db.store.Snapshot()}
+	return db.tx, nil
+}
+
+// apply makes a change to the passdb in a transaction of its own, committing it only if the change succeeds
+func (db *PassDB) apply(change func(tx *Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	err = change(tx)
+	if err != nil {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil {
+			return rollbackErr
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+// ListAllItems returns the names of every item, including the changes made so far in the transaction
+func (tx *Tx) ListAllItems() []string {
+	return tx.db.ListAllItems()
+}
+
+// RetrieveItem returns an item, including the changes made to it so far in the transaction
+func (tx *Tx) RetrieveItem(itemName string) (*item.Item, error) {
+	return tx.db.RetrieveItem(itemName)
+}
+
+// SaveNewItem adds a new item as part of the transaction
+func (tx *Tx) SaveNewItem(passItem *item.Item) error {
+	if tx.done {
+		return ErrTxDone
+	}
+	return tx.db.saveNewItem(passItem)
+}
+
+// UpdateItem updates an existing item as part of the transaction
+func (tx *Tx) UpdateItem(passItem *item.Item) error {
+	if tx.done {
+		return ErrTxDone
+	}
+	return tx.db.updateItem(passItem)
+}
+
+// RenameItem renames an existing item as part of the transaction
+func (tx *Tx) RenameItem(current, desired string) error {
+	if tx.done {
+		return ErrTxDone
+	}
+	return tx.db.renameItem(current, desired)
+}
+
+// DeleteItem deletes an existing item as part of the transaction
+func (tx *Tx) DeleteItem(name string) error {
+	if tx.done {
+		return ErrTxDone
+	}
+	attachmentIDs, err := tx.db.deleteItem(name)
+	if err != nil {
+		return err
+	}
+	tx.removedAttachments = append(tx.removedAttachments, attachmentIDs...)
+	return nil
+}
+
+// AddAttachment encrypts and stores data as a named attachment of an existing item as part of the transaction
+func (tx *Tx) AddAttachment(itemName, name string, data []byte) error {
+	if tx.done {
+		return ErrTxDone
+	}
+	id, err := tx.db.addAttachment(itemName, name, data)
+	if err != nil {
+		return err
+	}
+	tx.addedAttachments = append(tx.addedAttachments, id)
+	return nil
+}
+
+// Commit writes every change made in the transaction to the passdb at once. If that fails, the changes are rolled
+// back
+func (tx *Tx) Commit() error {
+	if tx.done {
+		return ErrTxDone
+	}
+	err := tx.db.commit()
+	if err != nil {
+		tx.restore()
+		return err
+	}
+	tx.end()
+	tx.db.removeAttachmentFiles(tx.removedAttachments...)
+	return nil
+}
+
+// Rollback discards every change made in the transaction, leaving the passdb exactly as it was when it began
+func (tx *Tx) Rollback() error {
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.restore()
+	return nil
+}
+
+func (tx *Tx) restore() {
+	// a closed passdb has already been cleared from memory, which must not be undone
+	if !tx.db.closed {
+		tx.db.store.Restore(tx.snapshot)
+	}
+	tx.end()
+	tx.db.removeAttachmentFiles(tx.addedAttachments...)
+}
+
+func (tx *Tx) end() {
+	tx.done = true
+	tx.db.tx = nil
+}
@@ -2,45 +2,233 @@ package item
 
 import (
 	"errors"
+	"strings"
+	"time"
 
+	"github.com/georgewheatcroft/simple-pass/pkg/otp"
 	"github.com/google/uuid"
 )
 
+// ConcealedMask is displayed in place of the value of a concealed field
+const ConcealedMask = "********"
+
+// FolderSeparator separates the folders an item is held in within its name, e.g. "prod/db/admin"
+const FolderSeparator = "/"
+
 var (
 	ErrNoItemNameSupplied      = errors.New("no item name supplied")
 	ErrInsufficientInformation = errors.New("insufficient information provided to create a geninue item")
+	ErrNoFieldNameSupplied     = errors.New("no field name supplied")
+	ErrFieldDoesNotExist       = errors.New("field does not exist on the item")
+	ErrAttachmentDoesNotExist  = errors.New("attachment does not exist on the item")
+	ErrNoTagSupplied           = errors.New("no tag supplied")
+	ErrTagDoesNotExist         = errors.New("tag does not exist on the item")
 )
 
+// Field is an arbitrary named value held on an item, e.g. an account number or a recovery code
+type Field struct {
+	Name      string
+	Value     string
+	Concealed bool
+}
+
+// Attachment describes a file attached to an item. The (encrypted) contents of the file are held outside of the item,
+// and can only be decrypted with the key held here
+type Attachment struct {
+	Name  string
+	ID    uuid.UUID
+	Size  int64
+	Added time.Time
+	// SHA256 is the hex encoded digest of the unencrypted contents, used to check their integrity
+	SHA256 string
+	Key    []byte
+}
+
 type Item struct {
 	Name     string
 	ID       uuid.UUID
+	Type     Type
 	Username string
 	Password string
 	URL      string
+	URLs     []ItemURL
 	Notes    []string
+	// Tags are free form labels for grouping and finding items, independent of the folder they are held in
+	Tags        []string `json:",omitempty"`
+	Fields      []Field
+	Attachments []Attachment
+	OTP         *otp.Key
+	// RotationDays overrides the folder and passdb rotation policy for the item's password, if it is non-zero
+	RotationDays int
+	// PasswordProfile names the password profile whose rules generated passwords for the item must follow
+	PasswordProfile string `json:",omitempty"`
+	Created         time.Time
+	Modified        time.Time
+	PasswordChanged time.Time
 }
 
 // NewItem returns an Item from the provided paramters, and additional metadata, or returns an error
-func NewItem(name, username, password, url string, notes []string) (*Item, error) {
+func NewItem(name, username, password, url string, notes []string, fields ...Field) (*Item, error) {
 	if name == "" {
 		return nil, ErrNoItemNameSupplied
 	}
 
-	if username == "" && password == "" && url == "" && notes == nil {
+	if username == "" && password == "" && url == "" && notes == nil && len(fields) == 0 {
 		return nil, ErrInsufficientInformation
 
 	}
 
-	uid, err := uuid.NewRandom()
-	if err != nil {
-		return nil, err
-	}
-	return &Item{
-		ID:       uid,
+	now := time.Now().UTC()
+	newItem := &Item{
 		Name:     name,
+		Type:     TypeLogin,
 		Notes:    notes,
 		Password: password,
 		URL:      url,
 		Username: username,
-	}, nil
+		Created:  now,
+		Modified: now,
+	}
+	if password != "" {
+		newItem.PasswordChanged = now
+	}
+	for _, field := range fields {
+		err := newItem.SetField(field.Name, field.Value, field.Concealed)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	uid, err := uuid.NewRandom()
+	if err != nil {
+		return nil, err
+	}
+	newItem.ID = uid
+	return newItem, nil
+}
+
+// SetField sets the value of a custom field on the item, adding the field if it does not already exist
+func (i *Item) SetField(name, value string, concealed bool) error {
+	if name == "" {
+		return ErrNoFieldNameSupplied
+	}
+	for idx := range i.Fields {
+		if i.Fields[idx].Name == name {
+			i.Fields[idx].Value = value
+			i.Fields[idx].Concealed = concealed
+			return nil
+		}
+	}
+	i.Fields = append(i.Fields, Field{Name: name, Value: value, Concealed: concealed})
+	return nil
+}
+
+// GetField returns the custom field with the given name, if it exists on the item
+func (i *Item) GetField(name string) (*Field, error) {
+	for idx := range i.Fields {
+		if i.Fields[idx].Name == name {
+			return &i.Fields[idx], nil
+		}
+	}
+	return nil, ErrFieldDoesNotExist
+}
+
+// RemoveField removes the custom field with the given name, if it exists on the item
+func (i *Item) RemoveField(name string) error {
+	for idx := range i.Fields {
+		if i.Fields[idx].Name == name {
+			i.Fields = append(i.Fields[:idx], i.Fields[idx+1:]...)
+			return nil
+		}
+	}
+	return ErrFieldDoesNotExist
+}
+
+// Masked returns a copy of the item where the values of concealed fields (and the otp secret) are replaced with
+// ConcealedMask, and attachment keys are removed
+func (i *Item) Masked() *Item {
+	masked := *i.WithoutAttachmentKeys()
+	if i.OTP != nil {
+		maskedOTP := *i.OTP
+		maskedOTP.Secret = ConcealedMask
+		masked.OTP = &maskedOTP
+	}
+	if i.Fields == nil {
+		return &masked
+	}
+	masked.Fields = make([]Field, len(i.Fields))
+	for idx, field := range i.Fields {
+		if field.Concealed {
+			field.Value = ConcealedMask
+		}
+		masked.Fields[idx] = field
+	}
+	return &masked
+}
+
+// AddTag adds a tag to the item, if the item does not already have it
+func (i *Item) AddTag(tag string) error {
+	tag = strings.TrimSpace(tag)
+	if tag == "" {
+		return ErrNoTagSupplied
+	}
+	if i.HasTag(tag) {
+		return nil
+	}
+	i.Tags = append(i.Tags, tag)
+	return nil
+}
+
+// HasTag returns whether the item has the given tag
+func (i *Item) HasTag(tag string) bool {
+	for _, existing := range i.Tags {
+		if existing == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// RemoveTag removes a tag from the item, if it exists on the item
+func (i *Item) RemoveTag(tag string) error {
+	for idx, existing := range i.Tags {
+		if existing == tag {
+			i.Tags = append(i.Tags[:idx], i.Tags[idx+1:]...)
+			return nil
+		}
+	}
+	return ErrTagDoesNotExist
+}
+
+// GetAttachment returns the attachment with the given name, if it exists on the item
+func (i *Item) GetAttachment(name string) (*Attachment, error) {
+	for idx := range i.Attachments {
+		if i.Attachments[idx].Name == name {
+			return &i.Attachments[idx], nil
+		}
+	}
+	return nil, ErrAttachmentDoesNotExist
+}
+
+// WithoutAttachmentKeys returns a copy of the item with the keys of its attachments removed, suitable for display
+func (i *Item) WithoutAttachmentKeys() *Item {
+	stripped := *i
+	if i.Attachments == nil {
+		return &stripped
+	}
+	stripped.Attachments = make([]Attachment, len(i.Attachments))
+	for idx, attachment := range i.Attachments {
+		attachment.Key = nil
+		stripped.Attachments[idx] = attachment
+	}
+	return &stripped
+}
+
+// Folder returns the folder the item is held in, given by the item's name, or "" for items held in no folder
+func (i *Item) Folder() string {
+	idx := strings.LastIndex(i.Name, FolderSeparator)
+	if idx < 0 {
+		return ""
+	}
+	return i.Name[:idx]
 }
@@ -1,13 +1,19 @@
 package item_test
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/georgewheatcroft/simple-pass/internal/common/constants"
 	"github.com/georgewheatcroft/simple-pass/internal/item"
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
 )
 
 
@@ -96,3 +102,218 @@ func TestShouldReturnErrForInvalidInput(t *testing.T) {
 		require.ErrorIs(t, err, input.expectedErr)
 	}
 }
+
+func TestShouldSetGetAndRemoveFields(t *testing.T) {
+	genItem, err := item.NewItem("fields", "", "", "", nil,
+		item.Field{Name: "account-number", Value: "12345678"},
+		item.Field{Name: "recovery-code", Value: "abcd-efgh", Concealed: true},
+	)
+	require.NoError(t, err)
+	require.Len(t, genItem.Fields, 2)
+
+	field, err := genItem.GetField("recovery-code")
+	require.NoError(t, err)
+	require.Equal(t, "abcd-efgh", field.Value)
+	require.True(t, field.Concealed)
+
+	// setting an existing field replaces it rather than adding another
+	err = genItem.SetField("account-number", "87654321", false)
+	require.NoError(t, err)
+	require.Len(t, genItem.Fields, 2)
+	field, err = genItem.GetField("account-number")
+	require.NoError(t, err)
+	require.Equal(t, "87654321", field.Value)
+
+	masked := genItem.Masked()
+	require.Equal(t, "87654321", masked.Fields[0].Value)
+	require.Equal(t, item.ConcealedMask, masked.Fields[1].Value)
+	// masking must not affect the original item
+	require.Equal(t, "abcd-efgh", genItem.Fields[1].Value)
+
+	err = genItem.RemoveField("recovery-code")
+	require.NoError(t, err)
+	_, err = genItem.GetField("recovery-code")
+	require.ErrorIs(t, err, item.ErrFieldDoesNotExist)
+
+	err = genItem.SetField("", "value", false)
+	require.ErrorIs(t, err, item.ErrNoFieldNameSupplied)
+}
+
+func TestShouldAddAndRemoveTags(t *testing.T) {
+	genItem, err := item.NewItem("tags", "", "password", "", nil)
+	require.NoError(t, err)
+
+	require.NoError(t, genItem.AddTag("work"))
+	require.NoError(t, genItem.AddTag(" banking "))
+	// adding an existing tag does not duplicate it
+	require.NoError(t, genItem.AddTag("work"))
+	require.Equal(t, []string{"work", "banking"}, genItem.Tags)
+	require.True(t, genItem.HasTag("banking"))
+
+	require.NoError(t, genItem.RemoveTag("work"))
+	require.False(t, genItem.HasTag("work"))
+	require.ErrorIs(t, genItem.RemoveTag("work"), item.ErrTagDoesNotExist)
+	require.ErrorIs(t, genItem.AddTag("  "), item.ErrNoTagSupplied)
+}
+
+func TestShouldValidateTypedItems(t *testing.T) {
+	inputs := []struct {
+		caseName    string
+		itemType    item.Type
+		notes       []string
+		fields      []item.Field
+		expectedErr error
+	}{
+		{
+			caseName: "validCard",
+			itemType: item.TypeCreditCard,
+			fields:   []item.Field{{Name: "number", Value: "4111 1111 1111 1111"}, {Name: "expiry", Value: "12/30"}},
+		},
+		{
+			caseName:    "cardFailsLuhn",
+			itemType:    item.TypeCreditCard,
+			fields:      []item.Field{{Name: "number", Value: "4111 1111 1111 1112"}, {Name: "expiry", Value: "12/30"}},
+			expectedErr: item.ErrInvalidFieldValue,
+		},
+		{
+			caseName:    "cardInvalidExpiry",
+			itemType:    item.TypeCreditCard,
+			fields:      []item.Field{{Name: "number", Value: "4111111111111111"}, {Name: "expiry", Value: "13/30"}},
+			expectedErr: item.ErrInvalidFieldValue,
+		},
+		{
+			caseName:    "cardMissingExpiry",
+			itemType:    item.TypeCreditCard,
+			fields:      []item.Field{{Name: "number", Value: "4111111111111111"}},
+			expectedErr: item.ErrRequiredFieldMissing,
+		},
+		{
+			caseName: "validNote",
+			itemType: item.TypeSecureNote,
+			notes:    []string{"something secret"},
+		},
+		{
+			caseName:    "apiTokenInvalidExpiry",
+			itemType:    item.TypeAPIToken,
+			fields:      []item.Field{{Name: "token", Value: "abc"}, {Name: "expires", Value: "tomorrow"}},
+			expectedErr: item.ErrInvalidFieldValue,
+		},
+		{
+			caseName:    "identityInvalidEmail",
+			itemType:    item.TypeIdentity,
+			fields:      []item.Field{{Name: "full-name", Value: "A Person"}, {Name: "email", Value: "not an email"}},
+			expectedErr: item.ErrInvalidFieldValue,
+		},
+		{
+			caseName:    "sshKeyUnparseable",
+			itemType:    item.TypeSSHKey,
+			fields:      []item.Field{{Name: "private-key", Value: "not a key"}},
+			expectedErr: item.ErrInvalidFieldValue,
+		},
+		{
+			caseName:    "unknownType",
+			itemType:    item.Type("spaceship"),
+			fields:      []item.Field{{Name: "a", Value: "b"}},
+			expectedErr: item.ErrUnknownItemType,
+		},
+	}
+
+	for _, input := range inputs {
+		typedItem, err := item.NewTypedItem(input.itemType, input.caseName, input.notes, input.fields...)
+		if input.expectedErr != nil {
+			require.ErrorIsf(t, err, input.expectedErr, "unexpected error in case %s", input.caseName)
+			continue
+		}
+		require.NoErrorf(t, err, "unexpected error in case %s", input.caseName)
+		require.Equal(t, input.itemType, typedItem.GetType())
+	}
+}
+
+func TestTypedItemsShouldConcealSchemaFieldsAndProvideDetails(t *testing.T) {
+	card, err := item.NewTypedItem(item.TypeCreditCard, "card", nil,
+		item.Field{Name: "number", Value: "5555-5555-5555-4444"},
+		item.Field{Name: "expiry", Value: "01/2001"},
+	)
+	require.NoError(t, err)
+	number, err := card.GetField("number")
+	require.NoError(t, err)
+	require.True(t, number.Concealed)
+	details := card.Details()
+	require.Equal(t, "4444", details["last-digits"])
+	require.Equal(t, "mastercard", details["brand"])
+	require.Equal(t, "true", details["expired"])
+
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	require.NoError(t, err)
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	sshKey, err := item.NewTypedItem(item.TypeSSHKey, "key", nil, item.Field{Name: "private-key", Value: string(keyPEM)})
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(privateKey)
+	require.NoError(t, err)
+	require.Equal(t, ssh.FingerprintSHA256(signer.PublicKey()), sshKey.Details()["fingerprint"])
+	require.True(t, strings.HasPrefix(sshKey.Details()["key-type"], "ssh-ed25519"))
+
+	// items stored before types existed have no type, and are logins
+	legacy := item.Item{Name: "legacy", Password: "a"}
+	require.Equal(t, item.TypeLogin, legacy.GetType())
+	require.NoError(t, legacy.Validate())
+}
+
+func TestShouldMatchURLsByMode(t *testing.T) {
+	inputs := []struct {
+		itemURL  string
+		target   string
+		expected bool
+	}{
+		{"exact=https://example.com/login", "https://EXAMPLE.com/login#top", true},
+		{"exact=https://example.com/login", "https://example.com/login/other", false},
+		{"prefix=https://example.com/app/", "https://example.com/app/settings", true},
+		{"prefix=https://example.com/app/", "https://example.com/other", false},
+		{"prefix=https://example.com/app", "https://example.com/app", true},
+		{"prefix=https://example.com/app", "https://example.com/app/settings", true},
+		// prefixes cover whole path segments, never part of one
+		{"prefix=https://example.com/app", "https://example.com/apple", false},
+		{"prefix=https://example.com/app", "https://example.com/app-evil", false},
+		{"prefix=https://example.com", "https://example.com.evil.com/", false},
+		{"prefix=https://example.com/app", "http://example.com/app", false},
+		{"host=example.com", "https://example.com/anything", true},
+		{"example.com", "https://www.example.com", false},
+		{"host=example.com:8443", "https://example.com:8443/", true},
+		{"host=example.com:8443", "https://example.com/", false},
+		{"base-domain=https://example.co.uk", "https://login.accounts.example.co.uk/", true},
+		// co.uk is a public suffix, so different registrable domains under it must not match
+		{"base-domain=https://example.co.uk", "https://other.co.uk/", false},
+		{"base-domain=localhost", "http://localhost:3000/", true},
+		{`regex=^https://[a-z]+\.aws\.amazon\.com/`, "https://console.aws.amazon.com/ec2", true},
+		{`regex=^https://[a-z]+\.aws\.amazon\.com/`, "https://evil.com/console.aws.amazon.com/", false},
+	}
+
+	for _, input := range inputs {
+		itemURL, err := item.ParseItemURL(input.itemURL)
+		require.NoError(t, err)
+		matched, err := itemURL.Matches(input.target)
+		require.NoError(t, err)
+		require.Equalf(t, input.expected, matched, "unexpected result matching %s against %s", input.target, input.itemURL)
+	}
+
+	_, err := item.ParseItemURL("regex=[unclosed")
+	require.ErrorIs(t, err, item.ErrInvalidURL)
+	_, err = item.ParseItemURL("host=")
+	require.ErrorIs(t, err, item.ErrInvalidURL)
+
+	// the most specific matching url of an item is reported
+	multiURLItem, err := item.NewItem("multi", "user", "", "example.com", nil)
+	require.NoError(t, err)
+	multiURLItem.URLs = []item.ItemURL{{URL: "https://example.com/login", Match: item.MatchExact}}
+	mode, matched := multiURLItem.MatchURL("https://example.com/login")
+	require.True(t, matched)
+	require.Equal(t, item.MatchExact, mode)
+	mode, matched = multiURLItem.MatchURL("https://example.com/elsewhere")
+	require.True(t, matched)
+	require.Equal(t, item.MatchHost, mode)
+	_, matched = multiURLItem.MatchURL("https://example.org")
+	require.False(t, matched)
+}
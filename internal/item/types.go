@@ -0,0 +1,401 @@
+package item
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Type discriminates between the different kinds of item which can be held, each of which has its own schema
+type Type string
+
+// Types of item which can be held
+const (
+	TypeLogin      Type = "login"
+	TypeSecureNote Type = "secure-note"
+	TypeCreditCard Type = "credit-card"
+	TypeSSHKey     Type = "ssh-key"
+	TypeAPIToken   Type = "api-token"
+	TypeIdentity   Type = "identity"
+)
+
+var (
+	ErrUnknownItemType       = errors.New("unknown item type")
+	ErrRequiredFieldMissing  = errors.New("required field missing for item type")
+	ErrInvalidFieldValue     = errors.New("invalid field value for item type")
+	ErrSecureNoteWithoutNote = errors.New("secure notes must have at least one note")
+)
+
+// FieldSpec describes a field which belongs to the schema of an item type
+type FieldSpec struct {
+	Name        string
+	Description string
+	Concealed   bool
+	Required    bool
+	// Multiline fields are typically supplied from a file rather than inline
+	Multiline bool
+	Validate  func(value string) error
+}
+
+// Schema describes the fields which make up an item of a given type
+type Schema struct {
+	Type        Type
+	Description string
+	Fields      []FieldSpec
+	// details derives additional information for display from a valid item of this type
+	details func(i *Item) map[string]string
+}
+
+var schemas = map[Type]*Schema{
+	TypeLogin: {
+		Type:        TypeLogin,
+		Description: "a username and password for a website or service",
+	},
+	TypeSecureNote: {
+		Type:        TypeSecureNote,
+		Description: "free text held securely in the item notes",
+	},
+	TypeCreditCard: {
+		Type:        TypeCreditCard,
+		Description: "a payment card",
+		Fields: []FieldSpec{
+			{Name: "cardholder", Description: "name of the cardholder"},
+			{Name: "number", Description: "card number", Concealed: true, Required: true, Validate: validateCardNumber},
+			{Name: "expiry", Description: "expiry date as MM/YY or MM/YYYY", Required: true, Validate: validateCardExpiry},
+			{Name: "cvv", Description: "card verification value", Concealed: true, Validate: validateCVV},
+			{Name: "pin", Description: "card pin", Concealed: true, Validate: validateDigits},
+		},
+		details: creditCardDetails,
+	},
+	TypeSSHKey: {
+		Type:        TypeSSHKey,
+		Description: "an ssh key pair",
+		Fields: []FieldSpec{
+			{Name: "private-key", Description: "private key in PEM/OpenSSH format", Concealed: true, Required: true, Multiline: true},
+			{Name: "passphrase", Description: "passphrase protecting the private key", Concealed: true},
+			{Name: "public-key", Description: "public key in authorized_keys format", Multiline: true, Validate: validateSSHPublicKey},
+		},
+		details: sshKeyDetails,
+	},
+	TypeAPIToken: {
+		Type:        TypeAPIToken,
+		Description: "a token or key pair for an api",
+		Fields: []FieldSpec{
+			{Name: "key-id", Description: "identifier of the token or key"},
+			{Name: "token", Description: "token or secret key", Concealed: true, Required: true},
+			{Name: "expires", Description: "expiry date as YYYY-MM-DD", Validate: validateDate},
+		},
+	},
+	TypeIdentity: {
+		Type:        TypeIdentity,
+		Description: "personal details of an identity",
+		Fields: []FieldSpec{
+			{Name: "full-name", Description: "full name", Required: true},
+			{Name: "email", Description: "email address", Validate: validateEmail},
+			{Name: "phone", Description: "phone number", Validate: validatePhone},
+			{Name: "address", Description: "postal address", Multiline: true},
+			{Name: "date-of-birth", Description: "date of birth as YYYY-MM-DD", Validate: validateDate},
+		},
+	},
+}
+
+// Types returns all of the item types which are supported, in a stable order
+func Types() []Type {
+	return []Type{TypeLogin, TypeSecureNote, TypeCreditCard, TypeSSHKey, TypeAPIToken, TypeIdentity}
+}
+
+// Schemas returns the schemas of all of the item types which are supported, in the order of Types
+func Schemas() []*Schema {
+	supported := make([]*Schema, 0, len(schemas))
+	for _, itemType := range Types() {
+		supported = append(supported, schemas[itemType])
+	}
+	return supported
+}
+
+// SchemaFor returns the schema for a given item type
+func SchemaFor(itemType Type) (*Schema, error) {
+	schema, exists := schemas[itemType]
+	if !exists {
+		return nil, fmt.Errorf("%w: '%s'", ErrUnknownItemType, itemType)
+	}
+	return schema, nil
+}
+
+// NewTypedItem returns an item of the given type from the provided fields, or returns an error if these are not valid
+// for the schema of that type. Fields in the schema are concealed as the schema dictates.
+func NewTypedItem(itemType Type, name string, notes []string, fields ...Field) (*Item, error) {
+	schema, err := SchemaFor(itemType)
+	if err != nil {
+		return nil, err
+	}
+	typedFields := make([]Field, len(fields))
+	for idx, field := range fields {
+		if spec := schema.Spec(field.Name); spec != nil {
+			field.Concealed = spec.Concealed
+		}
+		typedFields[idx] = field
+	}
+
+	newItem, err := NewItem(name, "", "", "", notes, typedFields...)
+	if err != nil {
+		return nil, err
+	}
+	newItem.Type = itemType
+	err = newItem.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return newItem, nil
+}
+
+// GetType returns the type of the item - items stored before types existed are logins
+func (i *Item) GetType() Type {
+	if i.Type == "" {
+		return TypeLogin
+	}
+	return i.Type
+}
+
+// Validate checks that the item satisfies the schema for its type
+func (i *Item) Validate() error {
+	schema, err := SchemaFor(i.GetType())
+	if err != nil {
+		return err
+	}
+	if i.OTP != nil {
+		if err := i.OTP.Validate(); err != nil {
+			return err
+		}
+	}
+	for _, itemURL := range i.URLs {
+		if err := itemURL.Validate(); err != nil {
+			return err
+		}
+	}
+	if schema.Type == TypeSecureNote && len(strings.Join(i.Notes, "")) == 0 {
+		return ErrSecureNoteWithoutNote
+	}
+	for _, spec := range schema.Fields {
+		field, err := i.GetField(spec.Name)
+		if err != nil || field.Value == "" {
+			if spec.Required {
+				return fmt.Errorf("%w: %s requires '%s'", ErrRequiredFieldMissing, schema.Type, spec.Name)
+			}
+			continue
+		}
+		if spec.Validate != nil {
+			err = spec.Validate(field.Value)
+			if err != nil {
+				return fmt.Errorf("%w: '%s' - %s", ErrInvalidFieldValue, spec.Name, err)
+			}
+		}
+	}
+	if schema.Type == TypeSSHKey {
+		_, err := parseSSHPrivateKey(i)
+		if err != nil {
+			return fmt.Errorf("%w: 'private-key' - %s", ErrInvalidFieldValue, err)
+		}
+	}
+	return nil
+}
+
+// Details returns information derived from the item's type specific fields, e.g. a key fingerprint
+func (i *Item) Details() map[string]string {
+	schema, err := SchemaFor(i.GetType())
+	if err != nil || schema.details == nil {
+		return nil
+	}
+	return schema.details(i)
+}
+
+// Spec returns the spec for the named field if it is part of the schema
+func (s *Schema) Spec(name string) *FieldSpec {
+	for idx := range s.Fields {
+		if s.Fields[idx].Name == name {
+			return &s.Fields[idx]
+		}
+	}
+	return nil
+}
+
+// fieldValue is a convenience for retrieving a field value which may not be set
+func (i *Item) fieldValue(name string) string {
+	field, err := i.GetField(name)
+	if err != nil {
+		return ""
+	}
+	return field.Value
+}
+
+var digitsOnly = regexp.MustCompile(`^[0-9]+$`)
+
+func validateDigits(value string) error {
+	if !digitsOnly.MatchString(value) {
+		return errors.New("must only contain digits")
+	}
+	return nil
+}
+
+func validateCVV(value string) error {
+	if len(value) < 3 || len(value) > 4 {
+		return errors.New("must be 3 or 4 digits")
+	}
+	return validateDigits(value)
+}
+
+// normaliseCardNumber strips the spaces and dashes commonly used to group card number digits
+func normaliseCardNumber(value string) string {
+	return strings.NewReplacer(" ", "", "-", "").Replace(value)
+}
+
+func validateCardNumber(value string) error {
+	number := normaliseCardNumber(value)
+	if len(number) < 12 || len(number) > 19 {
+		return errors.New("must be between 12 and 19 digits")
+	}
+	if err := validateDigits(number); err != nil {
+		return err
+	}
+	if !luhnValid(number) {
+		return errors.New("fails the luhn check")
+	}
+	return nil
+}
+
+// luhnValid checks a string of digits against the luhn (mod 10) checksum used by card numbers
+func luhnValid(number string) bool {
+	sum := 0
+	double := false
+	for idx := len(number) - 1; idx >= 0; idx-- {
+		digit := int(number[idx] - '0')
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// parseCardExpiry returns the last moment at which a card with the given expiry is valid
+func parseCardExpiry(value string) (time.Time, error) {
+	month, year, found := strings.Cut(value, "/")
+	if !found {
+		return time.Time{}, errors.New("must be given as MM/YY or MM/YYYY")
+	}
+	m, err := strconv.Atoi(month)
+	if err != nil || m < 1 || m > 12 || len(month) != 2 {
+		return time.Time{}, errors.New("month must be between 01 and 12")
+	}
+	y, err := strconv.Atoi(year)
+	if err != nil || (len(year) != 2 && len(year) != 4) {
+		return time.Time{}, errors.New("year must be given as YY or YYYY")
+	}
+	if len(year) == 2 {
+		y += 2000
+	}
+	// cards are valid until the end of their expiry month
+	return time.Date(y, time.Month(m)+1, 1, 0, 0, 0, 0, time.UTC).Add(-time.Nanosecond), nil
+}
+
+func validateCardExpiry(value string) error {
+	_, err := parseCardExpiry(value)
+	return err
+}
+
+func validateDate(value string) error {
+	_, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return errors.New("must be a date given as YYYY-MM-DD")
+	}
+	return nil
+}
+
+func validateEmail(value string) error {
+	_, err := mail.ParseAddress(value)
+	return err
+}
+
+var phoneChars = regexp.MustCompile(`^\+?[0-9 ()\-]{3,}$`)
+
+func validatePhone(value string) error {
+	if !phoneChars.MatchString(value) {
+		return errors.New("must only contain digits, spaces, brackets, dashes and a leading +")
+	}
+	return nil
+}
+
+func validateSSHPublicKey(value string) error {
+	_, _, _, _, err := ssh.ParseAuthorizedKey([]byte(value))
+	return err
+}
+
+// parseSSHPrivateKey parses the private key of an ssh key item, using its passphrase where one is set
+func parseSSHPrivateKey(i *Item) (ssh.Signer, error) {
+	privateKey := []byte(i.fieldValue("private-key"))
+	passphrase := i.fieldValue("passphrase")
+	if passphrase != "" {
+		return ssh.ParsePrivateKeyWithPassphrase(privateKey, []byte(passphrase))
+	}
+	signer, err := ssh.ParsePrivateKey(privateKey)
+	var missingErr *ssh.PassphraseMissingError
+	if errors.As(err, &missingErr) {
+		return nil, errors.New("key is encrypted - a passphrase is required")
+	}
+	return signer, err
+}
+
+func sshKeyDetails(i *Item) map[string]string {
+	signer, err := parseSSHPrivateKey(i)
+	if err != nil {
+		return nil
+	}
+	publicKey := signer.PublicKey()
+	return map[string]string{
+		"key-type":    publicKey.Type(),
+		"fingerprint": ssh.FingerprintSHA256(publicKey),
+	}
+}
+
+func creditCardDetails(i *Item) map[string]string {
+	number := normaliseCardNumber(i.fieldValue("number"))
+	details := map[string]string{}
+	if len(number) >= 4 {
+		details["last-digits"] = number[len(number)-4:]
+	}
+	if brand := cardBrand(number); brand != "" {
+		details["brand"] = brand
+	}
+	expiry, err := parseCardExpiry(i.fieldValue("expiry"))
+	if err == nil {
+		details["expired"] = strconv.FormatBool(time.Now().After(expiry))
+	}
+	return details
+}
+
+// cardBrand makes a best effort guess at the card network from the leading digits of the card number
+func cardBrand(number string) string {
+	switch {
+	case strings.HasPrefix(number, "4"):
+		return "visa"
+	case strings.HasPrefix(number, "34"), strings.HasPrefix(number, "37"):
+		return "amex"
+	case len(number) >= 2 && number[0] == '5' && number[1] >= '1' && number[1] <= '5',
+		strings.HasPrefix(number, "22"), strings.HasPrefix(number, "27"):
+		return "mastercard"
+	case strings.HasPrefix(number, "6011"), strings.HasPrefix(number, "65"):
+		return "discover"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,204 @@
+package item
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// MatchMode determines how an item url is compared to a url being looked up
+type MatchMode string
+
+// MatchModes, from the most to the least specific
+const (
+	// MatchExact the urls must be identical, once normalised
+	MatchExact MatchMode = "exact"
+	// MatchPrefix the url being looked up must start with the item url
+	MatchPrefix MatchMode = "prefix"
+	// MatchHost the urls must have the same host (and port, if the item url has one)
+	MatchHost MatchMode = "host"
+	// MatchBaseDomain the urls must share a registrable domain, e.g. login.example.co.uk and example.co.uk
+	MatchBaseDomain MatchMode = "base-domain"
+	// MatchRegex the url being looked up must match the item url, which is a regular expression
+	MatchRegex MatchMode = "regex"
+)
+
+// DefaultMatchMode is used for urls with no match mode, including the primary url of every item
+const DefaultMatchMode = MatchHost
+
+var (
+	ErrUnknownMatchMode = errors.New("unknown url match mode")
+	ErrInvalidURL       = errors.New("invalid url")
+)
+
+// ItemURL is a url associated with an item, along with how it should be matched
+type ItemURL struct {
+	URL   string
+	Match MatchMode
+}
+
+// MatchModes returns all of the url match modes, from the most to the least specific
+func MatchModes() []MatchMode {
+	return []MatchMode{MatchExact, MatchPrefix, MatchHost, MatchBaseDomain, MatchRegex}
+}
+
+// ParseItemURL parses urls given in the form [<match-mode>=]<url>, using the default match mode if none is given
+func ParseItemURL(value string) (ItemURL, error) {
+	itemURL := ItemURL{URL: value, Match: DefaultMatchMode}
+	if mode, rawURL, found := strings.Cut(value, "="); found {
+		for _, known := range MatchModes() {
+			if MatchMode(mode) == known {
+				itemURL = ItemURL{URL: rawURL, Match: known}
+			}
+		}
+	}
+	return itemURL, itemURL.Validate()
+}
+
+// Validate checks that the url can be matched using its match mode
+func (u ItemURL) Validate() error {
+	if u.URL == "" {
+		return fmt.Errorf("%w: url cannot be blank", ErrInvalidURL)
+	}
+	switch u.mode() {
+	case MatchRegex:
+		_, err := regexp.Compile(u.URL)
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidURL, err)
+		}
+		return nil
+	case MatchExact, MatchPrefix, MatchHost, MatchBaseDomain:
+		_, err := normaliseURL(u.URL)
+		return err
+	default:
+		return fmt.Errorf("%w: '%s'", ErrUnknownMatchMode, u.Match)
+	}
+}
+
+// Matches reports whether the target url is matched by this item url
+func (u ItemURL) Matches(target string) (bool, error) {
+	if u.mode() == MatchRegex {
+		pattern, err := regexp.Compile(u.URL)
+		if err != nil {
+			return false, fmt.Errorf("%w: %s", ErrInvalidURL, err)
+		}
+		return pattern.MatchString(target), nil
+	}
+
+	itemURL, err := normaliseURL(u.URL)
+	if err != nil {
+		return false, err
+	}
+	targetURL, err := normaliseURL(target)
+	if err != nil {
+		return false, err
+	}
+
+	switch u.mode() {
+	case MatchExact:
+		return itemURL.String() == targetURL.String(), nil
+	case MatchPrefix:
+		return itemURL.Scheme == targetURL.Scheme && itemURL.Host == targetURL.Host &&
+			pathHasPrefix(targetURL.EscapedPath(), itemURL.EscapedPath()), nil
+	case MatchHost:
+		if itemURL.Port() != "" {
+			return itemURL.Host == targetURL.Host, nil
+		}
+		return itemURL.Hostname() == targetURL.Hostname(), nil
+	case MatchBaseDomain:
+		return baseDomain(itemURL.Hostname()) == baseDomain(targetURL.Hostname()), nil
+	default:
+		return false, fmt.Errorf("%w: '%s'", ErrUnknownMatchMode, u.Match)
+	}
+}
+
+func (u ItemURL) mode() MatchMode {
+	if u.Match == "" {
+		return DefaultMatchMode
+	}
+	return u.Match
+}
+
+// AllURLs returns every url associated with the item - the primary url followed by any additional urls
+func (i *Item) AllURLs() []ItemURL {
+	urls := []ItemURL{}
+	if i.URL != "" {
+		urls = append(urls, ItemURL{URL: i.URL, Match: DefaultMatchMode})
+	}
+	return append(urls, i.URLs...)
+}
+
+// MatchURL returns the most specific match mode by which any url of the item matches the target url, or false if
+// none do
+func (i *Item) MatchURL(target string) (MatchMode, bool) {
+	var (
+		best    MatchMode
+		matched bool
+	)
+	for _, itemURL := range i.AllURLs() {
+		isMatch, err := itemURL.Matches(target)
+		// urls which cannot be parsed (e.g. legacy free text) simply never match
+		if err != nil || !isMatch {
+			continue
+		}
+		if !matched || specificity(itemURL.mode()) < specificity(best) {
+			best = itemURL.mode()
+		}
+		matched = true
+	}
+	return best, matched
+}
+
+// specificity ranks match modes, where lower is more specific
+func specificity(mode MatchMode) int {
+	for rank, known := range MatchModes() {
+		if known == mode {
+			return rank
+		}
+	}
+	return len(MatchModes())
+}
+
+// normaliseURL parses a url, assuming https for urls given without a scheme (e.g. "example.com/login")
+func normaliseURL(raw string) (*url.URL, error) {
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidURL, err)
+	}
+	if parsed.Hostname() == "" {
+		return nil, fmt.Errorf("%w: '%s' has no host", ErrInvalidURL, raw)
+	}
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+	parsed.Host = strings.ToLower(parsed.Host)
+	parsed.Fragment = ""
+	if parsed.Path == "" {
+		parsed.Path = "/"
+	}
+	return parsed, nil
+}
+
+// pathHasPrefix reports whether the path is the prefix or falls beneath it, matching whole segments only - so /app
+// covers /app/settings but not /apple
+func pathHasPrefix(path, prefix string) bool {
+	if path == prefix || (strings.HasSuffix(prefix, "/") && strings.HasPrefix(path, prefix)) {
+		return true
+	}
+	return strings.HasPrefix(path, prefix+"/")
+}
+
+// baseDomain returns the registrable domain of a host according to the public suffix list
+func baseDomain(host string) string {
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		// hosts such as localhost or ip addresses have no registrable domain - they can only match themselves
+		return host
+	}
+	return domain
+}
@@ -20,6 +20,7 @@ var (
 	ErrStoreNameEmpty				   = errors.New("store name cannot be empty")
 	ErrNoChangeToStoreDataKeyValueMade = errors.New("no changes to store datakey value were made")
 	ErrInvalidStoreDataKey             = errors.New("key provided is invalid")
+	ErrStoreCleared                    = errors.New("store has been cleared from memory")
 )
 
 // LocationType informs where the store is located
@@ -44,6 +45,8 @@ type Store struct {
 	Source    *Source
 	storeData *storeData
 	password  string
+	// cleared is set once the store data has been cleared from memory, after which it cannot be saved
+	cleared bool
 }
 
 // Load reads a store into memory from a given io.reader interface
@@ -77,6 +80,20 @@ func (store *Store) GetAllStoreDataKeyValues() map[string]string {
 	return store.storeData.Data
 }
 
+// Clear removes the decrypted store data and password held in memory, leaving an empty store which can no longer be
+// saved. NOTE go cannot guarantee the memory is overwritten - this only drops every reference to it
+func (store *Store) Clear() {
+	for key := range store.storeData.Data {
+		delete(store.storeData.Data, key)
+	}
+	for key := range store.storeData.Meta {
+		delete(store.storeData.Meta, key)
+	}
+	store.storeData = &storeData{Data: map[string]string{}}
+	store.password = ""
+	store.cleared = true
+}
+
 func (store *Store) GetStoreName() string {
 	return store.storeData.Name
 }
@@ -84,6 +101,9 @@ func (store *Store) GetStoreName() string {
 // Save writes storedata held in memory to an io.Writer provided
 func (store *Store) Save(w io.Writer) error {
 	//TODO after a certain point we may need to provide more specific errors - could  wrap these... etc
+	if store.cleared {
+		return ErrStoreCleared
+	}
 	storeData, err := json.Marshal(store.storeData)
 	if err != nil {
 		return err
@@ -111,6 +131,8 @@ type storeData struct {
 	//TODO could do with defining some kind of abstraction here rather than just working directly with this... leave for now
 	Data     map[string]string `json:"data"`
 	Password string            `json:"secretKey"`
+	// Meta holds settings which apply to the store as a whole, rather than to any one key in Data
+	Meta map[string]string `json:"meta,omitempty"`
 }
 
 // getSerialisedStoreData returns the current serialised store data in memory
@@ -137,6 +159,37 @@ func (s *Store) ReplaceStoreData(replacement map[string]string) {
 	s.storeData.Data = replacement
 }
 
+// Snapshot is a copy of the store data held in memory at some point, which the store can be restored to
+type Snapshot struct {
+	storeData storeData
+}
+
+// Snapshot copies the store data held in memory, so that any changes made to it afterwards can be undone
+func (s *Store) Snapshot() *Snapshot {
+	return &Snapshot{storeData: s.storeData.copy()}
+}
+
+// Restore replaces the store data held in memory with a snapshot taken of it, undoing every change made since
+func (s *Store) Restore(snapshot *Snapshot) {
+	restored := snapshot.storeData.copy()
+	s.storeData = &restored
+}
+
+func (s *storeData) copy() storeData {
+	copied := *s
+	copied.Data = make(map[string]string, len(s.Data))
+	for key, value := range s.Data {
+		copied.Data[key] = value
+	}
+	if s.Meta != nil {
+		copied.Meta = make(map[string]string, len(s.Meta))
+		for key, value := range s.Meta {
+			copied.Meta[key] = value
+		}
+	}
+	return copied
+}
+
 // UpdateStoreDataKeyValue updates the value for a key which exists in store data
 func (s *Store) UpdateStoreDataKeyValue(key, value string) error {
 	currentVal, exists := s.storeData.Data[key]
@@ -227,3 +280,26 @@ func (s *Store) DeleteStoreDataKey(key string) error {
 	log.Debugf("post key removal:%v\n ", s.storeData.Data)
 	return nil
 }
+
+// GetStoreMetaValue retrieves the value of a store wide setting, and whether it has been set
+func (s *Store) GetStoreMetaValue(key string) (string, bool) {
+	value, exists := s.storeData.Meta[key]
+	return value, exists
+}
+
+// SetStoreMetaValue sets the value of a store wide setting in memory - setting an empty value removes the setting.
+// NOTE Persisting the change requires writing this in memory storeData somewhere using Save
+func (s *Store) SetStoreMetaValue(key, value string) error {
+	if key == "" {
+		return ErrInvalidStoreDataKey
+	}
+	if value == "" {
+		delete(s.storeData.Meta, key)
+		return nil
+	}
+	if s.storeData.Meta == nil {
+		s.storeData.Meta = make(map[string]string)
+	}
+	s.storeData.Meta[key] = value
+	return nil
+}
@@ -197,3 +197,41 @@ func TestShouldErrWriteInvalidStoreData(t *testing.T) {
 	err = newStore.CreateStoreDataKeyValue("", testDataValue)
 	require.ErrorIs(t, err, store.ErrInvalidStoreDataKey)
 }
+
+func TestShouldRestoreSnapshotOfStoreData(t *testing.T) {
+	var storage bytes.Buffer
+	newStore, err := store.CreateStore(&storage, storeName, storePassword)
+	require.NoError(t, err)
+	require.NoError(t, newStore.CreateStoreDataKeyValue("kept", "value"))
+	require.NoError(t, newStore.CreateStoreDataKeyValue("deleted", "value"))
+	require.NoError(t, newStore.SetStoreMetaValue("setting", "value"))
+
+	var before bytes.Buffer
+	require.NoError(t, newStore.Save(&before))
+	snapshot := newStore.Snapshot()
+
+	require.NoError(t, newStore.UpdateStoreDataKeyValue("kept", "changed"))
+	require.NoError(t, newStore.DeleteStoreDataKey("deleted"))
+	require.NoError(t, newStore.CreateStoreDataKeyValue("added", "value"))
+	require.NoError(t, newStore.SetStoreMetaValue("setting", ""))
+	require.NoError(t, newStore.SetStoreMetaValue("another", "value"))
+
+	newStore.Restore(snapshot)
+	require.Equal(t, map[string]string{"kept": "value", "deleted": "value"}, newStore.GetAllStoreDataKeyValues())
+	value, exists := newStore.GetStoreMetaValue("setting")
+	require.True(t, exists)
+	require.Equal(t, "value", value)
+	_, exists = newStore.GetStoreMetaValue("another")
+	require.False(t, exists)
+
+	// changes made after restoring never reach the snapshot, so it can be restored again
+	require.NoError(t, newStore.DeleteStoreDataKey("kept"))
+	newStore.Restore(snapshot)
+	var after bytes.Buffer
+	require.NoError(t, newStore.Save(&after))
+	restored, err := store.Load(&after, storePassword)
+	require.NoError(t, err)
+	original, err := store.Load(&before, storePassword)
+	require.NoError(t, err)
+	require.Equal(t, original.GetAllStoreDataKeyValues(), restored.GetAllStoreDataKeyValues())
+}
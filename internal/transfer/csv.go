@@ -0,0 +1,386 @@
+package transfer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/georgewheatcroft/simple-pass/internal/item"
+	"github.com/georgewheatcroft/simple-pass/pkg/otp"
+)
+
+var (
+	ErrUnknownFormat       = fmt.Errorf("unknown format - must be one of %v", Formats())
+	ErrUnknownTarget       = errors.New("unknown target")
+	ErrInvalidMapping      = errors.New("mapping must be given as target=column")
+	ErrNoNameColumn        = fmt.Errorf("mapping must give a column for the %s or %s of items", TargetName, TargetURL)
+	ErrMissingColumn       = errors.New("column is missing from the csv header")
+	ErrEmptyCSV            = errors.New("csv is empty")
+	ErrUndetectableFormat  = errors.New("cannot tell which export the csv is from")
+	ErrRowWithoutName      = errors.New("row has no name, and no url to name it after")
+	ErrUnsupportedItemType = errors.New("unsupported item type")
+)
+
+// Format is a password manager or browser whose csv exports can be imported
+type Format string
+
+const (
+	FormatChrome    Format = "chrome"
+	FormatFirefox   Format = "firefox"
+	FormatBitwarden Format = "bitwarden"
+	FormatLastPass  Format = "lastpass"
+)
+
+// Formats returns every format with a built-in mapping
+func Formats() []Format {
+	return []Format{FormatChrome, FormatFirefox, FormatBitwarden, FormatLastPass}
+}
+
+// targets which a column can be mapped to. Any number of columns can also be mapped to custom fields, by prefixing
+// the name of the field with FieldTargetPrefix (or SecretFieldTargetPrefix for concealed fields)
+const (
+	TargetName     = "name"
+	TargetFolder   = "folder"
+	TargetUsername = "username"
+	TargetPassword = "password"
+	TargetURL      = "url"
+	TargetNotes    = "notes"
+	TargetOTP      = "otp"
+
+	FieldTargetPrefix       = "field."
+	SecretFieldTargetPrefix = "secret."
+)
+
+// Targets returns every target which is not a custom field
+func Targets() []string {
+	return []string{TargetName, TargetFolder, TargetUsername, TargetPassword, TargetURL, TargetNotes, TargetOTP}
+}
+
+// mapping describes the layout of a csv export - which column each part of an item is read from
+type mapping struct {
+	// columns maps each target to the header of the column holding it
+	columns map[string]string
+	// identifiedBy are further columns which exports of the format always have, telling them apart from others
+	identifiedBy []string
+	// finish makes changes specific to the export the mapping is for, given every column of the row
+	finish func(passItem *item.Item, row map[string]string) error
+}
+
+// mappingFor returns the built-in mapping for the csv exports of a format
+func mappingFor(format Format) (*mapping, error) {
+	switch format {
+	case FormatChrome:
+		return &mapping{
+			columns: map[string]string{TargetName: "name", TargetURL: "url", TargetUsername: "username", TargetPassword: "password"},
+			// only newer versions export notes
+			finish: func(passItem *item.Item, row map[string]string) error {
+				passItem.Notes = notesFrom(row["note"])
+				return nil
+			},
+		}, nil
+	case FormatFirefox:
+		// firefox has no names for logins - they are named after their url
+		return &mapping{
+			columns:      map[string]string{TargetURL: "url", TargetUsername: "username", TargetPassword: "password"},
+			identifiedBy: []string{"httpRealm", "formActionOrigin"},
+		}, nil
+	case FormatBitwarden:
+		return &mapping{
+			columns: map[string]string{
+				TargetFolder: "folder", TargetName: "name", TargetNotes: "notes", TargetURL: "login_uri",
+				TargetUsername: "login_username", TargetPassword: "login_password", TargetOTP: "login_totp",
+			},
+			finish: finishBitwarden,
+		}, nil
+	case FormatLastPass:
+		return &mapping{
+			columns: map[string]string{
+				TargetURL: "url", TargetUsername: "username", TargetPassword: "password", TargetOTP: "totp",
+				TargetNotes: "extra", TargetName: "name",
+			},
+			identifiedBy: []string{"grouping"},
+			finish:       finishLastPass,
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: '%s'", ErrUnknownFormat, format)
+}
+
+// bitwarden exports secure notes alongside logins, and custom fields as "name: value" lines
+func finishBitwarden(passItem *item.Item, row map[string]string) error {
+	switch row["type"] {
+	case "", "login":
+	case "note":
+		passItem.Type = item.TypeSecureNote
+	default:
+		return fmt.Errorf("%w: '%s'", ErrUnsupportedItemType, row["type"])
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(row["fields"], "\r\n", "\n"), "\n") {
+		name, value, found := strings.Cut(line, ": ")
+		if !found || name == "" {
+			continue
+		}
+		err := passItem.SetField(name, value, false)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lastPassSecureNoteURL is the url lastpass exports secure notes with
+const lastPassSecureNoteURL = "http://sn"
+
+// lastpass exports secure notes with a placeholder url, and nests groups using backslashes
+func finishLastPass(passItem *item.Item, row map[string]string) error {
+	if passItem.URL == lastPassSecureNoteURL {
+		passItem.URL = ""
+		passItem.Type = item.TypeSecureNote
+	}
+	if folder := row["grouping"]; folder != "" {
+		passItem.Name = joinFolder(strings.ReplaceAll(folder, `\`, item.FolderSeparator), passItem.Name)
+	}
+	return nil
+}
+
+// parseMapping returns a mapping from pairs given as target=column, starting from a base mapping if one is given
+func parseMapping(base *mapping, pairs []string) (*mapping, error) {
+	parsed := &mapping{columns: map[string]string{}}
+	if base != nil {
+		parsed.finish = base.finish
+		for target, column := range base.columns {
+			parsed.columns[target] = column
+		}
+	}
+	for _, pair := range pairs {
+		target, column, found := strings.Cut(pair, "=")
+		if !found || target == "" || column == "" {
+			return nil, fmt.Errorf("%w: '%s'", ErrInvalidMapping, pair)
+		}
+		if !isTarget(target) {
+			return nil, fmt.Errorf("%w: '%s' - must be one of %v, or a custom field given as %s<name> or %s<name>",
+				ErrUnknownTarget, target, Targets(), FieldTargetPrefix, SecretFieldTargetPrefix)
+		}
+		parsed.columns[target] = column
+	}
+	if parsed.columns[TargetName] == "" && parsed.columns[TargetURL] == "" {
+		return nil, ErrNoNameColumn
+	}
+	return parsed, nil
+}
+
+func isTarget(target string) bool {
+	for _, known := range Targets() {
+		if target == known {
+			return true
+		}
+	}
+	for _, prefix := range []string{FieldTargetPrefix, SecretFieldTargetPrefix} {
+		if name, found := strings.CutPrefix(target, prefix); found && name != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// detectFormat returns the format whose exports have the given columns. The formats are tried most specific first,
+// as the columns of some are a subset of others
+func detectFormat(columns map[string]bool) (Format, *mapping, error) {
+	for _, format := range []Format{FormatBitwarden, FormatLastPass, FormatChrome, FormatFirefox} {
+		detected, err := mappingFor(format)
+		if err != nil {
+			return "", nil, err
+		}
+		if detected.missingColumn(columns, detected.identifiedBy...) == "" {
+			return format, detected, nil
+		}
+	}
+	return "", nil, ErrUndetectableFormat
+}
+
+// missingColumn returns a column of the mapping (or one of the others given) which is not one of the columns of the
+// csv, or "" if none are missing
+func (m *mapping) missingColumn(columns map[string]bool, others ...string) string {
+	required := append([]string{}, others...)
+	for _, column := range m.columns {
+		required = append(required, column)
+	}
+	sort.Strings(required)
+	for _, column := range required {
+		if !columns[column] {
+			return column
+		}
+	}
+	return ""
+}
+
+// CSVImport is what was read from a csv export
+type CSVImport struct {
+	// Format is the export the csv was read as, or "" if it was only read using the mapping given
+	Format  Format
+	Entries []Entry
+}
+
+// Entry is an item read from a row of a csv export (or an entry of a KeePass database), or why one could not be
+type Entry struct {
+	// Row is the number of the row, counting the header as row 1 - or for KeePass, of the entry
+	Row  int
+	Item *item.Item
+	// Attachments are the files attached to the item, which are held outside of it
+	Attachments []Attachment
+	Err         error
+}
+
+// Attachment is a file attached to an item being imported
+type Attachment struct {
+	Name string
+	Data []byte
+}
+
+// ReadCSV reads an item from every row following the header of a csv export. The layout of the csv is that of the
+// format given, or (if none is) of whichever format's exports have the same header. Columns can be mapped to targets
+// using pairs given as target=column, either on top of the format's layout or to describe a layout of any other csv.
+// Rows which cannot be read as a valid item are returned with the reason why, rather than stopping the rest being read
+func ReadCSV(r io.Reader, format Format, mappingPairs []string) (*CSVImport, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, ErrEmptyCSV
+	}
+	if err != nil {
+		return nil, err
+	}
+	// exports written by some tools begin with a byte order mark
+	header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	columns := map[string]bool{}
+	for _, column := range header {
+		columns[column] = true
+	}
+
+	var base *mapping
+	if format != "" {
+		base, err = mappingFor(format)
+	} else {
+		format, base, err = detectFormat(columns)
+		if errors.Is(err, ErrUndetectableFormat) && len(mappingPairs) > 0 {
+			err = nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	csvMapping, err := parseMapping(base, mappingPairs)
+	if err != nil {
+		return nil, err
+	}
+	if missing := csvMapping.missingColumn(columns); missing != "" {
+		return nil, fmt.Errorf("%w: '%s'", ErrMissingColumn, missing)
+	}
+
+	read := &CSVImport{Format: format, Entries: []Entry{}}
+	for rowNumber := 2; ; rowNumber++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return read, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		row := map[string]string{}
+		for idx, column := range header {
+			if idx < len(record) {
+				row[column] = record[idx]
+			}
+		}
+		passItem, err := csvMapping.itemFrom(row)
+		read.Entries = append(read.Entries, Entry{Row: rowNumber, Item: passItem, Err: err})
+	}
+}
+
+// itemFrom returns the item held in a row of a csv export
+func (m *mapping) itemFrom(row map[string]string) (*item.Item, error) {
+	value := func(target string) string {
+		return row[m.columns[target]]
+	}
+
+	name := strings.TrimSpace(value(TargetName))
+	if name == "" {
+		name = nameFromURL(value(TargetURL))
+	}
+	if name == "" {
+		return nil, ErrRowWithoutName
+	}
+
+	targets := make([]string, 0, len(m.columns))
+	for target := range m.columns {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+	fields := []item.Field{}
+	for _, target := range targets {
+		fieldValue := value(target)
+		if fieldName, found := strings.CutPrefix(target, FieldTargetPrefix); found && fieldValue != "" {
+			fields = append(fields, item.Field{Name: fieldName, Value: fieldValue})
+		} else if fieldName, found := strings.CutPrefix(target, SecretFieldTargetPrefix); found && fieldValue != "" {
+			fields = append(fields, item.Field{Name: fieldName, Value: fieldValue, Concealed: true})
+		}
+	}
+
+	passItem, err := item.NewItem(joinFolder(value(TargetFolder), name), value(TargetUsername), value(TargetPassword),
+		value(TargetURL), notesFrom(value(TargetNotes)), fields...)
+	if err != nil {
+		return nil, err
+	}
+
+	if secret := strings.TrimSpace(value(TargetOTP)); secret != "" {
+		if strings.HasPrefix(secret, "otpauth://") {
+			passItem.OTP, err = otp.ParseURI(secret)
+		} else {
+			passItem.OTP, err = otp.NewTOTPKey(secret)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid otp: %w", err)
+		}
+	}
+
+	if m.finish != nil {
+		err = m.finish(passItem, row)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := passItem.Validate(); err != nil {
+		return nil, err
+	}
+	return passItem, nil
+}
+
+// notesFrom splits multi-line notes into the lines items hold them as
+func notesFrom(notes string) []string {
+	notes = strings.TrimRight(strings.ReplaceAll(notes, "\r\n", "\n"), "\n")
+	if notes == "" {
+		return nil
+	}
+	return strings.Split(notes, "\n")
+}
+
+// nameFromURL returns the host of a url, to name an item after
+func nameFromURL(rawURL string) string {
+	parsed, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return ""
+	}
+	return parsed.Hostname()
+}
+
+func joinFolder(folder, name string) string {
+	folder = strings.Trim(strings.TrimSpace(folder), item.FolderSeparator)
+	if folder == "" {
+		return name
+	}
+	return folder + item.FolderSeparator + name
+}
@@ -0,0 +1,139 @@
+package transfer_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/georgewheatcroft/simple-pass/internal/item"
+	"github.com/georgewheatcroft/simple-pass/internal/transfer"
+	"github.com/stretchr/testify/require"
+)
+
+const testOTPSecret = "JBSWY3DPEHPK3PXP"
+
+func TestShouldReadCSVExportsOfEachFormat(t *testing.T) {
+	tests := []struct {
+		format transfer.Format
+		csv    string
+		check  func(t *testing.T, entries []transfer.Entry)
+	}{
+		{
+			format: transfer.FormatChrome,
+			csv: "name,url,username,password,note\n" +
+				"example.com,https://example.com/login,alice,Chrome-Password-1,\"first\nsecond\"\n",
+			check: func(t *testing.T, entries []transfer.Entry) {
+				passItem := entries[0].Item
+				require.Equal(t, "example.com", passItem.Name)
+				require.Equal(t, "https://example.com/login", passItem.URL)
+				require.Equal(t, "alice", passItem.Username)
+				require.Equal(t, "Chrome-Password-1", passItem.Password)
+				require.Equal(t, []string{"first", "second"}, passItem.Notes)
+			},
+		},
+		{
+			format: transfer.FormatFirefox,
+			csv: `"url","username","password","httpRealm","formActionOrigin","guid","timeCreated","timeLastUsed","timePasswordChanged"` + "\n" +
+				`"https://accounts.example.com","bob","Firefox-Password-1",,"https://accounts.example.com","{guid}","1","2","3"` + "\n",
+			check: func(t *testing.T, entries []transfer.Entry) {
+				passItem := entries[0].Item
+				require.Equal(t, "accounts.example.com", passItem.Name)
+				require.Equal(t, "bob", passItem.Username)
+				require.Equal(t, "Firefox-Password-1", passItem.Password)
+			},
+		},
+		{
+			format: transfer.FormatBitwarden,
+			csv: "folder,favorite,type,name,notes,fields,reprompt,login_uri,login_username,login_password,login_totp\n" +
+				"work,,login,db,,\"pin: 1234\nregion: eu\",0,https://db.example.com,admin,Bitwarden-Password-1," + testOTPSecret + "\n" +
+				",,note,recovery codes,\"code one\ncode two\",,0,,,,\n" +
+				",,card,visa,expires soon,,0,,,,\n",
+			check: func(t *testing.T, entries []transfer.Entry) {
+				passItem := entries[0].Item
+				require.Equal(t, "work/db", passItem.Name)
+				require.Equal(t, "admin", passItem.Username)
+				require.NotNil(t, passItem.OTP)
+				pin, err := passItem.GetField("pin")
+				require.NoError(t, err)
+				require.Equal(t, "1234", pin.Value)
+				require.Len(t, passItem.Fields, 2)
+
+				note := entries[1].Item
+				require.Equal(t, item.TypeSecureNote, note.Type)
+				require.Equal(t, []string{"code one", "code two"}, note.Notes)
+
+				require.ErrorIs(t, entries[2].Err, transfer.ErrUnsupportedItemType)
+				require.Nil(t, entries[2].Item)
+			},
+		},
+		{
+			format: transfer.FormatLastPass,
+			csv: "url,username,password,totp,extra,name,grouping,fav\n" +
+				"https://mail.example.com,carol,LastPass-Password-1,,,mail,Personal\\Email,0\n" +
+				"http://sn,,,,the wifi password is hunter2,wifi,,0\n",
+			check: func(t *testing.T, entries []transfer.Entry) {
+				require.Equal(t, "Personal/Email/mail", entries[0].Item.Name)
+				note := entries[1].Item
+				require.Equal(t, item.TypeSecureNote, note.Type)
+				require.Empty(t, note.URL)
+				require.Equal(t, []string{"the wifi password is hunter2"}, note.Notes)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(string(test.format), func(t *testing.T) {
+			// the format is detected from the header when it is not given
+			for _, format := range []transfer.Format{test.format, ""} {
+				read, err := transfer.ReadCSV(strings.NewReader(test.csv), format, nil)
+				require.NoError(t, err)
+				require.Equal(t, test.format, read.Format)
+				require.Equal(t, 2, read.Entries[0].Row)
+				test.check(t, read.Entries)
+			}
+		})
+	}
+}
+
+func TestShouldReadCSVWithCustomMapping(t *testing.T) {
+	const csv = "Title,Group,Login,Secret,Site,Comments,PIN\n" +
+		"router,home,admin,Custom-Password-1,http://192.168.0.1,,0000\n" +
+		",home,nobody,,,,\n"
+	mapping := []string{"name=Title", "folder=Group", "username=Login", "password=Secret", "url=Site", "notes=Comments", "secret.pin=PIN"}
+
+	read, err := transfer.ReadCSV(strings.NewReader(csv), "", mapping)
+	require.NoError(t, err)
+	require.Empty(t, read.Format)
+	require.Len(t, read.Entries, 2)
+	passItem := read.Entries[0].Item
+	require.Equal(t, "home/router", passItem.Name)
+	require.Equal(t, "admin", passItem.Username)
+	pin, err := passItem.GetField("pin")
+	require.NoError(t, err)
+	require.True(t, pin.Concealed)
+	require.Equal(t, 3, read.Entries[1].Row)
+	require.ErrorIs(t, read.Entries[1].Err, transfer.ErrRowWithoutName)
+
+	// mappings can also adjust the layout of a format
+	read, err = transfer.ReadCSV(strings.NewReader("name,url,username,password,tag\nsite,https://site.example,u,Chrome-Password-1,red\n"),
+		transfer.FormatChrome, []string{"field.tag=tag"})
+	require.NoError(t, err)
+	tag, err := read.Entries[0].Item.GetField("tag")
+	require.NoError(t, err)
+	require.Equal(t, "red", tag.Value)
+
+	_, err = transfer.ReadCSV(strings.NewReader(csv), "", nil)
+	require.ErrorIs(t, err, transfer.ErrUndetectableFormat)
+	_, err = transfer.ReadCSV(strings.NewReader(csv), "", []string{"title=Title"})
+	require.ErrorIs(t, err, transfer.ErrUnknownTarget)
+	_, err = transfer.ReadCSV(strings.NewReader(csv), "", []string{"name"})
+	require.ErrorIs(t, err, transfer.ErrInvalidMapping)
+	_, err = transfer.ReadCSV(strings.NewReader(csv), "", []string{"username=Login"})
+	require.ErrorIs(t, err, transfer.ErrNoNameColumn)
+	_, err = transfer.ReadCSV(strings.NewReader(csv), "", []string{"name=Missing"})
+	require.ErrorIs(t, err, transfer.ErrMissingColumn)
+	_, err = transfer.ReadCSV(strings.NewReader(csv), transfer.FormatChrome, nil)
+	require.ErrorIs(t, err, transfer.ErrMissingColumn)
+	_, err = transfer.ReadCSV(strings.NewReader(csv), "keepass", nil)
+	require.ErrorIs(t, err, transfer.ErrUnknownFormat)
+	_, err = transfer.ReadCSV(strings.NewReader(""), "", nil)
+	require.ErrorIs(t, err, transfer.ErrEmptyCSV)
+}
@@ -0,0 +1,306 @@
+package transfer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/georgewheatcroft/simple-pass/internal/item"
+	"github.com/georgewheatcroft/simple-pass/pkg/kdbx"
+	"github.com/georgewheatcroft/simple-pass/pkg/otp"
+	"github.com/google/uuid"
+)
+
+/*
+	KeePass entries are mapped to items, and the groups holding them to the folders in the items' names. The standard
+	strings of an entry (title, username, password, url and notes) map to those of an item, and every other string to
+	a custom field - concealed if the string is protected. Attachments are kept, but the history of an entry is only
+	used to tell when its password was last changed.
+
+	One time passwords are read from the strings KeePassXC and KeePass each hold them in, and additional urls from
+	those KeePassXC and Keepass2Android use. What simple-pass holds that KeePass has no place for (item types, url match
+	modes, rotation policies and password profiles) is written as custom data of the entry, so it can be read back.
+*/
+
+var ErrEntryWithoutName = errors.New("entry has no title, and no url to name it after")
+
+// the strings of an entry which are not read as custom fields
+const (
+	kdbxOTP           = "otp"
+	kdbxTimeOTP       = "TimeOtp-Secret-Base32"
+	kdbxTimeOTPPrefix = "TimeOtp-"
+	kdbxURLPrefix     = "KP2A_URL"
+)
+
+// the custom data written to entries for what KeePass has no place for
+const (
+	customDataType            = "simple-pass/type"
+	customDataURLs            = "simple-pass/urls"
+	customDataRotationDays    = "simple-pass/rotation-days"
+	customDataPasswordProfile = "simple-pass/password-profile"
+)
+
+// ReadKDBX reads an item from every entry of a KeePass database, other than those in its recycle bin. Entries are
+// numbered in the order they are read, with those of each group before those of the groups within it. Entries which
+// cannot be read as a valid item are returned with the reason why, rather than stopping the rest being read
+func ReadKDBX(r io.Reader, password string) ([]Entry, error) {
+	database, err := kdbx.Read(r, password)
+	if err != nil {
+		return nil, err
+	}
+	entries := []Entry{}
+	var readGroup func(group *kdbx.Group, folder string)
+	readGroup = func(group *kdbx.Group, folder string) {
+		if group.UUID == database.RecycleBin && database.RecycleBin != uuid.Nil {
+			return
+		}
+		for _, kdbxEntry := range group.Entries {
+			passItem, attachments, err := itemFromKDBX(kdbxEntry, folder)
+			entries = append(entries, Entry{Row: len(entries) + 1, Item: passItem, Attachments: attachments, Err: err})
+		}
+		for _, child := range group.Groups {
+			readGroup(child, joinFolder(folder, strings.ReplaceAll(child.Name, item.FolderSeparator, "-")))
+		}
+	}
+	if database.Root != nil {
+		// the root group is the database itself, rather than a folder
+		readGroup(database.Root, "")
+	}
+	return entries, nil
+}
+
+// itemFromKDBX returns the item held in an entry of a KeePass database, along with its attachments
+func itemFromKDBX(entry *kdbx.Entry, folder string) (*item.Item, []Attachment, error) {
+	name := strings.TrimSpace(entry.Get(kdbx.KeyTitle))
+	if name == "" {
+		name = nameFromURL(entry.Get(kdbx.KeyURL))
+	}
+	if name == "" {
+		return nil, nil, ErrEntryWithoutName
+	}
+	name = joinFolder(folder, name)
+	notes := notesFrom(entry.Get(kdbx.KeyNotes))
+
+	fields := []item.Field{}
+	for _, str := range entry.Strings {
+		if str.Value != "" && !isReservedKDBXString(str.Key) {
+			fields = append(fields, item.Field{Name: str.Key, Value: str.Value, Concealed: str.Protected})
+		}
+	}
+
+	var (
+		passItem *item.Item
+		err      error
+	)
+	itemType := item.Type(entry.CustomData[customDataType])
+	if itemType == "" || itemType == item.TypeLogin {
+		passItem, err = item.NewItem(name, entry.Get(kdbx.KeyUserName), entry.Get(kdbx.KeyPassword),
+			entry.Get(kdbx.KeyURL), notes, fields...)
+	} else {
+		passItem, err = item.NewTypedItem(itemType, name, notes, fields...)
+		if err == nil {
+			passItem.Username, passItem.Password, passItem.URL = entry.Get(kdbx.KeyUserName), entry.Get(kdbx.KeyPassword), entry.Get(kdbx.KeyURL)
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if uri := strings.TrimSpace(entry.Get(kdbxOTP)); uri != "" {
+		if strings.HasPrefix(uri, "otpauth://") {
+			passItem.OTP, err = otp.ParseURI(uri)
+		} else {
+			passItem.OTP, err = otp.NewTOTPKey(uri)
+		}
+	} else if secret := strings.TrimSpace(entry.Get(kdbxTimeOTP)); secret != "" {
+		passItem.OTP, err = otp.NewTOTPKey(secret)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid otp: %w", err)
+	}
+
+	passItem.URLs, err = urlsFromKDBX(entry)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, tag := range entry.Tags {
+		err = passItem.AddTag(tag)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if days := entry.CustomData[customDataRotationDays]; days != "" {
+		passItem.RotationDays, err = strconv.Atoi(days)
+		if err != nil || passItem.RotationDays < 0 {
+			return nil, nil, fmt.Errorf("invalid rotation days '%s'", days)
+		}
+	}
+	passItem.PasswordProfile = entry.CustomData[customDataPasswordProfile]
+	setTimesFromKDBX(passItem, entry)
+
+	if err := passItem.Validate(); err != nil {
+		return nil, nil, err
+	}
+	attachments := []Attachment{}
+	for _, binary := range entry.Binaries {
+		// simple-pass does not hold empty attachments
+		if len(binary.Data) > 0 {
+			attachments = append(attachments, Attachment{Name: strings.ReplaceAll(binary.Name, "/", "_"), Data: binary.Data})
+		}
+	}
+	return passItem, attachments, nil
+}
+
+// isReservedKDBXString returns whether a string of an entry holds part of an item other than a custom field
+func isReservedKDBXString(key string) bool {
+	switch key {
+	case kdbx.KeyTitle, kdbx.KeyUserName, kdbx.KeyPassword, kdbx.KeyURL, kdbx.KeyNotes, kdbxOTP:
+		return true
+	}
+	return strings.HasPrefix(key, kdbxTimeOTPPrefix) || strings.HasPrefix(key, kdbxURLPrefix)
+}
+
+// urlsFromKDBX returns the additional urls of an entry - as written by simple-pass if it was, otherwise from the
+// strings KeePassXC and Keepass2Android hold them in
+func urlsFromKDBX(entry *kdbx.Entry) ([]item.ItemURL, error) {
+	urls := []item.ItemURL{}
+	if written, exists := entry.CustomData[customDataURLs]; exists {
+		for _, line := range notesFrom(written) {
+			itemURL, err := item.ParseItemURL(line)
+			if err != nil {
+				return nil, err
+			}
+			urls = append(urls, itemURL)
+		}
+		return urls, nil
+	}
+	for _, str := range entry.Strings {
+		if strings.HasPrefix(str.Key, kdbxURLPrefix) && strings.TrimSpace(str.Value) != "" {
+			urls = append(urls, item.ItemURL{URL: strings.TrimSpace(str.Value), Match: item.DefaultMatchMode})
+		}
+	}
+	if len(urls) == 0 {
+		return nil, nil
+	}
+	return urls, nil
+}
+
+// setTimesFromKDBX sets when the item was created and modified, and when its password was last changed - which is
+// when the entry was modified, unless earlier versions in its history have the same password
+func setTimesFromKDBX(passItem *item.Item, entry *kdbx.Entry) {
+	if entry.Times.Created.IsZero() || entry.Times.Modified.IsZero() {
+		return
+	}
+	passItem.Created, passItem.Modified = entry.Times.Created, entry.Times.Modified
+	if passItem.Password == "" {
+		return
+	}
+	passItem.PasswordChanged = entry.Times.Modified
+	for idx := len(entry.History) - 1; idx >= 0; idx-- {
+		previous := entry.History[idx]
+		if previous.Get(kdbx.KeyPassword) != passItem.Password || previous.Times.Modified.IsZero() {
+			break
+		}
+		passItem.PasswordChanged = previous.Times.Modified
+	}
+}
+
+// WriteKDBX writes the items as a KeePass database, with their folders as its groups. Attachments are read using
+// the function given, as the items only hold the keys to decrypt them
+func WriteKDBX(w io.Writer, password string, options kdbx.Options, name string, items []*item.Item,
+	readAttachment func(itemName, attachmentName string) ([]byte, error)) error {
+	now := time.Now().UTC()
+	groupTimes := kdbx.Times{Created: now, Modified: now, Accessed: now}
+	root := &kdbx.Group{UUID: uuid.New(), Name: name, Times: groupTimes}
+	groups := map[string]*kdbx.Group{"": root}
+	var groupFor func(folder string) *kdbx.Group
+	groupFor = func(folder string) *kdbx.Group {
+		if group, exists := groups[folder]; exists {
+			return group
+		}
+		parent, groupName := "", folder
+		if idx := strings.LastIndex(folder, item.FolderSeparator); idx >= 0 {
+			parent, groupName = folder[:idx], folder[idx+1:]
+		}
+		group := &kdbx.Group{UUID: uuid.New(), Name: groupName, Times: groupTimes}
+		parentGroup := groupFor(parent)
+		parentGroup.Groups = append(parentGroup.Groups, group)
+		groups[folder] = group
+		return group
+	}
+
+	sorted := append([]*item.Item{}, items...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	for _, passItem := range sorted {
+		entry, err := entryFromItem(passItem, readAttachment)
+		if err != nil {
+			return fmt.Errorf("%s: %w", passItem.Name, err)
+		}
+		group := groupFor(passItem.Folder())
+		group.Entries = append(group.Entries, entry)
+	}
+	return kdbx.Write(w, &kdbx.Database{Name: name, Root: root}, password, options)
+}
+
+// entryFromItem returns a KeePass entry holding an item and its attachments
+func entryFromItem(passItem *item.Item, readAttachment func(itemName, attachmentName string) ([]byte, error)) (*kdbx.Entry, error) {
+	entry := &kdbx.Entry{
+		UUID: passItem.ID,
+		Times: kdbx.Times{
+			Created:  passItem.Created,
+			Modified: passItem.Modified,
+			Accessed: passItem.Modified,
+		},
+		Tags:       passItem.Tags,
+		CustomData: map[string]string{},
+	}
+	if entry.UUID == uuid.Nil {
+		entry.UUID = uuid.New()
+	}
+	entry.Set(kdbx.KeyTitle, strings.TrimPrefix(passItem.Name[len(passItem.Folder()):], item.FolderSeparator), false)
+	entry.Set(kdbx.KeyUserName, passItem.Username, false)
+	entry.Set(kdbx.KeyPassword, passItem.Password, true)
+	entry.Set(kdbx.KeyURL, passItem.URL, false)
+	entry.Set(kdbx.KeyNotes, strings.Join(passItem.Notes, "\n"), false)
+	if passItem.OTP != nil {
+		entry.Set(kdbxOTP, passItem.OTP.URI(), true)
+	}
+	for _, field := range passItem.Fields {
+		entry.Set(field.Name, field.Value, field.Concealed)
+	}
+
+	urls := []string{}
+	for idx, itemURL := range passItem.URLs {
+		key := kdbxURLPrefix
+		if idx > 0 {
+			key += "_" + strconv.Itoa(idx)
+		}
+		entry.Set(key, itemURL.URL, false)
+		urls = append(urls, string(itemURL.Match)+"="+itemURL.URL)
+	}
+	if len(urls) > 0 {
+		entry.CustomData[customDataURLs] = strings.Join(urls, "\n")
+	}
+	if passItem.GetType() != item.TypeLogin {
+		entry.CustomData[customDataType] = string(passItem.GetType())
+	}
+	if passItem.RotationDays != 0 {
+		entry.CustomData[customDataRotationDays] = strconv.Itoa(passItem.RotationDays)
+	}
+	if passItem.PasswordProfile != "" {
+		entry.CustomData[customDataPasswordProfile] = passItem.PasswordProfile
+	}
+
+	for _, attachment := range passItem.Attachments {
+		data, err := readAttachment(passItem.Name, attachment.Name)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read attachment '%s': %w", attachment.Name, err)
+		}
+		entry.Binaries = append(entry.Binaries, kdbx.Binary{Name: attachment.Name, Data: data})
+	}
+	return entry, nil
+}
@@ -0,0 +1,215 @@
+package transfer_test
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/georgewheatcroft/simple-pass/internal/item"
+	"github.com/georgewheatcroft/simple-pass/internal/transfer"
+	"github.com/georgewheatcroft/simple-pass/pkg/argon2"
+	"github.com/georgewheatcroft/simple-pass/pkg/kdbx"
+	"github.com/georgewheatcroft/simple-pass/pkg/otp"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// the fixture is written by pkg/kdbx, laid out as KeePass and KeePassXC lay out their databases. It can be rewritten
+// using go test ./internal/transfer -update
+var update = flag.Bool("update", false, "rewrite the kdbx fixture in testdata")
+
+const (
+	kdbxFixture  = "keepass.kdbx"
+	kdbxPassword = "Fixture-Password-1"
+)
+
+var testKDBXOptions = kdbx.Options{Cipher: kdbx.CipherChaCha20, KDF: kdbx.KDF{Mode: argon2.ModeD, Iterations: 1, Memory: 256, Parallelism: 1}}
+
+func keepassDatabase() *kdbx.Database {
+	changed := time.Date(2023, 11, 5, 8, 0, 0, 0, time.UTC)
+	created := time.Date(2022, 1, 10, 12, 0, 0, 0, time.UTC)
+	modified := time.Date(2024, 2, 14, 16, 45, 0, 0, time.UTC)
+	times := kdbx.Times{Created: created, Modified: modified, Accessed: modified}
+	entry := func(strings ...kdbx.String) *kdbx.Entry {
+		return &kdbx.Entry{UUID: uuid.New(), Times: times, Strings: strings}
+	}
+
+	db := entry(
+		kdbx.String{Key: kdbx.KeyTitle, Value: "db"},
+		kdbx.String{Key: kdbx.KeyUserName, Value: "admin"},
+		kdbx.String{Key: kdbx.KeyPassword, Value: "Database-Password-2", Protected: true},
+		kdbx.String{Key: kdbx.KeyURL, Value: "https://db.example.com"},
+		kdbx.String{Key: kdbx.KeyNotes, Value: "primary\r\nreplica in eu"},
+		kdbx.String{Key: "KP2A_URL", Value: "https://replica.example.com"},
+		kdbx.String{Key: "KP2A_URL_1", Value: "https://backup.example.com"},
+		kdbx.String{Key: "otp", Value: "otpauth://totp/Example:admin?secret=" + testOTPSecret + "&period=30&digits=6&issuer=Example", Protected: true},
+		kdbx.String{Key: "pin", Value: "1234", Protected: true},
+		kdbx.String{Key: "region", Value: "eu-west-1"},
+	)
+	db.Tags = []string{"prod", "critical"}
+	db.Binaries = []kdbx.Binary{{Name: "ca.pem", Data: []byte("-----BEGIN CERTIFICATE-----\n")}, {Name: "empty.txt"}}
+	// the password was last changed in the second version of the entry
+	history := func(password string, modified time.Time) *kdbx.Entry {
+		previous := entry(kdbx.String{Key: kdbx.KeyTitle, Value: "db"}, kdbx.String{Key: kdbx.KeyPassword, Value: password, Protected: true})
+		previous.UUID, previous.Times.Modified = db.UUID, modified
+		return previous
+	}
+	db.History = []*kdbx.Entry{history("Database-Password-1", created), history("Database-Password-2", changed)}
+
+	bank := entry(
+		kdbx.String{Key: kdbx.KeyTitle, Value: "bank"},
+		kdbx.String{Key: kdbx.KeyUserName, Value: "alice"},
+		kdbx.String{Key: kdbx.KeyPassword, Value: "Bank-Password-1", Protected: true},
+		kdbx.String{Key: "TimeOtp-Secret-Base32", Value: testOTPSecret, Protected: true},
+		kdbx.String{Key: "TimeOtp-Period", Value: "30"},
+	)
+	untitled := entry(kdbx.String{Key: kdbx.KeyURL, Value: "https://untitled.example.com/login"}, kdbx.String{Key: kdbx.KeyPassword, Value: "Untitled-Password-1"})
+	nameless := entry(kdbx.String{Key: kdbx.KeyUserName, Value: "nobody"})
+	deleted := entry(kdbx.String{Key: kdbx.KeyTitle, Value: "deleted"}, kdbx.String{Key: kdbx.KeyPassword, Value: "Deleted-Password-1"})
+
+	recycleBin := &kdbx.Group{UUID: uuid.New(), Name: "Recycle Bin", Times: times, Entries: []*kdbx.Entry{deleted}}
+	return &kdbx.Database{
+		Name: "Passwords",
+		Root: &kdbx.Group{
+			UUID:    uuid.New(),
+			Name:    "Passwords",
+			Times:   times,
+			Entries: []*kdbx.Entry{bank, untitled, nameless},
+			Groups: []*kdbx.Group{
+				{UUID: uuid.New(), Name: "Work", Times: times, Groups: []*kdbx.Group{
+					{UUID: uuid.New(), Name: "Servers", Times: times, Entries: []*kdbx.Entry{db}},
+				}},
+				recycleBin,
+			},
+		},
+		RecycleBin: recycleBin.UUID,
+	}
+}
+
+func TestShouldReadKeePassDatabase(t *testing.T) {
+	path := filepath.Join("testdata", kdbxFixture)
+	if *update {
+		buf := &bytes.Buffer{}
+		require.NoError(t, kdbx.Write(buf, keepassDatabase(), kdbxPassword, testKDBXOptions))
+		require.NoError(t, os.MkdirAll("testdata", 0700))
+		require.NoError(t, os.WriteFile(path, buf.Bytes(), 0600))
+	}
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	entries, err := transfer.ReadKDBX(file, kdbxPassword)
+	require.NoError(t, err)
+	// the entry in the recycle bin is not read
+	require.Len(t, entries, 4)
+	for idx, entry := range entries {
+		require.Equal(t, idx+1, entry.Row)
+	}
+
+	bank := entries[0].Item
+	require.Equal(t, "bank", bank.Name)
+	require.Equal(t, "alice", bank.Username)
+	require.NotNil(t, bank.OTP)
+	require.Equal(t, testOTPSecret, bank.OTP.Secret)
+	require.Empty(t, bank.Fields)
+	require.Equal(t, "untitled.example.com", entries[1].Item.Name)
+	require.ErrorIs(t, entries[2].Err, transfer.ErrEntryWithoutName)
+
+	db := entries[3].Item
+	require.Equal(t, "Work/Servers/db", db.Name)
+	require.Equal(t, "admin", db.Username)
+	require.Equal(t, "Database-Password-2", db.Password)
+	require.Equal(t, "https://db.example.com", db.URL)
+	require.Equal(t, []string{"primary", "replica in eu"}, db.Notes)
+	require.Equal(t, []item.ItemURL{
+		{URL: "https://replica.example.com", Match: item.DefaultMatchMode},
+		{URL: "https://backup.example.com", Match: item.DefaultMatchMode},
+	}, db.URLs)
+	require.Equal(t, "Example", db.OTP.Issuer)
+	require.Equal(t, []item.Field{{Name: "pin", Value: "1234", Concealed: true}, {Name: "region", Value: "eu-west-1"}}, db.Fields)
+	require.Equal(t, []string{"prod", "critical"}, db.Tags)
+	require.Equal(t, time.Date(2022, 1, 10, 12, 0, 0, 0, time.UTC), db.Created)
+	require.Equal(t, time.Date(2024, 2, 14, 16, 45, 0, 0, time.UTC), db.Modified)
+	require.Equal(t, time.Date(2023, 11, 5, 8, 0, 0, 0, time.UTC), db.PasswordChanged)
+	// empty attachments are not kept
+	require.Equal(t, []transfer.Attachment{{Name: "ca.pem", Data: []byte("-----BEGIN CERTIFICATE-----\n")}}, entries[3].Attachments)
+
+	_, err = file.Seek(0, 0)
+	require.NoError(t, err)
+	_, err = transfer.ReadKDBX(file, "Wrong-Password-1")
+	require.ErrorIs(t, err, kdbx.ErrInvalidCredentials)
+}
+
+func TestShouldWriteItemsAsKeePassDatabase(t *testing.T) {
+	login, err := item.NewItem("work/servers/db", "admin", "Database-Password-1", "https://db.example.com", []string{"first", "second"},
+		item.Field{Name: "pin", Value: "1234", Concealed: true})
+	require.NoError(t, err)
+	login.URLs = []item.ItemURL{{URL: "https://db.example.com/admin", Match: item.MatchPrefix}}
+	login.OTP, err = otp.NewTOTPKey(testOTPSecret)
+	require.NoError(t, err)
+	require.NoError(t, login.AddTag("prod"))
+	login.RotationDays = 90
+	login.PasswordProfile = "pin"
+	login.Attachments = []item.Attachment{{Name: "ca.pem"}}
+	note, err := item.NewTypedItem(item.TypeSecureNote, "recovery codes", []string{"code one", "code two"})
+	require.NoError(t, err)
+	card, err := item.NewTypedItem(item.TypeCreditCard, "work/visa", nil,
+		item.Field{Name: "cardholder", Value: "Alice"}, item.Field{Name: "number", Value: "4111 1111 1111 1111"},
+		item.Field{Name: "expiry", Value: "12/30"}, item.Field{Name: "cvv", Value: "123"})
+	require.NoError(t, err)
+
+	readAttachment := func(itemName, attachmentName string) ([]byte, error) {
+		return []byte(itemName + "/" + attachmentName), nil
+	}
+	path := filepath.Join(t.TempDir(), "export.kdbx")
+	file, err := os.Create(path)
+	require.NoError(t, err)
+	require.NoError(t, transfer.WriteKDBX(file, kdbxPassword, testKDBXOptions, "export", []*item.Item{login, note, card}, readAttachment))
+	require.NoError(t, file.Close())
+
+	file, err = os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	database, err := kdbx.Read(file, kdbxPassword)
+	require.NoError(t, err)
+	require.Equal(t, "export", database.Root.Name)
+	require.Equal(t, []string{"work"}, groupNames(database.Root.Groups))
+	require.Equal(t, []string{"servers"}, groupNames(database.Root.Groups[0].Groups))
+	exported := database.Root.Groups[0].Groups[0].Entries[0]
+	require.Equal(t, login.ID, exported.UUID)
+	require.Equal(t, "db", exported.Get(kdbx.KeyTitle))
+	require.Equal(t, "https://db.example.com/admin", exported.Get("KP2A_URL"))
+
+	_, err = file.Seek(0, 0)
+	require.NoError(t, err)
+	entries, err := transfer.ReadKDBX(file, kdbxPassword)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	read := map[string]transfer.Entry{}
+	for _, entry := range entries {
+		require.NoError(t, entry.Err)
+		read[entry.Item.Name] = entry
+	}
+	for _, original := range []*item.Item{login, note, card} {
+		roundTripped := read[original.Name].Item
+		require.NotNil(t, roundTripped, original.Name)
+		// items are given new ids when imported, and attachments are added to them separately
+		roundTripped.ID, roundTripped.Attachments = original.ID, original.Attachments
+		require.Equal(t, original.Created.Truncate(time.Second), roundTripped.Created)
+		require.Equal(t, original.Modified.Truncate(time.Second), roundTripped.Modified)
+		roundTripped.Created, roundTripped.Modified, roundTripped.PasswordChanged = original.Created, original.Modified, original.PasswordChanged
+		require.Equal(t, original, roundTripped)
+	}
+	require.Equal(t, []transfer.Attachment{{Name: "ca.pem", Data: []byte("work/servers/db/ca.pem")}}, read[login.Name].Attachments)
+}
+
+func groupNames(groups []*kdbx.Group) []string {
+	names := []string{}
+	for _, group := range groups {
+		names = append(names, group.Name)
+	}
+	return names
+}
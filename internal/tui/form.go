@@ -0,0 +1,224 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/georgewheatcroft/simple-pass/internal/db"
+	"github.com/georgewheatcroft/simple-pass/internal/item"
+)
+
+type formResult int
+
+const (
+	formEditing formResult = iota
+	formSubmitted
+	formCancelled
+)
+
+type formField struct {
+	label string
+	value string
+	// secret values are masked as they are typed
+	secret bool
+	// exact values are kept as typed rather than trimmed, as credentials can begin or end with spaces
+	exact bool
+}
+
+// form is filled in to add, edit or rename an item. submit saves it to the vault
+type form struct {
+	title  string
+	fields []formField
+	focus  int
+	// done is the status shown once the form is saved
+	done string
+	// weakAccepted is set once a password weaker than the password policy allows has been refused, so that saving
+	// again keeps it anyway
+	weakAccepted bool
+	save         func(f *form, vault Vault) (string, error)
+}
+
+func newAddForm() *form {
+	return &form{
+		title: "add item",
+		fields: []formField{
+			{label: "name"},
+			{label: "username", exact: true},
+			{label: "password", secret: true, exact: true},
+			{label: "url"},
+			{label: "tags"},
+		},
+		save: func(f *form, vault Vault) (string, error) {
+			newItem, err := item.NewItem(f.value("name"), f.value("username"), f.value("password"), f.value("url"), nil)
+			if err != nil {
+				return "", err
+			}
+			err = setTags(newItem, f.value("tags"))
+			if err != nil {
+				return "", err
+			}
+			if newItem.Password != "" {
+				err = f.checkPasswordStrength(vault, newItem)
+				if err != nil {
+					return "", err
+				}
+			}
+			err = vault.SaveNewItem(newItem)
+			if err != nil {
+				return "", err
+			}
+			f.done = fmt.Sprintf("added '%s'", newItem.Name)
+			return newItem.Name, nil
+		},
+	}
+}
+
+func newEditForm(existing *item.Item) *form {
+	return &form{
+		title: fmt.Sprintf("edit '%s'", existing.Name),
+		fields: []formField{
+			{label: "username", value: existing.Username, exact: true},
+			{label: "password", value: existing.Password, secret: true, exact: true},
+			{label: "url", value: existing.URL},
+			{label: "tags", value: strings.Join(existing.Tags, ", ")},
+		},
+		save: func(f *form, vault Vault) (string, error) {
+			// the item is retrieved again, so that nothing the form does not show is lost
+			edited, err := vault.RetrieveItem(existing.Name)
+			if err != nil {
+				return "", err
+			}
+			edited.Username, edited.Password, edited.URL = f.value("username"), f.value("password"), f.value("url")
+			edited.Tags = nil
+			err = setTags(edited, f.value("tags"))
+			if err != nil {
+				return "", err
+			}
+			if edited.Password != "" && edited.Password != existing.Password {
+				err = f.checkPasswordStrength(vault, edited)
+				if err != nil {
+					return "", err
+				}
+			}
+			err = vault.UpdateItem(edited)
+			if errors.Is(err, db.ErrItemUnchanged) {
+				f.done = fmt.Sprintf("'%s' was unchanged", edited.Name)
+				return edited.Name, nil
+			}
+			if err != nil {
+				return "", err
+			}
+			f.done = fmt.Sprintf("updated '%s'", edited.Name)
+			return edited.Name, nil
+		},
+	}
+}
+
+func newRenameForm(existing *item.Item) *form {
+	return &form{
+		title:  fmt.Sprintf("rename '%s'", existing.Name),
+		fields: []formField{{label: "new name", value: existing.Name}},
+		save: func(f *form, vault Vault) (string, error) {
+			desired := f.value("new name")
+			err := vault.RenameItem(existing.Name, desired)
+			if err != nil {
+				return "", err
+			}
+			f.done = fmt.Sprintf("renamed '%s' to '%s'", existing.Name, desired)
+			return desired, nil
+		},
+	}
+}
+
+// update changes the form to reflect a key press, returning whether it has been submitted or cancelled
+func (f *form) update(key Key) formResult {
+	field := &f.fields[f.focus]
+	switch key.Type {
+	case KeyEsc:
+		return formCancelled
+	case KeyCtrlS:
+		return formSubmitted
+	case KeyEnter:
+		if f.focus == len(f.fields)-1 {
+			return formSubmitted
+		}
+		f.focus++
+	case KeyTab, KeyDown:
+		f.focus = (f.focus + 1) % len(f.fields)
+	case KeyUp:
+		f.focus = (f.focus + len(f.fields) - 1) % len(f.fields)
+	case KeyBackspace:
+		runes := []rune(field.value)
+		if len(runes) > 0 {
+			field.value = string(runes[:len(runes)-1])
+		}
+	case KeyRune:
+		field.value += string(key.Rune)
+	}
+	return formEditing
+}
+
+// submit saves the form to the vault, returning the name of the item saved
+func (f *form) submit(vault Vault) (string, error) {
+	return f.save(f, vault)
+}
+
+func (f *form) value(label string) string {
+	for _, field := range f.fields {
+		if field.label == label && field.exact {
+			return field.value
+		}
+		if field.label == label {
+			return strings.TrimSpace(field.value)
+		}
+	}
+	return ""
+}
+
+// checkPasswordStrength refuses passwords weaker than the password policy allows - but only once, so that they can be
+// kept by saving again
+func (f *form) checkPasswordStrength(vault Vault, passItem *item.Item) error {
+	_, err := vault.CheckPasswordStrength(passItem)
+	if errors.Is(err, db.ErrPasswordTooWeak) {
+		if f.weakAccepted {
+			return nil
+		}
+		f.weakAccepted = true
+		return fmt.Errorf("%s - save again to keep it anyway", err)
+	}
+	return err
+}
+
+func (f *form) lines() []string {
+	lines := []string{f.title, ""}
+	for idx, field := range f.fields {
+		marker := "  "
+		if idx == f.focus {
+			marker = "> "
+		}
+		value := field.value
+		if field.secret {
+			value = strings.Repeat("*", len([]rune(value)))
+		}
+		if idx == f.focus {
+			value += "_"
+		}
+		lines = append(lines, fmt.Sprintf("%s%-9s %s", marker, field.label+":", value))
+	}
+	return lines
+}
+
+// setTags adds the comma separated tags to the item
+func setTags(passItem *item.Item, tags string) error {
+	for _, tag := range strings.Split(tags, ",") {
+		if strings.TrimSpace(tag) == "" {
+			continue
+		}
+		err := passItem.AddTag(tag)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
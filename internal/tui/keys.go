@@ -0,0 +1,77 @@
+package tui
+
+import "unicode/utf8"
+
+// KeyType is the kind of key pressed
+type KeyType int
+
+const (
+	KeyRune KeyType = iota
+	KeyEnter
+	KeyTab
+	KeyBackspace
+	KeyEsc
+	KeyUp
+	KeyDown
+	KeyCtrlC
+	KeyCtrlS
+)
+
+// Key is a key press. Rune is only set for KeyRune
+type Key struct {
+	Type KeyType
+	Rune rune
+}
+
+// Runes returns a key press for each rune of the text, as if it were typed
+func Runes(text string) []Key {
+	keys := []Key{}
+	for _, r := range text {
+		keys = append(keys, Key{Type: KeyRune, Rune: r})
+	}
+	return keys
+}
+
+// ParseKeys decodes the key presses in input read from a terminal in raw mode. Escape sequences for keys the ui does
+// not use (e.g. function keys) are dropped, as are other control characters
+func ParseKeys(input []byte) []Key {
+	keys := []Key{}
+	for len(input) > 0 {
+		b := input[0]
+		switch {
+		case b == 0x1b && len(input) > 2 && (input[1] == '[' || input[1] == 'O'):
+			switch input[2] {
+			case 'A':
+				keys = append(keys, Key{Type: KeyUp})
+			case 'B':
+				keys = append(keys, Key{Type: KeyDown})
+			}
+			// sequences end with their first byte in the range @ to ~, after any parameters
+			end := 2
+			for end < len(input)-1 && (input[end] < 0x40 || input[end] > 0x7e) {
+				end++
+			}
+			input = input[end+1:]
+			continue
+		case b == 0x1b:
+			keys = append(keys, Key{Type: KeyEsc})
+		case b == '\r' || b == '\n':
+			keys = append(keys, Key{Type: KeyEnter})
+		case b == '\t':
+			keys = append(keys, Key{Type: KeyTab})
+		case b == 0x7f || b == 0x08:
+			keys = append(keys, Key{Type: KeyBackspace})
+		case b == 0x03:
+			keys = append(keys, Key{Type: KeyCtrlC})
+		case b == 0x13:
+			keys = append(keys, Key{Type: KeyCtrlS})
+		case b >= 0x20:
+			r, size := utf8.DecodeRune(input)
+			keys = append(keys, Key{Type: KeyRune, Rune: r})
+			input = input[size:]
+			continue
+		}
+		input = input[1:]
+	}
+	return keys
+}
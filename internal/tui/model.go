@@ -0,0 +1,418 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/georgewheatcroft/simple-pass/internal/db"
+	"github.com/georgewheatcroft/simple-pass/internal/item"
+	"github.com/georgewheatcroft/simple-pass/pkg/strength"
+)
+
+// Vault is the passdb the ui browses and edits - satisfied by *db.PassDB
+type Vault interface {
+	ListAllItems() []string
+	RetrieveItem(name string) (*item.Item, error)
+	SaveNewItem(passItem *item.Item) error
+	UpdateItem(passItem *item.Item) error
+	RenameItem(current, desired string) error
+	DeleteItem(name string) error
+	Search(query string, fields ...db.SearchField) ([]db.SearchResult, error)
+	CheckPasswordStrength(passItem *item.Item) (*strength.Result, error)
+}
+
+// Clipboard is where the ui copies values to
+type Clipboard interface {
+	Copy(value string) error
+}
+
+type mode int
+
+const (
+	modeBrowse mode = iota
+	modeFilter
+	modeForm
+	modeConfirmDelete
+)
+
+const (
+	// minListWidth and maxListWidth bound the width of the item list, which is otherwise a third of the screen
+	minListWidth = 16
+	maxListWidth = 40
+	// chromeHeight is the number of lines taken up by everything other than the item list and detail pane
+	chromeHeight = 5
+)
+
+// keyHelp describes the keys available in each mode
+var keyHelp = map[mode]string{
+	modeBrowse:        "j/k move  / search  r reveal  c/u copy password/username  a add  e edit  n rename  d delete  q quit",
+	modeFilter:        "type to search  enter done  esc clear",
+	modeForm:          "tab/up/down move  enter next  ctrl-s save  esc cancel",
+	modeConfirmDelete: "y delete  any other key cancels",
+}
+
+// Model is the state of the ui, which is changed by key presses (see Update) and drawn by View. It has no knowledge of
+// the terminal, so can be driven directly - e.g. in tests
+type Model struct {
+	vault     Vault
+	clipboard Clipboard
+
+	mode     mode
+	filter   string
+	names    []string
+	selected int
+	// revealed shows the concealed values of the selected item, until another item is selected
+	revealed bool
+	form     *form
+	status   string
+	quitting bool
+}
+
+// NewModel returns a model listing every item in the vault
+func NewModel(vault Vault, clipboard Clipboard) *Model {
+	m := &Model{vault: vault, clipboard: clipboard}
+	m.refresh("")
+	return m
+}
+
+// Quitting returns whether the ui has been quit
+func (m *Model) Quitting() bool {
+	return m.quitting
+}
+
+// Selected returns the name of the selected item, or "" if no item is listed
+func (m *Model) Selected() string {
+	if len(m.names) == 0 {
+		return ""
+	}
+	return m.names[m.selected]
+}
+
+// Names returns the names of the items listed, in the order they are listed
+func (m *Model) Names() []string {
+	return append([]string{}, m.names...)
+}
+
+// Status returns the message shown about the last thing done, e.g. an error saving an item
+func (m *Model) Status() string {
+	return m.status
+}
+
+// Update changes the model to reflect a key press
+func (m *Model) Update(key Key) {
+	if key.Type == KeyCtrlC {
+		m.quitting = true
+		return
+	}
+	switch m.mode {
+	case modeFilter:
+		m.updateFilter(key)
+	case modeForm:
+		m.updateForm(key)
+	case modeConfirmDelete:
+		m.mode = modeBrowse
+		if key.Type == KeyRune && key.Rune == 'y' {
+			m.delete()
+		} else {
+			m.status = "not deleted"
+		}
+	default:
+		m.updateBrowse(key)
+	}
+}
+
+func (m *Model) updateBrowse(key Key) {
+	switch {
+	case key.Type == KeyDown || key.Type == KeyRune && key.Rune == 'j':
+		m.move(1)
+	case key.Type == KeyUp || key.Type == KeyRune && key.Rune == 'k':
+		m.move(-1)
+	case key.Type == KeyEsc && m.filter != "":
+		m.filter = ""
+		m.refresh(m.Selected())
+	case key.Type != KeyRune:
+		return
+	}
+
+	switch key.Rune {
+	case 'q':
+		m.quitting = true
+	case '/':
+		m.mode = modeFilter
+		m.status = ""
+	case 'r':
+		m.revealed = !m.revealed
+	case 'c', 'u':
+		m.copy(key.Rune == 'c')
+	case 'a':
+		m.openForm(newAddForm())
+	case 'e', 'n', 'd':
+		selected, err := m.selectedItem()
+		if err != nil {
+			m.status = err.Error()
+			return
+		}
+		switch key.Rune {
+		case 'e':
+			m.openForm(newEditForm(selected))
+		case 'n':
+			m.openForm(newRenameForm(selected))
+		default:
+			m.mode = modeConfirmDelete
+			m.status = fmt.Sprintf("delete '%s'? (y/n)", selected.Name)
+		}
+	}
+}
+
+func (m *Model) updateFilter(key Key) {
+	switch key.Type {
+	case KeyEnter:
+		m.mode = modeBrowse
+		return
+	case KeyEsc:
+		m.mode = modeBrowse
+		m.filter = ""
+	case KeyBackspace:
+		runes := []rune(m.filter)
+		if len(runes) == 0 {
+			return
+		}
+		m.filter = string(runes[:len(runes)-1])
+	case KeyRune:
+		m.filter += string(key.Rune)
+	default:
+		return
+	}
+	// the best match is selected as the search changes
+	m.refresh("")
+}
+
+func (m *Model) updateForm(key Key) {
+	switch m.form.update(key) {
+	case formCancelled:
+		m.mode = modeBrowse
+		m.form = nil
+		m.status = ""
+	case formSubmitted:
+		saved, err := m.form.submit(m.vault)
+		if err != nil {
+			m.status = err.Error()
+			return
+		}
+		m.status = m.form.done
+		m.mode = modeBrowse
+		m.form = nil
+		m.refresh(saved)
+	}
+}
+
+func (m *Model) openForm(f *form) {
+	m.form = f
+	m.mode = modeForm
+	m.status = ""
+}
+
+func (m *Model) move(by int) {
+	if len(m.names) == 0 {
+		return
+	}
+	selected := m.selected + by
+	if selected < 0 || selected >= len(m.names) {
+		return
+	}
+	m.selected = selected
+	m.revealed = false
+}
+
+func (m *Model) selectedItem() (*item.Item, error) {
+	if len(m.names) == 0 {
+		return nil, errors.New("no item selected")
+	}
+	return m.vault.RetrieveItem(m.Selected())
+}
+
+func (m *Model) copy(password bool) {
+	selected, err := m.selectedItem()
+	if err != nil {
+		m.status = err.Error()
+		return
+	}
+	label, value := "username", selected.Username
+	if password {
+		label, value = "password", selected.Password
+	}
+	if value == "" {
+		m.status = fmt.Sprintf("'%s' has no %s", selected.Name, label)
+		return
+	}
+	err = m.clipboard.Copy(value)
+	if err != nil {
+		m.status = fmt.Sprintf("cannot copy %s: %s", label, err)
+		return
+	}
+	m.status = fmt.Sprintf("copied the %s of '%s'", label, selected.Name)
+}
+
+func (m *Model) delete() {
+	name := m.Selected()
+	err := m.vault.DeleteItem(name)
+	if err != nil {
+		m.status = fmt.Sprintf("cannot delete '%s': %s", name, err)
+		return
+	}
+	m.status = fmt.Sprintf("deleted '%s'", name)
+	m.refresh("")
+}
+
+// refresh lists the items matching the filter, keeping the named item selected if it is still listed
+func (m *Model) refresh(keep string) {
+	if m.filter == "" {
+		m.names = m.vault.ListAllItems()
+		sort.Strings(m.names)
+	} else {
+		results, err := m.vault.Search(m.filter, db.DefaultSearchFields()...)
+		if err != nil {
+			m.status = err.Error()
+			results = nil
+		}
+		m.names = make([]string, 0, len(results))
+		for _, result := range results {
+			m.names = append(m.names, result.ItemName)
+		}
+	}
+
+	m.selected = 0
+	for idx, name := range m.names {
+		if name == keep {
+			m.selected = idx
+		}
+	}
+	if keep != m.Selected() {
+		m.revealed = false
+	}
+}
+
+// View draws the ui as lines of text which are exactly width wide, filling the height given. Nothing is drawn if the
+// ui does not fit
+func (m *Model) View(width, height int) []string {
+	if width < minListWidth+4 || height < chromeHeight+1 {
+		return nil
+	}
+	listWidth := width / 3
+	if listWidth < minListWidth {
+		listWidth = minListWidth
+	}
+	if listWidth > maxListWidth {
+		listWidth = maxListWidth
+	}
+	paneWidth := width - listWidth - 3
+	bodyHeight := height - chromeHeight
+
+	title := fmt.Sprintf(" simple-pass - %d item(s)", len(m.names))
+	if m.filter != "" || m.mode == modeFilter {
+		title += "  search: " + m.filter
+		if m.mode == modeFilter {
+			title += "_"
+		}
+	}
+	lines := []string{fit(title, width), strings.Repeat("-", width)}
+
+	// the list scrolls to keep the selected item in view
+	offset := 0
+	if m.selected >= bodyHeight {
+		offset = m.selected - bodyHeight + 1
+	}
+	pane := m.paneLines()
+	for row := 0; row < bodyHeight; row++ {
+		entry := ""
+		if idx := offset + row; idx < len(m.names) {
+			marker := "  "
+			if idx == m.selected {
+				marker = "> "
+			}
+			entry = marker + m.names[idx]
+		}
+		detail := ""
+		if row < len(pane) {
+			detail = pane[row]
+		}
+		lines = append(lines, fit(entry, listWidth)+" | "+fit(detail, paneWidth))
+	}
+
+	return append(lines, strings.Repeat("-", width), fit(" "+m.status, width), fit(" "+keyHelp[m.mode], width))
+}
+
+// paneLines are the lines of the pane beside the item list - either the form being filled in, or the details of the
+// selected item
+func (m *Model) paneLines() []string {
+	if m.mode == modeForm {
+		return m.form.lines()
+	}
+	if len(m.names) == 0 {
+		return []string{"no items"}
+	}
+	selected, err := m.selectedItem()
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	display := selected.Masked()
+	password := display.Password
+	if m.revealed {
+		display = selected.WithoutAttachmentKeys()
+	} else if password != "" {
+		password = item.ConcealedMask
+	}
+	lines := []string{
+		"name:      " + display.Name,
+		"folder:    " + display.Folder(),
+		"type:      " + string(display.GetType()),
+		"username:  " + display.Username,
+		"password:  " + password,
+		"url:       " + display.URL,
+	}
+	for _, itemURL := range display.URLs {
+		lines = append(lines, fmt.Sprintf("url:       %s (%s)", itemURL.URL, itemURL.Match))
+	}
+	if len(display.Tags) > 0 {
+		lines = append(lines, "tags:      "+strings.Join(display.Tags, ", "))
+	}
+	for _, field := range display.Fields {
+		lines = append(lines, fmt.Sprintf("%s: %s", field.Name, field.Value))
+	}
+	for _, attachment := range display.Attachments {
+		lines = append(lines, fmt.Sprintf("attached:  %s (%d bytes)", attachment.Name, attachment.Size))
+	}
+	if display.OTP != nil {
+		lines = append(lines, "otp:       "+string(display.OTP.Kind))
+	}
+	if !display.Modified.IsZero() {
+		lines = append(lines, "modified:  "+display.Modified.Local().Format(time.RFC822))
+	}
+	if len(display.Notes) > 0 {
+		lines = append(lines, "notes:")
+		for _, note := range display.Notes {
+			lines = append(lines, "  "+note)
+		}
+	}
+	return lines
+}
+
+// fit truncates or pads the text to exactly width runes. Control characters are replaced, so that item values cannot
+// move the cursor or otherwise take control of the terminal
+func fit(text string, width int) string {
+	runes := make([]rune, 0, width)
+	for _, r := range text {
+		if len(runes) == width {
+			break
+		}
+		if r < 0x20 || r >= 0x7f && r < 0xa0 {
+			r = '?'
+		}
+		runes = append(runes, r)
+	}
+	return string(runes) + strings.Repeat(" ", width-len(runes))
+}
@@ -0,0 +1,229 @@
+package tui_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/georgewheatcroft/simple-pass/internal/common/constants"
+	"github.com/georgewheatcroft/simple-pass/internal/db"
+	"github.com/georgewheatcroft/simple-pass/internal/item"
+	"github.com/georgewheatcroft/simple-pass/internal/tui"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	dbName     = "testpassdb"
+	dbPassword = "!ProbablyThis!10"
+
+	width  = 100
+	height = 20
+)
+
+func init() {
+	log.SetLevel(log.DebugLevel)
+	// avoid overwritting local dev .passdb TODO better way
+	os.Setenv(constants.PassDBLocalDevEnvVar, "True")
+}
+
+// fakeClipboard records what is copied to it
+type fakeClipboard struct {
+	copied []string
+}
+
+func (c *fakeClipboard) Copy(value string) error {
+	c.copied = append(c.copied, value)
+	return nil
+}
+
+func setupPassDB(t *testing.T, names ...string) *db.PassDB {
+	passDB, err := db.CreatePassDB(filepath.Join(t.TempDir(), "test.db"), dbName, dbPassword)
+	require.NoError(t, err)
+	for _, name := range names {
+		newItem, err := item.NewItem(name, name+"-user", name+"-Password-1", "https://"+name, nil)
+		require.NoError(t, err)
+		require.NoError(t, passDB.SaveNewItem(newItem))
+	}
+	return passDB
+}
+
+func press(m *tui.Model, keys ...tui.Key) {
+	for _, key := range keys {
+		m.Update(key)
+	}
+}
+
+func key(keyType tui.KeyType) tui.Key {
+	return tui.Key{Type: keyType}
+}
+
+func screen(m *tui.Model) string {
+	lines := m.View(width, height)
+	return strings.Join(lines, "\n")
+}
+
+func TestShouldParseKeysReadFromTheTerminal(t *testing.T) {
+	require.Equal(t, []tui.Key{
+		{Type: tui.KeyRune, Rune: 'a'},
+		{Type: tui.KeyUp},
+		{Type: tui.KeyDown},
+		{Type: tui.KeyEnter},
+		{Type: tui.KeyBackspace},
+		{Type: tui.KeyTab},
+		{Type: tui.KeyCtrlS},
+		{Type: tui.KeyRune, Rune: 'é'},
+		{Type: tui.KeyCtrlC},
+		{Type: tui.KeyEsc},
+	}, tui.ParseKeys([]byte("a\x1b[A\x1bOB\r\x7f\t\x13\x1b[5~é\x01\x03\x1b")))
+}
+
+func TestModelShouldBrowseSearchRevealAndCopyItems(t *testing.T) {
+	passDB := setupPassDB(t, "work/github", "personal/gitlab", "bank")
+	clipboard := &fakeClipboard{}
+	m := tui.NewModel(passDB, clipboard)
+
+	require.Equal(t, []string{"bank", "personal/gitlab", "work/github"}, m.Names())
+	require.Equal(t, "bank", m.Selected())
+	lines := m.View(width, height)
+	require.Len(t, lines, height)
+	for _, line := range lines {
+		require.Len(t, []rune(line), width)
+	}
+	require.Contains(t, lines[height-1], "q quit")
+	require.Contains(t, screen(m), "bank-user")
+	require.NotContains(t, screen(m), "bank-Password-1")
+
+	// secrets are only revealed on demand, and hidden again once another item is selected
+	press(m, tui.Runes("r")...)
+	require.Contains(t, screen(m), "bank-Password-1")
+	press(m, key(tui.KeyDown))
+	require.Equal(t, "personal/gitlab", m.Selected())
+	require.NotContains(t, screen(m), "personal/gitlab-Password-1")
+
+	press(m, tui.Runes("cu")...)
+	require.Equal(t, []string{"personal/gitlab-Password-1", "personal/gitlab-user"}, clipboard.copied)
+	require.Contains(t, m.Status(), "copied the username of 'personal/gitlab'")
+
+	press(m, tui.Runes("/githb")...)
+	press(m, key(tui.KeyEnter))
+	require.Equal(t, []string{"work/github"}, m.Names())
+	require.Contains(t, screen(m), "search: githb")
+	press(m, key(tui.KeyEsc))
+	require.Len(t, m.Names(), 3)
+	require.Equal(t, "work/github", m.Selected())
+
+	press(m, tui.Runes("q")...)
+	require.True(t, m.Quitting())
+}
+
+func TestModelShouldAddEditRenameAndDeleteItems(t *testing.T) {
+	passDB := setupPassDB(t, "existing")
+	m := tui.NewModel(passDB, &fakeClipboard{})
+
+	press(m, tui.Runes("a")...)
+	press(m, tui.Runes("new")...)
+	press(m, key(tui.KeyTab))
+	press(m, tui.Runes("me")...)
+	press(m, key(tui.KeyEnter))
+	press(m, tui.Runes("hunter2-Correct-Horse")...)
+	require.Contains(t, screen(m), strings.Repeat("*", len("hunter2-Correct-Horse")))
+	require.NotContains(t, screen(m), "hunter2-Correct-Horse")
+	press(m, key(tui.KeyEnter), key(tui.KeyEnter))
+	press(m, tui.Runes("work, db")...)
+	press(m, key(tui.KeyEnter))
+	require.Equal(t, "added 'new'", m.Status())
+	require.Equal(t, "new", m.Selected())
+	added, err := passDB.RetrieveItem("new")
+	require.NoError(t, err)
+	require.Equal(t, "me", added.Username)
+	require.Equal(t, "hunter2-Correct-Horse", added.Password)
+	require.Equal(t, []string{"work", "db"}, added.Tags)
+
+	// adding an item which already exists is refused, leaving the form open to be corrected
+	press(m, tui.Runes("a")...)
+	press(m, tui.Runes("new")...)
+	press(m, key(tui.KeyTab))
+	press(m, tui.Runes("someone")...)
+	press(m, key(tui.KeyCtrlS))
+	require.NotEmpty(t, m.Status())
+	press(m, key(tui.KeyEsc))
+	require.Len(t, m.Names(), 2)
+
+	press(m, tui.Runes("e")...)
+	press(m, key(tui.KeyUp), key(tui.KeyUp))
+	press(m, tui.Runes("/login")...)
+	press(m, key(tui.KeyCtrlS))
+	require.Equal(t, "updated 'new'", m.Status())
+	edited, err := passDB.RetrieveItem("new")
+	require.NoError(t, err)
+	require.Equal(t, "/login", edited.URL)
+	require.Equal(t, added.Password, edited.Password)
+	require.Equal(t, added.Tags, edited.Tags)
+
+	press(m, tui.Runes("n")...)
+	press(m, key(tui.KeyBackspace), key(tui.KeyBackspace), key(tui.KeyBackspace))
+	press(m, tui.Runes("renamed")...)
+	press(m, key(tui.KeyEnter))
+	require.Equal(t, "renamed 'new' to 'renamed'", m.Status())
+	require.Equal(t, []string{"existing", "renamed"}, m.Names())
+	require.Equal(t, "renamed", m.Selected())
+
+	// deleting must be confirmed
+	press(m, tui.Runes("dn")...)
+	require.Len(t, m.Names(), 2)
+	press(m, tui.Runes("dy")...)
+	require.Equal(t, []string{"existing"}, m.Names())
+	_, err = passDB.RetrieveItem("renamed")
+	require.ErrorIs(t, err, db.ErrItemDoesNotExist)
+}
+
+func TestModelShouldKeepCredentialsAsTyped(t *testing.T) {
+	passDB := setupPassDB(t)
+	padded, err := item.NewItem("padded", " padded-user ", "  Padded-Password-1 ", "https://padded", nil)
+	require.NoError(t, err)
+	require.NoError(t, passDB.SaveNewItem(padded))
+	m := tui.NewModel(passDB, &fakeClipboard{})
+
+	// saving the edit form without changing anything leaves the item exactly as it was
+	press(m, tui.Runes("e")...)
+	press(m, key(tui.KeyCtrlS))
+	require.Equal(t, "'padded' was unchanged", m.Status())
+	edited, err := passDB.RetrieveItem("padded")
+	require.NoError(t, err)
+	require.Equal(t, " padded-user ", edited.Username)
+	require.Equal(t, "  Padded-Password-1 ", edited.Password)
+}
+
+func TestModelShouldOnlyKeepWeakPasswordsWhenSavedTwice(t *testing.T) {
+	passDB := setupPassDB(t)
+	require.NoError(t, passDB.SetMinPasswordScore(3))
+	m := tui.NewModel(passDB, &fakeClipboard{})
+
+	press(m, tui.Runes("a")...)
+	press(m, tui.Runes("weak")...)
+	press(m, key(tui.KeyTab), key(tui.KeyTab))
+	press(m, tui.Runes("password")...)
+	press(m, key(tui.KeyCtrlS))
+	require.Contains(t, m.Status(), db.ErrPasswordTooWeak.Error())
+	require.Empty(t, m.Names())
+
+	press(m, key(tui.KeyCtrlS))
+	require.Equal(t, []string{"weak"}, m.Names())
+}
+
+func TestModelViewShouldNeverWriteControlCharacters(t *testing.T) {
+	passDB := setupPassDB(t)
+	hostile, err := item.NewItem("hostile", "\x1b[2J\x1b]52;c;aGk=\x07", "", "", []string{"\x9b31m"})
+	require.NoError(t, err)
+	require.NoError(t, passDB.SaveNewItem(hostile))
+	m := tui.NewModel(passDB, &fakeClipboard{})
+
+	for _, line := range m.View(width, height) {
+		require.NotContains(t, line, "\x1b")
+		require.NotContains(t, line, "\x07")
+		require.NotContains(t, line, "\x9b")
+	}
+	require.Nil(t, m.View(10, height))
+}
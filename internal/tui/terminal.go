@@ -0,0 +1,149 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/georgewheatcroft/simple-pass/pkg/clipboard"
+	"golang.org/x/term"
+)
+
+const (
+	enterFullScreen = "\x1b[?1049h\x1b[?25l"
+	exitFullScreen  = "\x1b[?25h\x1b[?1049l"
+	cursorHome      = "\x1b[H"
+	clearLine       = "\x1b[K"
+	clearScreen     = "\x1b[2J"
+
+	tooSmallMessage = "the terminal is too small"
+)
+
+var ErrNotATerminal = errors.New("the ui can only be run in a terminal")
+
+// Run runs the ui full screen in the terminal until it is quit. Values copied are cleared from the clipboard after
+// clearAfter, or when the ui is quit - unless something else has been copied since. provider may be nil if there is
+// no clipboard
+func Run(ctx context.Context, vault Vault, provider clipboard.Provider, clearAfter time.Duration, in *os.File, out io.Writer) error {
+	fd := int(in.Fd())
+	if !term.IsTerminal(fd) {
+		return ErrNotATerminal
+	}
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(fd, state)
+
+	// the clipboard may be written to (e.g. by osc52) from the goroutines clearing it, as well as while drawing
+	var mu sync.Mutex
+	clip := newClearingClipboard(ctx, provider, clearAfter, &mu)
+	defer clip.close()
+
+	write := func(text string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		_, err := io.WriteString(out, text)
+		return err
+	}
+	err = write(enterFullScreen)
+	if err != nil {
+		return err
+	}
+	defer write(exitFullScreen)
+
+	model := NewModel(vault, clip)
+	buf := make([]byte, 256)
+	for !model.Quitting() {
+		width, height, err := term.GetSize(fd)
+		if err != nil {
+			return err
+		}
+		err = write(draw(model.View(width, height)))
+		if err != nil {
+			return err
+		}
+
+		n, err := in.Read(buf)
+		if err != nil {
+			return err
+		}
+		for _, key := range ParseKeys(buf[:n]) {
+			model.Update(key)
+		}
+	}
+	return nil
+}
+
+// draw returns the text which redraws the screen with the lines given
+func draw(lines []string) string {
+	if lines == nil {
+		return clearScreen + cursorHome + tooSmallMessage
+	}
+	// the last line is not followed by a new line, which would scroll the screen
+	return cursorHome + strings.Join(lines, clearLine+"\r\n") + clearLine
+}
+
+// clearingClipboard copies values to the clipboard, clearing each of them again once clearAfter has passed, or once
+// it is closed
+type clearingClipboard struct {
+	ctx        context.Context
+	cancel     context.CancelFunc
+	provider   clipboard.Provider
+	clearAfter time.Duration
+	mu         *sync.Mutex
+	clearing   sync.WaitGroup
+}
+
+func newClearingClipboard(ctx context.Context, provider clipboard.Provider, clearAfter time.Duration, mu *sync.Mutex) *clearingClipboard {
+	ctx, cancel := context.WithCancel(ctx)
+	return &clearingClipboard{ctx: ctx, cancel: cancel, provider: provider, clearAfter: clearAfter, mu: mu}
+}
+
+func (c *clearingClipboard) Copy(value string) error {
+	if c.provider == nil {
+		return clipboard.ErrNoProvider
+	}
+	c.mu.Lock()
+	err := c.provider.Copy(value)
+	c.mu.Unlock()
+	if err != nil || c.clearAfter <= 0 {
+		return err
+	}
+
+	c.clearing.Add(1)
+	go func() {
+		defer c.clearing.Done()
+		// there is nowhere to report failing to clear the clipboard without drawing over the ui
+		_, _ = clipboard.ClearAfter(c.ctx, lockedProvider{Provider: c.provider, mu: c.mu}, value, c.clearAfter)
+	}()
+	return nil
+}
+
+// close clears anything still on the clipboard straight away
+func (c *clearingClipboard) close() {
+	c.cancel()
+	c.clearing.Wait()
+}
+
+// lockedProvider holds the lock while it uses the clipboard
+type lockedProvider struct {
+	clipboard.Provider
+	mu *sync.Mutex
+}
+
+func (p lockedProvider) Paste() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.Provider.Paste()
+}
+
+func (p lockedProvider) Clear() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.Provider.Clear()
+}
@@ -0,0 +1,317 @@
+/*
+Package argon2 implements the Argon2d and Argon2id key derivation functions, as specified by RFC 9106.
+
+golang.org/x/crypto/argon2 only provides Argon2i and Argon2id, but KeePass databases are most often protected using
+Argon2d - and can also be given a secret and associated data, which it does not accept either.
+*/
+package argon2
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// Mode is the variant of Argon2 - how the blocks each block is computed from are chosen
+type Mode uint32
+
+const (
+	// ModeD chooses blocks depending on the password, which is fastest and most resistant to cracking on GPUs
+	ModeD Mode = 0
+	// ModeID chooses blocks independently of the password for the first half of the first pass, and depending on it
+	// for the rest, resisting side channel attacks as well as cracking on GPUs
+	ModeID Mode = 2
+
+	// modeI chooses blocks independently of the password throughout
+	modeI Mode = 1
+)
+
+// Version is the version of Argon2 implemented - 1.3
+const Version = 0x13
+
+const (
+	blockLength = 128
+	syncPoints  = 4
+	// maxThreads is the most lanes a derivation can use, as limited by RFC 9106
+	maxThreads = 1<<24 - 1
+)
+
+var (
+	ErrUnsupportedMode = errors.New("unsupported argon2 mode")
+	ErrInvalidParams   = errors.New("argon2 time, threads and key length must be at least 1, 1 and 4, and memory at least 8 KiB per thread")
+)
+
+// Params are the cost of deriving a key, along with any secret and associated data it also depends on
+type Params struct {
+	Mode Mode
+	// Time is the number of passes made over the memory
+	Time uint32
+	// Memory is the amount of memory used, in KiB
+	Memory  uint32
+	Threads uint32
+	Secret  []byte
+	Data    []byte
+}
+
+type block [blockLength]uint64
+
+// Validate checks that keys can be derived using the params, without deriving one
+func (p Params) Validate() error {
+	if p.Mode != ModeD && p.Mode != ModeID {
+		return ErrUnsupportedMode
+	}
+	if p.Time < 1 || p.Threads < 1 || p.Threads > maxThreads || uint64(p.Memory) < 2*syncPoints*uint64(p.Threads) {
+		return ErrInvalidParams
+	}
+	return nil
+}
+
+// Key derives a key of keyLen bytes from the password and salt
+func Key(password, salt []byte, params Params, keyLen uint32) ([]byte, error) {
+	err := params.Validate()
+	if err != nil {
+		return nil, err
+	}
+	if keyLen < 4 {
+		return nil, ErrInvalidParams
+	}
+
+	h0 := initHash(password, salt, params, keyLen)
+	// the memory is split into the same number of whole blocks for each lane
+	memory := params.Memory / (syncPoints * params.Threads) * (syncPoints * params.Threads)
+	blocks := initBlocks(&h0, memory, params.Threads)
+	processBlocks(blocks, params.Mode, params.Time, memory, params.Threads)
+	return extractKey(blocks, memory, params.Threads, keyLen), nil
+}
+
+func initHash(password, salt []byte, params Params, keyLen uint32) [blake2b.Size + 8]byte {
+	var h0 [blake2b.Size + 8]byte
+	b2, _ := blake2b.New512(nil)
+	writeUint32 := func(values ...uint32) {
+		var buf [4]byte
+		for _, value := range values {
+			binary.LittleEndian.PutUint32(buf[:], value)
+			b2.Write(buf[:])
+		}
+	}
+	writeUint32(params.Threads, keyLen, params.Memory, params.Time, Version, uint32(params.Mode))
+	for _, input := range [][]byte{password, salt, params.Secret, params.Data} {
+		writeUint32(uint32(len(input)))
+		b2.Write(input)
+	}
+	b2.Sum(h0[:0])
+	return h0
+}
+
+// initBlocks allocates the memory, computing the first two blocks of every lane from the initial hash
+func initBlocks(h0 *[blake2b.Size + 8]byte, memory, threads uint32) []block {
+	var buf [1024]byte
+	blocks := make([]block, memory)
+	for lane := uint32(0); lane < threads; lane++ {
+		first := lane * (memory / threads)
+		binary.LittleEndian.PutUint32(h0[blake2b.Size+4:], lane)
+		for idx := uint32(0); idx < 2; idx++ {
+			binary.LittleEndian.PutUint32(h0[blake2b.Size:], idx)
+			variableHash(buf[:], h0[:])
+			for word := range blocks[first+idx] {
+				blocks[first+idx][word] = binary.LittleEndian.Uint64(buf[word*8:])
+			}
+		}
+	}
+	return blocks
+}
+
+// processBlocks fills the memory, making each pass a slice at a time, with the segment of each lane within a slice
+// computed concurrently
+func processBlocks(blocks []block, mode Mode, time, memory, threads uint32) {
+	laneLength := memory / threads
+	segmentLength := laneLength / syncPoints
+
+	processSegment := func(pass, slice, lane uint32) {
+		var addresses, input, zero block
+		independent := mode == modeI || (mode == ModeID && pass == 0 && slice < syncPoints/2)
+		nextAddresses := func() {
+			input[6]++
+			compress(&addresses, &input, &zero, false)
+			compress(&addresses, &addresses, &zero, false)
+		}
+		if independent {
+			input[0], input[1], input[2] = uint64(pass), uint64(lane), uint64(slice)
+			input[3], input[4], input[5] = uint64(memory), uint64(time), uint64(mode)
+		}
+
+		index := uint32(0)
+		if pass == 0 && slice == 0 {
+			// the first two blocks of the lane are already computed
+			index = 2
+			if independent {
+				nextAddresses()
+			}
+		}
+		offset := lane*laneLength + slice*segmentLength + index
+		for ; index < segmentLength; index, offset = index+1, offset+1 {
+			prev := offset - 1
+			if index == 0 && slice == 0 {
+				prev += laneLength
+			}
+			var random uint64
+			if independent {
+				if index%blockLength == 0 {
+					nextAddresses()
+				}
+				random = addresses[index%blockLength]
+			} else {
+				random = blocks[prev][0]
+			}
+			ref := referenceIndex(random, laneLength, segmentLength, threads, pass, slice, lane, index)
+			// every block starts zeroed, so xoring the first pass into it is the same as overwriting it
+			compress(&blocks[offset], &blocks[prev], &blocks[ref], true)
+		}
+	}
+
+	for pass := uint32(0); pass < time; pass++ {
+		for slice := uint32(0); slice < syncPoints; slice++ {
+			var wg sync.WaitGroup
+			for lane := uint32(0); lane < threads; lane++ {
+				wg.Add(1)
+				go func(lane uint32) {
+					defer wg.Done()
+					processSegment(pass, slice, lane)
+				}(lane)
+			}
+			wg.Wait()
+		}
+	}
+}
+
+// referenceIndex returns the index of the block which the block being computed is computed from, along with the one
+// before it
+func referenceIndex(random uint64, laneLength, segmentLength, threads, pass, slice, lane, index uint32) uint32 {
+	refLane := uint32(random>>32) % threads
+	if pass == 0 && slice == 0 {
+		refLane = lane
+	}
+
+	// the blocks which can be referenced - those finished in this pass (or from the last pass, those not yet
+	// overwritten), less the one before the block being computed
+	area, start := 3*segmentLength, ((slice+1)%syncPoints)*segmentLength
+	if lane == refLane {
+		area += index
+	}
+	if pass == 0 {
+		area, start = slice*segmentLength, 0
+		if slice == 0 || lane == refLane {
+			area += index
+		}
+	}
+	if index == 0 || lane == refLane {
+		area--
+	}
+
+	relative := random & 0xFFFFFFFF
+	relative = (relative * relative) >> 32
+	relative = (uint64(area) * relative) >> 32
+	return refLane*laneLength + uint32((uint64(start)+uint64(area)-(relative+1))%uint64(laneLength))
+}
+
+// extractKey xors the last block of every lane together, hashing the result into the key
+func extractKey(blocks []block, memory, threads, keyLen uint32) []byte {
+	laneLength := memory / threads
+	var final block
+	for lane := uint32(0); lane < threads; lane++ {
+		for word, value := range blocks[lane*laneLength+laneLength-1] {
+			final[word] ^= value
+		}
+	}
+	var buf [1024]byte
+	for word, value := range final {
+		binary.LittleEndian.PutUint64(buf[word*8:], value)
+	}
+	key := make([]byte, keyLen)
+	variableHash(key, buf[:])
+	return key
+}
+
+// variableHash fills out with the hash of in, using BLAKE2b however many times is needed for the length of out
+func variableHash(out, in []byte) {
+	var length [4]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(len(out)))
+	if len(out) <= blake2b.Size {
+		b2, _ := blake2b.New(len(out), nil)
+		b2.Write(length[:])
+		b2.Write(in)
+		b2.Sum(out[:0])
+		return
+	}
+
+	b2, _ := blake2b.New512(nil)
+	b2.Write(length[:])
+	b2.Write(in)
+	previous := b2.Sum(nil)
+	// every hash but the last contributes only its first half, and the last is only as long as what remains
+	for len(out) > blake2b.Size {
+		copy(out, previous[:blake2b.Size/2])
+		out = out[blake2b.Size/2:]
+		if len(out) > blake2b.Size {
+			next := blake2b.Sum512(previous)
+			previous = next[:]
+		}
+	}
+	b2, _ = blake2b.New(len(out), nil)
+	b2.Write(previous)
+	b2.Sum(out[:0])
+}
+
+// compress computes a block from two others, either overwriting the output or xoring into it
+func compress(out, in1, in2 *block, xor bool) {
+	var t block
+	for i := range t {
+		t[i] = in1[i] ^ in2[i]
+	}
+	// the block is treated as an 8x8 matrix of 16 byte registers, mixed first by row then by column
+	for i := 0; i < blockLength; i += 16 {
+		blamka(&t[i], &t[i+1], &t[i+2], &t[i+3], &t[i+4], &t[i+5], &t[i+6], &t[i+7],
+			&t[i+8], &t[i+9], &t[i+10], &t[i+11], &t[i+12], &t[i+13], &t[i+14], &t[i+15])
+	}
+	for i := 0; i < 16; i += 2 {
+		blamka(&t[i], &t[i+1], &t[16+i], &t[16+i+1], &t[32+i], &t[32+i+1], &t[48+i], &t[48+i+1],
+			&t[64+i], &t[64+i+1], &t[80+i], &t[80+i+1], &t[96+i], &t[96+i+1], &t[112+i], &t[112+i+1])
+	}
+	for i := range t {
+		if xor {
+			out[i] ^= in1[i] ^ in2[i] ^ t[i]
+		} else {
+			out[i] = in1[i] ^ in2[i] ^ t[i]
+		}
+	}
+}
+
+// blamka is the BLAKE2b round function, with each addition strengthened by a multiplication of the low halves
+func blamka(v0, v1, v2, v3, v4, v5, v6, v7, v8, v9, v10, v11, v12, v13, v14, v15 *uint64) {
+	mix(v0, v4, v8, v12)
+	mix(v1, v5, v9, v13)
+	mix(v2, v6, v10, v14)
+	mix(v3, v7, v11, v15)
+
+	mix(v0, v5, v10, v15)
+	mix(v1, v6, v11, v12)
+	mix(v2, v7, v8, v13)
+	mix(v3, v4, v9, v14)
+}
+
+func mix(a, b, c, d *uint64) {
+	*a += *b + 2*uint64(uint32(*a))*uint64(uint32(*b))
+	*d = rotr(*d^*a, 32)
+	*c += *d + 2*uint64(uint32(*c))*uint64(uint32(*d))
+	*b = rotr(*b^*c, 24)
+	*a += *b + 2*uint64(uint32(*a))*uint64(uint32(*b))
+	*d = rotr(*d^*a, 16)
+	*c += *d + 2*uint64(uint32(*c))*uint64(uint32(*d))
+	*b = rotr(*b^*c, 63)
+}
+
+func rotr(value uint64, bits uint) uint64 {
+	return value>>bits | value<<(64-bits)
+}
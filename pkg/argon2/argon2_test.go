@@ -0,0 +1,63 @@
+package argon2_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/georgewheatcroft/simple-pass/pkg/argon2"
+	"github.com/stretchr/testify/require"
+	xargon2 "golang.org/x/crypto/argon2"
+)
+
+// test vectors from RFC 9106 section 5
+func TestShouldDeriveRFCTestVectors(t *testing.T) {
+	params := argon2.Params{
+		Time:    3,
+		Memory:  32,
+		Threads: 4,
+		Secret:  bytes.Repeat([]byte{0x03}, 8),
+		Data:    bytes.Repeat([]byte{0x04}, 12),
+	}
+	password, salt := bytes.Repeat([]byte{0x01}, 32), bytes.Repeat([]byte{0x02}, 16)
+
+	for mode, expected := range map[argon2.Mode]string{
+		argon2.ModeD:  "512b391b6f1162975371d30919734294f868e3be3984f3c1a13a4db9fabe4acb",
+		argon2.ModeID: "0d640df58d78766c08c037a34a8b53c9d01ef0452d75b65eb52520e96b01e659",
+	} {
+		params.Mode = mode
+		key, err := argon2.Key(password, salt, params, 32)
+		require.NoError(t, err)
+		require.Equal(t, expected, hex.EncodeToString(key), "mode %d", mode)
+	}
+}
+
+func TestShouldDeriveSameArgon2idKeysAsXCrypto(t *testing.T) {
+	password, salt := []byte("correct horse battery staple"), []byte("somesaltsomesalt")
+	for _, params := range []argon2.Params{
+		{Mode: argon2.ModeID, Time: 1, Memory: 64, Threads: 1},
+		{Mode: argon2.ModeID, Time: 3, Memory: 1024, Threads: 2},
+		{Mode: argon2.ModeID, Time: 2, Memory: 1000, Threads: 3},
+	} {
+		for _, keyLen := range []uint32{4, 32, 64, 65, 100} {
+			key, err := argon2.Key(password, salt, params, keyLen)
+			require.NoError(t, err)
+			require.Equal(t, xargon2.IDKey(password, salt, params.Time, params.Memory, uint8(params.Threads), keyLen), key)
+		}
+	}
+}
+
+func TestShouldRejectInvalidParams(t *testing.T) {
+	_, err := argon2.Key(nil, nil, argon2.Params{Mode: 1, Time: 1, Memory: 8, Threads: 1}, 32)
+	require.ErrorIs(t, err, argon2.ErrUnsupportedMode)
+	for _, params := range []argon2.Params{
+		{Time: 0, Memory: 8, Threads: 1},
+		{Time: 1, Memory: 8, Threads: 0},
+		{Time: 1, Memory: 15, Threads: 2},
+	} {
+		_, err = argon2.Key(nil, nil, params, 32)
+		require.ErrorIs(t, err, argon2.ErrInvalidParams)
+	}
+	_, err = argon2.Key(nil, nil, argon2.Params{Time: 1, Memory: 8, Threads: 1}, 3)
+	require.ErrorIs(t, err, argon2.ErrInvalidParams)
+}
@@ -0,0 +1,175 @@
+package clipboard
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNoProvider       = errors.New("no clipboard found - install wl-clipboard, xclip or xsel, or use a terminal supporting OSC 52 over ssh")
+	ErrPasteUnsupported = errors.New("the clipboard cannot be read")
+)
+
+// Provider copies values to, and reads them back from, a clipboard
+type Provider interface {
+	// Name is the name of the clipboard, e.g. the tool used to access it
+	Name() string
+	Copy(value string) error
+	// Paste returns the value held by the clipboard, or ErrPasteUnsupported if it cannot be read
+	Paste() (string, error)
+	Clear() error
+}
+
+// Detect returns the provider for the clipboard of the current session. Graphical sessions use wl-copy (wayland),
+// xclip or xsel (X11), whichever is installed. Over ssh, OSC 52 escape sequences are written to the terminal, which
+// copies them to the clipboard of the machine it runs on
+func Detect(terminal io.Writer) (Provider, error) {
+	return detect(os.Getenv, exec.LookPath, terminal)
+}
+
+func detect(getenv func(string) string, lookPath func(string) (string, error), terminal io.Writer) (Provider, error) {
+	installed := func(names ...string) bool {
+		for _, name := range names {
+			if _, err := lookPath(name); err != nil {
+				return false
+			}
+		}
+		return true
+	}
+
+	if getenv("WAYLAND_DISPLAY") != "" && installed("wl-copy", "wl-paste") {
+		return &commandProvider{
+			name:      "wl-copy",
+			copyArgs:  []string{"wl-copy"},
+			pasteArgs: []string{"wl-paste", "--no-newline"},
+			clearArgs: []string{"wl-copy", "--clear"},
+		}, nil
+	}
+	if getenv("DISPLAY") != "" {
+		if installed("xclip") {
+			return &commandProvider{
+				name:      "xclip",
+				copyArgs:  []string{"xclip", "-selection", "clipboard", "-in"},
+				pasteArgs: []string{"xclip", "-selection", "clipboard", "-out"},
+			}, nil
+		}
+		if installed("xsel") {
+			return &commandProvider{
+				name:      "xsel",
+				copyArgs:  []string{"xsel", "--clipboard", "--input"},
+				pasteArgs: []string{"xsel", "--clipboard", "--output"},
+				clearArgs: []string{"xsel", "--clipboard", "--delete"},
+			}, nil
+		}
+	}
+	if (getenv("SSH_TTY") != "" || getenv("SSH_CONNECTION") != "") && terminal != nil {
+		return &OSC52{Terminal: terminal, Tmux: getenv("TMUX") != ""}, nil
+	}
+	return nil, ErrNoProvider
+}
+
+// commandProvider uses command line tools to access the clipboard. Without clearArgs the clipboard is cleared by
+// copying an empty value
+type commandProvider struct {
+	name      string
+	copyArgs  []string
+	pasteArgs []string
+	clearArgs []string
+}
+
+func (p *commandProvider) Name() string {
+	return p.name
+}
+
+func (p *commandProvider) Copy(value string) error {
+	return p.run(p.copyArgs, strings.NewReader(value), nil)
+}
+
+func (p *commandProvider) Paste() (string, error) {
+	var out bytes.Buffer
+	err := p.run(p.pasteArgs, nil, &out)
+	if err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+func (p *commandProvider) Clear() error {
+	if p.clearArgs == nil {
+		return p.Copy("")
+	}
+	return p.run(p.clearArgs, nil, nil)
+}
+
+func (p *commandProvider) run(args []string, stdin io.Reader, stdout io.Writer) error {
+	var stderr bytes.Buffer
+	/* #nosec */
+	command := exec.Command(args[0], args[1:]...)
+	command.Stdin = stdin
+	command.Stdout = stdout
+	command.Stderr = &stderr
+	err := command.Run()
+	if err != nil {
+		return fmt.Errorf("%s failed: %s %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// OSC52 copies values by writing OSC 52 escape sequences to a terminal, which then copies them to its own clipboard.
+// This works over ssh, but the clipboard cannot be read back
+type OSC52 struct {
+	Terminal io.Writer
+	// Tmux wraps the sequences so that tmux passes them on to the terminal it runs in
+	Tmux bool
+}
+
+func (p *OSC52) Name() string {
+	return "osc52"
+}
+
+func (p *OSC52) Copy(value string) error {
+	sequence := "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(value)) + "\x07"
+	if p.Tmux {
+		sequence = "\x1bPtmux;" + strings.ReplaceAll(sequence, "\x1b", "\x1b\x1b") + "\x1b\\"
+	}
+	_, err := io.WriteString(p.Terminal, sequence)
+	return err
+}
+
+func (p *OSC52) Paste() (string, error) {
+	return "", ErrPasteUnsupported
+}
+
+func (p *OSC52) Clear() error {
+	return p.Copy("")
+}
+
+// ClearAfter waits until the timeout passes, or the context is done (e.g. on ctrl-c), then clears the clipboard -
+// but only if it still holds the value which was copied to it, so that anything copied since is left alone. A
+// clipboard which cannot be read is always cleared, as whether it has changed cannot be known. Whether the clipboard
+// was cleared is returned
+func ClearAfter(ctx context.Context, p Provider, value string, timeout time.Duration) (bool, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	current, err := p.Paste()
+	if err != nil && !errors.Is(err, ErrPasteUnsupported) {
+		return false, err
+	}
+	if err == nil && current != value {
+		return false, nil
+	}
+	return true, p.Clear()
+}
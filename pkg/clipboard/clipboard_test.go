@@ -0,0 +1,124 @@
+package clipboard_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/georgewheatcroft/simple-pass/internal/common/constants"
+	"github.com/georgewheatcroft/simple-pass/pkg/clipboard"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	log.SetLevel(log.DebugLevel)
+	// avoid overwritting local dev .passdb TODO better way
+	os.Setenv(constants.PassDBLocalDevEnvVar, "True")
+}
+
+// fakeProvider is a clipboard held in memory, which cannot be read if unreadable is set
+type fakeProvider struct {
+	value      string
+	unreadable bool
+	cleared    bool
+}
+
+func (p *fakeProvider) Name() string { return "fake" }
+
+func (p *fakeProvider) Copy(value string) error {
+	p.value = value
+	return nil
+}
+
+func (p *fakeProvider) Paste() (string, error) {
+	if p.unreadable {
+		return "", clipboard.ErrPasteUnsupported
+	}
+	return p.value, nil
+}
+
+func (p *fakeProvider) Clear() error {
+	p.value, p.cleared = "", true
+	return nil
+}
+
+func TestClearAfterShouldOnlyClearTheValueCopied(t *testing.T) {
+	unchanged := &fakeProvider{value: "secret"}
+	cleared, err := clipboard.ClearAfter(context.Background(), unchanged, "secret", time.Millisecond)
+	require.NoError(t, err)
+	require.True(t, cleared)
+	require.Empty(t, unchanged.value)
+
+	changed := &fakeProvider{value: "copied since"}
+	cleared, err = clipboard.ClearAfter(context.Background(), changed, "secret", time.Millisecond)
+	require.NoError(t, err)
+	require.False(t, cleared)
+	require.Equal(t, "copied since", changed.value)
+
+	unreadable := &fakeProvider{value: "copied since", unreadable: true}
+	cleared, err = clipboard.ClearAfter(context.Background(), unreadable, "secret", time.Millisecond)
+	require.NoError(t, err)
+	require.True(t, cleared)
+
+	// being interrupted clears straight away rather than waiting out the timeout
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	interrupted := &fakeProvider{value: "secret"}
+	start := time.Now()
+	cleared, err = clipboard.ClearAfter(ctx, interrupted, "secret", time.Hour)
+	require.NoError(t, err)
+	require.True(t, cleared)
+	require.Less(t, time.Since(start), time.Minute)
+}
+
+func TestDetectShouldFindTheSessionClipboard(t *testing.T) {
+	// a fake xsel, which keeps the (single line) clipboard in a file using nothing but shell builtins, as it is the
+	// only command on the path
+	bin := t.TempDir()
+	clip := filepath.Join(bin, "clip")
+	script := `#!/bin/sh
+case "$2" in
+--input) IFS= read -r value; printf '%s' "$value" > "` + clip + `" ;;
+--output) IFS= read -r value < "` + clip + `"; printf '%s' "$value" ;;
+--delete) : > "` + clip + `" ;;
+esac
+`
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "xsel"), []byte(script), 0700))
+	t.Setenv("PATH", bin)
+	t.Setenv("WAYLAND_DISPLAY", "")
+	t.Setenv("DISPLAY", ":0")
+
+	provider, err := clipboard.Detect(nil)
+	require.NoError(t, err)
+	require.Equal(t, "xsel", provider.Name())
+	require.NoError(t, provider.Copy("secret"))
+	pasted, err := provider.Paste()
+	require.NoError(t, err)
+	require.Equal(t, "secret", pasted)
+	require.NoError(t, provider.Clear())
+	pasted, err = provider.Paste()
+	require.NoError(t, err)
+	require.Empty(t, pasted)
+
+	// over ssh without a display, the terminal is asked to copy
+	t.Setenv("DISPLAY", "")
+	t.Setenv("SSH_TTY", "/dev/pts/0")
+	t.Setenv("TMUX", "")
+	var terminal bytes.Buffer
+	provider, err = clipboard.Detect(&terminal)
+	require.NoError(t, err)
+	require.Equal(t, "osc52", provider.Name())
+	require.NoError(t, provider.Copy("secret"))
+	require.Equal(t, "\x1b]52;c;c2VjcmV0\x07", terminal.String())
+	_, err = provider.Paste()
+	require.ErrorIs(t, err, clipboard.ErrPasteUnsupported)
+
+	t.Setenv("SSH_TTY", "")
+	t.Setenv("SSH_CONNECTION", "")
+	_, err = clipboard.Detect(&terminal)
+	require.ErrorIs(t, err, clipboard.ErrNoProvider)
+}
@@ -14,7 +14,11 @@ import (
 const minKeyLength = 32
 const minPasswordLength = 5
 
+// KeyLength is the length in bytes of keys used with EncryptWithKey and DecryptWithKey
+const KeyLength = 32
+
 var (
+	ErrInvalidKey                  = fmt.Errorf("key must be exactly %d bytes", KeyLength)
 	ErrInvalidPassword             = errors.New("invalid password - cannot be used for encryption or decryption")
 	ErrEmptyInputText              = errors.New("input text provided is empty")
 	ErrSecretKeyInsufficientLength = fmt.Errorf("secret key must be at least %d bytes", minKeyLength)
@@ -74,36 +78,52 @@ func Decrypt(text, password []byte) ([]byte, error) {
 	return decrypt(text, password)
 }
 
-func encrypt(text, password []byte) ([]byte, error) {
-	secretKey, salt, err := deriveKey(password, nil)
-	if err != nil {
+// NewKey returns a randomly generated key for use with EncryptWithKey and DecryptWithKey
+func NewKey() ([]byte, error) {
+	key := make([]byte, KeyLength)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
 		return nil, err
 	}
-	if len(secretKey) < 32 {
-		return nil, ErrSecretKeyInsufficientLength
+	return key, nil
+}
 
+// EncryptWithKey encrypts (not-empty) input text directly with a key, avoiding the cost of deriving one from a
+// password. This is intended for data whose key is itself held somewhere encrypted with a password
+func EncryptWithKey(text, key []byte) ([]byte, error) {
+	if len(key) != KeyLength {
+		return nil, ErrInvalidKey
+	}
+	if len(text) == 0 {
+		return nil, ErrEmptyInputText
 	}
+	return seal(text, key)
+}
 
-	c, err := aes.NewCipher(secretKey)
-	if err != nil {
-		return nil, err
+// DecryptWithKey decrypts (not-empty) encrypted text which was encrypted using EncryptWithKey
+func DecryptWithKey(encryptedData, key []byte) ([]byte, error) {
+	if len(key) != KeyLength {
+		return nil, ErrInvalidKey
+	}
+	if len(encryptedData) == 0 {
+		return nil, ErrEmptyInputText
 	}
+	return open(encryptedData, key)
+}
 
-	// gcm or Galois/Counter Mode, is a mode of operation
-	// for symmetric key cryptographic block ciphers
-	// - https://en.wikipedia.org/wiki/Galois/Counter_Mode
-	gcm, err := cipher.NewGCM(c)
+func encrypt(text, password []byte) ([]byte, error) {
+	secretKey, salt, err := deriveKey(password, nil)
 	if err != nil {
 		return nil, err
 	}
+	if len(secretKey) < 32 {
+		return nil, ErrSecretKeyInsufficientLength
 
-	nonce := make([]byte, gcm.NonceSize())
-	// nonce is a populated by a cryptographically secure random sequence
-	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
-		return nil, err
 	}
 
-	cipherText := gcm.Seal(nonce, nonce, text, nil)
+	cipherText, err := seal(text, secretKey)
+	if err != nil {
+		return nil, err
+	}
 
 	cipherText = append(cipherText, salt...)
 	return cipherText, nil
@@ -121,6 +141,11 @@ func decrypt(encryptedData, password []byte) ([]byte, error) {
 
 	}
 
+	return open(encryptedData, secretKey)
+}
+
+// newGCM returns an AES based AEAD cipher for the given key
+func newGCM(secretKey []byte) (cipher.AEAD, error) {
 	c, err := aes.NewCipher(secretKey)
 	if err != nil {
 		return nil, err
@@ -129,11 +154,35 @@ func decrypt(encryptedData, password []byte) ([]byte, error) {
 	// gcm or Galois/Counter Mode, is a mode of operation
 	// for symmetric key cryptographic block ciphers
 	// - https://en.wikipedia.org/wiki/Galois/Counter_Mode
-	gcm, err := cipher.NewGCM(c)
+	return cipher.NewGCM(c)
+}
+
+// seal encrypts text with the given key, returning the nonce followed by the cipher text
+func seal(text, secretKey []byte) ([]byte, error) {
+	gcm, err := newGCM(secretKey)
 	if err != nil {
 		return nil, err
 	}
 
+	nonce := make([]byte, gcm.NonceSize())
+	// nonce is a populated by a cryptographically secure random sequence
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, text, nil), nil
+}
+
+// open decrypts the output of seal with the given key
+func open(encryptedData, secretKey []byte) ([]byte, error) {
+	gcm, err := newGCM(secretKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(encryptedData) < gcm.NonceSize() {
+		return nil, ErrCannotDecrypt
+	}
+
 	nonce, cipherText := encryptedData[:gcm.NonceSize()], encryptedData[gcm.NonceSize():]
 	//gcm can panic - handle this here
 	var plaintext []byte
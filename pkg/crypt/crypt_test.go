@@ -237,3 +237,27 @@ func BenchmarkDecryption(b *testing.B) {
 		})
 	}
 }
+
+func TestShouldEncryptAndDecryptWithKey(t *testing.T) {
+	key, err := crypt.NewKey()
+	require.NoError(t, err)
+	require.Len(t, key, crypt.KeyLength)
+
+	encrypted, err := crypt.EncryptWithKey([]byte(generateBasicCharStr()), key)
+	require.NoError(t, err)
+	decrypted, err := crypt.DecryptWithKey(encrypted, key)
+	require.NoError(t, err)
+	require.Equal(t, []byte(generateBasicCharStr()), decrypted)
+
+	otherKey, err := crypt.NewKey()
+	require.NoError(t, err)
+	_, err = crypt.DecryptWithKey(encrypted, otherKey)
+	require.ErrorIs(t, err, crypt.ErrCannotDecrypt)
+
+	_, err = crypt.DecryptWithKey([]byte("short"), key)
+	require.ErrorIs(t, err, crypt.ErrCannotDecrypt)
+	_, err = crypt.EncryptWithKey([]byte(validInput), key[1:])
+	require.ErrorIs(t, err, crypt.ErrInvalidKey)
+	_, err = crypt.EncryptWithKey(nil, key)
+	require.ErrorIs(t, err, crypt.ErrEmptyInputText)
+}
@@ -0,0 +1,265 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// timeLayouts are the layouts in which strings compared with times can be given
+var timeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"}
+
+type node interface {
+	eval(env Env) (interface{}, error)
+}
+
+type literal struct {
+	value interface{}
+}
+
+func (l *literal) eval(Env) (interface{}, error) {
+	return l.value, nil
+}
+
+type identifier struct {
+	name string
+}
+
+func (i *identifier) eval(env Env) (interface{}, error) {
+	return env.Lookup(i.name)
+}
+
+type negation struct {
+	operand node
+}
+
+func (n *negation) eval(env Env) (interface{}, error) {
+	value, err := n.operand.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	return !Truthy(value), nil
+}
+
+type logical struct {
+	or          bool
+	left, right node
+}
+
+func (l *logical) eval(env Env) (interface{}, error) {
+	left, err := l.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	// short circuit, so that e.g. type == "login" && field.x is only looked up for logins
+	if Truthy(left) == l.or {
+		return l.or, nil
+	}
+	right, err := l.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	return Truthy(right), nil
+}
+
+type comparison struct {
+	operator    string
+	left, right node
+	pattern     *regexp.Regexp
+}
+
+func (c *comparison) eval(env Env) (interface{}, error) {
+	left, err := c.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	if c.pattern != nil {
+		matched := anyValue(left, func(value interface{}) bool {
+			s, ok := value.(string)
+			return ok && c.pattern.MatchString(s)
+		})
+		return matched == (c.operator == "~"), nil
+	}
+
+	right, err := c.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	// != is the negation of ==, so that lists match when none of their elements are equal
+	if c.operator == "!=" {
+		equal, err := compareAny(left, right, "==")
+		return !equal, err
+	}
+	return compareAny(left, right, c.operator)
+}
+
+// compareAny compares the values, or, if either is a list, whether any element of it compares
+func compareAny(left, right interface{}, operator string) (bool, error) {
+	if list, ok := left.([]string); ok {
+		for _, element := range list {
+			matched, err := compareAny(element, right, operator)
+			if err != nil || matched {
+				return matched, err
+			}
+		}
+		return false, nil
+	}
+	if list, ok := right.([]string); ok {
+		for _, element := range list {
+			matched, err := compareAny(left, element, operator)
+			if err != nil || matched {
+				return matched, err
+			}
+		}
+		return false, nil
+	}
+
+	if left == nil || right == nil {
+		return operator == "==" && left == nil && right == nil, nil
+	}
+	order, err := Compare(left, right)
+	if err != nil {
+		return false, err
+	}
+	switch operator {
+	case "==":
+		return order == 0, nil
+	case "<":
+		return order < 0, nil
+	case "<=":
+		return order <= 0, nil
+	case ">":
+		return order > 0, nil
+	case ">=":
+		return order >= 0, nil
+	default:
+		return false, fmt.Errorf("unknown operator %s", operator)
+	}
+}
+
+func anyValue(value interface{}, predicate func(interface{}) bool) bool {
+	if list, ok := value.([]string); ok {
+		for _, element := range list {
+			if predicate(element) {
+				return true
+			}
+		}
+		return false
+	}
+	return predicate(value)
+}
+
+// Compare orders two (non list) values, returning a negative number, zero or a positive number if a is less than,
+// equal to or greater than b. Strings compared with times are parsed as times. Nil values order before all others
+func Compare(a, b interface{}) (int, error) {
+	switch {
+	case a == nil && b == nil:
+		return 0, nil
+	case a == nil:
+		return -1, nil
+	case b == nil:
+		return 1, nil
+	}
+
+	switch av := a.(type) {
+	case string:
+		switch bv := b.(type) {
+		case string:
+			return strings.Compare(av, bv), nil
+		case time.Time:
+			at, err := parseTime(av)
+			if err != nil {
+				return 0, err
+			}
+			return compareTimes(at, bv), nil
+		}
+	case float64:
+		if bv, ok := b.(float64); ok {
+			switch {
+			case av < bv:
+				return -1, nil
+			case av > bv:
+				return 1, nil
+			}
+			return 0, nil
+		}
+	case bool:
+		if bv, ok := b.(bool); ok {
+			switch {
+			case av == bv:
+				return 0, nil
+			case !av:
+				return -1, nil
+			}
+			return 1, nil
+		}
+	case time.Time:
+		switch bv := b.(type) {
+		case time.Time:
+			return compareTimes(av, bv), nil
+		case string:
+			bt, err := parseTime(bv)
+			if err != nil {
+				return 0, err
+			}
+			return compareTimes(av, bt), nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %s and %s", ErrMismatch, describe(a), describe(b))
+}
+
+// Truthy returns whether a value counts as true on its own - i.e. it is not empty, zero, false or missing
+func Truthy(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case float64:
+		return v != 0
+	case time.Time:
+		return !v.IsZero()
+	case []string:
+		return len(v) > 0
+	default:
+		return true
+	}
+}
+
+func parseTime(value string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		t, err := time.ParseInLocation(layout, value, time.UTC)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%w: '%s' is not a time - use the form 2006-01-02 or %s", ErrMismatch, value, time.RFC3339)
+}
+
+func compareTimes(a, b time.Time) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	}
+	return 0
+}
+
+func describe(value interface{}) string {
+	switch value.(type) {
+	case string:
+		return "a string"
+	case float64:
+		return "a number"
+	case bool:
+		return "a boolean"
+	case time.Time:
+		return "a time"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
@@ -0,0 +1,342 @@
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+/*
+	A small expression language for filtering records, e.g.
+
+		username == "admin" && url ~ "aws" && modified < "2024-01-01"
+
+	Expressions are made of:
+
+		identifiers   looked up in the record being filtered, e.g. username, field.account-number
+		literals      "double quoted" (with go escapes) or 'single quoted' strings, numbers, true and false
+		comparisons   == != < <= > >=, and ~ !~ which match a regular expression given as a string literal
+		logic         && || ! and parentheses
+
+	Comparing a list (e.g. tags) matches if any element of the list matches, other than != and !~ which match if no
+	element does. A value on its own is true if it is not empty, zero or false. Missing values (nil) only ever equal
+	other missing values
+*/
+
+var (
+	ErrSyntax   = errors.New("invalid filter expression")
+	ErrMismatch = errors.New("cannot compare values")
+)
+
+// Env looks up the values of identifiers in the record being filtered. Values are strings, float64s, bools,
+// time.Times, []strings, or nil if the record has no such value
+type Env interface {
+	Lookup(name string) (interface{}, error)
+}
+
+// Filter is a compiled filter expression
+type Filter struct {
+	expression  string
+	root        node
+	identifiers []string
+}
+
+// Compile parses a filter expression
+func Compile(expression string) (*Filter, error) {
+	tokens, err := lex(expression)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, identifiers: map[string]bool{}}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokenEOF {
+		return nil, p.errorf("unexpected %s", p.peek())
+	}
+
+	identifiers := make([]string, 0, len(p.identifiers))
+	for name := range p.identifiers {
+		identifiers = append(identifiers, name)
+	}
+	sort.Strings(identifiers)
+	return &Filter{expression: expression, root: root, identifiers: identifiers}, nil
+}
+
+// Identifiers returns the names of the identifiers used by the expression, so that they can be checked before any
+// record is filtered
+func (f *Filter) Identifiers() []string {
+	return f.identifiers
+}
+
+// String returns the expression the filter was compiled from
+func (f *Filter) String() string {
+	return f.expression
+}
+
+// Match returns whether the record given by env matches the expression
+func (f *Filter) Match(env Env) (bool, error) {
+	value, err := f.root.eval(env)
+	if err != nil {
+		return false, err
+	}
+	return Truthy(value), nil
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdentifier
+	tokenString
+	tokenNumber
+	tokenBool
+	tokenOperator
+	tokenOpen
+	tokenClose
+)
+
+type token struct {
+	kind     tokenKind
+	text     string
+	value    interface{}
+	position int
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of expression"
+	}
+	return fmt.Sprintf("'%s'", t.text)
+}
+
+// operators, longest first so that e.g. <= is not lexed as <
+var operators = []string{"==", "!=", "<=", ">=", "!~", "&&", "||", "<", ">", "~", "!"}
+
+func lex(expression string) ([]token, error) {
+	tokens := []token{}
+	runes := []rune(expression)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')':
+			kind := tokenOpen
+			if r == ')' {
+				kind = tokenClose
+			}
+			tokens = append(tokens, token{kind: kind, text: string(r), position: i})
+			i++
+		case r == '"' || r == '\'':
+			end := i + 1
+			for ; end < len(runes) && runes[end] != r; end++ {
+				if runes[end] == '\\' && r == '"' {
+					end++
+				}
+			}
+			if end >= len(runes) {
+				return nil, fmt.Errorf("%w: unterminated string at position %d", ErrSyntax, i)
+			}
+			text := string(runes[i : end+1])
+			value := string(runes[i+1 : end])
+			if r == '"' {
+				unquoted, err := strconv.Unquote(text)
+				if err != nil {
+					return nil, fmt.Errorf("%w: invalid string %s at position %d", ErrSyntax, text, i)
+				}
+				value = unquoted
+			}
+			tokens = append(tokens, token{kind: tokenString, text: text, value: value, position: i})
+			i = end + 1
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			end := i + 1
+			for end < len(runes) && (unicode.IsDigit(runes[end]) || runes[end] == '.') {
+				end++
+			}
+			text := string(runes[i:end])
+			n, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%w: invalid number %s at position %d", ErrSyntax, text, i)
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: text, value: n, position: i})
+			i = end
+		case unicode.IsLetter(r) || r == '_':
+			end := i + 1
+			for end < len(runes) && isIdentifierRune(runes[end]) {
+				end++
+			}
+			text := string(runes[i:end])
+			switch text {
+			case "true", "false":
+				tokens = append(tokens, token{kind: tokenBool, text: text, value: text == "true", position: i})
+			default:
+				tokens = append(tokens, token{kind: tokenIdentifier, text: text, position: i})
+			}
+			i = end
+		default:
+			matched := false
+			for _, operator := range operators {
+				if strings.HasPrefix(string(runes[i:]), operator) {
+					tokens = append(tokens, token{kind: tokenOperator, text: operator, position: i})
+					i += len([]rune(operator))
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("%w: unexpected '%c' at position %d", ErrSyntax, r, i)
+			}
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, position: len(runes)}), nil
+}
+
+// isIdentifierRune allows identifiers such as field.account-number - there is no arithmetic for - to be confused with
+func isIdentifierRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.' || r == '-'
+}
+
+type parser struct {
+	tokens      []token
+	position    int
+	identifiers map[string]bool
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.position]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.position]
+	if t.kind != tokenEOF {
+		p.position++
+	}
+	return t
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s at position %d", ErrSyntax, fmt.Sprintf(format, args...), p.peek().position)
+}
+
+func (p *parser) acceptOperator(operators ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != tokenOperator {
+		return "", false
+	}
+	for _, operator := range operators {
+		if t.text == operator {
+			p.next()
+			return operator, true
+		}
+	}
+	return "", false
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, found := p.acceptOperator("||"); !found {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logical{or: true, left: left, right: right}
+	}
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, found := p.acceptOperator("&&"); !found {
+			return left, nil
+		}
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &logical{left: left, right: right}
+	}
+}
+
+func (p *parser) parseNot() (node, error) {
+	if _, found := p.acceptOperator("!"); found {
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &negation{operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	operator, found := p.acceptOperator("==", "!=", "<", "<=", ">", ">=", "~", "!~")
+	if !found {
+		return left, nil
+	}
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	c := &comparison{operator: operator, left: left, right: right}
+	if operator == "~" || operator == "!~" {
+		pattern, ok := right.(*literal)
+		if !ok {
+			return nil, p.errorf("%s must be followed by a regular expression given as a string", operator)
+		}
+		expression, ok := pattern.value.(string)
+		if !ok {
+			return nil, p.errorf("%s must be followed by a regular expression given as a string", operator)
+		}
+		c.pattern, err = regexp.Compile(expression)
+		if err != nil {
+			return nil, p.errorf("invalid regular expression: %s", err)
+		}
+	}
+	return c, nil
+}
+
+func (p *parser) parseOperand() (node, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokenEOF, tokenOperator, tokenClose:
+		return nil, p.errorf("expected a value but found %s", t)
+	}
+	p.next()
+	switch t.kind {
+	case tokenIdentifier:
+		p.identifiers[t.text] = true
+		return &identifier{name: t.text}, nil
+	case tokenOpen:
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek().kind != tokenClose {
+			return nil, p.errorf("expected ')' but found %s", p.peek())
+		}
+		p.next()
+		return inner, nil
+	default:
+		return &literal{value: t.value}, nil
+	}
+}
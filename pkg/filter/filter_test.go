@@ -0,0 +1,107 @@
+package filter_test
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/georgewheatcroft/simple-pass/internal/common/constants"
+	"github.com/georgewheatcroft/simple-pass/pkg/filter"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	log.SetLevel(log.DebugLevel)
+	// avoid overwritting local dev .passdb TODO better way
+	os.Setenv(constants.PassDBLocalDevEnvVar, "True")
+}
+
+type mapEnv map[string]interface{}
+
+func (m mapEnv) Lookup(name string) (interface{}, error) {
+	value, found := m[name]
+	if !found {
+		return nil, fmt.Errorf("unknown identifier %s", name)
+	}
+	return value, nil
+}
+
+func TestShouldEvaluateExpressions(t *testing.T) {
+	env := mapEnv{
+		"username": "admin",
+		"url":      "https://console.aws.amazon.com",
+		"tags":     []string{"cloud", "work"},
+		"days":     float64(90),
+		"otp":      true,
+		"modified": time.Date(2023, 12, 25, 10, 0, 0, 0, time.UTC),
+		"missing":  nil,
+		"empty":    []string{},
+	}
+
+	for expression, expected := range map[string]bool{
+		`username == "admin"`:                 true,
+		`username == 'admin' && url ~ "aws"`:  true,
+		`username != "admin" || url !~ "aws"`: false,
+		`!(username == "root")`:               true,
+		`tags == "work"`:                      true,
+		`tags != "work"`:                      false,
+		`tags != "home"`:                      true,
+		`tags ~ "^cl"`:                        true,
+		`days >= 90 && days < 91.5`:           true,
+		`days > -1`:                           true,
+		`otp == true`:                         true,
+		`otp && tags && !empty && !missing`:   true,
+		`modified < "2024-01-01"`:             true,
+		`modified > "2023-12-25T09:00:00Z"`:   true,
+		`"2023-12-25" <= modified`:            true,
+		`missing == "x"`:                      false,
+		`missing != "x"`:                      true,
+		`missing < "x"`:                       false,
+		`username == "root" || (days == 90 && tags ~ "(?i)WORK")`: true,
+		`username == "a\"b"`: false,
+	} {
+		f, err := filter.Compile(expression)
+		require.NoError(t, err, expression)
+		matched, err := f.Match(env)
+		require.NoError(t, err, expression)
+		require.Equal(t, expected, matched, expression)
+	}
+
+	f, err := filter.Compile(`username == "admin" && tags == "x" || username == "root"`)
+	require.NoError(t, err)
+	require.Equal(t, []string{"tags", "username"}, f.Identifiers())
+
+	// the right hand side is not evaluated when the left decides the result
+	f, err = filter.Compile(`username == "root" && unknown == "x"`)
+	require.NoError(t, err)
+	matched, err := f.Match(env)
+	require.NoError(t, err)
+	require.False(t, matched)
+}
+
+func TestShouldRejectInvalidExpressions(t *testing.T) {
+	for _, expression := range []string{
+		`username ==`,
+		`== "admin"`,
+		`(username == "admin"`,
+		`username == "admin")`,
+		`username = "admin"`,
+		`username == "unterminated`,
+		`url ~ username`,
+		`url ~ "("`,
+		`username == "a" "b"`,
+	} {
+		_, err := filter.Compile(expression)
+		require.ErrorIs(t, err, filter.ErrSyntax, expression)
+	}
+
+	env := mapEnv{"days": float64(1), "modified": time.Now()}
+	for _, expression := range []string{`days == "one"`, `modified < "last week"`} {
+		f, err := filter.Compile(expression)
+		require.NoError(t, err, expression)
+		_, err = f.Match(env)
+		require.ErrorIs(t, err, filter.ErrMismatch, expression)
+	}
+}
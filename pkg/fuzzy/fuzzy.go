@@ -0,0 +1,281 @@
+package fuzzy
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+/*
+	Fuzzy matching of a short query against text, e.g. an item name, tolerant of both abbreviations ("gthb" for
+	"github") and typos ("gihtub" for "github"). Matching ignores case. Better kinds of match score higher:
+
+		exact > prefix > prefix of a word > substring > typo > subsequence
+*/
+
+// Scores of each kind of match. Subsequence matches score between MinSubsequenceScore and MaxSubsequenceScore,
+// depending on how tightly the query matches
+const (
+	ScoreExact          = 100
+	ScorePrefix         = 90
+	ScoreWordPrefix     = 80
+	ScoreSubstring      = 70
+	ScoreTypo           = 60
+	MaxSubsequenceScore = 40
+	MinSubsequenceScore = 10
+	// typoPenalty is deducted from ScoreTypo for each edit needed to match
+	typoPenalty = 10
+	// wordBoundaryBonus is added to subsequence matches for each matched rune which starts a word
+	wordBoundaryBonus = 5
+)
+
+// StrongScore is the lowest score of a match which is almost certainly what was meant - anything up to a single typo
+const StrongScore = ScoreTypo - typoPenalty
+
+// Range is a matched range of text, given as rune offsets [Start, End)
+type Range struct {
+	Start int
+	End   int
+}
+
+// Match is how a query matched some text
+type Match struct {
+	Score  int
+	Ranges []Range
+}
+
+// Find returns how the query matches the text, or false if it does not
+func Find(query, text string) (Match, bool) {
+	original := []rune(text)
+	q, t := lowerRunes(strings.TrimSpace(query)), lowerRunes(text)
+	if len(q) == 0 || len(t) == 0 {
+		return Match{}, false
+	}
+
+	if match, found := substringMatch(q, t, original); found {
+		return match, true
+	}
+	if match, found := typoMatch(q, t); found {
+		return match, true
+	}
+	return subsequenceMatch(q, t, original)
+}
+
+// MaxTypos returns how many edits a query of the given length can be away from the text it matches. Short queries
+// must match exactly, as they are only a few edits away from anything
+func MaxTypos(queryLength int) int {
+	switch {
+	case queryLength < 4:
+		return 0
+	case queryLength < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// MergeRanges returns the union of the given ranges, in order
+func MergeRanges(ranges []Range) []Range {
+	sorted := append([]Range(nil), ranges...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+	merged := []Range{}
+	for _, r := range sorted {
+		if len(merged) > 0 && r.Start <= merged[len(merged)-1].End {
+			if r.End > merged[len(merged)-1].End {
+				merged[len(merged)-1].End = r.End
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// Highlight returns the text with each range wrapped in start and end markers
+func Highlight(text string, ranges []Range, start, end string) string {
+	runes := []rune(text)
+	var b strings.Builder
+	position := 0
+	for _, r := range MergeRanges(ranges) {
+		if r.Start < position || r.End > len(runes) {
+			continue
+		}
+		b.WriteString(string(runes[position:r.Start]))
+		b.WriteString(start)
+		b.WriteString(string(runes[r.Start:r.End]))
+		b.WriteString(end)
+		position = r.End
+	}
+	b.WriteString(string(runes[position:]))
+	return b.String()
+}
+
+// lowerRunes lower cases text rune by rune, so that offsets into the result are also offsets into the text
+func lowerRunes(text string) []rune {
+	runes := []rune(text)
+	for idx, r := range runes {
+		runes[idx] = unicode.ToLower(r)
+	}
+	return runes
+}
+
+// isWordStart returns whether the rune at idx starts a word, e.g. the g of "work/github" or the H of "gitHub"
+func isWordStart(original []rune, idx int) bool {
+	if idx == 0 {
+		return true
+	}
+	previous, current := original[idx-1], original[idx]
+	if !unicode.IsLetter(previous) && !unicode.IsDigit(previous) {
+		return unicode.IsLetter(current) || unicode.IsDigit(current)
+	}
+	return unicode.IsLower(previous) && unicode.IsUpper(current)
+}
+
+func substringMatch(q, t, original []rune) (Match, bool) {
+	best, bestScore := -1, 0
+	for idx := 0; idx+len(q) <= len(t); idx++ {
+		if !runesEqual(q, t[idx:idx+len(q)]) {
+			continue
+		}
+		score := ScoreSubstring
+		switch {
+		case idx == 0 && len(q) == len(t):
+			score = ScoreExact
+		case idx == 0:
+			score = ScorePrefix
+		case isWordStart(original, idx):
+			score = ScoreWordPrefix
+		}
+		if score > bestScore {
+			best, bestScore = idx, score
+		}
+	}
+	if best < 0 {
+		return Match{}, false
+	}
+	return Match{Score: bestScore, Ranges: []Range{{Start: best, End: best + len(q)}}}, true
+}
+
+// typoMatch finds the part of the text fewest edits (insertions, deletions, substitutions or transpositions of
+// adjacent runes) away from the query - Sellers' approximate substring matching
+func typoMatch(q, t []rune) (Match, bool) {
+	maxTypos := MaxTypos(len(q))
+	if maxTypos == 0 {
+		return Match{}, false
+	}
+
+	// distance[i][j] is the fewest edits to match q[:i] against text ending at t[j-1], which started at start[i][j]
+	distance := make([][]int, len(q)+1)
+	start := make([][]int, len(q)+1)
+	for i := range distance {
+		distance[i] = make([]int, len(t)+1)
+		start[i] = make([]int, len(t)+1)
+		distance[i][0] = i
+	}
+	for j := 0; j <= len(t); j++ {
+		start[0][j] = j
+	}
+	for i := 1; i <= len(q); i++ {
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if q[i-1] == t[j-1] {
+				cost = 0
+			}
+			distance[i][j], start[i][j] = distance[i-1][j-1]+cost, start[i-1][j-1]
+			if d := distance[i-1][j] + 1; d < distance[i][j] {
+				distance[i][j], start[i][j] = d, start[i-1][j]
+			}
+			if d := distance[i][j-1] + 1; d < distance[i][j] {
+				distance[i][j], start[i][j] = d, start[i][j-1]
+			}
+			if i > 1 && j > 1 && q[i-1] == t[j-2] && q[i-2] == t[j-1] {
+				if d := distance[i-2][j-2] + 1; d < distance[i][j] {
+					distance[i][j], start[i][j] = d, start[i-2][j-2]
+				}
+			}
+		}
+	}
+
+	bestEnd := -1
+	for j := 1; j <= len(t); j++ {
+		if distance[len(q)][j] <= maxTypos && (bestEnd < 0 || distance[len(q)][j] < distance[len(q)][bestEnd]) {
+			bestEnd = j
+		}
+	}
+	if bestEnd < 0 {
+		return Match{}, false
+	}
+	typos := distance[len(q)][bestEnd]
+	return Match{
+		Score:  ScoreTypo - typos*typoPenalty,
+		Ranges: []Range{{Start: start[len(q)][bestEnd], End: bestEnd}},
+	}, true
+}
+
+// subsequenceMatch matches the runes of the query in order, but not necessarily next to one another, preferring the
+// shortest span of text
+func subsequenceMatch(q, t, original []rune) (Match, bool) {
+	bestStart, bestEnd := -1, -1
+	for first := 0; first < len(t); first++ {
+		if t[first] != q[0] {
+			continue
+		}
+		// the earliest end of a match starting here
+		qi, end := 0, -1
+		for ti := first; ti < len(t); ti++ {
+			if t[ti] == q[qi] {
+				qi++
+				if qi == len(q) {
+					end = ti + 1
+					break
+				}
+			}
+		}
+		if end < 0 {
+			break
+		}
+		if bestStart < 0 || end-first < bestEnd-bestStart {
+			bestStart, bestEnd = first, end
+		}
+	}
+	if bestStart < 0 {
+		return Match{}, false
+	}
+
+	ranges := []Range{}
+	score := MaxSubsequenceScore * len(q) / (bestEnd - bestStart)
+	qi := 0
+	for ti := bestStart; ti < bestEnd && qi < len(q); ti++ {
+		if t[ti] != q[qi] {
+			continue
+		}
+		qi++
+		if isWordStart(original, ti) {
+			score += wordBoundaryBonus
+		}
+		if len(ranges) > 0 && ranges[len(ranges)-1].End == ti {
+			ranges[len(ranges)-1].End++
+			continue
+		}
+		ranges = append(ranges, Range{Start: ti, End: ti + 1})
+	}
+	if score > MaxSubsequenceScore {
+		score = MaxSubsequenceScore
+	}
+	if score < MinSubsequenceScore {
+		score = MinSubsequenceScore
+	}
+	return Match{Score: score, Ranges: ranges}, true
+}
+
+func runesEqual(a, b []rune) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for idx := range a {
+		if a[idx] != b[idx] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,73 @@
+package fuzzy_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/georgewheatcroft/simple-pass/internal/common/constants"
+	"github.com/georgewheatcroft/simple-pass/pkg/fuzzy"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	log.SetLevel(log.DebugLevel)
+	// avoid overwritting local dev .passdb TODO better way
+	os.Setenv(constants.PassDBLocalDevEnvVar, "True")
+}
+
+func TestShouldFindFuzzyMatches(t *testing.T) {
+	for _, tc := range []struct {
+		query   string
+		text    string
+		score   int
+		ranges  []fuzzy.Range
+		matched bool
+	}{
+		{"GitHub", "github", fuzzy.ScoreExact, []fuzzy.Range{{0, 6}}, true},
+		{"git", "github", fuzzy.ScorePrefix, []fuzzy.Range{{0, 3}}, true},
+		{"hub", "work/hub-admin", fuzzy.ScoreWordPrefix, []fuzzy.Range{{5, 8}}, true},
+		{"hub", "gitHub", fuzzy.ScoreWordPrefix, []fuzzy.Range{{3, 6}}, true},
+		{"thu", "github", fuzzy.ScoreSubstring, []fuzzy.Range{{2, 5}}, true},
+		{"gihtub", "work/github", fuzzy.ScoreTypo - 10, []fuzzy.Range{{5, 11}}, true},
+		{"gitlub", "github", fuzzy.ScoreTypo - 10, []fuzzy.Range{{0, 6}}, true},
+		{"amazn-prime", "amazon-prim", fuzzy.ScoreTypo - 20, []fuzzy.Range{{0, 11}}, true},
+		{"gthb", "github", 0, []fuzzy.Range{{0, 1}, {2, 4}, {5, 6}}, true},
+		{"xyz", "github", 0, nil, false},
+		{"gtihbu", "github", 0, nil, false},
+		{"", "github", 0, nil, false},
+		{"git", "", 0, nil, false},
+	} {
+		match, matched := fuzzy.Find(tc.query, tc.text)
+		require.Equal(t, tc.matched, matched, tc.query)
+		if !matched {
+			continue
+		}
+		require.Equal(t, tc.ranges, match.Ranges, tc.query)
+		if tc.score != 0 {
+			require.Equal(t, tc.score, match.Score, tc.query)
+			continue
+		}
+		require.GreaterOrEqual(t, match.Score, fuzzy.MinSubsequenceScore, tc.query)
+		require.LessOrEqual(t, match.Score, fuzzy.MaxSubsequenceScore, tc.query)
+	}
+
+	// a single typo is a strong match, but an abbreviation is not
+	match, _ := fuzzy.Find("gihtub", "github")
+	require.GreaterOrEqual(t, match.Score, fuzzy.StrongScore)
+	match, _ = fuzzy.Find("gthb", "github")
+	require.Less(t, match.Score, fuzzy.StrongScore)
+	// tighter subsequences score higher
+	tight, _ := fuzzy.Find("gh", "gh-pages")
+	require.Equal(t, fuzzy.ScorePrefix, tight.Score)
+	tight, _ = fuzzy.Find("ghb", "github")
+	loose, _ := fuzzy.Find("ghb", "gxxxxxxxxxhxxxxxxxxb")
+	require.Greater(t, tight.Score, loose.Score)
+}
+
+func TestShouldHighlightRanges(t *testing.T) {
+	require.Equal(t, "[git]hub", fuzzy.Highlight("github", []fuzzy.Range{{0, 3}}, "[", "]"))
+	require.Equal(t, "[gi]t[hub]", fuzzy.Highlight("github", []fuzzy.Range{{3, 6}, {0, 1}, {1, 2}}, "[", "]"))
+	require.Equal(t, "caf[é]", fuzzy.Highlight("café", []fuzzy.Range{{3, 4}}, "[", "]"))
+	require.Equal(t, []fuzzy.Range{{0, 4}, {5, 6}}, fuzzy.MergeRanges([]fuzzy.Range{{5, 6}, {2, 4}, {0, 3}}))
+}
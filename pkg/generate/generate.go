@@ -0,0 +1,158 @@
+package generate
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Character classes passwords can be generated from
+const (
+	Lower   = "abcdefghijklmnopqrstuvwxyz"
+	Upper   = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	Digits  = "0123456789"
+	Symbols = "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"
+
+	// Ambiguous characters are those easily mistaken for one another when read or typed by hand
+	Ambiguous = "Il1|O0o`'\""
+)
+
+const (
+	DefaultLength      = 20
+	DefaultMinPerClass = 1
+	// MaxLength guards against accidentally requesting an absurdly long password
+	MaxLength = 4096
+)
+
+var (
+	ErrInvalidLength      = fmt.Errorf("password length must be between 1 and %d", MaxLength)
+	ErrNoCharacterClasses = errors.New("at least one character class must be used")
+	ErrInvalidMinPerClass = errors.New("minimum characters per class cannot be negative")
+	ErrMinPerClassTooHigh = errors.New("password length is too short to hold the minimum characters of every class")
+)
+
+// Options determine how a password is generated
+type Options struct {
+	Length  int
+	Lower   bool
+	Upper   bool
+	Digits  bool
+	Symbols bool
+	// ExcludeAmbiguous removes characters which are easily confused (e.g. l, 1 and I) from every class
+	ExcludeAmbiguous bool
+	// MinPerClass is the fewest characters from each of the used classes the password will contain
+	MinPerClass int
+}
+
+// DefaultOptions returns options which generate passwords accepted by the vast majority of sites
+func DefaultOptions() Options {
+	return Options{
+		Length:      DefaultLength,
+		Lower:       true,
+		Upper:       true,
+		Digits:      true,
+		Symbols:     true,
+		MinPerClass: DefaultMinPerClass,
+	}
+}
+
+// classes returns the characters of each character class the options use
+func (o Options) classes() []string {
+	classes := []string{}
+	for _, class := range []struct {
+		used  bool
+		chars string
+	}{{o.Lower, Lower}, {o.Upper, Upper}, {o.Digits, Digits}, {o.Symbols, Symbols}} {
+		if !class.used {
+			continue
+		}
+		chars := class.chars
+		if o.ExcludeAmbiguous {
+			chars = strings.Map(func(r rune) rune {
+				if strings.ContainsRune(Ambiguous, r) {
+					return -1
+				}
+				return r
+			}, chars)
+		}
+		classes = append(classes, chars)
+	}
+	return classes
+}
+
+// Validate checks that a password can be generated with the options
+func (o Options) Validate() error {
+	if o.Length < 1 || o.Length > MaxLength {
+		return ErrInvalidLength
+	}
+	if o.MinPerClass < 0 {
+		return ErrInvalidMinPerClass
+	}
+	classes := o.classes()
+	if len(classes) == 0 {
+		return ErrNoCharacterClasses
+	}
+	if len(classes)*o.MinPerClass > o.Length {
+		return ErrMinPerClassTooHigh
+	}
+	return nil
+}
+
+// Password returns a password generated from a cryptographically secure source of randomness with the options
+func Password(opts Options) (string, error) {
+	err := opts.Validate()
+	if err != nil {
+		return "", err
+	}
+
+	classes := opts.classes()
+	password := make([]byte, 0, opts.Length)
+	// first satisfy the minimum of each class, then fill the remainder from every class used
+	for _, class := range classes {
+		for i := 0; i < opts.MinPerClass; i++ {
+			char, err := pick(class)
+			if err != nil {
+				return "", err
+			}
+			password = append(password, char)
+		}
+	}
+	all := strings.Join(classes, "")
+	for len(password) < opts.Length {
+		char, err := pick(all)
+		if err != nil {
+			return "", err
+		}
+		password = append(password, char)
+	}
+
+	// the minimum characters were added in class order - shuffle so their positions are not predictable
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := randomInt(i + 1)
+		if err != nil {
+			return "", err
+		}
+		password[i], password[j] = password[j], password[i]
+	}
+	return string(password), nil
+}
+
+// pick returns a uniformly random character from chars
+func pick(chars string) (byte, error) {
+	idx, err := randomInt(len(chars))
+	if err != nil {
+		return 0, err
+	}
+	return chars[idx], nil
+}
+
+// randomInt returns a uniformly random int in [0, max)
+func randomInt(max int) (int, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(max)))
+	if err != nil {
+		return 0, err
+	}
+	return int(n.Int64()), nil
+}
@@ -0,0 +1,154 @@
+package generate_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/georgewheatcroft/simple-pass/internal/common/constants"
+	"github.com/georgewheatcroft/simple-pass/pkg/generate"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	log.SetLevel(log.DebugLevel)
+	// avoid overwritting local dev .passdb TODO better way
+	os.Setenv(constants.PassDBLocalDevEnvVar, "True")
+}
+
+func countIn(password, chars string) int {
+	count := 0
+	for _, r := range password {
+		if strings.ContainsRune(chars, r) {
+			count++
+		}
+	}
+	return count
+}
+
+func TestShouldGeneratePasswordsMeetingOptions(t *testing.T) {
+	opts := generate.DefaultOptions()
+	opts.Length = 12
+	opts.MinPerClass = 3
+	opts.ExcludeAmbiguous = true
+
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		password, err := generate.Password(opts)
+		require.NoError(t, err)
+		require.Len(t, password, 12)
+		for _, class := range []string{generate.Lower, generate.Upper, generate.Digits, generate.Symbols} {
+			require.GreaterOrEqual(t, countIn(password, class), 3, password)
+		}
+		require.Zero(t, countIn(password, generate.Ambiguous), password)
+		seen[password] = true
+	}
+	require.Len(t, seen, 100)
+
+	opts = generate.Options{Length: 32, Digits: true}
+	password, err := generate.Password(opts)
+	require.NoError(t, err)
+	require.Equal(t, 32, countIn(password, generate.Digits))
+}
+
+func TestShouldRejectInvalidGenerateOptions(t *testing.T) {
+	opts := generate.DefaultOptions()
+	opts.Length = 0
+	_, err := generate.Password(opts)
+	require.ErrorIs(t, err, generate.ErrInvalidLength)
+
+	_, err = generate.Password(generate.Options{Length: 10})
+	require.ErrorIs(t, err, generate.ErrNoCharacterClasses)
+
+	opts = generate.DefaultOptions()
+	opts.Length = 7
+	opts.MinPerClass = 2
+	_, err = generate.Password(opts)
+	require.ErrorIs(t, err, generate.ErrMinPerClassTooHigh)
+
+	opts.MinPerClass = -1
+	_, err = generate.Password(opts)
+	require.ErrorIs(t, err, generate.ErrInvalidMinPerClass)
+}
+
+func TestShouldGeneratePassphrasesFromWordlists(t *testing.T) {
+	list := generate.EFFLargeWordlist()
+	require.Len(t, list, 7776)
+	words := map[string]bool{}
+	for _, word := range list {
+		words[word] = true
+	}
+
+	opts := generate.DefaultPassphraseOptions()
+	require.InDelta(t, 77.5, opts.EntropyBits(), 0.1)
+	passphrase, err := generate.Passphrase(opts)
+	require.NoError(t, err)
+	parts := strings.Split(passphrase, "-")
+	require.Len(t, parts, 6)
+	for _, part := range parts {
+		require.True(t, words[part], part)
+	}
+
+	opts.Words = 4
+	opts.Separator = " "
+	opts.Capitalise = true
+	opts.InsertDigit = true
+	opts.Wordlist, err = generate.ParseWordlist(strings.NewReader("# custom list\n\nalpha\nbravo\n11111 charlie\nalpha\n"))
+	require.NoError(t, err)
+	require.Equal(t, generate.Wordlist{"alpha", "bravo", "charlie"}, opts.Wordlist)
+	passphrase, err = generate.Passphrase(opts)
+	require.NoError(t, err)
+	require.Regexp(t, `^((Alpha|Bravo|Charlie)[0-9]? ){3}(Alpha|Bravo|Charlie)[0-9]?$`, passphrase)
+	require.Equal(t, 1, countIn(passphrase, generate.Digits))
+
+	_, err = generate.ParseWordlist(strings.NewReader("only\n"))
+	require.ErrorIs(t, err, generate.ErrWordlistTooSmall)
+	_, err = generate.ParseWordlist(strings.NewReader("two words\nthree\n"))
+	require.ErrorIs(t, err, generate.ErrInvalidWordInList)
+
+	opts.Words = 0
+	_, err = generate.Passphrase(opts)
+	require.ErrorIs(t, err, generate.ErrInvalidWords)
+}
+
+func TestShouldGeneratePasswordsFollowingRules(t *testing.T) {
+	rules, err := generate.ParseRules("minlength: 8; maxlength: 16; required: lower, upper; required: digit; allowed: [-]]; max-consecutive: 2;")
+	require.NoError(t, err)
+	require.Equal(t, 8, rules.MinLength)
+	require.Equal(t, 16, rules.MaxLength)
+	require.Equal(t, 2, rules.MaxConsecutive)
+	require.Equal(t, []string{generate.Upper + generate.Lower, generate.Digits}, rules.Required)
+	require.Equal(t, "-]", rules.Allowed)
+
+	for i := 0; i < 100; i++ {
+		password, err := rules.Password(0)
+		require.NoError(t, err)
+		require.Len(t, password, 16)
+		require.NoError(t, rules.Check(password))
+		require.Regexp(t, `^[a-zA-Z0-9\-\]]+$`, password)
+	}
+	_, err = rules.Password(20)
+	require.ErrorIs(t, err, generate.ErrLengthOutsideOfRules)
+
+	require.ErrorIs(t, rules.Check("aaa1Bcdefg"), generate.ErrPasswordBreaksRules)
+	require.ErrorIs(t, rules.Check("abcdefgh"), generate.ErrPasswordBreaksRules)
+	require.ErrorIs(t, rules.Check("abc1Bcd!"), generate.ErrPasswordBreaksRules)
+	require.ErrorIs(t, rules.Check("abc1Bcd"), generate.ErrPasswordBreaksRules)
+	require.NoError(t, rules.Check("ab-1Bcd]"))
+
+	for _, invalid := range []string{"minlength 8", "minlength: x", "required: vowels", "allowed: [abc", "colour: red", "required: lower upper"} {
+		_, err = generate.ParseRules(invalid)
+		require.ErrorIs(t, err, generate.ErrInvalidRules, invalid)
+	}
+	_, err = generate.ParseRules("minlength: 10; maxlength: 8")
+	require.ErrorIs(t, err, generate.ErrUnsatisfiableRules)
+
+	for name, value := range generate.BuiltinProfiles() {
+		rules, err := generate.ParseRules(value)
+		require.NoError(t, err, name)
+		password, err := rules.Password(0)
+		require.NoError(t, err, name)
+		require.NoError(t, rules.Check(password), name)
+	}
+}
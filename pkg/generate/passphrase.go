@@ -0,0 +1,168 @@
+package generate
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	DefaultWords     = 6
+	DefaultSeparator = "-"
+	// MaxWords guards against accidentally requesting an absurdly long passphrase
+	MaxWords = 64
+	// MinWordlistSize is the fewest distinct words a wordlist must hold to be used
+	MinWordlistSize = 2
+)
+
+var (
+	ErrInvalidWords      = fmt.Errorf("passphrase must have between 1 and %d words", MaxWords)
+	ErrWordlistTooSmall  = fmt.Errorf("wordlist must hold at least %d distinct words", MinWordlistSize)
+	ErrInvalidWordInList = errors.New("wordlist words cannot contain whitespace")
+)
+
+// effLargeWordlist is the EFF long wordlist for diceware passphrases - see
+// https://www.eff.org/deeplinks/2016/07/new-wordlists-random-passphrases
+//
+//go:embed eff_large_wordlist.txt
+var effLargeWordlist string
+
+var (
+	parseEFFLargeWordlist sync.Once
+	effLargeWords         Wordlist
+)
+
+// Wordlist is the set of words a passphrase is chosen from
+type Wordlist []string
+
+// EFFLargeWordlist returns the EFF long wordlist of 7776 words, each worth ~12.9 bits of entropy
+func EFFLargeWordlist() Wordlist {
+	parseEFFLargeWordlist.Do(func() {
+		// the embedded list is known to be valid
+		effLargeWords, _ = ParseWordlist(strings.NewReader(effLargeWordlist))
+	})
+	return effLargeWords
+}
+
+// ParseWordlist reads a wordlist with a word on each line. Lines may be prefixed with their dice roll (as in the EFF
+// and original diceware lists), blank lines and lines starting with # are ignored, and duplicate words are dropped
+func ParseWordlist(r io.Reader) (Wordlist, error) {
+	list := Wordlist{}
+	seen := map[string]bool{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 2 && strings.Trim(fields[0], "123456") == "":
+			line = fields[1]
+		case len(fields) != 1:
+			return nil, fmt.Errorf("%w: '%s'", ErrInvalidWordInList, line)
+		}
+		if !seen[line] {
+			seen[line] = true
+			list = append(list, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(list) < MinWordlistSize {
+		return nil, ErrWordlistTooSmall
+	}
+	return list, nil
+}
+
+// PassphraseOptions determine how a passphrase is generated
+type PassphraseOptions struct {
+	Words     int
+	Separator string
+	// Capitalise upper cases the first letter of every word
+	Capitalise bool
+	// InsertDigit adds a random digit to the end of a random word
+	InsertDigit bool
+	// Wordlist is the list words are chosen from, the EFF long wordlist if nil
+	Wordlist Wordlist
+}
+
+// DefaultPassphraseOptions returns options which generate passphrases of ~77 bits of entropy from the EFF long
+// wordlist
+func DefaultPassphraseOptions() PassphraseOptions {
+	return PassphraseOptions{
+		Words:     DefaultWords,
+		Separator: DefaultSeparator,
+	}
+}
+
+func (o PassphraseOptions) wordlist() Wordlist {
+	if o.Wordlist == nil {
+		return EFFLargeWordlist()
+	}
+	return o.Wordlist
+}
+
+// Validate checks that a passphrase can be generated with the options
+func (o PassphraseOptions) Validate() error {
+	if o.Words < 1 || o.Words > MaxWords {
+		return ErrInvalidWords
+	}
+	if len(o.wordlist()) < MinWordlistSize {
+		return ErrWordlistTooSmall
+	}
+	return nil
+}
+
+// EntropyBits returns the entropy of passphrases generated with the options, assuming an attacker knows the options
+// and the wordlist. Capitalising every word adds nothing, as it is not random
+func (o PassphraseOptions) EntropyBits() float64 {
+	bits := float64(o.Words) * math.Log2(float64(len(o.wordlist())))
+	if o.InsertDigit {
+		bits += math.Log2(float64(10 * o.Words))
+	}
+	return bits
+}
+
+// Passphrase returns a passphrase of words chosen from the wordlist with a cryptographically secure source of
+// randomness
+func Passphrase(opts PassphraseOptions) (string, error) {
+	err := opts.Validate()
+	if err != nil {
+		return "", err
+	}
+
+	list := opts.wordlist()
+	words := make([]string, opts.Words)
+	for i := range words {
+		idx, err := randomInt(len(list))
+		if err != nil {
+			return "", err
+		}
+		words[i] = list[idx]
+		if opts.Capitalise {
+			first, size := utf8.DecodeRuneInString(words[i])
+			words[i] = string(unicode.ToUpper(first)) + words[i][size:]
+		}
+	}
+	if opts.InsertDigit {
+		idx, err := randomInt(len(words))
+		if err != nil {
+			return "", err
+		}
+		digit, err := pick(Digits)
+		if err != nil {
+			return "", err
+		}
+		words[idx] += string(digit)
+	}
+	return strings.Join(words, opts.Separator), nil
+}
@@ -0,0 +1,24 @@
+package generate
+
+// builtinProfiles are the password rules of commonly encountered restrictions, by name
+var builtinProfiles = map[string]string{
+	// default matches the passwords generated with the default options
+	"default": "minlength: 20; required: lower; required: upper; required: digit; required: special;",
+	// max-16 suits the many sites which silently truncate or reject passwords over 16 characters
+	"max-16": "minlength: 12; maxlength: 16; required: lower; required: upper; required: digit; required: [-_!@#$%];",
+	// limited-symbols suits sites which only accept a handful of symbols
+	"limited-symbols": "minlength: 16; maxlength: 32; required: lower; required: upper; required: digit; required: [-_.!@#];",
+	// alphanumeric suits sites which reject every symbol
+	"alphanumeric": "minlength: 20; required: lower; required: upper; required: digit;",
+	// pin suits numeric pins, which often forbid repeated digits
+	"pin": "minlength: 6; maxlength: 6; required: digit; max-consecutive: 1;",
+}
+
+// BuiltinProfiles returns the password rules of the embedded generation profiles, by name
+func BuiltinProfiles() map[string]string {
+	profiles := make(map[string]string, len(builtinProfiles))
+	for name, rules := range builtinProfiles {
+		profiles[name] = rules
+	}
+	return profiles
+}
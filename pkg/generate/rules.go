@@ -0,0 +1,314 @@
+package generate
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+	Rules describe the passwords a site will accept, using the syntax of the passwordrules attribute proposed by Apple
+	- see https://developer.apple.com/password-rules/ e.g.
+
+		minlength: 8; maxlength: 16; required: lower; required: upper; required: digit; allowed: [-_!@#$];
+
+	Each "required" property gives a set of characters of which the password must contain at least one, "allowed"
+	properties give further characters it may contain, and "max-consecutive" limits how many times a character can
+	be repeated in a row. Character sets are made up of the classes upper, lower, digit, special, ascii-printable and
+	unicode, and of custom classes of ascii characters in square brackets (where - must come first and ] last).
+*/
+
+const (
+	// Special is the special character class of the passwordrules syntax, excluding space - which some sites accept
+	// but is never worth generating
+	Special = "-~!@#$%^&*_+=`|(){}[:;\"'<>,.?]/\\"
+
+	// MaxGenerateAttempts is how many candidate passwords are generated before giving up on satisfying max-consecutive
+	MaxGenerateAttempts = 1000
+
+	ruleMinLength      = "minlength"
+	ruleMaxLength      = "maxlength"
+	ruleRequired       = "required"
+	ruleAllowed        = "allowed"
+	ruleMaxConsecutive = "max-consecutive"
+)
+
+var (
+	ErrInvalidRules         = errors.New("invalid password rules")
+	ErrUnsatisfiableRules   = errors.New("no password can satisfy the password rules")
+	ErrPasswordBreaksRules  = errors.New("password does not satisfy the password rules")
+	ErrLengthOutsideOfRules = errors.New("password length is outside of that allowed by the password rules")
+)
+
+// characterClasses are the named classes of the passwordrules syntax. Generated passwords are limited to ascii, so
+// unicode is treated as ascii-printable
+var characterClasses = map[string]string{
+	"upper":           Upper,
+	"lower":           Lower,
+	"digit":           Digits,
+	"special":         Special,
+	"ascii-printable": Upper + Lower + Digits + Special,
+	"unicode":         Upper + Lower + Digits + Special,
+}
+
+// Rules are the restrictions a site places on passwords
+type Rules struct {
+	MinLength int
+	MaxLength int
+	// Required are the sets of characters of which a password must contain at least one each
+	Required []string
+	// Allowed are the characters a password may contain, in addition to those which are required
+	Allowed string
+	// MaxConsecutive is the most times a character can be repeated in a row, if non-zero
+	MaxConsecutive int
+}
+
+// ParseRules parses rules given in the passwordrules syntax
+func ParseRules(value string) (*Rules, error) {
+	rules := &Rules{}
+	for _, rule := range strings.Split(value, ";") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		name, arg, found := strings.Cut(rule, ":")
+		if !found {
+			return nil, fmt.Errorf("%w: '%s' must be given as <property>: <value>", ErrInvalidRules, rule)
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		arg = strings.TrimSpace(arg)
+
+		var err error
+		switch name {
+		case ruleMinLength:
+			rules.MinLength, err = parseRuleNumber(name, arg)
+		case ruleMaxLength:
+			rules.MaxLength, err = parseRuleNumber(name, arg)
+		case ruleMaxConsecutive:
+			rules.MaxConsecutive, err = parseRuleNumber(name, arg)
+		case ruleRequired:
+			var chars string
+			chars, err = parseCharacterSet(arg)
+			rules.Required = append(rules.Required, chars)
+		case ruleAllowed:
+			var chars string
+			chars, err = parseCharacterSet(arg)
+			rules.Allowed = union(rules.Allowed, chars)
+		default:
+			err = fmt.Errorf("%w: unknown property '%s'", ErrInvalidRules, name)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rules, rules.Validate()
+}
+
+func parseRuleNumber(name, arg string) (int, error) {
+	number, err := strconv.Atoi(arg)
+	if err != nil || number < 1 {
+		return 0, fmt.Errorf("%w: %s must be a positive number", ErrInvalidRules, name)
+	}
+	return number, nil
+}
+
+// parseCharacterSet parses a comma separated list of named and custom character classes into the characters in
+// any of them
+func parseCharacterSet(arg string) (string, error) {
+	chars := ""
+	for arg != "" {
+		if strings.HasPrefix(arg, "[") {
+			// the class ends at the last ] before the next separator, allowing ] to be the last character in it
+			end := strings.Index(arg, "]")
+			for end >= 0 && end+1 < len(arg) && arg[end+1] == ']' {
+				end++
+			}
+			if end < 0 {
+				return "", fmt.Errorf("%w: unterminated custom character class '%s'", ErrInvalidRules, arg)
+			}
+			custom := arg[1:end]
+			for _, r := range custom {
+				if r < ' ' || r > '~' {
+					return "", fmt.Errorf("%w: custom character classes must be ascii", ErrInvalidRules)
+				}
+			}
+			chars = union(chars, custom)
+			arg = arg[end+1:]
+		} else {
+			name, _, _ := strings.Cut(arg, ",")
+			name = strings.TrimSpace(name)
+			class, known := characterClasses[strings.ToLower(name)]
+			if !known {
+				return "", fmt.Errorf("%w: unknown character class '%s'", ErrInvalidRules, name)
+			}
+			chars = union(chars, class)
+			arg = arg[len(name):]
+		}
+		arg = strings.TrimSpace(arg)
+		if arg != "" {
+			if !strings.HasPrefix(arg, ",") {
+				return "", fmt.Errorf("%w: character classes must be separated by commas", ErrInvalidRules)
+			}
+			arg = strings.TrimSpace(arg[1:])
+		}
+	}
+	if chars == "" {
+		return "", fmt.Errorf("%w: empty character set", ErrInvalidRules)
+	}
+	return chars, nil
+}
+
+// union returns the distinct characters of a and b, in a stable order
+func union(a, b string) string {
+	seen := map[rune]bool{}
+	for _, r := range a + b {
+		seen[r] = true
+	}
+	chars := make([]rune, 0, len(seen))
+	for r := range seen {
+		chars = append(chars, r)
+	}
+	sort.Slice(chars, func(i, j int) bool { return chars[i] < chars[j] })
+	return string(chars)
+}
+
+// characters returns every character a password following the rules may contain - all printable ascii if the rules
+// do not restrict them
+func (r *Rules) characters() string {
+	chars := r.Allowed
+	for _, required := range r.Required {
+		chars = union(chars, required)
+	}
+	if chars == "" {
+		return characterClasses["ascii-printable"]
+	}
+	return chars
+}
+
+// Validate checks that some password can satisfy the rules
+func (r *Rules) Validate() error {
+	if r.MaxLength != 0 && r.MinLength > r.MaxLength {
+		return fmt.Errorf("%w: %s is greater than %s", ErrUnsatisfiableRules, ruleMinLength, ruleMaxLength)
+	}
+	if r.MaxLength != 0 && len(r.Required) > r.MaxLength {
+		return fmt.Errorf("%w: more character sets are required than %s allows", ErrUnsatisfiableRules, ruleMaxLength)
+	}
+	if r.MaxConsecutive == 1 && len(r.characters()) < 2 {
+		return fmt.Errorf("%w: a single character cannot be used without repeating it", ErrUnsatisfiableRules)
+	}
+	return nil
+}
+
+// lengthFor returns the length of password to generate for a requested length, where 0 requests the default
+func (r *Rules) lengthFor(length int) (int, error) {
+	if length == 0 {
+		length = DefaultLength
+		if r.MaxLength != 0 && length > r.MaxLength {
+			length = r.MaxLength
+		}
+		if length < r.MinLength {
+			length = r.MinLength
+		}
+		return length, nil
+	}
+	if length < r.MinLength || (r.MaxLength != 0 && length > r.MaxLength) {
+		return 0, ErrLengthOutsideOfRules
+	}
+	return length, nil
+}
+
+// Password returns a password following the rules, generated from a cryptographically secure source of
+// randomness. A length of 0 generates the default length, or as near to it as the rules allow
+func (r *Rules) Password(length int) (string, error) {
+	err := r.Validate()
+	if err != nil {
+		return "", err
+	}
+	length, err = r.lengthFor(length)
+	if err != nil {
+		return "", err
+	}
+	if length > MaxLength || length < len(r.Required) {
+		return "", ErrInvalidLength
+	}
+
+	// candidates breaking max-consecutive are rejected rather than fixed up, so every acceptable password remains
+	// equally likely
+	for attempt := 0; attempt < MaxGenerateAttempts; attempt++ {
+		password, err := r.candidate(length)
+		if err != nil {
+			return "", err
+		}
+		if r.Check(password) == nil {
+			return password, nil
+		}
+	}
+	return "", ErrUnsatisfiableRules
+}
+
+func (r *Rules) candidate(length int) (string, error) {
+	password := make([]byte, 0, length)
+	for _, required := range r.Required {
+		char, err := pick(required)
+		if err != nil {
+			return "", err
+		}
+		password = append(password, char)
+	}
+	all := r.characters()
+	for len(password) < length {
+		char, err := pick(all)
+		if err != nil {
+			return "", err
+		}
+		password = append(password, char)
+	}
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := randomInt(i + 1)
+		if err != nil {
+			return "", err
+		}
+		password[i], password[j] = password[j], password[i]
+	}
+	return string(password), nil
+}
+
+// Check reports whether a password satisfies the rules
+func (r *Rules) Check(password string) error {
+	length := len([]rune(password))
+	if length < r.MinLength {
+		return fmt.Errorf("%w: must be at least %d characters", ErrPasswordBreaksRules, r.MinLength)
+	}
+	if r.MaxLength != 0 && length > r.MaxLength {
+		return fmt.Errorf("%w: must be at most %d characters", ErrPasswordBreaksRules, r.MaxLength)
+	}
+	all := r.characters()
+	for _, c := range password {
+		if !strings.ContainsRune(all, c) {
+			return fmt.Errorf("%w: contains a character which is not allowed", ErrPasswordBreaksRules)
+		}
+	}
+	for _, required := range r.Required {
+		if !strings.ContainsAny(password, required) {
+			return fmt.Errorf("%w: missing a required character from '%s'", ErrPasswordBreaksRules, required)
+		}
+	}
+	if r.MaxConsecutive > 0 {
+		run := 0
+		var last rune
+		for idx, c := range password {
+			if idx > 0 && c == last {
+				run++
+			} else {
+				run = 1
+			}
+			last = c
+			if run > r.MaxConsecutive {
+				return fmt.Errorf("%w: a character is repeated more than %d times in a row", ErrPasswordBreaksRules, r.MaxConsecutive)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package hibp
+
+import (
+	"bytes"
+	"crypto/sha1" // #nosec - sha1 is the hash used by the Pwned Passwords dataset
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+/*
+	Looks up passwords in a local copy of the Have I Been Pwned "Pwned Passwords" SHA-1 dataset - see
+	https://haveibeenpwned.com/Passwords. The dataset is a text file of lines of the form
+
+		<upper case hex sha1 of the password>:<times seen in breaches>
+
+	sorted by hash. Being tens of gigabytes it is never read into memory - each lookup binary searches the file by
+	byte offset, reading only a handful of lines.
+*/
+
+const (
+	hashLength = sha1.Size * 2
+	// chunkSize is how much of the file is read at a time when searching for the boundaries of a line
+	chunkSize = 128
+)
+
+var ErrMalformedDataset = errors.New("malformed pwned passwords dataset - expected sorted lines of <sha1>:<count>")
+
+// Dataset is a sorted Pwned Passwords SHA-1 dataset
+type Dataset struct {
+	r    io.ReaderAt
+	size int64
+	// closer is set when the dataset owns the underlying file
+	closer io.Closer
+}
+
+// NewDataset returns a dataset read from r, which holds size bytes
+func NewDataset(r io.ReaderAt, size int64) *Dataset {
+	return &Dataset{r: r, size: size}
+}
+
+// Open opens the dataset file at path - it must be closed once finished with
+func Open(path string) (*Dataset, error) {
+	/* #nosec */
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := fh.Stat()
+	if err != nil {
+		fh.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		fh.Close()
+		return nil, fmt.Errorf("%s is a directory", path)
+	}
+	return &Dataset{r: fh, size: info.Size(), closer: fh}, nil
+}
+
+// Close closes the dataset file, if the dataset was opened from one
+func (d *Dataset) Close() error {
+	if d.closer == nil {
+		return nil
+	}
+	return d.closer.Close()
+}
+
+// HashPassword returns the hash of a password as it appears in the dataset
+func HashPassword(password string) string {
+	/* #nosec */
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// Lookup returns how many times the password was seen in breaches - 0 if it is not in the dataset
+func (d *Dataset) Lookup(password string) (int, error) {
+	return d.LookupHash(HashPassword(password))
+}
+
+// LookupHash returns how many times the password with the given hex sha1 hash was seen in breaches - 0 if it is
+// not in the dataset
+func (d *Dataset) LookupHash(hash string) (int, error) {
+	target := []byte(strings.ToUpper(hash))
+	if len(target) != hashLength {
+		return 0, fmt.Errorf("invalid sha1 hash '%s'", hash)
+	}
+
+	// invariant: if the hash is in the dataset, its line starts within [lo, hi)
+	lo, hi := int64(0), d.size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		start, err := d.lineStart(mid)
+		if err != nil {
+			return 0, err
+		}
+		line, next, err := d.readLine(start)
+		if err != nil {
+			return 0, err
+		}
+		lineHash, count, err := parseLine(line)
+		if err != nil {
+			// blank lines (e.g. at the end of the file) sort before everything
+			if len(bytes.TrimSpace(line)) != 0 {
+				return 0, fmt.Errorf("%w: at byte %d", ErrMalformedDataset, start)
+			}
+			lo = next
+			continue
+		}
+
+		switch bytes.Compare(target, lineHash) {
+		case 0:
+			return count, nil
+		case -1:
+			hi = start
+		default:
+			lo = next
+		}
+	}
+	return 0, nil
+}
+
+// lineStart returns the offset of the start of the line containing offset
+func (d *Dataset) lineStart(offset int64) (int64, error) {
+	buf := make([]byte, chunkSize)
+	end := offset
+	for end > 0 {
+		begin := end - chunkSize
+		if begin < 0 {
+			begin = 0
+		}
+		n, err := d.r.ReadAt(buf[:end-begin], begin)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return 0, err
+		}
+		if idx := bytes.LastIndexByte(buf[:n], '\n'); idx >= 0 {
+			return begin + int64(idx) + 1, nil
+		}
+		end = begin
+	}
+	return 0, nil
+}
+
+// readLine returns the line starting at offset (without its line ending), and the offset of the next line
+func (d *Dataset) readLine(offset int64) ([]byte, int64, error) {
+	line := []byte{}
+	buf := make([]byte, chunkSize)
+	for position := offset; position < d.size; {
+		n, err := d.r.ReadAt(buf, position)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, 0, err
+		}
+		if n == 0 {
+			break
+		}
+		if idx := bytes.IndexByte(buf[:n], '\n'); idx >= 0 {
+			line = append(line, buf[:idx]...)
+			return bytes.TrimRight(line, "\r"), position + int64(idx) + 1, nil
+		}
+		line = append(line, buf[:n]...)
+		position += int64(n)
+	}
+	return bytes.TrimRight(line, "\r"), d.size, nil
+}
+
+func parseLine(line []byte) ([]byte, int, error) {
+	hash, count, found := bytes.Cut(line, []byte(":"))
+	if !found || len(hash) != hashLength {
+		return nil, 0, ErrMalformedDataset
+	}
+	n, err := strconv.Atoi(string(bytes.TrimSpace(count)))
+	if err != nil {
+		return nil, 0, ErrMalformedDataset
+	}
+	return bytes.ToUpper(hash), n, nil
+}
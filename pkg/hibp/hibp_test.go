@@ -0,0 +1,86 @@
+package hibp_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/georgewheatcroft/simple-pass/internal/common/constants"
+	"github.com/georgewheatcroft/simple-pass/pkg/hibp"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	log.SetLevel(log.DebugLevel)
+	// avoid overwritting local dev .passdb TODO better way
+	os.Setenv(constants.PassDBLocalDevEnvVar, "True")
+}
+
+// writeDataset writes a dataset of the given passwords, seen i+1 times each, in the format of the downloader
+func writeDataset(t *testing.T, passwords []string, lineEnding string) string {
+	lines := make([]string, 0, len(passwords))
+	for i, password := range passwords {
+		lines = append(lines, fmt.Sprintf("%s:%d", hibp.HashPassword(password), i+1))
+	}
+	sort.Strings(lines)
+	path := filepath.Join(t.TempDir(), "pwnedpasswords.txt")
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, lineEnding)+lineEnding), 0600))
+	return path
+}
+
+func TestShouldLookupPasswordsInDataset(t *testing.T) {
+	passwords := make([]string, 0, 500)
+	for i := 0; i < 500; i++ {
+		passwords = append(passwords, fmt.Sprintf("password%d", i))
+	}
+
+	for _, lineEnding := range []string{"\n", "\r\n"} {
+		dataset, err := hibp.Open(writeDataset(t, passwords, lineEnding))
+		require.NoError(t, err)
+		for i, password := range passwords {
+			count, err := dataset.Lookup(password)
+			require.NoError(t, err)
+			require.Equal(t, i+1, count, password)
+		}
+		for _, password := range []string{"", "not-in-the-dataset", "password500"} {
+			count, err := dataset.Lookup(password)
+			require.NoError(t, err)
+			require.Zero(t, count, password)
+		}
+		// hashes are matched regardless of case
+		count, err := dataset.LookupHash(strings.ToLower(hibp.HashPassword("password7")))
+		require.NoError(t, err)
+		require.Equal(t, 8, count)
+		require.NoError(t, dataset.Close())
+	}
+}
+
+func TestShouldLookupPasswordsInSmallDatasets(t *testing.T) {
+	dataset := hibp.NewDataset(strings.NewReader(""), 0)
+	count, err := dataset.Lookup("password")
+	require.NoError(t, err)
+	require.Zero(t, count)
+
+	line := hibp.HashPassword("password") + ":3861493"
+	dataset = hibp.NewDataset(strings.NewReader(line), int64(len(line)))
+	count, err = dataset.Lookup("password")
+	require.NoError(t, err)
+	require.Equal(t, 3861493, count)
+}
+
+func TestShouldRejectMalformedDatasets(t *testing.T) {
+	contents := "not a pwned passwords dataset\n"
+	dataset := hibp.NewDataset(strings.NewReader(contents), int64(len(contents)))
+	_, err := dataset.Lookup("password")
+	require.ErrorIs(t, err, hibp.ErrMalformedDataset)
+
+	_, err = dataset.LookupHash("abc")
+	require.Error(t, err)
+
+	_, err = hibp.Open(t.TempDir())
+	require.Error(t, err)
+}
@@ -0,0 +1,579 @@
+package kdbx
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/georgewheatcroft/simple-pass/pkg/argon2"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/chacha20"
+)
+
+/*
+	A KDBX 4 file is laid out as:
+		signatures and version
+		outer header - fields describing how the rest is encrypted
+		sha-256 of the outer header
+		hmac-sha-256 of the outer header
+		hmac block stream - the encrypted (and possibly compressed) inner header followed by the xml document, split
+		into blocks which each have their own hmac
+
+	The hmacs are keyed by the derived key, so a wrong password is detected by the outer header hmac not matching.
+*/
+
+var (
+	ErrNotKDBX                = errors.New("not a kdbx file")
+	ErrUnsupportedVersion     = errors.New("unsupported kdbx version - only kdbx 4 is supported")
+	ErrInvalidCredentials     = errors.New("invalid password, or the file is corrupt")
+	ErrCorrupt                = errors.New("kdbx file is corrupt")
+	ErrUnsupportedCipher      = errors.New("unsupported cipher")
+	ErrUnsupportedKDF         = errors.New("unsupported key derivation function")
+	ErrUnsupportedCompression = errors.New("unsupported compression")
+)
+
+const (
+	signature1 = 0x9AA2D903
+	signature2 = 0xB54BFB67
+	// version is 4.0 - the minor version is in the lower half
+	version          = 0x00040000
+	majorVersionMask = 0xFFFF0000
+
+	hmacBlockSize = 1 << 20
+)
+
+// outer header field ids
+const (
+	headerEndOfHeader      = 0
+	headerCipherID         = 2
+	headerCompressionFlags = 3
+	headerMasterSeed       = 4
+	headerEncryptionIV     = 7
+	headerKdfParameters    = 11
+	headerPublicCustomData = 12
+)
+
+const (
+	compressionNone = 0
+	compressionGzip = 1
+)
+
+var (
+	cipherAES256UUID   = uuid.MustParse("31c1f2e6-bf71-4350-be58-05216afc5aff")
+	cipherChaCha20UUID = uuid.MustParse("d6038a2b-8b6f-4cb5-a524-339a31dbb59a")
+
+	kdfArgon2dUUID  = uuid.MustParse("ef636ddf-8c29-444b-91f7-a9a403e30a0c")
+	kdfArgon2idUUID = uuid.MustParse("9e298b19-56db-4773-b23d-fc3ec6f0a1e6")
+	// KeePass and KeePassXC each have their own id for AES-KDF
+	kdfAESUUID      = uuid.MustParse("c9d9f39a-628a-4460-bf74-0d08c18a4fea")
+	kdfAESKDBX4UUID = uuid.MustParse("7c02bb82-79a7-4ac0-927d-114a00648238")
+)
+
+// header is the outer header of a kdbx file
+type header struct {
+	cipher      uuid.UUID
+	compression uint32
+	masterSeed  []byte
+	iv          []byte
+	kdf         variantDictionary
+}
+
+// readHeader reads the outer header, returning it along with its raw bytes - which its hash and hmac are of
+func readHeader(r io.Reader) (*header, []byte, error) {
+	raw := &bytes.Buffer{}
+	tee := io.TeeReader(r, raw)
+
+	var preamble [3]uint32
+	err := binary.Read(tee, binary.LittleEndian, &preamble)
+	if err != nil {
+		return nil, nil, ErrNotKDBX
+	}
+	if preamble[0] != signature1 || preamble[1] != signature2 {
+		return nil, nil, ErrNotKDBX
+	}
+	if preamble[2]&majorVersionMask != version {
+		return nil, nil, fmt.Errorf("%w: %d.%d", ErrUnsupportedVersion, preamble[2]>>16, preamble[2]&0xFFFF)
+	}
+
+	h := &header{}
+	for {
+		id, data, err := readField(tee)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %s", ErrCorrupt, err)
+		}
+		switch id {
+		case headerEndOfHeader:
+			return h, raw.Bytes(), h.validate()
+		case headerCipherID:
+			h.cipher, err = uuid.FromBytes(data)
+		case headerCompressionFlags:
+			if len(data) != 4 {
+				return nil, nil, fmt.Errorf("%w: invalid compression flags", ErrCorrupt)
+			}
+			h.compression = binary.LittleEndian.Uint32(data)
+		case headerMasterSeed:
+			h.masterSeed = data
+		case headerEncryptionIV:
+			h.iv = data
+		case headerKdfParameters:
+			h.kdf, err = readVariantDictionary(data)
+		}
+		// the comment and public custom data are of no use here
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %s", ErrCorrupt, err)
+		}
+	}
+}
+
+func (h *header) validate() error {
+	if len(h.masterSeed) != 32 {
+		return fmt.Errorf("%w: invalid master seed", ErrCorrupt)
+	}
+	if h.compression != compressionNone && h.compression != compressionGzip {
+		return ErrUnsupportedCompression
+	}
+	if h.kdf == nil {
+		return fmt.Errorf("%w: no key derivation parameters", ErrCorrupt)
+	}
+	return nil
+}
+
+func (h *header) write(w io.Writer) error {
+	var compression [4]byte
+	binary.LittleEndian.PutUint32(compression[:], h.compression)
+	kdf, err := h.kdf.bytes()
+	if err != nil {
+		return err
+	}
+
+	err = binary.Write(w, binary.LittleEndian, [3]uint32{signature1, signature2, version})
+	if err != nil {
+		return err
+	}
+	for _, field := range []struct {
+		id   byte
+		data []byte
+	}{
+		{headerCipherID, h.cipher[:]},
+		{headerCompressionFlags, compression[:]},
+		{headerMasterSeed, h.masterSeed},
+		{headerEncryptionIV, h.iv},
+		{headerKdfParameters, kdf},
+		{headerEndOfHeader, []byte("\r\n\r\n")},
+	} {
+		err = writeField(w, field.id, field.data)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readField reads a type-length-value field, as used by both the outer and inner headers
+func readField(r io.Reader) (byte, []byte, error) {
+	var prefix [5]byte
+	_, err := io.ReadFull(r, prefix[:])
+	if err != nil {
+		return 0, nil, err
+	}
+	length := binary.LittleEndian.Uint32(prefix[1:])
+	if length > math.MaxInt32 {
+		return 0, nil, errors.New("header field too large")
+	}
+	data := make([]byte, length)
+	_, err = io.ReadFull(r, data)
+	return prefix[0], data, err
+}
+
+func writeField(w io.Writer, id byte, data []byte) error {
+	var prefix [5]byte
+	prefix[0] = id
+	binary.LittleEndian.PutUint32(prefix[1:], uint32(len(data)))
+	_, err := w.Write(append(prefix[:], data...))
+	return err
+}
+
+// variantDictionary holds the parameters of the key derivation function, keyed by name. Its values are one of
+// uint32, uint64, bool, int32, int64, string or []byte
+type variantDictionary map[string]interface{}
+
+const (
+	variantDictionaryVersion = 0x0100
+	variantUint32            = 0x04
+	variantUint64            = 0x05
+	variantBool              = 0x08
+	variantInt32             = 0x0C
+	variantInt64             = 0x0D
+	variantString            = 0x18
+	variantBytes             = 0x42
+)
+
+func readVariantDictionary(data []byte) (variantDictionary, error) {
+	r := bytes.NewReader(data)
+	var dictVersion uint16
+	err := binary.Read(r, binary.LittleEndian, &dictVersion)
+	if err != nil {
+		return nil, err
+	}
+	if dictVersion&0xFF00 != variantDictionaryVersion {
+		return nil, fmt.Errorf("unsupported variant dictionary version %#x", dictVersion)
+	}
+
+	dict := variantDictionary{}
+	for {
+		valueType, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if valueType == 0 {
+			return dict, nil
+		}
+		key, err := readSized(r)
+		if err != nil {
+			return nil, err
+		}
+		value, err := readSized(r)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case valueType == variantUint32 && len(value) == 4:
+			dict[string(key)] = binary.LittleEndian.Uint32(value)
+		case valueType == variantUint64 && len(value) == 8:
+			dict[string(key)] = binary.LittleEndian.Uint64(value)
+		case valueType == variantBool && len(value) == 1:
+			dict[string(key)] = value[0] != 0
+		case valueType == variantInt32 && len(value) == 4:
+			dict[string(key)] = int32(binary.LittleEndian.Uint32(value))
+		case valueType == variantInt64 && len(value) == 8:
+			dict[string(key)] = int64(binary.LittleEndian.Uint64(value))
+		case valueType == variantString:
+			dict[string(key)] = string(value)
+		case valueType == variantBytes:
+			dict[string(key)] = value
+		default:
+			return nil, fmt.Errorf("invalid variant dictionary value for '%s'", key)
+		}
+	}
+}
+
+func readSized(r *bytes.Reader) ([]byte, error) {
+	var length int32
+	err := binary.Read(r, binary.LittleEndian, &length)
+	if err != nil {
+		return nil, err
+	}
+	if length < 0 || int(length) > r.Len() {
+		return nil, errors.New("invalid variant dictionary length")
+	}
+	value := make([]byte, length)
+	_, err = io.ReadFull(r, value)
+	return value, err
+}
+
+func (d variantDictionary) bytes() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	_ = binary.Write(buf, binary.LittleEndian, uint16(variantDictionaryVersion))
+	keys := make([]string, 0, len(d))
+	for key := range d {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		var valueType byte
+		value := &bytes.Buffer{}
+		switch typed := d[key].(type) {
+		case uint32:
+			valueType = variantUint32
+			_ = binary.Write(value, binary.LittleEndian, typed)
+		case uint64:
+			valueType = variantUint64
+			_ = binary.Write(value, binary.LittleEndian, typed)
+		case bool:
+			valueType = variantBool
+			_ = binary.Write(value, binary.LittleEndian, typed)
+		case int32:
+			valueType = variantInt32
+			_ = binary.Write(value, binary.LittleEndian, typed)
+		case int64:
+			valueType = variantInt64
+			_ = binary.Write(value, binary.LittleEndian, typed)
+		case string:
+			valueType = variantString
+			value.WriteString(typed)
+		case []byte:
+			valueType = variantBytes
+			value.Write(typed)
+		default:
+			return nil, fmt.Errorf("cannot write variant dictionary value of type %T", typed)
+		}
+		buf.WriteByte(valueType)
+		_ = binary.Write(buf, binary.LittleEndian, int32(len(key)))
+		buf.WriteString(key)
+		_ = binary.Write(buf, binary.LittleEndian, int32(value.Len()))
+		buf.Write(value.Bytes())
+	}
+	buf.WriteByte(0)
+	return buf.Bytes(), nil
+}
+
+// the names of the key derivation parameters
+const (
+	kdfUUID        = "$UUID"
+	kdfSalt        = "S"
+	kdfParallelism = "P"
+	kdfMemory      = "M"
+	kdfIterations  = "I"
+	kdfVersion     = "V"
+	kdfSecret      = "K"
+	kdfData        = "A"
+	kdfRounds      = "R"
+)
+
+// newKDFParameters returns the parameters to derive the key of a database being written, with a new salt
+func newKDFParameters(kdf KDF) (variantDictionary, error) {
+	var id uuid.UUID
+	switch kdf.Mode {
+	case argon2.ModeD:
+		id = kdfArgon2dUUID
+	case argon2.ModeID:
+		id = kdfArgon2idUUID
+	default:
+		return nil, ErrUnsupportedKDF
+	}
+	salt := make([]byte, 32)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return nil, err
+	}
+	return variantDictionary{
+		kdfUUID:        id[:],
+		kdfSalt:        salt,
+		kdfParallelism: kdf.Parallelism,
+		kdfMemory:      uint64(kdf.Memory) * 1024,
+		kdfIterations:  uint64(kdf.Iterations),
+		kdfVersion:     uint32(argon2.Version),
+	}, nil
+}
+
+// transformKey derives the key the database is encrypted with from its password, using the parameters given
+func transformKey(password string, params variantDictionary) ([]byte, error) {
+	passwordHash := sha256.Sum256([]byte(password))
+	// the composite key is the hash of the hashes of every part of the key - of which only a password is supported
+	compositeKey := sha256.Sum256(passwordHash[:])
+
+	rawID, _ := params[kdfUUID].([]byte)
+	id, err := uuid.FromBytes(rawID)
+	if err != nil {
+		return nil, ErrUnsupportedKDF
+	}
+	salt, hasSalt := params[kdfSalt].([]byte)
+	if !hasSalt {
+		return nil, fmt.Errorf("%w: no salt", ErrCorrupt)
+	}
+
+	switch id {
+	case kdfArgon2dUUID, kdfArgon2idUUID:
+		mode := argon2.ModeD
+		if id == kdfArgon2idUUID {
+			mode = argon2.ModeID
+		}
+		iterations, _ := params[kdfIterations].(uint64)
+		memory, _ := params[kdfMemory].(uint64)
+		parallelism, _ := params[kdfParallelism].(uint32)
+		argonVersion, _ := params[kdfVersion].(uint32)
+		secret, _ := params[kdfSecret].([]byte)
+		data, _ := params[kdfData].([]byte)
+		if argonVersion != argon2.Version {
+			return nil, fmt.Errorf("%w: argon2 version %#x", ErrUnsupportedKDF, argonVersion)
+		}
+		if iterations > math.MaxUint32 || memory/1024 > math.MaxUint32 {
+			return nil, fmt.Errorf("%w: argon2 parameters too large", ErrUnsupportedKDF)
+		}
+		return argon2.Key(compositeKey[:], salt, argon2.Params{
+			Mode:    mode,
+			Time:    uint32(iterations),
+			Memory:  uint32(memory / 1024),
+			Threads: parallelism,
+			Secret:  secret,
+			Data:    data,
+		}, 32)
+	case kdfAESUUID, kdfAESKDBX4UUID:
+		rounds, _ := params[kdfRounds].(uint64)
+		return aesKDF(compositeKey[:], salt, rounds)
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedKDF, id)
+}
+
+// aesKDF derives a key by encrypting it with the seed the given number of times
+func aesKDF(key, seed []byte, rounds uint64) ([]byte, error) {
+	block, err := aes.NewCipher(seed)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrCorrupt, err)
+	}
+	transformed := append([]byte{}, key...)
+	for round := uint64(0); round < rounds; round++ {
+		block.Encrypt(transformed[:16], transformed[:16])
+		block.Encrypt(transformed[16:], transformed[16:])
+	}
+	hashed := sha256.Sum256(transformed)
+	return hashed[:], nil
+}
+
+// keys are the keys used to encrypt and authenticate the contents of a database
+type keys struct {
+	encryption []byte
+	hmac       []byte
+}
+
+func deriveKeys(masterSeed, transformedKey []byte) keys {
+	encryption := sha256.Sum256(append(append([]byte{}, masterSeed...), transformedKey...))
+	hmacKey := sha512.Sum512(append(append(append([]byte{}, masterSeed...), transformedKey...), 0x01))
+	return keys{encryption: encryption[:], hmac: hmacKey[:]}
+}
+
+// headerBlockIndex is the block index the outer header's hmac is keyed with
+const headerBlockIndex = math.MaxUint64
+
+// blockHMAC returns the hmac of a block of the hmac block stream, keyed by its index
+func (k keys) blockHMAC(index uint64, data []byte) []byte {
+	var indexBytes [8]byte
+	binary.LittleEndian.PutUint64(indexBytes[:], index)
+	blockKey := sha512.Sum512(append(indexBytes[:], k.hmac...))
+	mac := hmac.New(sha256.New, blockKey[:])
+	if index == headerBlockIndex {
+		mac.Write(data)
+		return mac.Sum(nil)
+	}
+	var length [4]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(len(data)))
+	mac.Write(indexBytes[:])
+	mac.Write(length[:])
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// readBlocks reads the hmac block stream, checking the hmac of every block
+func (k keys) readBlocks(r io.Reader) ([]byte, error) {
+	content := &bytes.Buffer{}
+	for index := uint64(0); ; index++ {
+		var prefix [36]byte
+		_, err := io.ReadFull(r, prefix[:])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrCorrupt, err)
+		}
+		length := int32(binary.LittleEndian.Uint32(prefix[32:]))
+		if length < 0 {
+			return nil, fmt.Errorf("%w: invalid block length", ErrCorrupt)
+		}
+		data := make([]byte, length)
+		_, err = io.ReadFull(r, data)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrCorrupt, err)
+		}
+		if !hmac.Equal(prefix[:32], k.blockHMAC(index, data)) {
+			return nil, fmt.Errorf("%w: block %d failed its integrity check", ErrCorrupt, index)
+		}
+		if length == 0 {
+			return content.Bytes(), nil
+		}
+		content.Write(data)
+	}
+}
+
+// writeBlocks writes the content as an hmac block stream, ending with an empty block
+func (k keys) writeBlocks(w io.Writer, content []byte) error {
+	for index := uint64(0); ; index++ {
+		data := content
+		if len(data) > hmacBlockSize {
+			data = data[:hmacBlockSize]
+		}
+		content = content[len(data):]
+		var length [4]byte
+		binary.LittleEndian.PutUint32(length[:], uint32(len(data)))
+		for _, part := range [][]byte{k.blockHMAC(index, data), length[:], data} {
+			_, err := w.Write(part)
+			if err != nil {
+				return err
+			}
+		}
+		if len(data) == 0 {
+			return nil
+		}
+	}
+}
+
+// newIV returns a random iv of the length the cipher uses
+func newIV(cipherID uuid.UUID) ([]byte, error) {
+	var iv []byte
+	switch cipherID {
+	case cipherAES256UUID:
+		iv = make([]byte, aes.BlockSize)
+	case cipherChaCha20UUID:
+		iv = make([]byte, chacha20.NonceSize)
+	default:
+		return nil, ErrUnsupportedCipher
+	}
+	_, err := rand.Read(iv)
+	return iv, err
+}
+
+func encrypt(cipherID uuid.UUID, key, iv, plaintext []byte) ([]byte, error) {
+	switch cipherID {
+	case cipherAES256UUID:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		padding := aes.BlockSize - len(plaintext)%aes.BlockSize
+		padded := append(append([]byte{}, plaintext...), bytes.Repeat([]byte{byte(padding)}, padding)...)
+		cipher.NewCBCEncrypter(block, iv).CryptBlocks(padded, padded)
+		return padded, nil
+	case cipherChaCha20UUID:
+		return chaCha20(key, iv, plaintext)
+	}
+	return nil, ErrUnsupportedCipher
+}
+
+func decrypt(cipherID uuid.UUID, key, iv, ciphertext []byte) ([]byte, error) {
+	switch cipherID {
+	case cipherAES256UUID:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		if len(iv) != aes.BlockSize || len(ciphertext) == 0 || len(ciphertext)%aes.BlockSize != 0 {
+			return nil, fmt.Errorf("%w: invalid aes ciphertext", ErrCorrupt)
+		}
+		plaintext := make([]byte, len(ciphertext))
+		cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, ciphertext)
+		padding := int(plaintext[len(plaintext)-1])
+		if padding == 0 || padding > aes.BlockSize || !bytes.Equal(plaintext[len(plaintext)-padding:], bytes.Repeat([]byte{byte(padding)}, padding)) {
+			return nil, fmt.Errorf("%w: invalid aes padding", ErrCorrupt)
+		}
+		return plaintext[:len(plaintext)-padding], nil
+	case cipherChaCha20UUID:
+		return chaCha20(key, iv, ciphertext)
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedCipher, cipherID)
+}
+
+func chaCha20(key, iv, in []byte) ([]byte, error) {
+	stream, err := chacha20.NewUnauthenticatedCipher(key, iv)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrCorrupt, err)
+	}
+	out := make([]byte, len(in))
+	stream.XORKeyStream(out, in)
+	return out, nil
+}
@@ -0,0 +1,335 @@
+/*
+Package kdbx reads and writes KeePass databases in the KDBX 4 format, as used by KeePass 2 and KeePassXC.
+
+Databases protected by a password alone are supported, with the key derived using Argon2d, Argon2id or (when reading)
+AES-KDF, and the contents encrypted using AES-256 or ChaCha20.
+*/
+package kdbx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/georgewheatcroft/simple-pass/pkg/argon2"
+	"github.com/google/uuid"
+)
+
+// Database is the contents of a KeePass database - a tree of groups holding entries
+type Database struct {
+	Name string
+	Root *Group
+	// RecycleBin is the group holding deleted entries, if there is one
+	RecycleBin uuid.UUID
+}
+
+// Group is a group of entries, which can itself hold other groups
+type Group struct {
+	UUID    uuid.UUID
+	Name    string
+	Notes   string
+	Times   Times
+	Groups  []*Group
+	Entries []*Entry
+}
+
+// Entry is a single set of credentials, held as strings keyed by name - e.g. Title, UserName, Password
+type Entry struct {
+	UUID     uuid.UUID
+	Times    Times
+	Tags     []string
+	Strings  []String
+	Binaries []Binary
+	// CustomData holds anything else applications store on the entry, keyed by name
+	CustomData map[string]string
+	// History is every previous version of the entry, oldest first
+	History []*Entry
+}
+
+// String is a named value of an entry. Protected values are held encrypted within the database, even once decrypted
+type String struct {
+	Key       string
+	Value     string
+	Protected bool
+}
+
+// Binary is a file attached to an entry
+type Binary struct {
+	Name string
+	Data []byte
+}
+
+// Times are when a group or entry was created, changed and used, and when it expires (if it does)
+type Times struct {
+	Created  time.Time
+	Modified time.Time
+	Accessed time.Time
+	Expiry   time.Time
+	Expires  bool
+}
+
+// the keys of the strings every entry has
+const (
+	KeyTitle    = "Title"
+	KeyUserName = "UserName"
+	KeyPassword = "Password"
+	KeyURL      = "URL"
+	KeyNotes    = "Notes"
+)
+
+// Get returns the value of a string of the entry, or "" if it has none with the key given
+func (e *Entry) Get(key string) string {
+	for _, str := range e.Strings {
+		if str.Key == key {
+			return str.Value
+		}
+	}
+	return ""
+}
+
+// Set sets the value of a string of the entry, adding it if the entry does not already have one with the key given
+func (e *Entry) Set(key, value string, protected bool) {
+	for idx := range e.Strings {
+		if e.Strings[idx].Key == key {
+			e.Strings[idx].Value, e.Strings[idx].Protected = value, protected
+			return
+		}
+	}
+	e.Strings = append(e.Strings, String{Key: key, Value: value, Protected: protected})
+}
+
+// Cipher encrypts the contents of a database
+type Cipher string
+
+const (
+	CipherAES256   Cipher = "aes256"
+	CipherChaCha20 Cipher = "chacha20"
+)
+
+// Ciphers returns every cipher a database can be written with
+func Ciphers() []Cipher {
+	return []Cipher{CipherAES256, CipherChaCha20}
+}
+
+// KDF is how the key which encrypts a database is derived from its password, using Argon2
+type KDF struct {
+	Mode       argon2.Mode
+	Iterations uint32
+	// Memory is the amount of memory used, in KiB
+	Memory      uint32
+	Parallelism uint32
+}
+
+// Options are how a database is protected when it is written
+type Options struct {
+	Cipher Cipher
+	KDF    KDF
+}
+
+// DefaultOptions returns the options KeePass itself writes new databases with
+func DefaultOptions() Options {
+	return Options{
+		Cipher: CipherAES256,
+		KDF:    KDF{Mode: argon2.ModeD, Iterations: 2, Memory: 64 << 10, Parallelism: 2},
+	}
+}
+
+// Read reads a database, decrypting it with the password given
+func Read(r io.Reader, password string) (*Database, error) {
+	h, rawHeader, err := readHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	var headerHash, headerHMAC [sha256.Size]byte
+	_, err = io.ReadFull(r, headerHash[:])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrCorrupt, err)
+	}
+	_, err = io.ReadFull(r, headerHMAC[:])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrCorrupt, err)
+	}
+	if computed := sha256.Sum256(rawHeader); !hmac.Equal(headerHash[:], computed[:]) {
+		return nil, fmt.Errorf("%w: header failed its integrity check", ErrCorrupt)
+	}
+
+	transformedKey, err := transformKey(password, h.kdf)
+	if err != nil {
+		return nil, err
+	}
+	derived := deriveKeys(h.masterSeed, transformedKey)
+	// the header is known to be intact, so the only reason its hmac would not match is the key being wrong
+	if !hmac.Equal(headerHMAC[:], derived.blockHMAC(headerBlockIndex, rawHeader)) {
+		return nil, ErrInvalidCredentials
+	}
+
+	encrypted, err := derived.readBlocks(r)
+	if err != nil {
+		return nil, err
+	}
+	decrypted, err := decrypt(h.cipher, derived.encryption, h.iv, encrypted)
+	if err != nil {
+		return nil, err
+	}
+	var content io.Reader = bytes.NewReader(decrypted)
+	if h.compression == compressionGzip {
+		content, err = gzip.NewReader(content)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrCorrupt, err)
+		}
+	}
+
+	inner, err := readInnerHeader(content)
+	if err != nil {
+		return nil, err
+	}
+	document, err := io.ReadAll(content)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrCorrupt, err)
+	}
+	stream, err := inner.innerStream()
+	if err != nil {
+		return nil, err
+	}
+	// a byte order mark is allowed before the xml declaration, but not understood by encoding/xml
+	document, err = unprotect(bytes.TrimPrefix(document, []byte("\xef\xbb\xbf")), stream)
+	if err != nil {
+		return nil, err
+	}
+	file := xmlFile{}
+	err = xml.Unmarshal(document, &file)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrCorrupt, err)
+	}
+
+	root, err := groupFromXML(file.Root.Group, inner.binaries)
+	if err != nil {
+		return nil, err
+	}
+	database := &Database{Name: file.Meta.DatabaseName, Root: root}
+	if file.Meta.RecycleBinEnabled {
+		database.RecycleBin = uuid.UUID(file.Meta.RecycleBinUUID)
+	}
+	return database, nil
+}
+
+// Write writes the database, encrypting it with the password given and protecting it as the options say
+func Write(w io.Writer, database *Database, password string, options Options) error {
+	var cipherID uuid.UUID
+	switch options.Cipher {
+	case CipherAES256:
+		cipherID = cipherAES256UUID
+	case CipherChaCha20:
+		cipherID = cipherChaCha20UUID
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedCipher, options.Cipher)
+	}
+	kdf, err := newKDFParameters(options.KDF)
+	if err != nil {
+		return err
+	}
+	h := &header{cipher: cipherID, compression: compressionGzip, masterSeed: make([]byte, 32), kdf: kdf}
+	_, err = rand.Read(h.masterSeed)
+	if err != nil {
+		return err
+	}
+	h.iv, err = newIV(cipherID)
+	if err != nil {
+		return err
+	}
+	transformedKey, err := transformKey(password, kdf)
+	if err != nil {
+		return err
+	}
+	derived := deriveKeys(h.masterSeed, transformedKey)
+
+	content, err := writeContent(database)
+	if err != nil {
+		return err
+	}
+	encrypted, err := encrypt(cipherID, derived.encryption, h.iv, content)
+	if err != nil {
+		return err
+	}
+
+	rawHeader := &bytes.Buffer{}
+	err = h.write(rawHeader)
+	if err != nil {
+		return err
+	}
+	headerHash := sha256.Sum256(rawHeader.Bytes())
+	headerHMAC := derived.blockHMAC(headerBlockIndex, rawHeader.Bytes())
+	for _, part := range [][]byte{rawHeader.Bytes(), headerHash[:], headerHMAC} {
+		_, err = w.Write(part)
+		if err != nil {
+			return err
+		}
+	}
+	return derived.writeBlocks(w, encrypted)
+}
+
+// writeContent returns the compressed inner header and xml document of the database, before it is encrypted
+func writeContent(database *Database) ([]byte, error) {
+	root := database.Root
+	if root == nil {
+		root = &Group{Name: database.Name}
+	}
+	pool := &binaryPool{}
+	file := xmlFile{
+		Meta: xmlMeta{
+			Generator:         generator,
+			DatabaseName:      database.Name,
+			MemoryProtection:  xmlMemoryProtection{ProtectPassword: true},
+			RecycleBinEnabled: database.RecycleBin != uuid.Nil,
+			RecycleBinUUID:    xmlUUID(database.RecycleBin),
+		},
+		Root: xmlRoot{Group: groupToXML(root, pool)},
+	}
+	document, err := xml.MarshalIndent(file, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+
+	inner := &innerHeader{streamKey: make([]byte, 64), binaries: pool.binaries}
+	_, err = rand.Read(inner.streamKey)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := inner.innerStream()
+	if err != nil {
+		return nil, err
+	}
+	document, err = protect(append([]byte(xml.Header), document...), stream)
+	if err != nil {
+		return nil, err
+	}
+
+	compressed := &bytes.Buffer{}
+	gzipWriter := gzip.NewWriter(compressed)
+	err = inner.write(gzipWriter)
+	if err != nil {
+		return nil, err
+	}
+	_, err = gzipWriter.Write(document)
+	if err != nil {
+		return nil, err
+	}
+	err = gzipWriter.Close()
+	return compressed.Bytes(), err
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
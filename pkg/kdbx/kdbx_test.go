@@ -0,0 +1,280 @@
+package kdbx_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/georgewheatcroft/simple-pass/pkg/argon2"
+	"github.com/georgewheatcroft/simple-pass/pkg/kdbx"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// the fixtures are written by this package, so after changing what is written they can be rewritten using
+// go test ./pkg/kdbx -update
+var update = flag.Bool("update", false, "rewrite the kdbx fixtures in testdata")
+
+const fixturePassword = "Fixture-Password-1"
+
+var fixtures = map[string]kdbx.Options{
+	"aes256-argon2d.kdbx": {
+		Cipher: kdbx.CipherAES256,
+		KDF:    kdbx.KDF{Mode: argon2.ModeD, Iterations: 2, Memory: 1024, Parallelism: 2},
+	},
+	"chacha20-argon2id.kdbx": {
+		Cipher: kdbx.CipherChaCha20,
+		KDF:    kdbx.KDF{Mode: argon2.ModeID, Iterations: 1, Memory: 2048, Parallelism: 1},
+	},
+}
+
+func fixtureDatabase() *kdbx.Database {
+	created := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	modified := time.Date(2024, 5, 20, 18, 0, 5, 0, time.UTC)
+	times := kdbx.Times{Created: created, Modified: modified, Accessed: modified}
+
+	mail := &kdbx.Entry{
+		UUID:  uuid.MustParse("7f1e5c3a-1111-4a4a-9b9b-000000000001"),
+		Times: times,
+		Tags:  []string{"personal", "email"},
+		Strings: []kdbx.String{
+			{Key: kdbx.KeyTitle, Value: "mail"},
+			{Key: kdbx.KeyUserName, Value: "alice@example.com"},
+			{Key: kdbx.KeyPassword, Value: "Mail-Password-2", Protected: true},
+			{Key: kdbx.KeyURL, Value: "https://mail.example.com"},
+			{Key: kdbx.KeyNotes, Value: "first line\nsecond line <with> & markup"},
+			{Key: "recovery code", Value: "ABCD-EFGH", Protected: true},
+			{Key: "empty secret", Value: "", Protected: true},
+		},
+		Binaries: []kdbx.Binary{
+			{Name: "backup-codes.txt", Data: []byte("1234 5678\n")},
+			{Name: "copy.txt", Data: []byte("1234 5678\n")},
+		},
+		CustomData: map[string]string{"simple-pass/type": "login"},
+		History: []*kdbx.Entry{{
+			UUID:  uuid.MustParse("7f1e5c3a-1111-4a4a-9b9b-000000000001"),
+			Times: kdbx.Times{Created: created, Modified: created, Accessed: created},
+			Strings: []kdbx.String{
+				{Key: kdbx.KeyTitle, Value: "mail"},
+				{Key: kdbx.KeyPassword, Value: "Mail-Password-1", Protected: true},
+			},
+		}},
+	}
+	router := &kdbx.Entry{
+		UUID:  uuid.MustParse("7f1e5c3a-1111-4a4a-9b9b-000000000002"),
+		Times: kdbx.Times{Created: created, Modified: created, Expiry: modified, Expires: true},
+		Strings: []kdbx.String{
+			{Key: kdbx.KeyTitle, Value: "router"},
+			{Key: kdbx.KeyPassword, Value: "Router-Password-1", Protected: true},
+		},
+	}
+	return &kdbx.Database{
+		Name: "fixture",
+		Root: &kdbx.Group{
+			UUID:  uuid.MustParse("7f1e5c3a-2222-4a4a-9b9b-000000000001"),
+			Name:  "fixture",
+			Times: times,
+			Groups: []*kdbx.Group{
+				{
+					UUID:    uuid.MustParse("7f1e5c3a-2222-4a4a-9b9b-000000000002"),
+					Name:    "home",
+					Notes:   "everything at home",
+					Times:   times,
+					Entries: []*kdbx.Entry{router},
+				},
+				{
+					UUID:    uuid.MustParse("7f1e5c3a-2222-4a4a-9b9b-000000000003"),
+					Name:    "Recycle Bin",
+					Times:   times,
+					Entries: []*kdbx.Entry{},
+				},
+			},
+			Entries: []*kdbx.Entry{mail},
+		},
+		RecycleBin: uuid.MustParse("7f1e5c3a-2222-4a4a-9b9b-000000000003"),
+	}
+}
+
+func TestShouldReadFixtures(t *testing.T) {
+	for name, options := range fixtures {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join("testdata", name)
+			if *update {
+				buf := &bytes.Buffer{}
+				require.NoError(t, kdbx.Write(buf, fixtureDatabase(), fixturePassword, options))
+				require.NoError(t, os.WriteFile(path, buf.Bytes(), 0600))
+			}
+			file, err := os.Open(path)
+			require.NoError(t, err)
+			defer file.Close()
+
+			database, err := kdbx.Read(file, fixturePassword)
+			require.NoError(t, err)
+			expected := fixtureDatabase()
+			// a group with no entries is read back without them
+			expected.Root.Groups[1].Entries = nil
+			require.Equal(t, expected, database)
+		})
+	}
+}
+
+// the reference fixtures were written by a separate encoder rather than by Write, following the layout KeePassXC 2.7
+// gives its databases (including elements this package ignores, and binaries shared between entries). They were not
+// saved by KeePassXC itself, so check the reader against a second implementation of the format - not against real
+// KeePassXC exports
+func referenceFixtureDatabase() *kdbx.Database {
+	created := time.Date(2023, 6, 1, 10, 0, 0, 0, time.UTC)
+	modified := time.Date(2024, 1, 15, 20, 30, 45, 0, time.UTC)
+	accessed := time.Date(2024, 2, 1, 7, 5, 0, 0, time.UTC)
+	expiry := time.Date(2025, 12, 31, 23, 59, 59, 0, time.UTC)
+	times := kdbx.Times{Created: created, Modified: modified, Accessed: accessed, Expiry: expiry}
+	publicKey := []byte("ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAI fixture\n")
+
+	github := &kdbx.Entry{
+		UUID:  uuid.MustParse("5a3f1c2e-1111-4b1d-8e2f-000000000001"),
+		Times: times,
+		Tags:  []string{"work", "code"},
+		Strings: []kdbx.String{
+			{Key: kdbx.KeyNotes, Value: "line one\nline two & <three>"},
+			{Key: kdbx.KeyPassword, Value: "GitHub-Password-2", Protected: true},
+			{Key: kdbx.KeyTitle, Value: "github"},
+			{Key: kdbx.KeyURL, Value: "https://github.com"},
+			{Key: kdbx.KeyUserName, Value: "octocat"},
+			{Key: "account id", Value: "42"},
+			{Key: "empty secret", Value: "", Protected: true},
+			{Key: "recovery code", Value: "ABCD-1234", Protected: true},
+		},
+		Binaries: []kdbx.Binary{{Name: "id_ed25519.pub", Data: publicKey}},
+		History: []*kdbx.Entry{{
+			UUID:  uuid.MustParse("5a3f1c2e-1111-4b1d-8e2f-000000000001"),
+			Times: kdbx.Times{Created: created, Modified: created, Accessed: accessed, Expiry: expiry},
+			Strings: []kdbx.String{
+				{Key: kdbx.KeyPassword, Value: "GitHub-Password-1", Protected: true},
+				{Key: kdbx.KeyTitle, Value: "github"},
+			},
+			Binaries: []kdbx.Binary{{Name: "id_ed25519.pub", Data: publicKey}},
+		}},
+	}
+	bank := &kdbx.Entry{
+		UUID:  uuid.MustParse("5a3f1c2e-1111-4b1d-8e2f-000000000002"),
+		Times: kdbx.Times{Created: created, Modified: modified, Accessed: accessed, Expiry: expiry, Expires: true},
+		Strings: []kdbx.String{
+			{Key: kdbx.KeyNotes, Value: ""},
+			{Key: kdbx.KeyPassword, Value: "Bank-Password-1", Protected: true},
+			{Key: kdbx.KeyTitle, Value: "bank"},
+			{Key: kdbx.KeyURL, Value: ""},
+			{Key: kdbx.KeyUserName, Value: "alice"},
+		},
+		Binaries: []kdbx.Binary{
+			{Name: "statement.txt", Data: []byte("opening balance 100.00\n")},
+			{Name: "key copy.pub", Data: publicKey},
+		},
+	}
+	old := &kdbx.Entry{
+		UUID:  uuid.MustParse("5a3f1c2e-1111-4b1d-8e2f-000000000003"),
+		Times: kdbx.Times{Created: created, Modified: created, Accessed: accessed, Expiry: expiry},
+		Strings: []kdbx.String{
+			{Key: kdbx.KeyPassword, Value: "Old-Password-1", Protected: true},
+			{Key: kdbx.KeyTitle, Value: "old"},
+		},
+	}
+	return &kdbx.Database{
+		Name: "Passwords",
+		Root: &kdbx.Group{
+			UUID:  uuid.MustParse("5a3f1c2e-0000-4b1d-8e2f-000000000001"),
+			Name:  "Root",
+			Times: times,
+			Groups: []*kdbx.Group{
+				{
+					UUID:    uuid.MustParse("5a3f1c2e-0000-4b1d-8e2f-000000000002"),
+					Name:    "Banking",
+					Notes:   "money & accounts",
+					Times:   times,
+					Entries: []*kdbx.Entry{bank},
+				},
+				{
+					UUID:    uuid.MustParse("5a3f1c2e-0000-4b1d-8e2f-000000000003"),
+					Name:    "Recycle Bin",
+					Times:   times,
+					Entries: []*kdbx.Entry{old},
+				},
+			},
+			Entries: []*kdbx.Entry{github},
+		},
+		RecycleBin: uuid.MustParse("5a3f1c2e-0000-4b1d-8e2f-000000000003"),
+	}
+}
+
+func TestShouldReadReferenceFixtures(t *testing.T) {
+	for _, name := range []string{
+		"reference-aes256-argon2d.kdbx",
+		"reference-chacha20-argon2id.kdbx",
+		"reference-aes256-aeskdf.kdbx",
+	} {
+		t.Run(name, func(t *testing.T) {
+			file, err := os.Open(filepath.Join("testdata", name))
+			require.NoError(t, err)
+			defer file.Close()
+
+			database, err := kdbx.Read(file, fixturePassword)
+			require.NoError(t, err)
+			require.Equal(t, referenceFixtureDatabase(), database)
+		})
+	}
+}
+
+func TestShouldWriteAndReadDatabase(t *testing.T) {
+	for _, cipher := range kdbx.Ciphers() {
+		t.Run(string(cipher), func(t *testing.T) {
+			options := kdbx.Options{Cipher: cipher, KDF: kdbx.KDF{Mode: argon2.ModeID, Iterations: 1, Memory: 64, Parallelism: 1}}
+			entry := &kdbx.Entry{UUID: uuid.New()}
+			entry.Set(kdbx.KeyTitle, "large", false)
+			entry.Set(kdbx.KeyPassword, "Large-Password-1", true)
+			// an attachment which cannot be compressed, so the contents take more than one block
+			attachment := make([]byte, 3<<19)
+			_, err := rand.Read(attachment)
+			require.NoError(t, err)
+			entry.Binaries = []kdbx.Binary{{Name: "noise.bin", Data: attachment}}
+			database := &kdbx.Database{Name: "large", Root: &kdbx.Group{Name: "large", Entries: []*kdbx.Entry{entry}}}
+
+			buf := &bytes.Buffer{}
+			require.NoError(t, kdbx.Write(buf, database, fixturePassword, options))
+			require.Greater(t, buf.Len(), 1<<20)
+			read, err := kdbx.Read(bytes.NewReader(buf.Bytes()), fixturePassword)
+			require.NoError(t, err)
+			require.Equal(t, database, read)
+		})
+	}
+
+	err := kdbx.Write(&bytes.Buffer{}, fixtureDatabase(), fixturePassword, kdbx.Options{Cipher: "twofish", KDF: kdbx.DefaultOptions().KDF})
+	require.ErrorIs(t, err, kdbx.ErrUnsupportedCipher)
+}
+
+func TestShouldRejectWrongPasswordOrCorruptFile(t *testing.T) {
+	fixture, err := os.ReadFile(filepath.Join("testdata", "aes256-argon2d.kdbx"))
+	require.NoError(t, err)
+
+	_, err = kdbx.Read(bytes.NewReader(fixture), "Wrong-Password-1")
+	require.ErrorIs(t, err, kdbx.ErrInvalidCredentials)
+
+	// a byte of the outer header, then one of the data of the last block before the empty one ending the file
+	for _, offset := range []int{12, len(fixture) - 40} {
+		tampered := append([]byte{}, fixture...)
+		tampered[offset] ^= 0x01
+		_, err = kdbx.Read(bytes.NewReader(tampered), fixturePassword)
+		require.ErrorIs(t, err, kdbx.ErrCorrupt, "offset %d", offset)
+	}
+	_, err = kdbx.Read(bytes.NewReader(fixture[:len(fixture)-1]), fixturePassword)
+	require.ErrorIs(t, err, kdbx.ErrCorrupt)
+
+	_, err = kdbx.Read(bytes.NewReader([]byte("name,url,username,password\n")), fixturePassword)
+	require.ErrorIs(t, err, kdbx.ErrNotKDBX)
+	kdbx3 := append([]byte{}, fixture...)
+	kdbx3[10] = 0x03
+	_, err = kdbx.Read(bytes.NewReader(kdbx3), fixturePassword)
+	require.ErrorIs(t, err, kdbx.ErrUnsupportedVersion)
+}
@@ -0,0 +1,461 @@
+package kdbx
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/chacha20"
+)
+
+/*
+	The decrypted contents of a kdbx 4 file are the inner header - which holds the key protected values are encrypted
+	with, and the binaries attached to entries - followed by the xml document holding everything else.
+
+	Protected values are encrypted using one stream cipher across the whole document, in the order they appear in it.
+	So rather than each value being encrypted as it is marshalled, the whole document is rewritten once it has been.
+*/
+
+var ErrUnsupportedInnerStream = errors.New("unsupported inner stream cipher")
+
+// inner header field ids
+const (
+	innerEndOfHeader = 0
+	innerStreamID    = 1
+	innerStreamKey   = 2
+	innerBinary      = 3
+)
+
+// innerStreamChaCha20 is the id of the only inner stream cipher used by kdbx 4
+const innerStreamChaCha20 = 3
+
+const generator = "simple-pass"
+
+// innerHeader holds the key of the inner stream, and the binaries referred to from the xml document by their index
+type innerHeader struct {
+	streamKey []byte
+	binaries  [][]byte
+}
+
+func readInnerHeader(r io.Reader) (*innerHeader, error) {
+	h := &innerHeader{}
+	for {
+		id, data, err := readField(r)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrCorrupt, err)
+		}
+		switch id {
+		case innerEndOfHeader:
+			if h.streamKey == nil {
+				return nil, fmt.Errorf("%w: no inner stream key", ErrCorrupt)
+			}
+			return h, nil
+		case innerStreamID:
+			if len(data) != 4 || binary.LittleEndian.Uint32(data) != innerStreamChaCha20 {
+				return nil, ErrUnsupportedInnerStream
+			}
+		case innerStreamKey:
+			h.streamKey = data
+		case innerBinary:
+			// the first byte holds flags - only whether the binary should be protected in memory
+			if len(data) == 0 {
+				return nil, fmt.Errorf("%w: invalid binary", ErrCorrupt)
+			}
+			h.binaries = append(h.binaries, data[1:])
+		}
+	}
+}
+
+func (h *innerHeader) write(w io.Writer) error {
+	var streamID [4]byte
+	binary.LittleEndian.PutUint32(streamID[:], innerStreamChaCha20)
+	err := writeField(w, innerStreamID, streamID[:])
+	if err != nil {
+		return err
+	}
+	err = writeField(w, innerStreamKey, h.streamKey)
+	if err != nil {
+		return err
+	}
+	for _, data := range h.binaries {
+		err = writeField(w, innerBinary, append([]byte{0}, data...))
+		if err != nil {
+			return err
+		}
+	}
+	return writeField(w, innerEndOfHeader, nil)
+}
+
+// innerStream returns the stream cipher protected values are encrypted with
+func (h *innerHeader) innerStream() (*chacha20.Cipher, error) {
+	hashed := sha512.Sum512(h.streamKey)
+	return chacha20.NewUnauthenticatedCipher(hashed[:chacha20.KeySize], hashed[chacha20.KeySize:chacha20.KeySize+chacha20.NonceSize])
+}
+
+// transformProtected rewrites the xml document, replacing the text of every protected value with the result of
+// transform - which is given them in the order they appear
+func transformProtected(document []byte, transform func(value string) (string, error)) ([]byte, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(document))
+	out := &bytes.Buffer{}
+	encoder := xml.NewEncoder(out)
+	protected := false
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrCorrupt, err)
+		}
+		switch typed := token.(type) {
+		case xml.StartElement:
+			protected = typed.Name.Local == "Value" && isProtected(typed.Attr)
+		case xml.EndElement:
+			protected = false
+		case xml.CharData:
+			if protected {
+				// an empty value has no text, but also takes nothing from the stream
+				value, err := transform(string(typed))
+				if err != nil {
+					return nil, err
+				}
+				token = xml.CharData(value)
+			}
+		}
+		err = encoder.EncodeToken(xml.CopyToken(token))
+		if err != nil {
+			return nil, err
+		}
+	}
+	err := encoder.Flush()
+	return out.Bytes(), err
+}
+
+func isProtected(attrs []xml.Attr) bool {
+	for _, attr := range attrs {
+		if attr.Name.Local == "Protected" && strings.EqualFold(attr.Value, "true") {
+			return true
+		}
+	}
+	return false
+}
+
+func unprotect(document []byte, stream *chacha20.Cipher) ([]byte, error) {
+	return transformProtected(document, func(value string) (string, error) {
+		data, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return "", fmt.Errorf("%w: invalid protected value", ErrCorrupt)
+		}
+		stream.XORKeyStream(data, data)
+		return string(data), nil
+	})
+}
+
+func protect(document []byte, stream *chacha20.Cipher) ([]byte, error) {
+	return transformProtected(document, func(value string) (string, error) {
+		data := []byte(value)
+		stream.XORKeyStream(data, data)
+		return base64.StdEncoding.EncodeToString(data), nil
+	})
+}
+
+type xmlFile struct {
+	XMLName xml.Name `xml:"KeePassFile"`
+	Meta    xmlMeta
+	Root    xmlRoot
+}
+
+type xmlMeta struct {
+	Generator         string
+	DatabaseName      string
+	MemoryProtection  xmlMemoryProtection
+	RecycleBinEnabled xmlBool
+	RecycleBinUUID    xmlUUID
+}
+
+// xmlMemoryProtection is which of the standard strings are protected by default
+type xmlMemoryProtection struct {
+	ProtectTitle    xmlBool
+	ProtectUserName xmlBool
+	ProtectPassword xmlBool
+	ProtectURL      xmlBool
+	ProtectNotes    xmlBool
+}
+
+type xmlRoot struct {
+	Group xmlGroup
+}
+
+type xmlGroup struct {
+	UUID    xmlUUID
+	Name    string
+	Notes   string
+	IconID  int
+	Times   xmlTimes
+	Entries []xmlEntry `xml:"Entry"`
+	Groups  []xmlGroup `xml:"Group"`
+}
+
+type xmlEntry struct {
+	UUID       xmlUUID
+	IconID     int
+	Tags       string
+	Times      xmlTimes
+	Strings    []xmlString   `xml:"String"`
+	Binaries   []xmlBinary   `xml:"Binary"`
+	CustomData []xmlDataItem `xml:"CustomData>Item"`
+	History    *xmlHistory   `xml:",omitempty"`
+}
+
+type xmlHistory struct {
+	Entries []xmlEntry `xml:"Entry"`
+}
+
+type xmlString struct {
+	Key   string
+	Value xmlValue
+}
+
+type xmlValue struct {
+	Protected string `xml:",attr,omitempty"`
+	Value     string `xml:",chardata"`
+}
+
+type xmlBinary struct {
+	Key   string
+	Value struct {
+		Ref int `xml:",attr"`
+	}
+}
+
+type xmlDataItem struct {
+	Key   string
+	Value string
+}
+
+type xmlTimes struct {
+	CreationTime         xmlTime
+	LastModificationTime xmlTime
+	LastAccessTime       xmlTime
+	ExpiryTime           xmlTime
+	Expires              xmlBool
+	UsageCount           int
+	LocationChanged      xmlTime
+}
+
+type xmlBool bool
+
+func (b xmlBool) MarshalText() ([]byte, error) {
+	if b {
+		return []byte("True"), nil
+	}
+	return []byte("False"), nil
+}
+
+func (b *xmlBool) UnmarshalText(text []byte) error {
+	*b = xmlBool(strings.EqualFold(strings.TrimSpace(string(text)), "true"))
+	return nil
+}
+
+// xmlUUID is a uuid held as the base64 of its bytes
+type xmlUUID uuid.UUID
+
+func (u xmlUUID) MarshalText() ([]byte, error) {
+	return []byte(base64.StdEncoding.EncodeToString(u[:])), nil
+}
+
+func (u *xmlUUID) UnmarshalText(text []byte) error {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(text)))
+	if err != nil || (len(data) != 0 && len(data) != len(u)) {
+		return fmt.Errorf("%w: invalid uuid '%s'", ErrCorrupt, text)
+	}
+	*u = xmlUUID{}
+	copy(u[:], data)
+	return nil
+}
+
+// epochOffset is the number of seconds from 0001-01-01, which kdbx 4 times count from, to the unix epoch
+const epochOffset = 62135596800
+
+// xmlTime is a time held as the base64 of the number of seconds since 0001-01-01 - or in older files, as text
+type xmlTime time.Time
+
+func (t xmlTime) MarshalText() ([]byte, error) {
+	var data [8]byte
+	binary.LittleEndian.PutUint64(data[:], uint64(time.Time(t).Unix()+epochOffset))
+	return []byte(base64.StdEncoding.EncodeToString(data[:])), nil
+}
+
+func (t *xmlTime) UnmarshalText(text []byte) error {
+	trimmed := strings.TrimSpace(string(text))
+	if trimmed == "" {
+		*t = xmlTime{}
+		return nil
+	}
+	if parsed, err := time.Parse(time.RFC3339, trimmed); err == nil {
+		*t = xmlTime(parsed.UTC())
+		return nil
+	}
+	data, err := base64.StdEncoding.DecodeString(trimmed)
+	if err != nil || len(data) != 8 {
+		return fmt.Errorf("%w: invalid time '%s'", ErrCorrupt, text)
+	}
+	*t = xmlTime(time.Unix(int64(binary.LittleEndian.Uint64(data))-epochOffset, 0).UTC())
+	return nil
+}
+
+func timesFromXML(times xmlTimes) Times {
+	return Times{
+		Created:  time.Time(times.CreationTime),
+		Modified: time.Time(times.LastModificationTime),
+		Accessed: time.Time(times.LastAccessTime),
+		Expiry:   time.Time(times.ExpiryTime),
+		Expires:  bool(times.Expires),
+	}
+}
+
+func timesToXML(times Times) xmlTimes {
+	return xmlTimes{
+		CreationTime:         xmlTime(times.Created),
+		LastModificationTime: xmlTime(times.Modified),
+		LastAccessTime:       xmlTime(times.Accessed),
+		ExpiryTime:           xmlTime(times.Expiry),
+		Expires:              xmlBool(times.Expires),
+		LocationChanged:      xmlTime(times.Modified),
+	}
+}
+
+func groupFromXML(group xmlGroup, binaries [][]byte) (*Group, error) {
+	converted := &Group{
+		UUID:  uuid.UUID(group.UUID),
+		Name:  group.Name,
+		Notes: group.Notes,
+		Times: timesFromXML(group.Times),
+	}
+	for _, entry := range group.Entries {
+		convertedEntry, err := entryFromXML(entry, binaries)
+		if err != nil {
+			return nil, err
+		}
+		converted.Entries = append(converted.Entries, convertedEntry)
+	}
+	for _, child := range group.Groups {
+		convertedChild, err := groupFromXML(child, binaries)
+		if err != nil {
+			return nil, err
+		}
+		converted.Groups = append(converted.Groups, convertedChild)
+	}
+	return converted, nil
+}
+
+func entryFromXML(entry xmlEntry, binaries [][]byte) (*Entry, error) {
+	converted := &Entry{
+		UUID:  uuid.UUID(entry.UUID),
+		Times: timesFromXML(entry.Times),
+	}
+	for _, tag := range strings.FieldsFunc(entry.Tags, func(r rune) bool { return r == ';' || r == ',' }) {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			converted.Tags = append(converted.Tags, tag)
+		}
+	}
+	for _, str := range entry.Strings {
+		converted.Strings = append(converted.Strings, String{Key: str.Key, Value: str.Value.Value, Protected: strings.EqualFold(str.Value.Protected, "true")})
+	}
+	for _, attached := range entry.Binaries {
+		if attached.Value.Ref < 0 || attached.Value.Ref >= len(binaries) {
+			return nil, fmt.Errorf("%w: entry refers to missing binary %d", ErrCorrupt, attached.Value.Ref)
+		}
+		converted.Binaries = append(converted.Binaries, Binary{Name: attached.Key, Data: binaries[attached.Value.Ref]})
+	}
+	if len(entry.CustomData) > 0 {
+		converted.CustomData = map[string]string{}
+		for _, data := range entry.CustomData {
+			converted.CustomData[data.Key] = data.Value
+		}
+	}
+	if entry.History != nil {
+		for _, previous := range entry.History.Entries {
+			convertedPrevious, err := entryFromXML(previous, binaries)
+			if err != nil {
+				return nil, err
+			}
+			converted.History = append(converted.History, convertedPrevious)
+		}
+	}
+	return converted, nil
+}
+
+// binaryPool collects the binaries of every entry written, holding each distinct one once
+type binaryPool struct {
+	binaries [][]byte
+	refs     map[string]int
+}
+
+func (p *binaryPool) ref(data []byte) int {
+	if ref, exists := p.refs[string(data)]; exists {
+		return ref
+	}
+	if p.refs == nil {
+		p.refs = map[string]int{}
+	}
+	p.refs[string(data)] = len(p.binaries)
+	p.binaries = append(p.binaries, data)
+	return len(p.binaries) - 1
+}
+
+func groupToXML(group *Group, pool *binaryPool) xmlGroup {
+	converted := xmlGroup{
+		UUID:  xmlUUID(group.UUID),
+		Name:  group.Name,
+		Notes: group.Notes,
+		// the folder icon
+		IconID: 48,
+		Times:  timesToXML(group.Times),
+	}
+	for _, entry := range group.Entries {
+		converted.Entries = append(converted.Entries, entryToXML(entry, pool))
+	}
+	for _, child := range group.Groups {
+		converted.Groups = append(converted.Groups, groupToXML(child, pool))
+	}
+	return converted
+}
+
+func entryToXML(entry *Entry, pool *binaryPool) xmlEntry {
+	converted := xmlEntry{
+		UUID:  xmlUUID(entry.UUID),
+		Tags:  strings.Join(entry.Tags, ";"),
+		Times: timesToXML(entry.Times),
+	}
+	for _, str := range entry.Strings {
+		value := xmlValue{Value: str.Value}
+		if str.Protected {
+			value.Protected = "True"
+		}
+		converted.Strings = append(converted.Strings, xmlString{Key: str.Key, Value: value})
+	}
+	for _, attached := range entry.Binaries {
+		binaryRef := xmlBinary{Key: attached.Name}
+		binaryRef.Value.Ref = pool.ref(attached.Data)
+		converted.Binaries = append(converted.Binaries, binaryRef)
+	}
+	for _, key := range sortedKeys(entry.CustomData) {
+		converted.CustomData = append(converted.CustomData, xmlDataItem{Key: key, Value: entry.CustomData[key]})
+	}
+	if len(entry.History) > 0 {
+		converted.History = &xmlHistory{}
+		for _, previous := range entry.History {
+			converted.History.Entries = append(converted.History.Entries, entryToXML(previous, pool))
+		}
+	}
+	return converted
+}
@@ -0,0 +1,287 @@
+package otp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1" // #nosec - sha1 is mandated by RFC 4226 and is the default for most authenticators
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kind is the kind of one time password a key generates
+type Kind string
+
+// Kinds of one time password
+const (
+	// TOTP time based one time passwords - RFC 6238
+	TOTP Kind = "totp"
+	// HOTP counter based one time passwords - RFC 4226
+	HOTP Kind = "hotp"
+)
+
+// Algorithm is the HMAC hash algorithm used to generate codes
+type Algorithm string
+
+// Algorithms supported for generating codes
+const (
+	SHA1   Algorithm = "SHA1"
+	SHA256 Algorithm = "SHA256"
+	SHA512 Algorithm = "SHA512"
+)
+
+const (
+	DefaultDigits = 6
+	DefaultPeriod = 30
+	uriScheme     = "otpauth"
+)
+
+var (
+	ErrInvalidURI       = errors.New("invalid otpauth uri")
+	ErrInvalidSecret    = errors.New("otp secret must be non-empty base32")
+	ErrInvalidKind      = errors.New("otp kind must be totp or hotp")
+	ErrInvalidAlgorithm = errors.New("otp algorithm must be one of SHA1, SHA256 or SHA512")
+	ErrInvalidDigits    = errors.New("otp digits must be between 6 and 10")
+	ErrInvalidPeriod    = errors.New("otp period must be a positive number of seconds")
+)
+
+// Clock provides the current time, allowing time based codes to be generated for any given moment
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is a Clock which reports the actual current time
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// Key holds the parameters needed to generate one time passwords for an account
+type Key struct {
+	Kind Kind
+	// Secret is the base32 encoded shared secret
+	Secret    string
+	Algorithm Algorithm
+	Digits    int
+	// Period is the number of seconds each TOTP code is valid for
+	Period int `json:",omitempty"`
+	// Counter is the moving factor of the next HOTP code to be generated
+	Counter uint64 `json:",omitempty"`
+	Issuer  string `json:",omitempty"`
+	Account string `json:",omitempty"`
+}
+
+// Code is a generated one time password
+type Code struct {
+	Value string
+	// Remaining is how much longer a TOTP code is valid for - this is always zero for HOTP codes, which are valid
+	// until used
+	Remaining time.Duration
+}
+
+// NewTOTPKey returns a TOTP key with the default parameters used by most services for a base32 secret
+func NewTOTPKey(secret string) (*Key, error) {
+	key := &Key{
+		Kind:      TOTP,
+		Secret:    normaliseSecret(secret),
+		Algorithm: SHA1,
+		Digits:    DefaultDigits,
+		Period:    DefaultPeriod,
+	}
+	return key, key.Validate()
+}
+
+// ParseURI parses a key from an otpauth:// uri, as encoded in the QR codes used to set up authenticators - see
+// https://github.com/google/google-authenticator/wiki/Key-Uri-Format
+func ParseURI(uri string) (*Key, error) {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidURI, err)
+	}
+	if parsed.Scheme != uriScheme {
+		return nil, fmt.Errorf("%w: scheme must be %s", ErrInvalidURI, uriScheme)
+	}
+
+	query := parsed.Query()
+	key := &Key{
+		Kind:      Kind(strings.ToLower(parsed.Host)),
+		Secret:    normaliseSecret(query.Get("secret")),
+		Algorithm: SHA1,
+		Digits:    DefaultDigits,
+		Issuer:    query.Get("issuer"),
+	}
+
+	label := strings.TrimPrefix(parsed.Path, "/")
+	if issuer, account, found := strings.Cut(label, ":"); found {
+		key.Account = strings.TrimSpace(account)
+		if key.Issuer == "" {
+			key.Issuer = issuer
+		}
+	} else {
+		key.Account = label
+	}
+
+	if algorithm := query.Get("algorithm"); algorithm != "" {
+		key.Algorithm = Algorithm(strings.ToUpper(algorithm))
+	}
+	if digits := query.Get("digits"); digits != "" {
+		key.Digits, err = strconv.Atoi(digits)
+		if err != nil {
+			return nil, ErrInvalidDigits
+		}
+	}
+	switch key.Kind {
+	case TOTP:
+		key.Period = DefaultPeriod
+		if period := query.Get("period"); period != "" {
+			key.Period, err = strconv.Atoi(period)
+			if err != nil {
+				return nil, ErrInvalidPeriod
+			}
+		}
+	case HOTP:
+		if counter := query.Get("counter"); counter != "" {
+			key.Counter, err = strconv.ParseUint(counter, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%w: invalid counter", ErrInvalidURI)
+			}
+		}
+	}
+
+	return key, key.Validate()
+}
+
+// URI returns the otpauth:// uri for the key
+func (k *Key) URI() string {
+	query := url.Values{}
+	query.Set("secret", k.Secret)
+	query.Set("algorithm", string(k.Algorithm))
+	query.Set("digits", strconv.Itoa(k.Digits))
+	if k.Issuer != "" {
+		query.Set("issuer", k.Issuer)
+	}
+	if k.Kind == TOTP {
+		query.Set("period", strconv.Itoa(k.Period))
+	} else {
+		query.Set("counter", strconv.FormatUint(k.Counter, 10))
+	}
+
+	label := k.Account
+	if k.Issuer != "" {
+		label = k.Issuer + ":" + k.Account
+	}
+	uri := url.URL{Scheme: uriScheme, Host: string(k.Kind), Path: "/" + label, RawQuery: query.Encode()}
+	return uri.String()
+}
+
+// Validate checks that codes can be generated from the key
+func (k *Key) Validate() error {
+	if k.Kind != TOTP && k.Kind != HOTP {
+		return ErrInvalidKind
+	}
+	if _, err := k.secretBytes(); err != nil {
+		return err
+	}
+	if _, err := k.Algorithm.hash(); err != nil {
+		return err
+	}
+	if k.Digits < 6 || k.Digits > 10 {
+		return ErrInvalidDigits
+	}
+	if k.Kind == TOTP && k.Period <= 0 {
+		return ErrInvalidPeriod
+	}
+	return nil
+}
+
+// Generate returns the code for the key - for TOTP keys this is the code valid at the time given by the clock, and
+// for HOTP keys this is the code for the current counter. NOTE generating a HOTP code does not advance the counter;
+// callers are responsible for persisting the incremented counter before using the code
+func (k *Key) Generate(clock Clock) (*Code, error) {
+	err := k.Validate()
+	if err != nil {
+		return nil, err
+	}
+	if k.Kind == HOTP {
+		value, err := k.generate(k.Counter)
+		if err != nil {
+			return nil, err
+		}
+		return &Code{Value: value}, nil
+	}
+
+	now := clock.Now().Unix()
+	period := int64(k.Period)
+	value, err := k.generate(uint64(now / period))
+	if err != nil {
+		return nil, err
+	}
+	return &Code{Value: value, Remaining: time.Duration(period-now%period) * time.Second}, nil
+}
+
+// generate implements the HOTP algorithm from RFC 4226, of which TOTP is a time based application
+func (k *Key) generate(counter uint64) (string, error) {
+	secret, err := k.secretBytes()
+	if err != nil {
+		return "", err
+	}
+	hashFn, err := k.Algorithm.hash()
+	if err != nil {
+		return "", err
+	}
+
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, counter)
+	mac := hmac.New(hashFn, secret)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	// dynamic truncation - RFC 4226 section 5.3
+	offset := sum[len(sum)-1] & 0x0f
+	binCode := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	modulus := uint64(1)
+	for i := 0; i < k.Digits; i++ {
+		modulus *= 10
+	}
+	return fmt.Sprintf("%0*d", k.Digits, uint64(binCode)%modulus), nil
+}
+
+func (k *Key) secretBytes() ([]byte, error) {
+	if k.Secret == "" {
+		return nil, ErrInvalidSecret
+	}
+	secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(k.Secret)
+	if err != nil {
+		return nil, ErrInvalidSecret
+	}
+	return secret, nil
+}
+
+func (a Algorithm) hash() (func() hash.Hash, error) {
+	switch a {
+	case SHA1:
+		return sha1.New, nil
+	case SHA256:
+		return sha256.New, nil
+	case SHA512:
+		return sha512.New, nil
+	default:
+		return nil, ErrInvalidAlgorithm
+	}
+}
+
+// normaliseSecret accepts secrets in the forms they are commonly presented, e.g. lower case, grouped with spaces or
+// padded
+func normaliseSecret(secret string) string {
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	return strings.TrimRight(secret, "=")
+}
@@ -0,0 +1,126 @@
+package otp_test
+
+import (
+	"encoding/base32"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/georgewheatcroft/simple-pass/internal/common/constants"
+	"github.com/georgewheatcroft/simple-pass/pkg/otp"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	log.SetLevel(log.DebugLevel)
+	// avoid overwritting local dev .passdb TODO better way
+	os.Setenv(constants.PassDBLocalDevEnvVar, "True")
+}
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c fakeClock) Now() time.Time {
+	return c.now
+}
+
+func encodeSecret(secret string) string {
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte(secret))
+}
+
+// TestShouldGenerateHOTPTestVectors checks against the test values from RFC 4226 appendix D
+func TestShouldGenerateHOTPTestVectors(t *testing.T) {
+	expected := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+
+	key := &otp.Key{Kind: otp.HOTP, Secret: encodeSecret("12345678901234567890"), Algorithm: otp.SHA1, Digits: 6}
+	for counter, expectedCode := range expected {
+		key.Counter = uint64(counter)
+		code, err := key.Generate(nil)
+		require.NoError(t, err)
+		require.Equalf(t, expectedCode, code.Value, "unexpected code for counter %d", counter)
+		require.Zero(t, code.Remaining)
+	}
+}
+
+// TestShouldGenerateTOTPTestVectors checks against the test values from RFC 6238 appendix B
+func TestShouldGenerateTOTPTestVectors(t *testing.T) {
+	secrets := map[otp.Algorithm]string{
+		otp.SHA1:   "12345678901234567890",
+		otp.SHA256: "12345678901234567890123456789012",
+		otp.SHA512: "1234567890123456789012345678901234567890123456789012345678901234",
+	}
+	vectors := []struct {
+		unixTime int64
+		expected map[otp.Algorithm]string
+	}{
+		{59, map[otp.Algorithm]string{otp.SHA1: "94287082", otp.SHA256: "46119246", otp.SHA512: "90693936"}},
+		{1111111109, map[otp.Algorithm]string{otp.SHA1: "07081804", otp.SHA256: "68084774", otp.SHA512: "25091201"}},
+		{1111111111, map[otp.Algorithm]string{otp.SHA1: "14050471", otp.SHA256: "67062674", otp.SHA512: "99943326"}},
+		{1234567890, map[otp.Algorithm]string{otp.SHA1: "89005924", otp.SHA256: "91819424", otp.SHA512: "93441116"}},
+		{2000000000, map[otp.Algorithm]string{otp.SHA1: "69279037", otp.SHA256: "90698825", otp.SHA512: "38618901"}},
+		{20000000000, map[otp.Algorithm]string{otp.SHA1: "65353130", otp.SHA256: "77737706", otp.SHA512: "47863826"}},
+	}
+
+	for _, vector := range vectors {
+		clock := fakeClock{now: time.Unix(vector.unixTime, 0)}
+		for algorithm, expectedCode := range vector.expected {
+			key := &otp.Key{Kind: otp.TOTP, Secret: encodeSecret(secrets[algorithm]), Algorithm: algorithm, Digits: 8, Period: 30}
+			code, err := key.Generate(clock)
+			require.NoError(t, err)
+			require.Equalf(t, expectedCode, code.Value, "unexpected code at %d using %s", vector.unixTime, algorithm)
+		}
+	}
+}
+
+func TestShouldReportRemainingValidity(t *testing.T) {
+	key, err := otp.NewTOTPKey(encodeSecret("12345678901234567890"))
+	require.NoError(t, err)
+
+	code, err := key.Generate(fakeClock{now: time.Unix(59, 0)})
+	require.NoError(t, err)
+	require.Equal(t, time.Second, code.Remaining)
+
+	code, err = key.Generate(fakeClock{now: time.Unix(60, 0)})
+	require.NoError(t, err)
+	require.Equal(t, 30*time.Second, code.Remaining)
+}
+
+func TestShouldParseURIs(t *testing.T) {
+	key, err := otp.ParseURI("otpauth://totp/Example:alice@example.com?secret=JBSWY3DPEHPK3PXP&issuer=Example&period=60&digits=8&algorithm=sha256")
+	require.NoError(t, err)
+	require.Equal(t, otp.TOTP, key.Kind)
+	require.Equal(t, "JBSWY3DPEHPK3PXP", key.Secret)
+	require.Equal(t, "Example", key.Issuer)
+	require.Equal(t, "alice@example.com", key.Account)
+	require.Equal(t, 60, key.Period)
+	require.Equal(t, 8, key.Digits)
+	require.Equal(t, otp.SHA256, key.Algorithm)
+
+	// uris generated for a key must parse back to the same key
+	reparsed, err := otp.ParseURI(key.URI())
+	require.NoError(t, err)
+	require.Equal(t, key, reparsed)
+
+	key, err = otp.ParseURI("otpauth://hotp/alice?secret=jbsw y3dp ehpk 3pxp&counter=42")
+	require.NoError(t, err)
+	require.Equal(t, otp.HOTP, key.Kind)
+	require.Equal(t, uint64(42), key.Counter)
+	require.Equal(t, 6, key.Digits)
+
+	invalidURIs := map[string]error{
+		"https://totp/alice?secret=JBSWY3DPEHPK3PXP":                      otp.ErrInvalidURI,
+		"otpauth://motp/alice?secret=JBSWY3DPEHPK3PXP":                    otp.ErrInvalidKind,
+		"otpauth://totp/alice?secret=not-base32!":                         otp.ErrInvalidSecret,
+		"otpauth://totp/alice":                                            otp.ErrInvalidSecret,
+		"otpauth://totp/alice?secret=JBSWY3DPEHPK3PXP&algorithm=md5":      otp.ErrInvalidAlgorithm,
+		"otpauth://totp/alice?secret=JBSWY3DPEHPK3PXP&digits=4":           otp.ErrInvalidDigits,
+		"otpauth://totp/alice?secret=JBSWY3DPEHPK3PXP&period=0":           otp.ErrInvalidPeriod,
+		"otpauth://hotp/alice?secret=JBSWY3DPEHPK3PXP&counter=not-number": otp.ErrInvalidURI,
+	}
+	for uri, expectedErr := range invalidURIs {
+		_, err := otp.ParseURI(uri)
+		require.ErrorIsf(t, err, expectedErr, "unexpected error for %s", uri)
+	}
+}
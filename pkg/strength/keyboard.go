@@ -0,0 +1,135 @@
+package strength
+
+import (
+	"math"
+)
+
+// qwertyRows are the rows of a qwerty keyboard, unshifted and shifted. Each row is offset by part of a key from the
+// one above it, so a key's neighbours above are at the same and next positions, and those below at the previous and
+// same positions
+var qwertyRows = [][2]string{
+	{"1234567890-=", "!@#$%^&*()_+"},
+	{"qwertyuiop[]\\", "QWERTYUIOP{}|"},
+	{"asdfghjkl;'", "ASDFGHJKL:\""},
+	{"zxcvbnm,./", "ZXCVBNM<>?"},
+}
+
+type keyPosition struct {
+	row, col int
+	shifted  bool
+}
+
+var qwertyKeys = func() map[rune]keyPosition {
+	keys := map[rune]keyPosition{}
+	for row, chars := range qwertyRows {
+		for shifted, layer := range chars {
+			for col, r := range []rune(layer) {
+				keys[r] = keyPosition{row: row, col: col, shifted: shifted == 1}
+			}
+		}
+	}
+	return keys
+}()
+
+// qwertyDirections are the offsets from a key to each of its (up to 6) neighbours
+var qwertyDirections = [][2]int{{0, -1}, {0, 1}, {-1, 0}, {-1, 1}, {1, -1}, {1, 0}}
+
+// spatialDirection returns the direction from one key to an adjacent key, or -1 if they are not adjacent
+func spatialDirection(from, to rune) int {
+	a, aFound := qwertyKeys[from]
+	b, bFound := qwertyKeys[to]
+	if !aFound || !bFound {
+		return -1
+	}
+	for idx, direction := range qwertyDirections {
+		if b.row-a.row == direction[0] && b.col-a.col == direction[1] {
+			return idx
+		}
+	}
+	return -1
+}
+
+// spatialMatches finds runs of three or more adjacent keys, e.g. qwerty or zaq1
+func spatialMatches(password []rune) []Match {
+	matches := []Match{}
+	for i := 0; i < len(password)-2; {
+		j, turns, lastDirection := i, 0, -1
+		shifted := 0
+		if key, found := qwertyKeys[password[i]]; found && key.shifted {
+			shifted++
+		}
+		for j+1 < len(password) {
+			direction := spatialDirection(password[j], password[j+1])
+			if direction < 0 {
+				break
+			}
+			if direction != lastDirection {
+				turns++
+				lastDirection = direction
+			}
+			if qwertyKeys[password[j+1]].shifted {
+				shifted++
+			}
+			j++
+		}
+		if j-i >= 2 {
+			token := string(password[i : j+1])
+			matches = append(matches, Match{
+				Pattern: PatternSpatial,
+				Token:   token,
+				I:       i,
+				J:       j,
+				Turns:   turns,
+				Shifted: shifted,
+				Guesses: spatialGuesses(len(password[i:j+1]), turns, shifted),
+			})
+			i = j + 1
+			continue
+		}
+		i++
+	}
+	return matches
+}
+
+// spatialGuesses estimates the guesses needed to find a keyboard pattern of the given length, with the given number
+// of changes of direction, by trying every starting key and every direction at each turn
+func spatialGuesses(length, turns, shifted int) float64 {
+	startingPositions := float64(len(qwertyKeys))
+	averageDegree := qwertyAverageDegree
+
+	guesses := 0.0
+	for i := 2; i <= length; i++ {
+		possibleTurns := turns
+		if i-1 < possibleTurns {
+			possibleTurns = i - 1
+		}
+		for j := 1; j <= possibleTurns; j++ {
+			guesses += binomial(i-1, j-1) * startingPositions * math.Pow(averageDegree, float64(j))
+		}
+	}
+	if shifted > 0 {
+		unshifted := length - shifted
+		if unshifted == 0 {
+			guesses *= 2
+		} else {
+			variations := 0.0
+			for i := 1; i <= shifted && i <= unshifted; i++ {
+				variations += binomial(shifted+unshifted, i)
+			}
+			guesses *= variations
+		}
+	}
+	return guesses
+}
+
+var qwertyAverageDegree = func() float64 {
+	total := 0
+	for from := range qwertyKeys {
+		for to := range qwertyKeys {
+			if spatialDirection(from, to) >= 0 {
+				total++
+			}
+		}
+	}
+	return float64(total) / float64(len(qwertyKeys))
+}()
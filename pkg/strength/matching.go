@@ -0,0 +1,494 @@
+package strength
+
+import (
+	"bufio"
+	_ "embed"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/georgewheatcroft/simple-pass/pkg/generate"
+)
+
+const (
+	dictionaryPasswords  = "passwords"
+	dictionaryEnglish    = "english"
+	dictionaryUserInputs = "user-inputs"
+
+	// englishWordRank is the rank given to every english word. The wordlist is not ordered by frequency, so each word
+	// is treated as a typical word from a vocabulary of a few thousand
+	englishWordRank = 3000
+	// maxDictionaryTokenLength bounds the substrings looked up in the dictionaries
+	maxDictionaryTokenLength = 32
+
+	minYear = 1000
+	maxYear = 2050
+	// minYearSpace is the fewest years an attacker would try around the current year
+	minYearSpace = 20
+)
+
+// commonPasswords are frequently used passwords, most common first
+//
+//go:embed common_passwords.txt
+var commonPasswords string
+
+var (
+	loadRankedDictionaries sync.Once
+	rankedDictionaries     map[string]map[string]int
+)
+
+// l33tTable are the characters commonly substituted for letters
+var l33tTable = map[rune][]rune{
+	'4': {'a'}, '@': {'a'},
+	'8': {'b'},
+	'(': {'c'}, '{': {'c'}, '[': {'c'}, '<': {'c'},
+	'3': {'e'},
+	'6': {'g'}, '9': {'g'},
+	'1': {'i', 'l'}, '!': {'i'}, '|': {'i', 'l'},
+	'0': {'o'},
+	'$': {'s'}, '5': {'s'},
+	'+': {'t'}, '7': {'t'},
+	'%': {'x'},
+	'2': {'z'},
+}
+
+// dictionaries are ranked word lists, by name - where rank 1 is the most commonly used word
+type dictionaries map[string]map[string]int
+
+func newDictionaries(userInputs []string) dictionaries {
+	loadRankedDictionaries.Do(func() {
+		rankedDictionaries = map[string]map[string]int{
+			dictionaryPasswords: rankLines(commonPasswords),
+			dictionaryEnglish:   {},
+		}
+		for _, word := range generate.EFFLargeWordlist() {
+			rankedDictionaries[dictionaryEnglish][word] = englishWordRank
+		}
+	})
+
+	dicts := dictionaries{}
+	for name, ranked := range rankedDictionaries {
+		dicts[name] = ranked
+	}
+	inputs := map[string]int{}
+	for _, input := range userInputs {
+		// inputs such as urls and email addresses are made up of words an attacker would also try
+		for _, word := range strings.FieldsFunc(strings.ToLower(input), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			if _, exists := inputs[word]; !exists {
+				inputs[word] = len(inputs) + 1
+			}
+		}
+		if lowered := strings.ToLower(input); lowered != "" {
+			if _, exists := inputs[lowered]; !exists {
+				inputs[lowered] = len(inputs) + 1
+			}
+		}
+	}
+	dicts[dictionaryUserInputs] = inputs
+	return dicts
+}
+
+func rankLines(lines string) map[string]int {
+	ranked := map[string]int{}
+	scanner := bufio.NewScanner(strings.NewReader(lines))
+	for scanner.Scan() {
+		word := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if _, exists := ranked[word]; word != "" && !exists {
+			ranked[word] = len(ranked) + 1
+		}
+	}
+	return ranked
+}
+
+// omnimatch returns every match of every pattern within the password
+func omnimatch(password []rune, dicts dictionaries) []Match {
+	matches := []Match{}
+	matches = append(matches, dictionaryMatches(password, dicts)...)
+	matches = append(matches, reversedDictionaryMatches(password, dicts)...)
+	matches = append(matches, l33tMatches(password, dicts)...)
+	matches = append(matches, spatialMatches(password)...)
+	matches = append(matches, repeatMatches(password, dicts)...)
+	matches = append(matches, sequenceMatches(password)...)
+	matches = append(matches, dateMatches(password)...)
+	sort.SliceStable(matches, func(a, b int) bool {
+		if matches[a].I != matches[b].I {
+			return matches[a].I < matches[b].I
+		}
+		return matches[a].J < matches[b].J
+	})
+	return matches
+}
+
+func dictionaryMatches(password []rune, dicts dictionaries) []Match {
+	lower := []rune(strings.ToLower(string(password)))
+	// lower casing can change the number of runes of some scripts, in which case tokens can't be mapped back
+	if len(lower) != len(password) {
+		return nil
+	}
+	matches := []Match{}
+	for name, ranked := range dicts {
+		for i := range lower {
+			for j := i; j < len(lower) && j-i < maxDictionaryTokenLength; j++ {
+				rank, found := ranked[string(lower[i:j+1])]
+				if !found {
+					continue
+				}
+				token := string(password[i : j+1])
+				matches = append(matches, Match{
+					Pattern:    PatternDictionary,
+					Token:      token,
+					I:          i,
+					J:          j,
+					Dictionary: name,
+					Rank:       rank,
+					Guesses:    float64(rank) * uppercaseVariations(token),
+				})
+			}
+		}
+	}
+	return matches
+}
+
+func reversedDictionaryMatches(password []rune, dicts dictionaries) []Match {
+	reversed := make([]rune, len(password))
+	for i, r := range password {
+		reversed[len(password)-1-i] = r
+	}
+	matches := []Match{}
+	for _, m := range dictionaryMatches(reversed, dicts) {
+		// single characters and palindromes are already found unreversed
+		if len([]rune(m.Token)) < 2 || isPalindrome(m.Token) {
+			continue
+		}
+		m.Token = string(password[len(password)-1-m.J : len(password)-m.I])
+		m.I, m.J = len(password)-1-m.J, len(password)-1-m.I
+		m.Reversed = true
+		m.Guesses *= 2
+		matches = append(matches, m)
+	}
+	return matches
+}
+
+func isPalindrome(token string) bool {
+	runes := []rune(strings.ToLower(token))
+	for i := 0; i < len(runes)/2; i++ {
+		if runes[i] != runes[len(runes)-1-i] {
+			return false
+		}
+	}
+	return true
+}
+
+// l33tMatches finds dictionary words which have had letters substituted, e.g. p@ssw0rd
+func l33tMatches(password []rune, dicts dictionaries) []Match {
+	hasSubstitution := false
+	for _, r := range password {
+		if _, found := l33tTable[r]; found {
+			hasSubstitution = true
+			break
+		}
+	}
+	if !hasSubstitution {
+		return nil
+	}
+
+	matches := []Match{}
+	seen := map[[2]int]map[string]bool{}
+	for _, substitutions := range l33tSubstitutions(password) {
+		unl33ted := make([]rune, len(password))
+		for idx, r := range password {
+			if sub, found := substitutions[r]; found {
+				unl33ted[idx] = sub
+			} else {
+				unl33ted[idx] = r
+			}
+		}
+		for _, m := range dictionaryMatches(unl33ted, dicts) {
+			token := string(password[m.I : m.J+1])
+			// tokens without any substitution are plain dictionary matches, and single characters are too short to
+			// be worth considering
+			if strings.EqualFold(token, m.Token) || m.J == m.I {
+				continue
+			}
+			key := [2]int{m.I, m.J}
+			if seen[key] == nil {
+				seen[key] = map[string]bool{}
+			}
+			if seen[key][m.Dictionary] {
+				continue
+			}
+			seen[key][m.Dictionary] = true
+			m.Guesses *= l33tVariations([]rune(token), substitutions)
+			m.Token = token
+			m.L33t = true
+			matches = append(matches, m)
+		}
+	}
+	return matches
+}
+
+// l33tSubstitutions returns each combination of substitutions which could have been applied to the password
+func l33tSubstitutions(password []rune) []map[rune]rune {
+	present := []rune{}
+	for r := range l33tTable {
+		if strings.ContainsRune(string(password), r) {
+			present = append(present, r)
+		}
+	}
+	sort.Slice(present, func(i, j int) bool { return present[i] < present[j] })
+
+	combinations := []map[rune]rune{{}}
+	for _, r := range present {
+		next := []map[rune]rune{}
+		for _, combination := range combinations {
+			for _, letter := range l33tTable[r] {
+				extended := map[rune]rune{r: letter}
+				for k, v := range combination {
+					extended[k] = v
+				}
+				next = append(next, extended)
+			}
+		}
+		combinations = next
+	}
+	return combinations
+}
+
+// l33tVariations is the number of ways the substitutions could have been made in the token, as an attacker would try
+// them
+func l33tVariations(token []rune, substitutions map[rune]rune) float64 {
+	variations := 1.0
+	for subbed, letter := range substitutions {
+		s, u := 0, 0
+		for _, r := range token {
+			switch unicode.ToLower(r) {
+			case subbed:
+				s++
+			case letter:
+				u++
+			}
+		}
+		if s == 0 {
+			continue
+		}
+		if u == 0 {
+			variations *= 2
+			continue
+		}
+		possibilities := 0.0
+		for i := 1; i <= s && i <= u; i++ {
+			possibilities += binomial(s+u, i)
+		}
+		variations *= possibilities
+	}
+	return variations
+}
+
+// repeatMatches finds tokens repeated back to back, e.g. aaa or abcabc
+func repeatMatches(password []rune, dicts dictionaries) []Match {
+	matches := []Match{}
+	for i := 0; i < len(password); {
+		bestLength, bestBase, bestRepeats := 0, 0, 0
+		for base := 1; i+base*2 <= len(password); base++ {
+			repeats := 1
+			for i+(repeats+1)*base <= len(password) &&
+				string(password[i+repeats*base:i+(repeats+1)*base]) == string(password[i:i+base]) {
+				repeats++
+			}
+			if repeats >= 2 && repeats*base > bestLength {
+				bestLength, bestBase, bestRepeats = repeats*base, base, repeats
+			}
+		}
+		if bestLength == 0 {
+			i++
+			continue
+		}
+
+		base := password[i : i+bestBase]
+		baseGuesses, _ := mostGuessableSequence(base, omnimatch(base, dicts), true)
+		matches = append(matches, Match{
+			Pattern:   PatternRepeat,
+			Token:     string(password[i : i+bestLength]),
+			I:         i,
+			J:         i + bestLength - 1,
+			BaseToken: string(base),
+			Repeats:   bestRepeats,
+			Guesses:   baseGuesses * float64(bestRepeats),
+		})
+		i += bestLength
+	}
+	return matches
+}
+
+// sequenceMatches finds runs of characters with a constant step, e.g. abc, 6543 or aceg
+func sequenceMatches(password []rune) []Match {
+	const maxDelta = 5
+	matches := []Match{}
+	emit := func(i, j, delta int) {
+		if j-i < 2 || delta == 0 || delta > maxDelta || delta < -maxDelta {
+			return
+		}
+		token := password[i : j+1]
+		var base float64
+		switch {
+		case strings.ContainsRune("aAzZ019", token[0]):
+			// obvious starting points are tried first
+			base = 4
+		case unicode.IsDigit(token[0]):
+			base = 10
+		default:
+			base = 26
+		}
+		if delta < 0 {
+			base *= 2
+		}
+		matches = append(matches, Match{Pattern: PatternSequence, Token: string(token), I: i, J: j, Guesses: base * float64(len(token))})
+	}
+
+	start, lastDelta := 0, 0
+	for k := 1; k < len(password); k++ {
+		delta := int(password[k]) - int(password[k-1])
+		if !sameClass(password[k], password[k-1]) {
+			delta = 0
+		}
+		if k == 1 {
+			lastDelta = delta
+			continue
+		}
+		if delta == lastDelta {
+			continue
+		}
+		emit(start, k-1, lastDelta)
+		start, lastDelta = k-1, delta
+	}
+	if len(password) > 1 {
+		emit(start, len(password)-1, lastDelta)
+	}
+	return matches
+}
+
+func sameClass(a, b rune) bool {
+	switch {
+	case unicode.IsLower(a):
+		return unicode.IsLower(b)
+	case unicode.IsUpper(a):
+		return unicode.IsUpper(b)
+	case unicode.IsDigit(a):
+		return unicode.IsDigit(b)
+	}
+	return false
+}
+
+// dateMatches finds dates (with or without separators between their parts, and with 2 or 4 digit years) and
+// recent years
+func dateMatches(password []rune) []Match {
+	matches := []Match{}
+	currentYear := time.Now().Year()
+	yearGuesses := func(year int) float64 {
+		return math.Max(math.Abs(float64(year-currentYear)), minYearSpace)
+	}
+
+	for i := range password {
+		for j := i + 3; j < len(password) && j-i < 10; j++ {
+			token := string(password[i : j+1])
+			if j-i == 3 && isDigits(token) {
+				if year, _ := strconv.Atoi(token); year >= 1900 && year <= maxYear {
+					matches = append(matches, Match{Pattern: PatternDate, Token: token, I: i, J: j, Guesses: yearGuesses(year)})
+				}
+			}
+			year, separated, found := parseDate(token)
+			if !found {
+				continue
+			}
+			guesses := yearGuesses(year) * 365
+			if separated {
+				guesses *= 4
+			}
+			matches = append(matches, Match{Pattern: PatternDate, Token: token, I: i, J: j, Guesses: guesses})
+		}
+	}
+	return matches
+}
+
+// parseDate reports whether the token is a date, and if so its year and whether its parts were separated
+func parseDate(token string) (int, bool, bool) {
+	var splits [][]string
+	separated := false
+	if isDigits(token) {
+		if len(token) < 4 || len(token) > 8 {
+			return 0, false, false
+		}
+		// try every way of splitting the digits into three parts
+		for a := 1; a < len(token)-1 && a <= 4; a++ {
+			for b := a + 1; b < len(token) && b-a <= 2; b++ {
+				if len(token)-b <= 4 {
+					splits = append(splits, []string{token[:a], token[a:b], token[b:]})
+				}
+			}
+		}
+	} else {
+		for _, sep := range []string{"/", "-", ".", "_", " ", "\\"} {
+			parts := strings.Split(token, sep)
+			if len(parts) == 3 && isDigits(parts[0]) && isDigits(parts[1]) && isDigits(parts[2]) {
+				splits = append(splits, parts)
+				separated = true
+			}
+		}
+	}
+
+	for _, parts := range splits {
+		if len(parts[1]) > 2 {
+			continue
+		}
+		first, _ := strconv.Atoi(parts[0])
+		middle, _ := strconv.Atoi(parts[1])
+		last, _ := strconv.Atoi(parts[2])
+		// year first (y-m-d) or last (d-m-y or m-d-y)
+		if len(parts[0]) == 4 || len(parts[0]) == 2 && len(parts[2]) <= 2 {
+			if year, ok := toYear(parts[0], first); ok && isDayMonth(last, middle) {
+				return year, separated, true
+			}
+		}
+		if len(parts[0]) <= 2 && (len(parts[2]) == 4 || len(parts[2]) == 2) {
+			if year, ok := toYear(parts[2], last); ok && (isDayMonth(first, middle) || isDayMonth(middle, first)) {
+				return year, separated, true
+			}
+		}
+	}
+	return 0, false, false
+}
+
+func toYear(digits string, value int) (int, bool) {
+	switch len(digits) {
+	case 2:
+		if value > 50 {
+			return 1900 + value, true
+		}
+		return 2000 + value, true
+	case 4:
+		return value, value >= minYear && value <= maxYear
+	}
+	return 0, false
+}
+
+func isDayMonth(day, month int) bool {
+	return day >= 1 && day <= 31 && month >= 1 && month <= 12
+}
+
+func isDigits(token string) bool {
+	if token == "" {
+		return false
+	}
+	for _, r := range token {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
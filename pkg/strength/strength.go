@@ -0,0 +1,388 @@
+package strength
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+/*
+	Estimates how many guesses an attacker would need to find a password, in the style of Dropbox's zxcvbn - see
+	https://www.usenix.org/conference/usenixsecurity16/technical-sessions/presentation/wheeler
+
+	The password is matched against patterns people commonly use (dictionary words, keyboard patterns, sequences,
+	repeats and dates), each of which takes some number of guesses to find. The estimate is that of the sequence of
+	non overlapping matches (with any characters not matched being brute forced) which needs the fewest guesses - on
+	the basis that an attacker knows to try these patterns, and will find the weakest way to build the password.
+*/
+
+const (
+	// MaxScore is the score of the strongest passwords
+	MaxScore = 4
+	// GuessesPerSecond is the rate an attacker with the password hash could make guesses, assuming a slow hash
+	GuessesPerSecond = 1e4
+
+	// maxAnalysedLength bounds the cost of estimating very long passwords - any characters beyond it are treated as
+	// brute forced, which only makes an already strong estimate stronger
+	maxAnalysedLength = 64
+	// bruteforceCardinality is the guesses per character of characters which do not match any pattern
+	bruteforceCardinality = 10
+	// minSubmatchGuesses are the fewest guesses a match of one, or more, characters within a longer password can take
+	minSubmatchGuessesSingleChar = 10
+	minSubmatchGuessesMultiChar  = 50
+	// minGuessesBeforeGrowingSequence penalises splitting a password into more matches
+	minGuessesBeforeGrowingSequence = 10000
+	maxGuesses                      = 1e300
+)
+
+// Pattern is the kind of pattern a part of a password was matched as
+type Pattern string
+
+const (
+	PatternDictionary Pattern = "dictionary"
+	PatternSpatial    Pattern = "spatial"
+	PatternRepeat     Pattern = "repeat"
+	PatternSequence   Pattern = "sequence"
+	PatternDate       Pattern = "date"
+	PatternBruteforce Pattern = "bruteforce"
+)
+
+// Match is a part of a password which was matched as a pattern
+type Match struct {
+	Pattern Pattern
+	Token   string
+	// I and J are the (inclusive) indexes of the first and last characters of the token within the password
+	I, J    int
+	Guesses float64
+
+	// Dictionary, Rank, Reversed and L33t describe dictionary matches
+	Dictionary string `json:",omitempty"`
+	Rank       int    `json:",omitempty"`
+	Reversed   bool   `json:",omitempty"`
+	L33t       bool   `json:",omitempty"`
+	// Turns and Shifted describe spatial matches
+	Turns   int `json:",omitempty"`
+	Shifted int `json:",omitempty"`
+	// BaseToken and Repeats describe repeat matches
+	BaseToken string `json:",omitempty"`
+	Repeats   int    `json:",omitempty"`
+}
+
+// Result is the estimated strength of a password
+type Result struct {
+	// Score is from 0 (too guessable) to MaxScore (very unguessable)
+	Score   int
+	Guesses float64
+	// CrackTimeSeconds is how long an attacker with the password hash would take to guess it
+	CrackTimeSeconds float64
+	// Warning explains what makes a weak password weak, if anything does
+	Warning  string `json:",omitempty"`
+	Sequence []Match
+}
+
+// Estimate returns the estimated strength of a password. userInputs are words an attacker is likely to try for this
+// password in particular, e.g. the account's username
+func Estimate(password string, userInputs ...string) Result {
+	runes := []rune(password)
+	analysed := runes
+	if len(analysed) > maxAnalysedLength {
+		analysed = analysed[:maxAnalysedLength]
+	}
+
+	matches := omnimatch(analysed, newDictionaries(userInputs))
+	guesses, sequence := mostGuessableSequence(analysed, matches, false)
+	for i := len(analysed); i < len(runes); i++ {
+		guesses = math.Min(guesses*bruteforceCardinality, maxGuesses)
+	}
+
+	result := Result{
+		Score:            score(guesses),
+		Guesses:          guesses,
+		CrackTimeSeconds: guesses / GuessesPerSecond,
+		Sequence:         sequence,
+	}
+	if result.Score <= 2 {
+		result.Warning = warning(sequence, len(runes))
+	}
+	return result
+}
+
+// score buckets guesses into scores, at the thresholds used by zxcvbn
+func score(guesses float64) int {
+	const delta = 5
+	switch {
+	case guesses < 1e3+delta:
+		return 0
+	case guesses < 1e6+delta:
+		return 1
+	case guesses < 1e8+delta:
+		return 2
+	case guesses < 1e10+delta:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// CrackTimeDisplay describes how long the password would take to crack, e.g. "3 hours" or "centuries"
+func (r Result) CrackTimeDisplay() string {
+	const (
+		minute  = 60
+		hour    = minute * 60
+		day     = hour * 24
+		month   = day * 31
+		year    = month * 12
+		century = year * 100
+	)
+	seconds := r.CrackTimeSeconds
+	plural := func(n float64, unit string) string {
+		rounded := math.Round(n)
+		if rounded == 1 {
+			return "1 " + unit
+		}
+		return fmt.Sprintf("%.0f %ss", rounded, unit)
+	}
+	switch {
+	case seconds < 1:
+		return "less than a second"
+	case seconds < minute:
+		return plural(seconds, "second")
+	case seconds < hour:
+		return plural(seconds/minute, "minute")
+	case seconds < day:
+		return plural(seconds/hour, "hour")
+	case seconds < month:
+		return plural(seconds/day, "day")
+	case seconds < year:
+		return plural(seconds/month, "month")
+	case seconds < century:
+		return plural(seconds/year, "year")
+	default:
+		return "centuries"
+	}
+}
+
+// mostGuessableSequence finds the sequence of non overlapping matches covering the password which needs the fewest
+// guesses - see section 5 of the zxcvbn paper. Characters covered by no match are brute forced. If excludeAdditive
+// is set, the penalty for using more matches is left out, as when estimating the base of a repeat
+func mostGuessableSequence(password []rune, matches []Match, excludeAdditive bool) (float64, []Match) {
+	n := len(password)
+	if n == 0 {
+		return 1, []Match{}
+	}
+
+	type step struct {
+		guesses float64 // total guesses of the sequence of matches ending here
+		product float64 // product of the guesses of each of the matches in it
+		match   Match
+		prev    int // length of the sequence before its last match
+	}
+	// optimal[k][l] is the best sequence of l matches covering password[0:k+1]
+	optimal := make([]map[int]step, n)
+	for k := range optimal {
+		optimal[k] = map[int]step{}
+	}
+
+	matchesByEnd := make([][]Match, n)
+	for _, m := range matches {
+		matchesByEnd[m.J] = append(matchesByEnd[m.J], m)
+	}
+	for k := 0; k < n; k++ {
+		for i := 0; i <= k; i++ {
+			matchesByEnd[k] = append(matchesByEnd[k], bruteforceMatch(password, i, k))
+		}
+	}
+
+	consider := func(m Match, l int, prevProduct float64) {
+		guesses := matchGuesses(m, n)
+		product := math.Min(prevProduct*guesses, maxGuesses)
+		total := product * factorial(l)
+		if !excludeAdditive {
+			total += math.Pow(minGuessesBeforeGrowingSequence, float64(l-1))
+		}
+		total = math.Min(total, maxGuesses)
+		if existing, found := optimal[m.J][l]; found && existing.guesses <= total {
+			return
+		}
+		// a shorter sequence needing no more guesses is always preferable
+		for otherL, other := range optimal[m.J] {
+			if otherL < l && other.guesses <= total {
+				return
+			}
+		}
+		optimal[m.J][l] = step{guesses: total, product: product, match: m, prev: l - 1}
+	}
+
+	for k := 0; k < n; k++ {
+		for _, m := range matchesByEnd[k] {
+			if m.I == 0 {
+				consider(m, 1, 1)
+				continue
+			}
+			for l, prev := range optimal[m.I-1] {
+				// consecutive brute force matches are only ever worse than one covering both
+				if m.Pattern == PatternBruteforce && prev.match.Pattern == PatternBruteforce {
+					continue
+				}
+				consider(m, l+1, prev.product)
+			}
+		}
+	}
+
+	bestL, best := 0, step{guesses: math.Inf(1)}
+	for l, candidate := range optimal[n-1] {
+		if candidate.guesses < best.guesses || (candidate.guesses == best.guesses && l < bestL) {
+			bestL, best = l, candidate
+		}
+	}
+
+	sequence := make([]Match, bestL)
+	k, l := n-1, bestL
+	for l > 0 {
+		current := optimal[k][l]
+		current.match.Guesses = matchGuesses(current.match, n)
+		sequence[l-1] = current.match
+		k, l = current.match.I-1, current.prev
+	}
+	return best.guesses, sequence
+}
+
+func bruteforceMatch(password []rune, i, j int) Match {
+	return Match{Pattern: PatternBruteforce, Token: string(password[i : j+1]), I: i, J: j}
+}
+
+// matchGuesses returns the guesses needed to find a match, with a floor on those needed for a match which is only
+// part of the password - an attacker is unlikely to try every short token in every position
+func matchGuesses(m Match, passwordLength int) float64 {
+	guesses := m.Guesses
+	if m.Pattern == PatternBruteforce {
+		guesses = math.Min(math.Pow(bruteforceCardinality, float64(len([]rune(m.Token)))), maxGuesses)
+		// a brute forced token of over one character cannot have been found in fewer guesses than another match
+		minGuesses := float64(minSubmatchGuessesSingleChar + 1)
+		if len([]rune(m.Token)) > 1 {
+			minGuesses = minSubmatchGuessesMultiChar + 1
+		}
+		guesses = math.Max(guesses, minGuesses)
+	}
+	if len([]rune(m.Token)) < passwordLength {
+		minGuesses := float64(minSubmatchGuessesSingleChar)
+		if len([]rune(m.Token)) > 1 {
+			minGuesses = minSubmatchGuessesMultiChar
+		}
+		guesses = math.Max(guesses, minGuesses)
+	}
+	return guesses
+}
+
+func factorial(n int) float64 {
+	f := 1.0
+	for i := 2; i <= n; i++ {
+		f *= float64(i)
+	}
+	return f
+}
+
+// binomial returns n choose k
+func binomial(n, k int) float64 {
+	if k > n {
+		return 0
+	}
+	if k == 0 {
+		return 1
+	}
+	r := 1.0
+	for d := 1; d <= k; d++ {
+		r *= float64(n)
+		r /= float64(d)
+		n--
+	}
+	return r
+}
+
+// warning explains the weakness of the most significant match of a weak password
+func warning(sequence []Match, passwordLength int) string {
+	if len(sequence) == 0 {
+		return "use a few words, avoiding common phrases"
+	}
+	longest := sequence[0]
+	for _, m := range sequence[1:] {
+		if len(m.Token) > len(longest.Token) {
+			longest = m
+		}
+	}
+	isSoleMatch := len(sequence) == 1
+
+	switch longest.Pattern {
+	case PatternDictionary:
+		return dictionaryWarning(longest, isSoleMatch)
+	case PatternSpatial:
+		if longest.Turns == 1 {
+			return "straight rows of keys are easy to guess"
+		}
+		return "short keyboard patterns are easy to guess"
+	case PatternRepeat:
+		if len([]rune(longest.BaseToken)) == 1 {
+			return `repeats like "aaa" are easy to guess`
+		}
+		return `repeats like "abcabc" are only slightly harder to guess than "abc"`
+	case PatternSequence:
+		return "sequences like abc or 6543 are easy to guess"
+	case PatternDate:
+		return "dates are often easy to guess"
+	}
+	if passwordLength < 8 {
+		return "short passwords are easy to guess - use a longer one"
+	}
+	return ""
+}
+
+func dictionaryWarning(m Match, isSoleMatch bool) string {
+	switch {
+	case m.Dictionary == dictionaryPasswords && isSoleMatch && !m.L33t && !m.Reversed && m.Rank <= 10:
+		return "this is a top-10 common password"
+	case m.Dictionary == dictionaryPasswords && isSoleMatch && !m.L33t && !m.Reversed && m.Rank <= 100:
+		return "this is a top-100 common password"
+	case m.Dictionary == dictionaryPasswords:
+		return "this is similar to a commonly used password"
+	case m.Dictionary == dictionaryUserInputs:
+		return "this contains details of the account it is for, such as its name or username"
+	case m.L33t:
+		return "predictable substitutions like '@' instead of 'a' don't help very much"
+	case m.Reversed:
+		return "reversed words aren't much harder to guess"
+	case isSoleMatch:
+		return "a word by itself is easy to guess"
+	}
+	return "common words are easy to guess"
+}
+
+// uppercaseVariations is the number of ways the letters of a word could have been capitalised, as an attacker would
+// try them - the common forms of capitalisation first
+func uppercaseVariations(word string) float64 {
+	if word == strings.ToLower(word) {
+		return 1
+	}
+	runes := []rune(word)
+	first, rest := string(runes[0]), string(runes[1:])
+	last, init := string(runes[len(runes)-1]), string(runes[:len(runes)-1])
+	if word == strings.ToUpper(word) ||
+		(first == strings.ToUpper(first) && rest == strings.ToLower(rest)) ||
+		(last == strings.ToUpper(last) && init == strings.ToLower(init)) {
+		return 2
+	}
+
+	upper, lower := 0, 0
+	for _, r := range runes {
+		switch {
+		case strings.ToUpper(string(r)) == string(r) && strings.ToLower(string(r)) != string(r):
+			upper++
+		case strings.ToLower(string(r)) == string(r) && strings.ToUpper(string(r)) != string(r):
+			lower++
+		}
+	}
+	variations := 0.0
+	for i := 1; i <= upper && i <= lower; i++ {
+		variations += binomial(upper+lower, i)
+	}
+	return math.Max(variations, 1)
+}
@@ -0,0 +1,85 @@
+package strength_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/georgewheatcroft/simple-pass/internal/common/constants"
+	"github.com/georgewheatcroft/simple-pass/pkg/strength"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	log.SetLevel(log.DebugLevel)
+	// avoid overwritting local dev .passdb TODO better way
+	os.Setenv(constants.PassDBLocalDevEnvVar, "True")
+}
+
+func TestShouldRecognisePatterns(t *testing.T) {
+	for _, tc := range []struct {
+		password string
+		pattern  strength.Pattern
+		token    string
+	}{
+		{"password", strength.PatternDictionary, "password"},
+		{"Monkey", strength.PatternDictionary, "Monkey"},
+		{"p@ssw0rd", strength.PatternDictionary, "p@ssw0rd"},
+		{"drowssap", strength.PatternDictionary, "drowssap"},
+		{"mju7nhy6", strength.PatternSpatial, "mju7"},
+		{"zzzzzzzz", strength.PatternRepeat, "zzzzzzzz"},
+		{"lmnopqrs", strength.PatternSequence, "lmnopqrs"},
+		{"98765", strength.PatternSequence, "98765"},
+		{"13/04/1987", strength.PatternDate, "13/04/1987"},
+		{"19870413", strength.PatternDate, "19870413"},
+	} {
+		result := strength.Estimate(tc.password)
+		require.NotEmpty(t, result.Sequence, tc.password)
+		require.Equal(t, tc.pattern, result.Sequence[0].Pattern, tc.password)
+		require.Equal(t, tc.token, result.Sequence[0].Token, tc.password)
+		require.LessOrEqual(t, result.Score, 2, tc.password)
+		require.NotEmpty(t, result.Warning, tc.password)
+	}
+}
+
+func TestShouldScorePasswords(t *testing.T) {
+	for _, tc := range []struct {
+		password   string
+		userInputs []string
+		maxScore   int
+		minScore   int
+	}{
+		{"", nil, 0, 0},
+		{"password", nil, 0, 0},
+		{"qwerty123", nil, 0, 0},
+		{"Password1", nil, 0, 0},
+		{"jsmith1987", []string{"jsmith@example.com"}, 1, 0},
+		{"correct-horse-battery-staple", nil, 4, 4},
+		{"kX9#mQ2$vL7!pR4@", nil, 4, 4},
+	} {
+		result := strength.Estimate(tc.password, tc.userInputs...)
+		require.GreaterOrEqual(t, result.Score, tc.minScore, tc.password)
+		require.LessOrEqual(t, result.Score, tc.maxScore, tc.password)
+	}
+
+	// details of the account make a password weaker
+	require.Less(t, strength.Estimate("jsmith1987", "jsmith").Guesses, strength.Estimate("jsmith1987").Guesses)
+	// very long passwords are estimated without error
+	long := strength.Estimate("kX9#mQ2$vL7!pR4@kX9#mQ2$vL7!pR4@kX9#mQ2$vL7!pR4@kX9#mQ2$vL7!pR4@kX9#mQ2$vL7!pR4@")
+	require.Equal(t, strength.MaxScore, long.Score)
+}
+
+func TestShouldDisplayCrackTimes(t *testing.T) {
+	for seconds, display := range map[float64]string{
+		0.5:        "less than a second",
+		1:          "1 second",
+		90:         "2 minutes",
+		7200:       "2 hours",
+		86400:      "1 day",
+		4e7:        "1 year",
+		1e12:       "centuries",
+		86400 * 60: "2 months",
+	} {
+		require.Equal(t, display, strength.Result{CrackTimeSeconds: seconds}.CrackTimeDisplay(), seconds)
+	}
+}